@@ -0,0 +1,465 @@
+// Package cms implements the subset of CMS (RFC 5652) SignedData needed for timestamp tokens
+// and document signatures: one signer identified by issuer and serial number, signed attributes,
+// and encapsulated or detached content.
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Object identifiers
+var (
+	OIDData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	OIDAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	OIDAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	OIDAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	// OIDAttributeSigningCertificateV2 is the ESS signing-certificate-v2 attribute (RFC 5035)
+	OIDAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	// OIDAttributeTimeStampToken is the signature timestamp unsigned attribute (RFC 3161 appendix A)
+	OIDAttributeTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+	OIDDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	OIDDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	OIDDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECPublicKey     = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// ErrVerification is returned when a signature does not verify
+var ErrVerification = errors.New("cms: signature verification failed")
+
+// Attribute is a signed or unsigned attribute. Value holds the DER encoded SET of values.
+type Attribute struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+// NewAttribute creates an attribute with a single value
+func NewAttribute(attributeType asn1.ObjectIdentifier, value any) (Attribute, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return Attribute{}, fmt.Errorf("cms: error encoding attribute %v: %v", attributeType, err)
+	}
+	return Attribute{
+		Type:  attributeType,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der},
+	}, nil
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type rawCertificates struct {
+	Raw asn1.RawContent
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     rawCertificates `asn1:"optional,tag:0"`
+	CRLs             []asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo    `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        []Attribute `asn1:"optional,omitempty,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      []Attribute `asn1:"optional,omitempty,tag:1"`
+}
+
+// SignOptions configures Sign
+type SignOptions struct {
+	// ContentType is the type of the signed content, defaults to id-data
+	ContentType asn1.ObjectIdentifier
+	// Detached leaves the content out of the SignedData, as for PDF signatures
+	Detached bool
+	// Hash is the digest algorithm, defaults to SHA-256
+	Hash crypto.Hash
+	// Chain holds intermediate certificates included after the signer's certificate
+	Chain []*x509.Certificate
+	// SignedAttributes are added to the content type and message digest attributes
+	SignedAttributes []Attribute
+}
+
+// Sign creates a DER encoded ContentInfo holding a SignedData over content, signed by key with
+// certificate
+func Sign(content []byte, certificate *x509.Certificate, key crypto.Signer, opts SignOptions) ([]byte, error) {
+	if opts.ContentType == nil {
+		opts.ContentType = OIDData
+	}
+	if opts.Hash == 0 {
+		opts.Hash = crypto.SHA256
+	}
+	digestAlgorithm, err := DigestAlgorithm(opts.Hash)
+	if err != nil {
+		return nil, err
+	}
+	signatureAlgorithm, err := signatureAlgorithmFor(key.Public(), opts.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	h := opts.Hash.New()
+	h.Write(content)
+	contentTypeAttribute, err := NewAttribute(OIDAttributeContentType, opts.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	digestAttribute, err := NewAttribute(OIDAttributeMessageDigest, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	signed := append([]Attribute{contentTypeAttribute, digestAttribute}, opts.SignedAttributes...)
+	signedAttrs, err := marshalAttributes(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := signMessage(key, opts.Hash, signedAttrs)
+	if err != nil {
+		return nil, fmt.Errorf("cms: error signing: %v", err)
+	}
+
+	info := signerInfo{
+		Version:            1,
+		SID:                issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: certificate.RawIssuer}, SerialNumber: certificate.SerialNumber},
+		DigestAlgorithm:    digestAlgorithm,
+		SignedAttrs:        signed,
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          signature,
+	}
+
+	var certificates []byte
+	for _, c := range append([]*x509.Certificate{certificate}, opts.Chain...) {
+		certificates = append(certificates, c.Raw...)
+	}
+	rawCerts, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certificates})
+	if err != nil {
+		return nil, err
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgorithm},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: opts.ContentType},
+		Certificates:     rawCertificates{Raw: rawCerts},
+		SignerInfos:      []signerInfo{info},
+	}
+	if !opts.ContentType.Equal(OIDData) {
+		sd.Version = 3
+	}
+	if !opts.Detached {
+		eContent, err := asn1.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		sd.EncapContentInfo.EContent = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: eContent}
+	}
+	return marshalContentInfo(sd)
+}
+
+// SignedData is a parsed CMS SignedData with a single signer
+type SignedData struct {
+	// ContentType is the type of the encapsulated content
+	ContentType asn1.ObjectIdentifier
+	// Content is the encapsulated content, nil when the signature is detached
+	Content []byte
+	// Certificates are the certificates included in the SignedData
+	Certificates []*x509.Certificate
+	// SigningTime is the signing-time signed attribute, if present
+	SigningTime *time.Time
+
+	raw    signedData
+	signer signerInfo
+}
+
+// Parse parses a DER encoded ContentInfo holding a SignedData
+func Parse(der []byte) (*SignedData, error) {
+	var ci contentInfo
+	rest, err := asn1.Unmarshal(der, &ci)
+	if err != nil {
+		return nil, fmt.Errorf("cms: error parsing content info: %v", err)
+	}
+	// Signatures embedded in PDFs are zero padded to the size of their placeholder
+	if len(bytes.TrimRight(rest, "\x00")) > 0 {
+		return nil, fmt.Errorf("cms: trailing data after content info")
+	}
+	if !ci.ContentType.Equal(OIDSignedData) {
+		return nil, fmt.Errorf("cms: content type %v is not signed data", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("cms: error parsing signed data: %v", err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("cms: expected one signer, found %d", len(sd.SignerInfos))
+	}
+
+	result := &SignedData{ContentType: sd.EncapContentInfo.EContentType, raw: sd, signer: sd.SignerInfos[0]}
+	if len(sd.EncapContentInfo.EContent.Bytes) > 0 {
+		if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &result.Content); err != nil {
+			return nil, fmt.Errorf("cms: error parsing content: %v", err)
+		}
+	}
+	if len(sd.Certificates.Raw) > 0 {
+		var certificates asn1.RawValue
+		if _, err := asn1.Unmarshal(sd.Certificates.Raw, &certificates); err != nil {
+			return nil, fmt.Errorf("cms: error parsing certificates: %v", err)
+		}
+		if result.Certificates, err = x509.ParseCertificates(certificates.Bytes); err != nil {
+			return nil, fmt.Errorf("cms: error parsing certificates: %v", err)
+		}
+	}
+	var signingTime time.Time
+	if ok, err := result.SignedAttribute(OIDAttributeSigningTime, &signingTime); err != nil {
+		return nil, err
+	} else if ok {
+		result.SigningTime = &signingTime
+	}
+	return result, nil
+}
+
+// SignerCertificate returns the included certificate of the signer
+func (sd *SignedData) SignerCertificate() (*x509.Certificate, error) {
+	for _, c := range sd.Certificates {
+		if bytes.Equal(c.RawIssuer, sd.signer.SID.Issuer.FullBytes) && c.SerialNumber.Cmp(sd.signer.SID.SerialNumber) == 0 {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("cms: signer certificate is not included")
+}
+
+// Signature returns the signer's signature value
+func (sd *SignedData) Signature() []byte {
+	return sd.signer.Signature
+}
+
+// SignedAttribute decodes the first value of a signed attribute into out. It reports whether the
+// attribute is present.
+func (sd *SignedData) SignedAttribute(attributeType asn1.ObjectIdentifier, out any) (bool, error) {
+	return findAttribute(sd.signer.SignedAttrs, attributeType, out)
+}
+
+// UnsignedAttribute decodes the first value of an unsigned attribute into out. It reports
+// whether the attribute is present.
+func (sd *SignedData) UnsignedAttribute(attributeType asn1.ObjectIdentifier, out any) (bool, error) {
+	return findAttribute(sd.signer.UnsignedAttrs, attributeType, out)
+}
+
+// AddUnsignedAttribute adds an unsigned attribute to the signer and returns the re-encoded
+// ContentInfo. Unsigned attributes are not covered by the signature.
+func (sd *SignedData) AddUnsignedAttribute(attribute Attribute) ([]byte, error) {
+	sd.signer.UnsignedAttrs = append(sd.signer.UnsignedAttrs, attribute)
+	sd.raw.SignerInfos = []signerInfo{sd.signer}
+	return marshalContentInfo(sd.raw)
+}
+
+// Verify checks the signer's signature over the content, or over detached content when the
+// SignedData has none. It does not check the certificate chain.
+func (sd *SignedData) Verify(detached []byte) (*x509.Certificate, error) {
+	content := sd.Content
+	if content == nil {
+		content = detached
+	}
+	certificate, err := sd.SignerCertificate()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := HashFunc(sd.signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sd.signer.SignedAttrs) == 0 {
+		return nil, fmt.Errorf("cms: signer has no signed attributes")
+	}
+	var contentType asn1.ObjectIdentifier
+	if ok, err := sd.SignedAttribute(OIDAttributeContentType, &contentType); err != nil || !ok {
+		return nil, fmt.Errorf("%w: missing content type attribute", ErrVerification)
+	}
+	if !contentType.Equal(sd.ContentType) {
+		return nil, fmt.Errorf("%w: content type attribute does not match the content", ErrVerification)
+	}
+	var digest []byte
+	if ok, err := sd.SignedAttribute(OIDAttributeMessageDigest, &digest); err != nil || !ok {
+		return nil, fmt.Errorf("%w: missing message digest attribute", ErrVerification)
+	}
+	h := hash.New()
+	h.Write(content)
+	if !bytes.Equal(h.Sum(nil), digest) {
+		return nil, fmt.Errorf("%w: message digest does not match the content", ErrVerification)
+	}
+
+	signedAttrs, err := marshalAttributes(sd.signer.SignedAttrs)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, err := x509SignatureAlgorithm(sd.signer.SignatureAlgorithm.Algorithm, hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := certificate.CheckSignature(algorithm, signedAttrs, sd.signer.Signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	return certificate, nil
+}
+
+func marshalContentInfo(sd signedData) ([]byte, error) {
+	content, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, fmt.Errorf("cms: error encoding signed data: %v", err)
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: OIDSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+}
+
+// marshalAttributes returns the DER SET OF attributes the signature is computed over
+func marshalAttributes(attributes []Attribute) ([]byte, error) {
+	encoded := make([][]byte, len(attributes))
+	for i, attribute := range attributes {
+		der, err := asn1.Marshal(attribute)
+		if err != nil {
+			return nil, fmt.Errorf("cms: error encoding attribute %v: %v", attribute.Type, err)
+		}
+		encoded[i] = der
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(encoded, nil)})
+}
+
+func findAttribute(attributes []Attribute, attributeType asn1.ObjectIdentifier, out any) (bool, error) {
+	for _, attribute := range attributes {
+		if !attribute.Type.Equal(attributeType) {
+			continue
+		}
+		if _, err := asn1.Unmarshal(attribute.Value.Bytes, out); err != nil {
+			return true, fmt.Errorf("cms: error parsing attribute %v: %v", attributeType, err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func signMessage(key crypto.Signer, hash crypto.Hash, message []byte) ([]byte, error) {
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		return key.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+	h := hash.New()
+	h.Write(message)
+	return key.Sign(rand.Reader, h.Sum(nil), hash)
+}
+
+// DigestAlgorithm returns the digest algorithm identifier of a hash function
+func DigestAlgorithm(hash crypto.Hash) (pkix.AlgorithmIdentifier, error) {
+	switch hash {
+	case crypto.SHA256:
+		return pkix.AlgorithmIdentifier{Algorithm: OIDDigestSHA256}, nil
+	case crypto.SHA384:
+		return pkix.AlgorithmIdentifier{Algorithm: OIDDigestSHA384}, nil
+	case crypto.SHA512:
+		return pkix.AlgorithmIdentifier{Algorithm: OIDDigestSHA512}, nil
+	}
+	return pkix.AlgorithmIdentifier{}, fmt.Errorf("cms: unsupported hash %v", hash)
+}
+
+// HashFunc returns the hash function of a digest algorithm identifier
+func HashFunc(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(OIDDigestSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(OIDDigestSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(OIDDigestSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("cms: unsupported digest algorithm %v", oid)
+}
+
+func signatureAlgorithmFor(publicKey crypto.PublicKey, hash crypto.Hash) (pkix.AlgorithmIdentifier, error) {
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}, nil
+	case *ecdsa.PublicKey:
+		switch hash {
+		case crypto.SHA256:
+			return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}, nil
+		case crypto.SHA384:
+			return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA384}, nil
+		case crypto.SHA512:
+			return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA512}, nil
+		}
+	case ed25519.PublicKey:
+		return pkix.AlgorithmIdentifier{Algorithm: oidEd25519}, nil
+	}
+	return pkix.AlgorithmIdentifier{}, fmt.Errorf("cms: unsupported key type %T", publicKey)
+}
+
+// x509SignatureAlgorithm maps a SignerInfo signature algorithm and digest to the x509 algorithm
+// the signature is checked with
+func x509SignatureAlgorithm(oid asn1.ObjectIdentifier, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch {
+	case oid.Equal(oidRSAEncryption), oid.Equal(oidSHA256WithRSA), oid.Equal(oidSHA384WithRSA), oid.Equal(oidSHA512WithRSA):
+		switch hash {
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case oid.Equal(oidECPublicKey), oid.Equal(oidECDSAWithSHA256), oid.Equal(oidECDSAWithSHA384), oid.Equal(oidECDSAWithSHA512):
+		switch hash {
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	case oid.Equal(oidEd25519):
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("cms: unsupported signature algorithm %v", oid)
+}
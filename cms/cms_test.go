@@ -0,0 +1,108 @@
+package cms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCertificate(t *testing.T, key crypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "Test signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return certificate
+}
+
+func TestSign(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	content := []byte("signed content")
+
+	tests := []struct {
+		name     string
+		key      crypto.Signer
+		detached bool
+	}{
+		{name: "ECDSA", key: ecdsaKey},
+		{name: "ECDSA detached", key: ecdsaKey, detached: true},
+		{name: "RSA", key: rsaKey},
+		{name: "Ed25519 detached", key: ed25519Key, detached: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certificate := testCertificate(t, tt.key)
+			der, err := Sign(content, certificate, tt.key, SignOptions{Detached: tt.detached})
+			assert.NoError(t, err)
+
+			signedData, err := Parse(der)
+			assert.NoError(t, err)
+			assert.Equal(t, OIDData, signedData.ContentType)
+			if tt.detached {
+				assert.Nil(t, signedData.Content)
+			} else {
+				assert.Equal(t, content, signedData.Content)
+			}
+
+			signer, err := signedData.Verify(content)
+			assert.NoError(t, err)
+			assert.Equal(t, certificate.Raw, signer.Raw)
+
+			if tt.detached {
+				_, err = signedData.Verify([]byte("other content"))
+				assert.ErrorIs(t, err, ErrVerification)
+			}
+		})
+	}
+}
+
+func TestSignedData_AddUnsignedAttribute(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	certificate := testCertificate(t, key)
+	content := []byte("signed content")
+
+	der, err := Sign(content, certificate, key, SignOptions{Detached: true})
+	assert.NoError(t, err)
+	signedData, err := Parse(der)
+	assert.NoError(t, err)
+
+	attribute, err := NewAttribute(OIDAttributeTimeStampToken, []byte("token"))
+	assert.NoError(t, err)
+	der, err = signedData.AddUnsignedAttribute(attribute)
+	assert.NoError(t, err)
+
+	// Unsigned attributes are outside the signature, so it still verifies
+	signedData, err = Parse(append(der, make([]byte, 16)...))
+	assert.NoError(t, err)
+	_, err = signedData.Verify(content)
+	assert.NoError(t, err)
+
+	var value []byte
+	found, err := signedData.UnsignedAttribute(OIDAttributeTimeStampToken, &value)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("token"), value)
+}
@@ -0,0 +1,80 @@
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+// oidAttributeSigningCertificate is the ESS signing-certificate attribute (RFC 2634), still
+// used by signers that identify their certificate with SHA-1
+var oidAttributeSigningCertificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 12}
+
+type essCertIDv2 struct {
+	HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+	CertHash      []byte
+	IssuerSerial  asn1.RawValue `asn1:"optional"`
+}
+
+type signingCertificateV2 struct {
+	Certs    []essCertIDv2
+	Policies []asn1.RawValue `asn1:"optional"`
+}
+
+type essCertID struct {
+	CertHash     []byte
+	IssuerSerial asn1.RawValue `asn1:"optional"`
+}
+
+type signingCertificate struct {
+	Certs    []essCertID
+	Policies []asn1.RawValue `asn1:"optional"`
+}
+
+// NewSigningCertificateAttribute creates the ESS signing-certificate-v2 attribute binding a
+// signature to certificate, as required for timestamp tokens and CAdES/PAdES signatures
+func NewSigningCertificateAttribute(certificate *x509.Certificate) (Attribute, error) {
+	sum := sha256.Sum256(certificate.Raw)
+	return NewAttribute(OIDAttributeSigningCertificateV2, signingCertificateV2{
+		Certs: []essCertIDv2{{CertHash: sum[:]}},
+	})
+}
+
+// CheckSigningCertificate checks the ESS signing-certificate attribute against the signer's
+// certificate, so the certificate cannot be substituted
+func (sd *SignedData) CheckSigningCertificate(certificate *x509.Certificate) error {
+	var v2 signingCertificateV2
+	if ok, err := sd.SignedAttribute(OIDAttributeSigningCertificateV2, &v2); err != nil {
+		return err
+	} else if ok && len(v2.Certs) > 0 {
+		hash := crypto.SHA256
+		if v2.Certs[0].HashAlgorithm.Algorithm != nil {
+			if hash, err = HashFunc(v2.Certs[0].HashAlgorithm.Algorithm); err != nil {
+				return err
+			}
+		}
+		h := hash.New()
+		h.Write(certificate.Raw)
+		if !bytes.Equal(h.Sum(nil), v2.Certs[0].CertHash) {
+			return fmt.Errorf("%w: the signing certificate attribute does not match the certificate", ErrVerification)
+		}
+		return nil
+	}
+
+	var v1 signingCertificate
+	if ok, err := sd.SignedAttribute(oidAttributeSigningCertificate, &v1); err != nil {
+		return err
+	} else if ok && len(v1.Certs) > 0 {
+		sum := sha1.Sum(certificate.Raw)
+		if !bytes.Equal(sum[:], v1.Certs[0].CertHash) {
+			return fmt.Errorf("%w: the signing certificate attribute does not match the certificate", ErrVerification)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no signing certificate attribute", ErrVerification)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/templates"
+)
+
+type CertificateHandler struct {
+	store          models.DocumentStore
+	sealer         *models.Sealer
+	timestampRoots *x509.CertPool
+	timeNow        func() time.Time
+}
+
+func NewCertificateHandler(store models.DocumentStore) *CertificateHandler {
+	return &CertificateHandler{store: store, timeNow: time.Now}
+}
+
+// WithSealer verifies seals against the sealer's published keys
+func (h *CertificateHandler) WithSealer(sealer *models.Sealer) *CertificateHandler {
+	h.sealer = sealer
+	return h
+}
+
+// WithTimestampRoots verifies timestamps to be issued by an authority chaining to one of roots
+func (h *CertificateHandler) WithTimestampRoots(roots *x509.CertPool) *CertificateHandler {
+	h.timestampRoots = roots
+	return h
+}
+
+// GetCertificate handles GET /api/documents/signatures/{request_id}/certificate. It returns the
+// certificate of completion as JSON, or with ?format=html as a human-readable page.
+func (h *CertificateHandler) GetCertificate(w http.ResponseWriter, r *http.Request) {
+	requestID := mux.Vars(r)["request_id"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "html" {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Unsupported certificate format", map[string]string{
+			"format": "must be json or html",
+		})
+		return
+	}
+
+	doc, ok := h.getCompletedDocument(w, r, requestID)
+	if !ok {
+		return
+	}
+
+	certificate, err := models.NewCertificate(doc, h.timeNow())
+	if err != nil {
+		log.Printf("Error issuing certificate for %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		templates.Layout(templates.CertificatePage(certificate)).Render(r.Context(), w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(certificate)
+}
+
+// VerifyCertificate handles POST /api/certificates/verify. The body is a certificate of
+// completion; it is checked against the stored record of the request it was issued for.
+func (h *CertificateHandler) VerifyCertificate(w http.ResponseWriter, r *http.Request) {
+	var certificate models.Certificate
+	if err := json.NewDecoder(r.Body).Decode(&certificate); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid request body", nil)
+		return
+	}
+	if certificate.RequestID == "" || certificate.IntegrityHash == "" {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Missing required fields", map[string]string{
+			"request_id":     "is required",
+			"integrity_hash": "is required",
+		})
+		return
+	}
+
+	doc, ok := h.getCompletedDocument(w, r, certificate.RequestID)
+	if !ok {
+		return
+	}
+
+	opts := models.VerifyOptions{TimestampRoots: h.timestampRoots}
+	if h.sealer != nil {
+		opts.SealKeys = h.sealer.PublicKeys()
+	}
+	verification, err := models.VerifyDocument(doc, &certificate, opts)
+	if err != nil {
+		log.Printf("Error verifying certificate for %s: %v", certificate.RequestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verification)
+}
+
+// SealKeysResponse represents the response body for the seal-keys endpoint
+type SealKeysResponse struct {
+	Keys []models.SealKey `json:"keys"`
+}
+
+// SealKeysHandler handles GET /api/seal/keys, publishing the public keys seals are verified with
+func SealKeysHandler(w http.ResponseWriter, r *http.Request, sealer *models.Sealer) {
+	response := SealKeysResponse{Keys: []models.SealKey{}}
+	if sealer != nil {
+		response.Keys = sealer.PublicKeys()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getCompletedDocument loads a document that has an integrity hash together with its signature,
+// writing the error response when there is none
+func (h *CertificateHandler) getCompletedDocument(w http.ResponseWriter, r *http.Request, requestID string) (models.Document, bool) {
+	doc, err := h.store.GetDocument(requestID)
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Signature request not found", nil)
+		return models.Document{}, false
+	}
+	if err != nil {
+		log.Printf("Error getting document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return models.Document{}, false
+	}
+	if doc.IntegrityHash == "" {
+		WriteError(w, r, http.StatusConflict, ErrCodeConflict, "Document has no certificate of completion", map[string]string{
+			"status": doc.Status,
+		})
+		return models.Document{}, false
+	}
+	// The record hashed at completion includes the signature
+	doc.SignatureData, err = h.store.GetSignatureData(requestID)
+	if err != nil {
+		log.Printf("Error getting signature of document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return models.Document{}, false
+	}
+	return doc, true
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func addCompletedDocument(t *testing.T, store *models.InMemoryDocumentStore) string {
+	requestID, _ := store.AddDocument(models.Document{
+		DocumentTitle:   "Agreement",
+		DocumentContent: []models.DocumentSection{{ID: "s1", Type: "text", Content: "Terms"}},
+		SignerName:      "John Smith",
+		SignerEmail:     "john@example.com",
+		Status:          models.StatusPending,
+	})
+	store.UpdateDocumentSignature(requestID, testSignatureDataURL(t))
+	store.StoreConsents(requestID, []models.Consent{{ConsentType: "marketing_email", Granted: true, Timestamp: time.Now()}})
+
+	doc, _ := store.GetDocument(requestID)
+	doc.SignatureData, _ = store.GetSignatureData(requestID)
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	doc.CompletedAt = &completedAt
+	hash, err := models.IntegrityHash(doc)
+	assert.NoError(t, err)
+	assert.NoError(t, store.StoreCompletion(requestID, completedAt, hash, nil))
+	return requestID
+}
+
+func TestCertificateHandler_GetCertificate(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+
+	store := models.NewInMemoryDocumentStore()
+	completedID := addCompletedDocument(t, store)
+	pendingID, _ := store.AddDocument(models.Document{SignerEmail: "jane@example.com", Status: models.StatusPending})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/documents/signatures/{request_id}/certificate", NewCertificateHandler(store).GetCertificate)
+
+	tests := []struct {
+		name            string
+		url             string
+		expectedStatus  int
+		expectedType    string
+		expectedContent string
+	}{
+		{
+			name:            "JSON certificate",
+			url:             "/api/documents/signatures/" + completedID + "/certificate",
+			expectedStatus:  http.StatusOK,
+			expectedType:    "application/json",
+			expectedContent: `"integrity_hash":"sha256:`,
+		},
+		{
+			name:            "HTML certificate",
+			url:             "/api/documents/signatures/" + completedID + "/certificate?format=html",
+			expectedStatus:  http.StatusOK,
+			expectedType:    "text/html; charset=utf-8",
+			expectedContent: "Certificate of Completion",
+		},
+		{
+			name:           "Unsupported format",
+			url:            "/api/documents/signatures/" + completedID + "/certificate?format=pdf",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Not completed",
+			url:            "/api/documents/signatures/" + pendingID + "/certificate",
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Not found",
+			url:            "/api/documents/signatures/missing/certificate",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedType != "" {
+				assert.Equal(t, tt.expectedType, rr.Header().Get("Content-Type"))
+			}
+			if tt.expectedContent != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedContent)
+			}
+		})
+	}
+}
+
+func TestCertificateHandler_VerifyCertificate(t *testing.T) {
+	store := models.NewInMemoryDocumentStore()
+	requestID := addCompletedDocument(t, store)
+	doc, _ := store.GetDocument(requestID)
+	doc.SignatureData, _ = store.GetSignatureData(requestID)
+	certificate, err := models.NewCertificate(doc, time.Now())
+	assert.NoError(t, err)
+
+	tampered := certificate
+	tampered.Record.SignerName = "Jane Smith"
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/certificates/verify", NewCertificateHandler(store).VerifyCertificate).Methods(http.MethodPost)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedValid  bool
+	}{
+		{name: "Valid certificate", body: mustJSON(t, certificate), expectedStatus: http.StatusOK, expectedValid: true},
+		{name: "Tampered certificate", body: mustJSON(t, tampered), expectedStatus: http.StatusOK, expectedValid: false},
+		{name: "Missing fields", body: `{}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Unknown request", body: `{"request_id": "missing", "integrity_hash": "sha256:00"}`, expectedStatus: http.StatusNotFound},
+		{name: "Invalid body", body: `{`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/certificates/verify", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var verification models.CertificateVerification
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&verification))
+				assert.Equal(t, tt.expectedValid, verification.Valid)
+				assert.Equal(t, requestID, verification.RequestID)
+			}
+		})
+	}
+}
+
+func TestCertificateHandler_VerifySealedCertificate(t *testing.T) {
+	sealer := newTestSealer(t)
+	otherSealer := newTestSealer(t)
+
+	store := models.NewInMemoryDocumentStore()
+	requestID := addCompletedDocument(t, store)
+	doc, _ := store.GetDocument(requestID)
+	seal := sealer.Seal(doc.IntegrityHash)
+	assert.NoError(t, store.StoreCompletion(requestID, *doc.CompletedAt, doc.IntegrityHash, &seal))
+	doc, _ = store.GetDocument(requestID)
+	doc.SignatureData, _ = store.GetSignatureData(requestID)
+	certificate, err := models.NewCertificate(doc, time.Now())
+	assert.NoError(t, err)
+
+	forged := certificate
+	forgedSeal := otherSealer.Seal(doc.IntegrityHash)
+	forgedSeal.KeyID = sealer.KeyID()
+	forged.Seal = &forgedSeal
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/certificates/verify", NewCertificateHandler(store).WithSealer(sealer).VerifyCertificate).Methods(http.MethodPost)
+
+	tests := []struct {
+		name          string
+		certificate   models.Certificate
+		expectedValid bool
+	}{
+		{name: "Sealed certificate", certificate: certificate, expectedValid: true},
+		{name: "Forged seal", certificate: forged, expectedValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/certificates/verify", strings.NewReader(mustJSON(t, tt.certificate)))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var verification models.CertificateVerification
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&verification))
+			assert.Equal(t, tt.expectedValid, verification.Valid)
+			assert.Equal(t, models.CheckSeal, verification.Checks[len(verification.Checks)-1].Name)
+		})
+	}
+}
+
+func TestSealKeysHandler(t *testing.T) {
+	sealer := newTestSealer(t)
+
+	tests := []struct {
+		name         string
+		sealer       *models.Sealer
+		expectedKeys int
+	}{
+		{name: "With sealer", sealer: sealer, expectedKeys: 1},
+		{name: "Without sealer", sealer: nil, expectedKeys: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/seal/keys", nil)
+			rr := httptest.NewRecorder()
+			SealKeysHandler(rr, req, tt.sealer)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			keys, err := models.ParseSealKeys(rr.Body.Bytes())
+			assert.NoError(t, err)
+			assert.Len(t, keys, tt.expectedKeys)
+			if tt.expectedKeys > 0 {
+				assert.Equal(t, sealer.KeyID(), keys[0].KeyID)
+				assert.True(t, keys[0].Active)
+			}
+		})
+	}
+}
+
+func newTestSealer(t *testing.T) *models.Sealer {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	sealer, err := models.NewSealer(privateKey)
+	assert.NoError(t, err)
+	return sealer
+}
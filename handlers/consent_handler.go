@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+)
+
+// ConsentStateResponse represents the response body for the consent-state endpoint
+type ConsentStateResponse struct {
+	SubjectID string                `json:"subject_id"`
+	Consents  []models.ConsentState `json:"consents"`
+}
+
+// ConsentHistoryResponse represents the response body for the consent-history endpoint
+type ConsentHistoryResponse struct {
+	SubjectID string                `json:"subject_id"`
+	Events    []models.ConsentEvent `json:"events"`
+}
+
+// ConsentWithdrawalRequest represents the request body for the consent-withdrawal endpoint.
+// SourceRequestID must be a document addressed to the subject, requested with ClientID.
+type ConsentWithdrawalRequest struct {
+	ConsentType     string `json:"consent_type"`
+	Reason          string `json:"reason"`
+	SourceRequestID string `json:"source_request_id"`
+	ClientID        string `json:"client_id"`
+}
+
+type ConsentHandler struct {
+	ledger  models.ConsentLedger
+	store   models.DocumentStore
+	timeNow func() time.Time
+	notify  func(callbackURL string, event models.ConsentEvent)
+}
+
+func NewConsentHandler(ledger models.ConsentLedger, store models.DocumentStore) *ConsentHandler {
+	return &ConsentHandler{
+		ledger:  ledger,
+		store:   store,
+		timeNow: time.Now,
+		notify: func(callbackURL string, event models.ConsentEvent) {
+			go func() {
+				if err := models.NewCallbackSender().SendConsentWithdrawal(callbackURL, event); err != nil {
+					// Log the error but don't fail the request
+					log.Printf("Error sending consent withdrawal callback for %s: %v", event.SubjectID, err)
+				}
+			}()
+		},
+	}
+}
+
+// GetConsentState handles GET /api/consents/{subject_id}
+func (h *ConsentHandler) GetConsentState(w http.ResponseWriter, r *http.Request) {
+	subjectID := models.NormalizeSubjectID(mux.Vars(r)["subject_id"])
+
+	history, err := h.ledger.ListConsentHistory(subjectID)
+	if err != nil {
+		log.Printf("Error listing consent history for %s: %v", subjectID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	if len(history) == 0 {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "No consents recorded for subject", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ConsentStateResponse{
+		SubjectID: subjectID,
+		Consents:  models.CurrentConsentState(history),
+	})
+}
+
+// GetConsentHistory handles GET /api/consents/{subject_id}/history
+func (h *ConsentHandler) GetConsentHistory(w http.ResponseWriter, r *http.Request) {
+	subjectID := models.NormalizeSubjectID(mux.Vars(r)["subject_id"])
+
+	history, err := h.ledger.ListConsentHistory(subjectID)
+	if err != nil {
+		log.Printf("Error listing consent history for %s: %v", subjectID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	if len(history) == 0 {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "No consents recorded for subject", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ConsentHistoryResponse{
+		SubjectID: subjectID,
+		Events:    history,
+	})
+}
+
+// WithdrawConsent handles POST /api/consents/{subject_id}/withdrawals
+func (h *ConsentHandler) WithdrawConsent(w http.ResponseWriter, r *http.Request) {
+	subjectID := models.NormalizeSubjectID(mux.Vars(r)["subject_id"])
+
+	var req ConsentWithdrawalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Request body is not valid JSON", nil)
+		return
+	}
+	if req.ConsentType == "" {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Missing required fields", map[string]string{
+			"consent_type": "is required",
+		})
+		return
+	}
+	// The withdrawal is sent to the callback of the source document, which must therefore be one
+	// of the subject's documents requested by the same client
+	if req.SourceRequestID != "" {
+		invalid, err := h.sourceErrors(subjectID, req)
+		if err != nil {
+			log.Printf("Error listing documents of %s: %v", subjectID, err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+			return
+		}
+		if len(invalid) > 0 {
+			WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid source request", invalid)
+			return
+		}
+	}
+
+	history, err := h.ledger.ListConsentHistory(subjectID)
+	if err != nil {
+		log.Printf("Error listing consent history for %s: %v", subjectID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	var current *models.ConsentState
+	for _, state := range models.CurrentConsentState(history) {
+		if state.ConsentType == req.ConsentType {
+			current = &state
+			break
+		}
+	}
+	if current == nil {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "No consent of this type recorded for subject", map[string]string{
+			"consent_type": req.ConsentType,
+		})
+		return
+	}
+	if !current.Granted {
+		WriteError(w, r, http.StatusConflict, ErrCodeConflict, "Consent is not currently granted", map[string]string{
+			"consent_type": req.ConsentType,
+			"last_action":  current.LastAction,
+		})
+		return
+	}
+
+	sourceRequestID := req.SourceRequestID
+	if sourceRequestID == "" {
+		sourceRequestID = current.SourceRequestID
+	}
+
+	event := models.ConsentEvent{
+		ID:              uuid.NewString(),
+		SubjectID:       subjectID,
+		ConsentType:     req.ConsentType,
+		Action:          models.ConsentActionWithdraw,
+		Granted:         false,
+		Source:          models.ConsentSourceAPI,
+		SourceRequestID: sourceRequestID,
+		Reason:          req.Reason,
+		OccurredAt:      h.timeNow().UTC(),
+	}
+	if err := h.ledger.RecordConsentEvents([]models.ConsentEvent{event}); err != nil {
+		log.Printf("Error recording consent withdrawal for %s: %v", subjectID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	// Notify the client that collected the consent, if we know where to send it
+	if sourceRequestID != "" {
+		doc, err := h.store.GetDocument(sourceRequestID)
+		if err != nil {
+			log.Printf("Error getting source document %s for consent withdrawal: %v", sourceRequestID, err)
+		} else if doc.CallbackURL != "" {
+			h.notify(doc.CallbackURL, event)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
+
+// sourceErrors validates the source document of a withdrawal, which must be addressed to the
+// subject, as found by their email's blind index, and have been requested by the client
+func (h *ConsentHandler) sourceErrors(subjectID string, req ConsentWithdrawalRequest) (map[string]string, error) {
+	docs, err := h.store.ListDocumentsBySigner(subjectID)
+	if err != nil {
+		return nil, err
+	}
+	invalid := map[string]string{}
+	for _, doc := range docs {
+		if doc.ID != req.SourceRequestID {
+			continue
+		}
+		if doc.ClientID != req.ClientID {
+			invalid["source_request_id"] = "was requested by another client"
+		}
+		return invalid, nil
+	}
+	invalid["source_request_id"] = "is not a document of the subject"
+	return invalid, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsentHandler(t *testing.T) {
+	store := models.NewInMemoryDocumentStore()
+	ledger := models.NewInMemoryConsentLedger()
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	docID, _ := store.AddDocument(models.Document{
+		SignerName:  "Test User",
+		SignerEmail: "test@example.com",
+		DeviceID:    "test-device",
+		CallbackURL: "https://client.example.com/callback",
+		Status:      "completed",
+	})
+	otherClientDocID, _ := store.AddDocument(models.Document{
+		SignerEmail: "test@example.com",
+		CallbackURL: "https://other-client.example.com/callback",
+		ClientID:    "other-client",
+		Status:      "completed",
+	})
+	otherSignerDocID, _ := store.AddDocument(models.Document{
+		SignerEmail: "someone@example.com",
+		CallbackURL: "https://other-client.example.com/callback",
+		Status:      "completed",
+	})
+	doc, _ := store.GetDocument(docID)
+	ledger.RecordConsentEvents(models.ConsentEventsFromSignature(doc, []models.Consent{
+		{ConsentType: "marketing_email", Granted: true, Timestamp: now.Add(-time.Hour)},
+		{ConsentType: "marketing_sms", Granted: false, Timestamp: now.Add(-time.Hour)},
+	}, now.Add(-time.Hour)))
+
+	var notified []string
+	handler := NewConsentHandler(ledger, store)
+	handler.timeNow = func() time.Time { return now }
+	handler.notify = func(callbackURL string, event models.ConsentEvent) {
+		notified = append(notified, callbackURL+" "+event.ConsentType)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/consents/{subject_id}", handler.GetConsentState).Methods(http.MethodGet)
+	router.HandleFunc("/api/consents/{subject_id}/history", handler.GetConsentHistory).Methods(http.MethodGet)
+	router.HandleFunc("/api/consents/{subject_id}/withdrawals", handler.WithdrawConsent).Methods(http.MethodPost)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Unknown subject",
+			method:         http.MethodGet,
+			path:           "/api/consents/nobody@example.com",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  ErrCodeNotFound,
+		},
+		{
+			name:           "Withdraw denied consent",
+			method:         http.MethodPost,
+			path:           "/api/consents/test@example.com/withdrawals",
+			body:           ConsentWithdrawalRequest{ConsentType: "marketing_sms"},
+			expectedStatus: http.StatusConflict,
+			expectedError:  ErrCodeConflict,
+		},
+		{
+			name:           "Withdraw unknown consent type",
+			method:         http.MethodPost,
+			path:           "/api/consents/test@example.com/withdrawals",
+			body:           ConsentWithdrawalRequest{ConsentType: "newsletter"},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  ErrCodeNotFound,
+		},
+		{
+			name:           "Withdraw without consent type",
+			method:         http.MethodPost,
+			path:           "/api/consents/test@example.com/withdrawals",
+			body:           ConsentWithdrawalRequest{},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:           "Withdraw with document of another subject",
+			method:         http.MethodPost,
+			path:           "/api/consents/test@example.com/withdrawals",
+			body:           ConsentWithdrawalRequest{ConsentType: "marketing_email", SourceRequestID: otherSignerDocID},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:           "Withdraw with document of another client",
+			method:         http.MethodPost,
+			path:           "/api/consents/test@example.com/withdrawals",
+			body:           ConsentWithdrawalRequest{ConsentType: "marketing_email", SourceRequestID: otherClientDocID},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:           "Withdraw granted consent",
+			method:         http.MethodPost,
+			path:           "/api/consents/Test@Example.com/withdrawals",
+			body:           ConsentWithdrawalRequest{ConsentType: "marketing_email", Reason: "requested by phone"},
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			if tt.body != nil {
+				body, _ = json.Marshal(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedError != "" {
+				var response ErrorResponse
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				assert.Equal(t, tt.expectedError, response.Code)
+			}
+		})
+	}
+
+	assert.Equal(t, []string{"https://client.example.com/callback marketing_email"}, notified)
+
+	// Current state reflects the withdrawal
+	req := httptest.NewRequest(http.MethodGet, "/api/consents/test@example.com", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var state ConsentStateResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&state))
+	assert.Equal(t, "test@example.com", state.SubjectID)
+	assert.Equal(t, []models.ConsentState{
+		{ConsentType: "marketing_email", Granted: false, LastAction: models.ConsentActionWithdraw, SourceRequestID: docID, UpdatedAt: now},
+		{ConsentType: "marketing_sms", Granted: false, LastAction: models.ConsentActionDeny, SourceRequestID: docID, UpdatedAt: now.Add(-time.Hour)},
+	}, state.Consents)
+
+	// History lists grants, denials and the withdrawal
+	req = httptest.NewRequest(http.MethodGet, "/api/consents/test@example.com/history", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var history ConsentHistoryResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&history))
+	assert.Len(t, history.Events, 3)
+	assert.Equal(t, models.ConsentActionWithdraw, history.Events[2].Action)
+	assert.Equal(t, "requested by phone", history.Events[2].Reason)
+}
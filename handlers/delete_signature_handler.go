@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -20,20 +22,27 @@ func DeleteSignatureHandler(w http.ResponseWriter, r *http.Request, store models
 	requestID := vars["request_id"]
 
 	// Get the document to verify it exists
-	_, err := store.GetDocument(requestID)
+	doc, err := store.GetDocument(requestID)
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Signature request not found", nil)
+		return
+	}
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Signature request not found",
-		})
+		log.Printf("Error getting document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	if doc.Status == "removed" {
+		WriteError(w, r, http.StatusConflict, ErrCodeConflict, "Signature request already removed", nil)
 		return
 	}
 
 	// Update document status to removed
 	err = store.UpdateDocumentStatus(requestID, "removed")
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error removing document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
 		return
 	}
 
@@ -40,12 +40,22 @@ func TestDeleteSignatureHandler(t *testing.T) {
 				"status":     "removed",
 			},
 		},
+		{
+			name:           "Already Removed",
+			requestID:      requestID,
+			expectedStatus: http.StatusConflict,
+			expectedBody: map[string]string{
+				"code":    ErrCodeConflict,
+				"message": "Signature request already removed",
+			},
+		},
 		{
 			name:           "Not Found",
 			requestID:      "non-existent-id",
 			expectedStatus: http.StatusNotFound,
 			expectedBody: map[string]string{
-				"error": "Signature request not found",
+				"code":    ErrCodeNotFound,
+				"message": "Signature request not found",
 			},
 		},
 	}
@@ -3,9 +3,13 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/jakubsacha/signature-collector/i18n"
 	"github.com/jakubsacha/signature-collector/templates"
 )
 
+// tabletCookieMaxAge keeps the language and time zone of a tablet for a year
+const tabletCookieMaxAge = 365 * 24 * 60 * 60
+
 type DeviceEntryHandler struct{}
 
 func NewDeviceEntryHandler() *DeviceEntryHandler {
@@ -13,7 +17,11 @@ func NewDeviceEntryHandler() *DeviceEntryHandler {
 }
 
 func (h *DeviceEntryHandler) ShowForm(w http.ResponseWriter, r *http.Request) {
-	component := templates.Layout(templates.DeviceIDForm())
+	var tabletLang string
+	if cookie, err := r.Cookie(i18n.LanguageCookieName); err == nil {
+		tabletLang = cookie.Value
+	}
+	component := templates.Layout(templates.DeviceIDForm(tabletLang))
 	component.Render(r.Context(), w)
 }
 
@@ -24,7 +32,38 @@ func (h *DeviceEntryHandler) ProcessForm(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Remember the tablet's language, or forget it to follow the browser's again
+	var lang string
+	if match, ok := i18n.Match(r.FormValue("lang")); ok {
+		lang = match
+	}
+	http.SetCookie(w, tabletCookie(i18n.LanguageCookieName, lang))
+
+	// Remember the tablet's time zone, as reported by its browser
+	var timezone string
+	if location, err := i18n.LoadTimezone(r.FormValue("timezone")); err == nil {
+		timezone = location.String()
+	}
+	http.SetCookie(w, tabletCookie(i18n.TimezoneCookieName, timezone))
+
 	// Set HX-Redirect header for HTMX to handle the redirect
 	w.Header().Set("HX-Redirect", "/documents/"+deviceID)
 	w.WriteHeader(http.StatusOK)
 }
+
+// tabletCookie returns a cookie storing a setting of the tablet, or deleting it when value is
+// empty
+func tabletCookie(name, value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   tabletCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
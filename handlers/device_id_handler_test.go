@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceEntryHandler_Settings(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	handler := NewDeviceEntryHandler()
+
+	// The form offers every supported language and selects the tablet's, and sends the time
+	// zone of the browser
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: i18n.LanguageCookieName, Value: "pl"})
+	rr := httptest.NewRecorder()
+	handler.ShowForm(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<option value="en">English</option>`)
+	assert.Contains(t, rr.Body.String(), `<option value="pl" selected>polski</option>`)
+	assert.Contains(t, rr.Body.String(), `hx-vals="js:{timezone: Intl.DateTimeFormat().resolvedOptions().timeZone}"`)
+
+	tests := []struct {
+		name             string
+		lang             string
+		timezone         string
+		expectedLang     string
+		expectedTimezone string
+	}{
+		{name: "Supported language", lang: "pl", timezone: "Europe/Warsaw", expectedLang: "pl", expectedTimezone: "Europe/Warsaw"},
+		{name: "Automatic", lang: "", timezone: "America/New_York", expectedLang: "", expectedTimezone: "America/New_York"},
+		{name: "Unsupported language", lang: "ja", timezone: "Asia/Tokyo", expectedLang: "", expectedTimezone: "Asia/Tokyo"},
+		{name: "Unknown time zone", lang: "en", timezone: "Mars/Olympus_Mons", expectedLang: "en", expectedTimezone: ""},
+		{name: "Server time zone", lang: "en", timezone: "Local", expectedLang: "en", expectedTimezone: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"device_id": {"tablet-1"}, "lang": {tt.lang}, "timezone": {tt.timezone}}
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			handler.ProcessForm(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "/documents/tablet-1", rr.Header().Get("HX-Redirect"))
+
+			cookies := map[string]*http.Cookie{}
+			for _, cookie := range rr.Result().Cookies() {
+				assert.True(t, cookie.HttpOnly)
+				cookies[cookie.Name] = cookie
+			}
+			for name, expected := range map[string]string{i18n.LanguageCookieName: tt.expectedLang, i18n.TimezoneCookieName: tt.expectedTimezone} {
+				if assert.Contains(t, cookies, name) {
+					assert.Equal(t, expected, cookies[name].Value)
+					if expected == "" {
+						assert.Equal(t, -1, cookies[name].MaxAge)
+					} else {
+						assert.Equal(t, tabletCookieMaxAge, cookies[name].MaxAge)
+					}
+				}
+			}
+		})
+	}
+}
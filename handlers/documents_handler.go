@@ -38,7 +38,7 @@ func (h *DocumentsHandler) ListDocuments(w http.ResponseWriter, r *http.Request)
 
 	// Check if this is a content-only request
 	if r.URL.Path == "/documents/"+deviceID+"/content" {
-		component := templates.DocumentsContent(deviceID, documents, i18n.T("ConfirmDelete", nil))
+		component := templates.DocumentsContent(deviceID, documents, i18n.T(r.Context(), "ConfirmDelete", nil))
 		component.Render(r.Context(), w)
 		return
 	}
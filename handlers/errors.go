@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Error codes returned in the "code" field of an ErrorResponse
+const (
+	ErrCodeBadRequest       = "bad_request"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeConflict         = "conflict"
+	ErrCodeValidation       = "validation_failed"
+	ErrCodeInvalidSignature = "invalid_signature"
+	ErrCodeInternal         = "internal_error"
+	// ErrCodeVerificationRequired is returned for a signature submitted before the signer proved
+	// who they are
+	ErrCodeVerificationRequired = "verification_required"
+)
+
+// RequestIDHeader is the header used to correlate a request with its error responses and logs
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the length of a request ID taken from the client
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+// ErrorResponse is the problem-details body returned by every API and tablet JSON endpoint
+type ErrorResponse struct {
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// RequestIDMiddleware assigns every request an ID, taken from the incoming X-Request-ID header
+// when it is a valid one, and echoes it back in the response headers
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+	})
+}
+
+// RequestIDFromContext returns the ID assigned by RequestIDMiddleware, if any
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// validRequestID reports whether a request ID from the client is short and made only of letters,
+// digits and dashes, so that it is safe to echo in responses and logs
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// WantsJSON reports whether the client expects a JSON response, because it accepts JSON or sent
+// a JSON body, as the tablet does when submitting a signature
+func WantsJSON(r *http.Request) bool {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// WriteError writes a JSON error response with the given status code
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]string) {
+	requestID := RequestIDFromContext(r.Context())
+	if requestID == "" && validRequestID(r.Header.Get(RequestIDHeader)) {
+		requestID = r.Header.Get(RequestIDHeader)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestID,
+	})
+}
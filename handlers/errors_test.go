@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteErrorIncludesRequestID(t *testing.T) {
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Signature request not found", map[string]string{
+			"request_id": "abc",
+		})
+	}))
+
+	tests := []struct {
+		name            string
+		incomingID      string
+		expectGenerated bool
+	}{
+		{
+			name:       "propagates incoming request ID",
+			incomingID: "client-supplied-id",
+		},
+		{
+			name:            "generates request ID when missing",
+			expectGenerated: true,
+		},
+		{
+			name:            "replaces request ID with other characters",
+			incomingID:      "abc\r\nX-Injected: 1",
+			expectGenerated: true,
+		},
+		{
+			name:            "replaces request ID that is too long",
+			incomingID:      strings.Repeat("a", maxRequestIDLength+1),
+			expectGenerated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/documents/signatures/abc/status", nil)
+			if tt.incomingID != "" {
+				req.Header.Set(RequestIDHeader, tt.incomingID)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var response ErrorResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.Equal(t, ErrCodeNotFound, response.Code)
+			assert.Equal(t, "Signature request not found", response.Message)
+			assert.Equal(t, "abc", response.Details["request_id"])
+			assert.Equal(t, w.Header().Get(RequestIDHeader), response.RequestID)
+			if tt.expectGenerated {
+				assert.NotEmpty(t, response.RequestID)
+				assert.NotEqual(t, tt.incomingID, response.RequestID)
+			} else {
+				assert.Equal(t, tt.incomingID, response.RequestID)
+			}
+		})
+	}
+}
+
+func TestWantsJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/documents/sign/abc", nil)
+	assert.False(t, WantsJSON(req))
+
+	req.Header.Set("Accept", "text/html, application/json;q=0.9")
+	assert.True(t, WantsJSON(req))
+
+	req = httptest.NewRequest(http.MethodPost, "/documents/sign/abc", nil)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	assert.True(t, WantsJSON(req))
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/pdf"
+	"github.com/jakubsacha/signature-collector/render"
+)
+
+// queuedSubmissionDelay is how long after it was captured a signature must have been received for
+// the PDF to say when, as it was queued on the tablet while offline
+const queuedSubmissionDelay = time.Minute
+
+type PDFHandler struct {
+	store   models.DocumentStore
+	signer  *pdf.Signer
+	fonts   []pdf.FontFile
+	timeNow func() time.Time
+}
+
+func NewPDFHandler(store models.DocumentStore) *PDFHandler {
+	return &PDFHandler{store: store, timeNow: time.Now}
+}
+
+// WithSigner adds a PAdES digital signature to the PDFs, with the handwritten signature as its
+// visible appearance
+func (h *PDFHandler) WithSigner(signer *pdf.Signer) *PDFHandler {
+	h.signer = signer
+	return h
+}
+
+// WithFonts sets the fonts used for characters the built-in fonts have no glyphs for, such as
+// Arabic or Hebrew ones
+func (h *PDFHandler) WithFonts(fonts []pdf.FontFile) *PDFHandler {
+	h.fonts = fonts
+	return h
+}
+
+// GetPDF handles GET /api/documents/signatures/{request_id}/pdf. It renders a completed document
+// with its consents and handwritten signature as a PDF, or stamps the signature into the
+// original of a PDF document, digitally signed when a signing certificate is configured.
+func (h *PDFHandler) GetPDF(w http.ResponseWriter, r *http.Request) {
+	requestID := mux.Vars(r)["request_id"]
+
+	doc, err := h.store.GetDocument(requestID)
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Signature request not found", nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	doc.SignatureData, err = h.store.GetSignatureData(requestID)
+	if err != nil {
+		log.Printf("Error getting signature of document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	if doc.Status != models.StatusCompleted || doc.SignatureData == "" {
+		WriteError(w, r, http.StatusConflict, ErrCodeConflict, "Document is not completed", map[string]string{
+			"status": doc.Status,
+		})
+		return
+	}
+
+	data, err := h.renderPDF(r.Context(), doc)
+	if err != nil {
+		log.Printf("Error rendering PDF for %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "document-"+doc.ID+".pdf"))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// ServeCopy handles GET /c/{token}, the link a signer downloads their copy of a completed document
+// from. It needs no credentials; the link is known only to the signer and expires.
+func (h *PDFHandler) ServeCopy(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+
+	doc, err := h.store.GetDocumentByCopyToken(models.HashCopyToken(token))
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting document by copy link: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	doc.SignatureData, err = h.store.GetSignatureData(doc.ID)
+	if err != nil {
+		log.Printf("Error getting signature of document %s: %v", doc.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// A document whose signature was purged since can no longer be downloaded
+	if doc.CopyLinkExpired(h.timeNow()) || doc.Status != models.StatusCompleted || doc.SignatureData == "" {
+		http.Error(w, "Link has expired", http.StatusGone)
+		return
+	}
+
+	data, err := h.renderPDF(r.Context(), doc)
+	if err != nil {
+		log.Printf("Error rendering PDF for %s: %v", doc.ID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// The token is in the URL, so it must not be passed on to other sites or kept in caches
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "document-"+doc.ID+".pdf"))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// renderPDF lays out the document and signs it. Without a signer the handwritten signature is
+// drawn on the page; with one it is the appearance of the digital signature.
+func (h *PDFHandler) renderPDF(ctx context.Context, doc models.Document) ([]byte, error) {
+	// The PDF is in the signer's language and time zone, whatever those of the API client
+	ctx = i18n.WithTimezone(i18n.WithLanguage(ctx, doc.Locale), doc.Timezone)
+	signature, err := render.DecodeDataURL(doc.SignatureData)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %v", err)
+	}
+
+	completedAt := doc.CreatedAt
+	if doc.CompletedAt != nil {
+		completedAt = *doc.CompletedAt
+	}
+	if doc.IsPDF() {
+		return h.stampPDF(ctx, doc, signature, completedAt)
+	}
+	// A signature queued on the tablet while it was offline was given before it was received
+	signedAt := completedAt
+	if doc.CapturedAt != nil {
+		signedAt = *doc.CapturedAt
+	}
+
+	document := pdf.Document{
+		Title:       doc.DocumentTitle,
+		SignerName:  doc.SignerName,
+		Date:        i18n.FormatDateTime(ctx, signedAt),
+		Footer:      []string{i18n.T(ctx, "RequestID", nil) + ": " + doc.ID},
+		CreatedAt:   doc.CreatedAt,
+		RightToLeft: i18n.IsRightToLeft(ctx),
+		Fonts:       h.fonts,
+		Labels: pdf.Labels{
+			Granted:    i18n.T(ctx, "ConsentGranted", nil),
+			NotGranted: i18n.T(ctx, "ConsentDenied", nil),
+			Signature:  i18n.T(ctx, "Signature", nil),
+			Signer:     i18n.T(ctx, "Signer", nil),
+			Date:       i18n.T(ctx, "CompletedAt", nil),
+		},
+	}
+	if completedAt.Sub(signedAt) >= queuedSubmissionDelay {
+		document.Footer = append(document.Footer, i18n.T(ctx, "ReceivedAt", nil)+": "+i18n.FormatDateTime(ctx, completedAt))
+	}
+	if doc.IntegrityHash != "" {
+		document.Footer = append(document.Footer, i18n.T(ctx, "IntegrityHash", nil)+": "+doc.IntegrityHash)
+	}
+	for _, section := range doc.DocumentContent {
+		entry := pdf.Section{Text: section.Content}
+		if section.Type == "consent" && section.ConsentType != nil {
+			entry.Consent = true
+			for _, consent := range doc.Consents {
+				if consent.ConsentType == *section.ConsentType {
+					entry.Granted = consent.Granted
+				}
+			}
+		}
+		document.Sections = append(document.Sections, entry)
+	}
+
+	if h.signer == nil {
+		document.Signature = signature
+	}
+	data, placement, err := pdf.Render(document)
+	if err != nil || h.signer == nil {
+		return data, err
+	}
+	return h.signer.Sign(ctx, data, pdf.SignOptions{
+		Name:        doc.SignerName,
+		SigningTime: completedAt,
+		Placement:   &placement,
+		Image:       signature,
+	})
+}
+
+// stampPDF stamps the handwritten signature into every signature field of the original PDF.
+// The digital signature is placed over the first field without an appearance of its own, so the
+// stamp shows through it.
+func (h *PDFHandler) stampPDF(ctx context.Context, doc models.Document, signature image.Image, completedAt time.Time) ([]byte, error) {
+	original, err := h.store.GetDocumentPDF(doc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting document PDF: %v", err)
+	}
+	digest := sha256.Sum256(original)
+	if original == nil || hex.EncodeToString(digest[:]) != doc.DocumentPDFSHA256 {
+		return nil, fmt.Errorf("document PDF is missing or does not match its digest")
+	}
+
+	placements := make([]pdf.Placement, len(doc.SignatureFields))
+	for i, field := range doc.SignatureFields {
+		placements[i] = pdf.Placement{Page: field.Page, X: field.X, Y: field.Y, Width: field.Width, Height: field.Height}
+	}
+	data, err := pdf.Stamp(original, signature, placements)
+	if err != nil || h.signer == nil || len(placements) == 0 {
+		return data, err
+	}
+	return h.signer.Sign(ctx, data, pdf.SignOptions{
+		Name:        doc.SignerName,
+		SigningTime: completedAt,
+		Placement:   &placements[0],
+	})
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/pdf"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPDFSigner(t *testing.T) *pdf.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Signature Collector test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return pdf.NewSigner(certificate, key)
+}
+
+func TestPDFHandler_GetPDF(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+
+	store := models.NewInMemoryDocumentStore()
+	completedID := addCompletedDocument(t, store)
+	store.UpdateDocumentStatus(completedID, models.StatusCompleted)
+	pendingID, _ := store.AddDocument(models.Document{SignerEmail: "jane@example.com", Status: models.StatusPending})
+	signer := newTestPDFSigner(t)
+
+	// A PDF document has the signature stamped into its original as an incremental update
+	documentPDF := testDocumentPDF(t)
+	digest := sha256.Sum256(documentPDF)
+	pdfID, _ := store.AddDocument(models.Document{
+		DocumentTitle:     "Contract",
+		DocumentPDF:       documentPDF,
+		DocumentPDFSHA256: hex.EncodeToString(digest[:]),
+		SignatureFields:   []models.SignatureField{{Page: 1, X: 56, Y: 100, Width: 200, Height: 80}},
+		SignerName:        "John Smith",
+		Status:            models.StatusPending,
+	})
+	store.UpdateDocumentSignature(pdfID, testSignatureDataURL(t))
+	assert.NoError(t, store.StoreCompletion(pdfID, time.Now().UTC().Truncate(time.Second), "sha256:digest", nil))
+	store.UpdateDocumentStatus(pdfID, models.StatusCompleted)
+
+	tests := []struct {
+		name               string
+		handler            *PDFHandler
+		requestID          string
+		expectedStatus     int
+		expectedSignatures int
+		expectedOriginal   []byte
+	}{
+		{name: "Unsigned PDF", handler: NewPDFHandler(store), requestID: completedID, expectedStatus: http.StatusOK},
+		{name: "Signed PDF", handler: NewPDFHandler(store).WithSigner(signer), requestID: completedID, expectedStatus: http.StatusOK, expectedSignatures: 1},
+		{name: "Stamped PDF document", handler: NewPDFHandler(store), requestID: pdfID, expectedStatus: http.StatusOK, expectedOriginal: documentPDF},
+		{name: "Stamped and signed PDF document", handler: NewPDFHandler(store).WithSigner(signer), requestID: pdfID, expectedStatus: http.StatusOK, expectedSignatures: 1, expectedOriginal: documentPDF},
+		{name: "Pending document", handler: NewPDFHandler(store), requestID: pendingID, expectedStatus: http.StatusConflict},
+		{name: "Unknown document", handler: NewPDFHandler(store), requestID: "missing", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/api/documents/signatures/{request_id}/pdf", tt.handler.GetPDF)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/documents/signatures/"+tt.requestID+"/pdf", nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Header().Get("Content-Disposition"), "document-"+tt.requestID+".pdf")
+			assert.True(t, bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-")))
+			if tt.expectedOriginal != nil {
+				assert.True(t, bytes.HasPrefix(rr.Body.Bytes(), tt.expectedOriginal))
+				assert.Contains(t, string(rr.Body.Bytes()[len(tt.expectedOriginal):]), "/XObject <</SigStamp ")
+			}
+
+			signatures, err := pdf.Verify(rr.Body.Bytes())
+			assert.NoError(t, err)
+			assert.Len(t, signatures, tt.expectedSignatures)
+			if tt.expectedSignatures > 0 {
+				doc, _ := store.GetDocument(tt.requestID)
+				assert.Equal(t, "John Smith", signatures[0].Name)
+				assert.Equal(t, doc.CompletedAt.UTC(), signatures[0].SigningTime)
+				assert.Equal(t, signer.Certificate(), signatures[0].Signer)
+			}
+		})
+	}
+}
+
+func TestPDFHandler_ServeCopy(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	store := models.NewInMemoryDocumentStore()
+	completedID := addCompletedDocument(t, store)
+	store.UpdateDocumentStatus(completedID, models.StatusCompleted)
+	assert.NoError(t, store.StoreCopyLink(completedID, models.HashCopyToken("Ab3xK9pQ2mZt"), now.Add(time.Hour)))
+	expiredID := addCompletedDocument(t, store)
+	store.UpdateDocumentStatus(expiredID, models.StatusCompleted)
+	assert.NoError(t, store.StoreCopyLink(expiredID, models.HashCopyToken("expired00000"), now))
+	purgedID := addCompletedDocument(t, store)
+	store.UpdateDocumentStatus(purgedID, models.StatusCompleted)
+	assert.NoError(t, store.StoreCopyLink(purgedID, models.HashCopyToken("purged000000"), now.Add(time.Hour)))
+	assert.NoError(t, store.PurgeDocument(purgedID, models.RetentionActionPurgeSignature))
+
+	handler := NewPDFHandler(store)
+	handler.timeNow = func() time.Time { return now }
+	router := mux.NewRouter()
+	router.HandleFunc("/c/{token}", handler.ServeCopy)
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "Copy", token: "Ab3xK9pQ2mZt", expectedStatus: http.StatusOK},
+		{name: "Expired link", token: "expired00000", expectedStatus: http.StatusGone},
+		{name: "Purged signature", token: "purged000000", expectedStatus: http.StatusGone},
+		{name: "Unknown link", token: "unknown00000", expectedStatus: http.StatusNotFound},
+		{name: "Stored hash", token: models.HashCopyToken("Ab3xK9pQ2mZt"), expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/c/"+tt.token, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+			assert.Contains(t, rr.Header().Get("Content-Disposition"), "document-"+completedID+".pdf")
+			assert.Equal(t, "private, no-store", rr.Header().Get("Cache-Control"))
+			assert.Equal(t, "no-referrer", rr.Header().Get("Referrer-Policy"))
+			assert.True(t, bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-")))
+		})
+	}
+}
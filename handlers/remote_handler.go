@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/templates"
+)
+
+// remoteSessionCookie holds the session a signer's browser signs a document remotely in
+const remoteSessionCookie = "signer_session"
+
+// RemoteSigningHandler lets signers sign documents in their own browser. A remote sign request is
+// issued a single-use link; the signer who opens it verifies their email address with a code
+// sent to it, which exchanges the link for a session in which the signing pages of the document
+// work as they do on a tablet.
+type RemoteSigningHandler struct {
+	store     models.DocumentStore
+	audit     models.AuditLog
+	mailer    *SignerMailer
+	ttl       time.Duration
+	publicURL string
+	timeNow   func() time.Time
+}
+
+// NewRemoteSigningHandler creates a handler issuing links valid for ttl and emailing codes with
+// mailer. Verifications are recorded in audit, if not nil.
+func NewRemoteSigningHandler(store models.DocumentStore, audit models.AuditLog, mailer *SignerMailer, ttl time.Duration) *RemoteSigningHandler {
+	return &RemoteSigningHandler{store: store, audit: audit, mailer: mailer, ttl: ttl, timeNow: time.Now}
+}
+
+// WithPublicURL sets the URL signers reach this service at, which links are issued under. Without
+// it links use the URL the API client reached the service at.
+func (h *RemoteSigningHandler) WithPublicURL(publicURL string) *RemoteSigningHandler {
+	h.publicURL = publicURL
+	return h
+}
+
+// IssueLink creates the link to sign a pending document remotely and, with email, sends it to the
+// signer in the background
+func (h *RemoteSigningHandler) IssueLink(r *http.Request, doc models.Document, email bool) (string, time.Time, error) {
+	token, err := models.NewRemoteToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := h.timeNow().UTC().Add(h.ttl).Truncate(time.Second)
+	if err := h.store.StoreRemoteLink(doc.ID, models.HashRemoteSession(token), expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	link := models.RemoteURL(requestBaseURL(r, h.publicURL), token)
+
+	if email {
+		// The email is sent after the response, so it must outlive the request
+		ctx := context.WithoutCancel(r.Context())
+		go func() {
+			if err := h.mailer.SendSigningLink(ctx, doc, link, expiresAt); err != nil {
+				log.Printf("Error emailing signing link of %s: %v", doc.ID, err)
+			}
+		}()
+	}
+	return link, expiresAt, nil
+}
+
+// ShowVerification handles GET /r/{token}, asking the signer to verify their email address. It
+// sends nothing itself, so links opened by mail scanners use up no codes.
+func (h *RemoteSigningHandler) ShowVerification(w http.ResponseWriter, r *http.Request) {
+	doc, ok := h.linkDocument(w, r)
+	if !ok {
+		return
+	}
+	h.render(w, r, http.StatusOK, doc, "")
+}
+
+// SendCode handles POST /r/{token}/code, emailing the signer a new code
+func (h *RemoteSigningHandler) SendCode(w http.ResponseWriter, r *http.Request) {
+	doc, ok := h.linkDocument(w, r)
+	if !ok {
+		return
+	}
+	if status, message := sendCode(r, h.store, h.mailer, doc, h.timeNow()); status != 0 {
+		h.render(w, r, status, doc, message)
+		return
+	}
+	http.Redirect(w, r, models.RemoteURL("", mux.Vars(r)["token"]), http.StatusSeeOther)
+}
+
+// Verify handles POST /r/{token}, checking the code the signer entered. The right code uses up
+// the link and starts the session the signer signs the document in.
+func (h *RemoteSigningHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	doc, ok := h.linkDocument(w, r)
+	if !ok {
+		return
+	}
+
+	// The attempt is counted before the code is checked, so that concurrent guesses cannot
+	// exceed the limit. Unless the sign request asked for another verification, the code is the
+	// signer's verification.
+	err := h.store.RecordOTPAttempt(doc.ID)
+	if err == nil && doc.VerificationMethod == models.VerificationEmailCode {
+		err = h.store.RecordVerificationAttempt(doc.ID)
+	}
+	if err != nil {
+		log.Printf("Error recording code attempt: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	doc, err = h.store.GetDocument(doc.ID)
+	if err != nil {
+		log.Printf("Error getting document: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	now := h.timeNow()
+	if err := doc.CheckOTP(r.FormValue("code"), now); err != nil {
+		h.render(w, r, http.StatusUnprocessableEntity, doc, otpMessage(signerContext(r.Context(), doc), err))
+		return
+	}
+
+	session, err := models.NewRemoteToken()
+	if err != nil {
+		log.Printf("Error generating session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := now.UTC().Add(models.RemoteSessionTTL).Truncate(time.Second)
+	if err := h.store.StoreRemoteSession(doc.ID, models.HashRemoteSession(session), expiresAt); err != nil {
+		log.Printf("Error storing session: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if doc.VerificationMethod == models.VerificationEmailCode {
+		if err := h.store.StoreVerificationOutcome(doc.ID, models.VerificationVerified, now); err != nil {
+			log.Printf("Error storing verification outcome: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		doc.VerificationOutcome = models.VerificationVerified
+		recordVerification(h.audit, doc, now)
+	}
+
+	signingPath := "/documents/sign/" + url.PathEscape(doc.ID)
+	http.SetCookie(w, &http.Cookie{
+		Name:     remoteSessionCookie,
+		Value:    session,
+		Path:     signingPath,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(requestBaseURL(r, h.publicURL), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, signingPath, http.StatusSeeOther)
+}
+
+// SignerOrStaff lets a browser holding the remote signing session of a document into its signing
+// pages, served by next, and passes any other request to staff, which authenticates tablets
+func (h *RemoteSigningHandler) SignerOrStaff(next http.HandlerFunc, staff http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(remoteSessionCookie)
+		if err != nil {
+			staff(w, r)
+			return
+		}
+		doc, err := h.store.GetDocument(mux.Vars(r)["request_id"])
+		if err != nil || !doc.Remote || !doc.HasRemoteSession(cookie.Value, h.timeNow()) {
+			http.Error(w, "Your signing session has expired", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// linkDocument returns the pending document the remote signing link of r is for, or answers that
+// the link cannot be used
+func (h *RemoteSigningHandler) linkDocument(w http.ResponseWriter, r *http.Request) (models.Document, bool) {
+	// The token is in the URL, so it must not be passed on to other sites or kept in caches
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	doc, err := h.store.GetDocumentByRemoteToken(models.HashRemoteSession(mux.Vars(r)["token"]))
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		http.Error(w, "Link not found or already used", http.StatusNotFound)
+		return models.Document{}, false
+	}
+	if err != nil {
+		log.Printf("Error getting document by remote link: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return models.Document{}, false
+	}
+	if doc.RemoteLinkExpired(h.timeNow()) || doc.Status != models.StatusPending {
+		http.Error(w, "Link has expired", http.StatusGone)
+		return models.Document{}, false
+	}
+	return doc, true
+}
+
+// render shows the verification page of a document in the signer's language, with message
+func (h *RemoteSigningHandler) render(w http.ResponseWriter, r *http.Request, status int, doc models.Document, message string) {
+	codeSent := doc.OTPHash != "" && doc.OTPExpiresAt != nil && h.timeNow().Before(*doc.OTPExpiresAt)
+	page := templates.RemoteVerifyPage(doc, mux.Vars(r)["token"], models.MaskEmail(doc.SignerEmail), codeSent, message)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	templates.Layout(page).Render(signerContext(r.Context(), doc), w)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+// remoteSigningSetup serves a remote signing handler and the signature page it guards, with email
+// delivered to a local server
+type remoteSigningSetup struct {
+	store  *models.InMemoryDocumentStore
+	audit  *models.InMemoryAuditLog
+	local  *notify.LocalServer
+	remote *RemoteSigningHandler
+	router *mux.Router
+	now    time.Time
+}
+
+func newRemoteSigningSetup(t *testing.T) *remoteSigningSetup {
+	assert.NoError(t, i18n.Init("en"))
+	local, err := notify.NewLocalServer("", "")
+	assert.NoError(t, err)
+	t.Cleanup(func() { local.Close() })
+	sender, err := notify.NewSMTPSender(local.Host(), local.Port(), "", "", "noreply@example.com")
+	assert.NoError(t, err)
+
+	s := &remoteSigningSetup{
+		store: models.NewInMemoryDocumentStore(),
+		audit: models.NewInMemoryAuditLog(),
+		local: local,
+		now:   time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC),
+	}
+	mailer := NewSignerMailer(s.store, sender, NewPDFHandler(s.store), SignerEmailAttachment)
+	s.remote = NewRemoteSigningHandler(s.store, s.audit, mailer, 72*time.Hour).WithPublicURL("https://sign.example.com")
+	s.remote.timeNow = func() time.Time { return s.now }
+
+	staff := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Staff credentials required", http.StatusUnauthorized)
+	}
+	signatureHandler := NewSignatureHandler(s.store)
+	s.router = mux.NewRouter()
+	s.router.HandleFunc("/api/documents/signatures/request", func(w http.ResponseWriter, r *http.Request) {
+		SignRequestHandler(w, r, s.store, s.remote)
+	}).Methods(http.MethodPost)
+	s.router.HandleFunc("/documents/sign/{request_id}", s.remote.SignerOrStaff(signatureHandler.ShowSignaturePage, staff)).Methods(http.MethodGet)
+	s.router.HandleFunc("/r/{token}", s.remote.ShowVerification).Methods(http.MethodGet)
+	s.router.HandleFunc("/r/{token}", s.remote.Verify).Methods(http.MethodPost)
+	s.router.HandleFunc("/r/{token}/code", s.remote.SendCode).Methods(http.MethodPost)
+	return s
+}
+
+// request creates a remote sign request and returns its response
+func (s *remoteSigningSetup) request(t *testing.T, emailLink bool) SignResponse {
+	body := mustJSON(t, SignRequest{
+		DocumentTitle:   "Agreement",
+		DocumentContent: []models.DocumentSection{{ID: "s1", Type: "text", Content: "Terms"}},
+		SignerName:      "John Smith",
+		SignerEmail:     "john@example.com",
+		CallbackURL:     "https://client.example.com/callback",
+		Remote:          true,
+		EmailLink:       emailLink,
+	})
+	rr := s.serve(http.MethodPost, "/api/documents/signatures/request", body, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response SignResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	return response
+}
+
+func (s *remoteSigningSetup) serve(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if method == http.MethodPost && !strings.HasPrefix(target, "/api/") {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, req)
+	return rr
+}
+
+// lastEmail returns the body of the last message the local server received
+func (s *remoteSigningSetup) lastEmail(t *testing.T) string {
+	messages := s.local.Messages()
+	if !assert.NotEmpty(t, messages) {
+		return ""
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(messages[len(messages)-1].Data))
+	assert.NoError(t, err)
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestRemoteSigning(t *testing.T) {
+	s := newRemoteSigningSetup(t)
+	response := s.request(t, false)
+	assert.Regexp(t, `^https://sign\.example\.com/r/[A-Za-z0-9]{32}$`, response.SigningURL)
+	if assert.NotNil(t, response.SigningURLExpiresAt) {
+		assert.Equal(t, s.now.Add(72*time.Hour), *response.SigningURLExpiresAt)
+	}
+	link := strings.TrimPrefix(response.SigningURL, "https://sign.example.com")
+	signingPath := "/documents/sign/" + response.RequestID
+
+	// Only the hash of the link's token is stored
+	doc, _ := s.store.GetDocument(response.RequestID)
+	assert.Equal(t, models.HashRemoteSession(strings.TrimPrefix(link, "/r/")), doc.RemoteToken)
+
+	// Opening the link sends nothing
+	rr := s.serve(http.MethodGet, link, "", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.Contains(t, rr.Body.String(), "j•••@example.com")
+	assert.NotContains(t, rr.Body.String(), `name="code"`)
+	assert.Empty(t, s.local.Messages())
+
+	rr = s.serve(http.MethodPost, link, "code=123456", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Send a code first.")
+
+	rr = s.serve(http.MethodPost, link+"/code", "", nil)
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, link, rr.Header().Get("Location"))
+	code := regexp.MustCompile(`\b[0-9]{6}\b`).FindString(s.lastEmail(t))
+	assert.NotEmpty(t, code)
+
+	rr = s.serve(http.MethodPost, link+"/code", "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Wait a minute")
+	assert.Len(t, s.local.Messages(), 1)
+
+	rr = s.serve(http.MethodGet, link, "", nil)
+	assert.Contains(t, rr.Body.String(), `name="code"`)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "000001"
+	}
+	rr = s.serve(http.MethodPost, link, "code="+wrong, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "The code is not correct.")
+
+	// Without a session the signing page is for staff only
+	rr = s.serve(http.MethodGet, signingPath, "", nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Staff credentials required")
+
+	rr = s.serve(http.MethodPost, link, "code="+url.QueryEscape(code), nil)
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, signingPath, rr.Header().Get("Location"))
+	cookies := rr.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		return
+	}
+	session := cookies[0]
+	assert.Equal(t, remoteSessionCookie, session.Name)
+	assert.Equal(t, signingPath, session.Path)
+	assert.True(t, session.HttpOnly)
+	assert.True(t, session.Secure)
+
+	entries, err := s.audit.ListAuditEntries(response.RequestID)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.AuditActionSignerVerified, entries[0].Action)
+		assert.Equal(t, map[string]string{"method": "email_code", "channel": "remote", "attempts": "3"}, entries[0].Details)
+	}
+
+	// The link is used up and the session opens the signing page
+	rr = s.serve(http.MethodGet, link, "", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = s.serve(http.MethodGet, signingPath, "", session)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Agreement")
+
+	rr = s.serve(http.MethodGet, signingPath, "", &http.Cookie{Name: remoteSessionCookie, Value: "forged"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "Staff credentials required")
+
+	s.now = s.now.Add(models.RemoteSessionTTL)
+	rr = s.serve(http.MethodGet, signingPath, "", session)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRemoteSigning_Limits(t *testing.T) {
+	s := newRemoteSigningSetup(t)
+	link := strings.TrimPrefix(s.request(t, false).SigningURL, "https://sign.example.com")
+
+	for i := 0; i < models.OTPMaxSends; i++ {
+		rr := s.serve(http.MethodPost, link+"/code", "", nil)
+		assert.Equal(t, http.StatusSeeOther, rr.Code)
+		s.now = s.now.Add(models.OTPResendInterval)
+	}
+	code := regexp.MustCompile(`\b[0-9]{6}\b`).FindString(s.lastEmail(t))
+
+	rr := s.serve(http.MethodPost, link+"/code", "", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Contains(t, rr.Body.String(), "No more codes can be sent")
+
+	for i := 0; i < models.OTPMaxAttempts; i++ {
+		s.serve(http.MethodPost, link, "code=abcdef", nil)
+	}
+	rr = s.serve(http.MethodPost, link, "code="+code, nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Too many incorrect codes.")
+
+	s.now = s.now.Add(72 * time.Hour)
+	rr = s.serve(http.MethodGet, link, "", nil)
+	assert.Equal(t, http.StatusGone, rr.Code)
+}
+
+func TestRemoteSigning_EmailLink(t *testing.T) {
+	s := newRemoteSigningSetup(t)
+	response := s.request(t, true)
+
+	assert.Eventually(t, func() bool { return len(s.local.Messages()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	body := s.lastEmail(t)
+	assert.Contains(t, body, `sign "Agreement"`)
+	assert.Contains(t, body, response.SigningURL)
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/jakubsacha/signature-collector/models"
+)
+
+// RetentionReportHandler handles the retention-report endpoint. It performs a dry run of the
+// configured retention policy and returns the documents that would be purged.
+func RetentionReportHandler(w http.ResponseWriter, r *http.Request, job *models.RetentionJob) {
+	report, err := job.Run(true)
+	if err != nil {
+		log.Printf("Error running retention dry run: %v", err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
@@ -1,45 +1,333 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"time"
 
+	"github.com/jakubsacha/signature-collector/i18n"
 	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/pdf"
 )
 
+// maxDocumentPDFBytes bounds the size of a PDF document
+const maxDocumentPDFBytes = 20 << 20
+
+// maxAttachmentBytes bounds the size of a single attachment
+const maxAttachmentBytes = 10 << 20
+
+// maxAttachments bounds the number of attachments of a sign request
+const maxAttachments = 10
+
+// maxAttachmentsBytes bounds the total size of a sign request's attachments
+const maxAttachmentsBytes = 50 << 20
+
+// maxSignRequestBytes bounds the body of a sign request, leaving room for a base64-encoded PDF
+// and the attachments
+const maxSignRequestBytes = 2*maxDocumentPDFBytes + maxAttachmentsBytes
+
+// attachmentMediaTypes are the detected content types accepted for attachments, all of which
+// the tablet's browser can show
+var attachmentMediaTypes = map[string]bool{
+	"application/pdf": true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+}
+
 // SignRequest represents the request body for the sign-request endpoint
 type SignRequest struct {
 	DocumentContent []models.DocumentSection `json:"document_content"`
 	DocumentTitle   string                   `json:"document_title"`
-	SignerName      string                   `json:"signer_name"`
-	SignerEmail     string                   `json:"signer_email"`
-	DeviceID        string                   `json:"device_id"`
-	CallbackURL     string                   `json:"callback_url"`
+	// DocumentPDF is shown page by page above the sections. It is base64-encoded in JSON
+	// requests and the document_pdf file of multipart requests.
+	DocumentPDF     []byte                  `json:"document_pdf,omitempty"`
+	SignatureFields []models.SignatureField `json:"signature_fields,omitempty"`
+	SignerName      string                  `json:"signer_name"`
+	SignerEmail     string                  `json:"signer_email"`
+	DeviceID        string                  `json:"device_id"`
+	CallbackURL     string                  `json:"callback_url"`
+	TemplateID      string                  `json:"template_id"`
+	ClientID        string                  `json:"client_id"`
+	// Locale is the language the signer reads, as a BCP 47 tag such as en-GB, and Timezone the
+	// IANA time zone dates are shown in, such as Europe/Warsaw. They override the language and
+	// time zone of the tablet for this document.
+	Locale   string `json:"locale,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	// Review shows the signer a summary of the consents they gave and their signature to confirm
+	// before the signature is submitted
+	Review bool `json:"review,omitempty"`
+	// EmailCopy emails the signer their copy of the document once it is signed
+	EmailCopy bool `json:"email_copy,omitempty"`
+	// Remote issues a single-use link the signer opens to sign the document in their own
+	// browser, once they verify their email address with a code sent to it. The document needs
+	// no device. With EmailLink the link is emailed to the signer.
+	Remote    bool `json:"remote,omitempty"`
+	EmailLink bool `json:"email_link,omitempty"`
+	// Verification asks the signer to prove who they are before the signature pad unlocks.
+	// Remote sign requests are verified with email_code when it is not given.
+	Verification *VerificationRequest `json:"verification,omitempty"`
+
+	// attachments are the attachments files of a multipart request
+	attachments []models.Attachment
+}
+
+// VerificationRequest is the verification a sign request asks of the signer: their name, the
+// last characters of their ID number given in IDDigits, or a code emailed to them
+type VerificationRequest struct {
+	Method   string `json:"method"`
+	IDDigits string `json:"id_digits,omitempty"`
+}
+
+// missingFields returns a map of required field names that are empty in the request
+func (req SignRequest) missingFields() map[string]string {
+	missing := map[string]string{}
+	if req.SignerName == "" {
+		missing["signer_name"] = "is required"
+	}
+	if req.SignerEmail == "" {
+		missing["signer_email"] = "is required"
+	}
+	if req.DeviceID == "" && !req.Remote {
+		missing["device_id"] = "is required"
+	}
+	if req.CallbackURL == "" {
+		missing["callback_url"] = "is required"
+	}
+	return missing
+}
+
+// remoteErrors validates the remote signing options, which need remote signing to be enabled
+func (req SignRequest) remoteErrors(enabled bool) map[string]string {
+	invalid := map[string]string{}
+	if req.Remote && !enabled {
+		invalid["remote"] = "is not enabled on this server"
+	}
+	if req.EmailLink && !req.Remote {
+		invalid["email_link"] = "requires remote"
+	}
+	return invalid
+}
+
+// verificationErrors validates the requested verification. Email codes need email to be
+// configured.
+func (req SignRequest) verificationErrors(emailEnabled bool) map[string]string {
+	invalid := map[string]string{}
+	if req.Verification == nil {
+		return invalid
+	}
+	switch req.Verification.Method {
+	case models.VerificationIDDigits:
+		if !models.ValidIDDigits(req.Verification.IDDigits) {
+			invalid["verification.id_digits"] = fmt.Sprintf("must be %d letters or digits", models.IDDigitsLength)
+		}
+	case models.VerificationEmailCode:
+		if !emailEnabled {
+			invalid["verification.method"] = "email_code is not enabled on this server"
+		}
+	case models.VerificationName:
+	default:
+		invalid["verification.method"] = "must be one of name, id_digits or email_code"
+	}
+	if req.Verification.IDDigits != "" && req.Verification.Method != models.VerificationIDDigits {
+		invalid["verification.id_digits"] = "requires method id_digits"
+	}
+	return invalid
+}
+
+// localeErrors validates the requested language, which must be one the UI is translated into,
+// and time zone
+func (req SignRequest) localeErrors() map[string]string {
+	invalid := map[string]string{}
+	if req.Locale != "" {
+		if _, ok := i18n.Match(req.Locale); !ok {
+			invalid["locale"] = "is not supported"
+		}
+	}
+	if req.Timezone != "" {
+		if _, err := i18n.LoadTimezone(req.Timezone); err != nil {
+			invalid["timezone"] = "is not a known IANA time zone"
+		}
+	}
+	return invalid
+}
+
+// pdfErrors validates the PDF document and its signature fields. Every field must lie within
+// the media box of its page.
+func (req SignRequest) pdfErrors() map[string]string {
+	invalid := map[string]string{}
+	if req.DocumentPDF == nil {
+		if len(req.SignatureFields) > 0 {
+			invalid["signature_fields"] = "require document_pdf"
+		}
+		return invalid
+	}
+	if len(req.DocumentPDF) > maxDocumentPDFBytes {
+		invalid["document_pdf"] = fmt.Sprintf("must be at most %d MB", maxDocumentPDFBytes>>20)
+		return invalid
+	}
+	boxes, err := pdf.PageBoxes(req.DocumentPDF)
+	if errors.Is(err, pdf.ErrEncrypted) {
+		invalid["document_pdf"] = "must not be encrypted"
+		return invalid
+	}
+	if err != nil {
+		invalid["document_pdf"] = "is not a valid PDF"
+		return invalid
+	}
+	if len(req.SignatureFields) == 0 {
+		invalid["signature_fields"] = "are required with document_pdf"
+	}
+	for i, field := range req.SignatureFields {
+		key := fmt.Sprintf("signature_fields[%d]", i)
+		if field.Page < 1 || field.Page > len(boxes) {
+			invalid[key] = fmt.Sprintf("page must be between 1 and %d", len(boxes))
+			continue
+		}
+		if field.Width <= 0 || field.Height <= 0 {
+			invalid[key] = "must have a positive width and height"
+			continue
+		}
+		box := boxes[field.Page-1]
+		if field.X < box[0] || field.Y < box[1] || field.X+field.Width > box[2] || field.Y+field.Height > box[3] {
+			invalid[key] = "must lie within the page"
+		}
+	}
+	return invalid
+}
+
+// attachmentErrors validates the number, sizes and detected content types of the attachments
+func (req SignRequest) attachmentErrors() map[string]string {
+	invalid := map[string]string{}
+	if len(req.attachments) > maxAttachments {
+		invalid["attachments"] = fmt.Sprintf("must be at most %d files", maxAttachments)
+		return invalid
+	}
+	total := 0
+	for i, attachment := range req.attachments {
+		key := fmt.Sprintf("attachments[%d]", i)
+		total += attachment.Size
+		if attachment.Size == 0 {
+			invalid[key] = "must not be empty"
+		} else if attachment.Size > maxAttachmentBytes {
+			invalid[key] = fmt.Sprintf("must be at most %d MB", maxAttachmentBytes>>20)
+		} else if !attachmentMediaTypes[attachment.MediaType()] {
+			invalid[key] = fmt.Sprintf("has unsupported content type %s", attachment.MediaType())
+		}
+	}
+	if total > maxAttachmentsBytes {
+		invalid["attachments"] = fmt.Sprintf("must be at most %d MB in total", maxAttachmentsBytes>>20)
+	}
+	return invalid
+}
+
+// decodeSignRequest reads a JSON sign request, or a multipart form with the JSON in its request
+// field, the PDF as its document_pdf file and any number of attachments files
+func decodeSignRequest(w http.ResponseWriter, r *http.Request) (SignRequest, error) {
+	var req SignRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxSignRequestBytes)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return req, json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	if err := r.ParseMultipartForm(maxDocumentPDFBytes); err != nil {
+		return req, err
+	}
+	defer r.MultipartForm.RemoveAll()
+	if err := json.Unmarshal([]byte(r.FormValue("request")), &req); err != nil {
+		return req, err
+	}
+	for _, header := range r.MultipartForm.File["attachments"] {
+		data, err := readFormFile(header)
+		if err != nil {
+			return req, err
+		}
+		req.attachments = append(req.attachments, models.NewAttachment(header.Filename, data))
+	}
+	file, _, err := r.FormFile("document_pdf")
+	if errors.Is(err, http.ErrMissingFile) {
+		return req, nil
+	}
+	if err != nil {
+		return req, err
+	}
+	defer file.Close()
+	req.DocumentPDF, err = io.ReadAll(file)
+	return req, err
+}
+
+func readFormFile(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
 }
 
 // SignResponse represents the response body for the sign-request endpoint
 type SignResponse struct {
 	RequestID string `json:"request_id"`
 	Status    string `json:"status"`
+	// SigningURL is the link a remote signer signs the document at, until SigningURLExpiresAt
+	SigningURL          string     `json:"signing_url,omitempty"`
+	SigningURLExpiresAt *time.Time `json:"signing_url_expires_at,omitempty"`
 }
 
-// SignRequestHandler handles the sign-request endpoint
-func SignRequestHandler(w http.ResponseWriter, r *http.Request, store models.DocumentStore) {
+// SignRequestHandler handles the sign-request endpoint. Remote sign requests are issued their
+// link by remote, which is nil when remote signing is not enabled.
+func SignRequestHandler(w http.ResponseWriter, r *http.Request, store models.DocumentStore, remote *RemoteSigningHandler) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		WriteError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed", nil)
 		return
 	}
 
-	var req SignRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+	req, err := decodeSignRequest(w, r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			WriteError(w, r, http.StatusRequestEntityTooLarge, ErrCodeBadRequest, "Request body is too large", nil)
+			return
+		}
+		WriteError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Request body is not valid JSON", nil)
 		return
 	}
 
 	// Validate required fields
-	if req.SignerName == "" || req.SignerEmail == "" || req.DeviceID == "" || req.CallbackURL == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
+	if missing := req.missingFields(); len(missing) > 0 {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Missing required fields", missing)
+		return
+	}
+	if invalid := req.remoteErrors(remote != nil); len(invalid) > 0 {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid remote signing options", invalid)
+		return
+	}
+	// Email is configured whenever remote signing is enabled
+	if invalid := req.verificationErrors(remote != nil); len(invalid) > 0 {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid verification", invalid)
+		return
+	}
+	if invalid := req.localeErrors(); len(invalid) > 0 {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Unsupported locale or time zone", invalid)
+		return
+	}
+	if invalid := req.pdfErrors(); len(invalid) > 0 {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid PDF document", invalid)
+		return
+	}
+	if invalid := req.attachmentErrors(); len(invalid) > 0 {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid attachments", invalid)
 		return
 	}
 
@@ -51,13 +339,42 @@ func SignRequestHandler(w http.ResponseWriter, r *http.Request, store models.Doc
 		SignerEmail:     req.SignerEmail,
 		DeviceID:        req.DeviceID,
 		CallbackURL:     req.CallbackURL,
+		TemplateID:      req.TemplateID,
+		ClientID:        req.ClientID,
+		Attachments:     req.attachments,
+		Timezone:        req.Timezone,
+		Review:          req.Review,
+		EmailCopy:       req.EmailCopy,
+		Remote:          req.Remote,
 		Status:          "pending",
 	}
+	if req.Locale != "" {
+		doc.Locale, _ = i18n.Match(req.Locale)
+	}
+	if req.Verification != nil {
+		doc.VerificationMethod = req.Verification.Method
+	} else if req.Remote {
+		doc.VerificationMethod = models.VerificationEmailCode
+	}
+	if doc.VerificationMethod == models.VerificationIDDigits {
+		doc.VerificationSecret, err = models.HashIDDigits(req.Verification.IDDigits)
+		if err != nil {
+			log.Printf("Error hashing ID digits: %v", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+			return
+		}
+	}
+	if req.DocumentPDF != nil {
+		digest := sha256.Sum256(req.DocumentPDF)
+		doc.DocumentPDF = req.DocumentPDF
+		doc.DocumentPDFSHA256 = hex.EncodeToString(digest[:])
+		doc.SignatureFields = req.SignatureFields
+	}
 
 	requestID, err := store.AddDocument(doc)
 	if err != nil {
 		log.Printf("Error adding document: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
 		return
 	}
 
@@ -65,6 +382,17 @@ func SignRequestHandler(w http.ResponseWriter, r *http.Request, store models.Doc
 		RequestID: requestID,
 		Status:    "pending",
 	}
+	if req.Remote {
+		doc.ID = requestID
+		link, expiresAt, err := remote.IssueLink(r, doc, req.EmailLink)
+		if err != nil {
+			log.Printf("Error issuing remote signing link: %v", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+			return
+		}
+		response.SigningURL = link
+		response.SigningURLExpiresAt = &expiresAt
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/jakubsacha/signature-collector/i18n"
 	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/pdf"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,6 +46,7 @@ func TestSignRequestHandler(t *testing.T) {
 		body           interface{}
 		expectedStatus int
 		checkResponse  bool
+		expectedError  string
 	}{
 		{
 			name:   "Valid Request",
@@ -60,12 +66,14 @@ func TestSignRequestHandler(t *testing.T) {
 			method:         http.MethodGet,
 			body:           nil,
 			expectedStatus: http.StatusMethodNotAllowed,
+			expectedError:  ErrCodeMethodNotAllowed,
 		},
 		{
 			name:           "Invalid Body",
 			method:         http.MethodPost,
 			body:           "invalid body",
 			expectedStatus: http.StatusBadRequest,
+			expectedError:  ErrCodeBadRequest,
 		},
 		{
 			name:   "Missing Required Fields",
@@ -77,7 +85,77 @@ func TestSignRequestHandler(t *testing.T) {
 				DeviceID:        "test_device_id",
 				CallbackURL:     "https://client.example.com/callback",
 			},
-			expectedStatus: http.StatusBadRequest,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:   "Remote Signing Not Enabled",
+			method: http.MethodPost,
+			body: SignRequest{
+				DocumentContent: validDocumentContent,
+				SignerName:      "Test User",
+				SignerEmail:     "test@example.com",
+				CallbackURL:     "https://client.example.com/callback",
+				Remote:          true,
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:   "Email Link Without Remote",
+			method: http.MethodPost,
+			body: SignRequest{
+				DocumentContent: validDocumentContent,
+				SignerName:      "Test User",
+				SignerEmail:     "test@example.com",
+				DeviceID:        "test_device_id",
+				CallbackURL:     "https://client.example.com/callback",
+				EmailLink:       true,
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:   "Unknown Verification Method",
+			method: http.MethodPost,
+			body: SignRequest{
+				DocumentContent: validDocumentContent,
+				SignerName:      "Test User",
+				SignerEmail:     "test@example.com",
+				DeviceID:        "test_device_id",
+				CallbackURL:     "https://client.example.com/callback",
+				Verification:    &VerificationRequest{Method: "passport"},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:   "Invalid ID Digits",
+			method: http.MethodPost,
+			body: SignRequest{
+				DocumentContent: validDocumentContent,
+				SignerName:      "Test User",
+				SignerEmail:     "test@example.com",
+				DeviceID:        "test_device_id",
+				CallbackURL:     "https://client.example.com/callback",
+				Verification:    &VerificationRequest{Method: models.VerificationIDDigits, IDDigits: "48"},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
+		},
+		{
+			name:   "Email Code Not Enabled",
+			method: http.MethodPost,
+			body: SignRequest{
+				DocumentContent: validDocumentContent,
+				SignerName:      "Test User",
+				SignerEmail:     "test@example.com",
+				DeviceID:        "test_device_id",
+				CallbackURL:     "https://client.example.com/callback",
+				Verification:    &VerificationRequest{Method: models.VerificationEmailCode},
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrCodeValidation,
 		},
 	}
 
@@ -91,7 +169,7 @@ func TestSignRequestHandler(t *testing.T) {
 			req := httptest.NewRequest(tt.method, "/api/documents/sign-request", bytes.NewReader(body))
 			w := httptest.NewRecorder()
 
-			SignRequestHandler(w, req, store)
+			SignRequestHandler(w, req, store, nil)
 
 			assert.Equal(t, tt.expectedStatus, w.Code)
 
@@ -102,6 +180,13 @@ func TestSignRequestHandler(t *testing.T) {
 				assert.NotEmpty(t, response.RequestID)
 				assert.Equal(t, "pending", response.Status)
 			}
+
+			if tt.expectedError != "" {
+				var response ErrorResponse
+				err := json.NewDecoder(w.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedError, response.Code)
+			}
 		})
 	}
 }
@@ -109,3 +194,301 @@ func TestSignRequestHandler(t *testing.T) {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func testDocumentPDF(t *testing.T) []byte {
+	t.Helper()
+	data, _, err := pdf.Render(pdf.Document{Title: "Contract", Sections: []pdf.Section{{Text: "Terms"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSignRequestHandler_PDF(t *testing.T) {
+	documentPDF := testDocumentPDF(t)
+	digest := sha256.Sum256(documentPDF)
+	field := models.SignatureField{Page: 1, X: 56, Y: 100, Width: 200, Height: 80}
+
+	validRequest := func() SignRequest {
+		return SignRequest{
+			DocumentTitle:   "Contract",
+			DocumentPDF:     documentPDF,
+			SignatureFields: []models.SignatureField{field},
+			SignerName:      "Test User",
+			SignerEmail:     "test@example.com",
+			DeviceID:        "test_device_id",
+			CallbackURL:     "https://client.example.com/callback",
+		}
+	}
+	jsonBody := func(req SignRequest) (string, []byte) {
+		body, _ := json.Marshal(req)
+		return "application/json", body
+	}
+	multipartBody := func(req SignRequest) (string, []byte) {
+		document := req.DocumentPDF
+		req.DocumentPDF = nil
+		fields, _ := json.Marshal(req)
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("request", string(fields))
+		part, _ := mw.CreateFormFile("document_pdf", "contract.pdf")
+		part.Write(document)
+		mw.Close()
+		return mw.FormDataContentType(), body.Bytes()
+	}
+
+	tests := []struct {
+		name            string
+		request         func() SignRequest
+		encode          func(SignRequest) (string, []byte)
+		expectedStatus  int
+		expectedDetails map[string]string
+	}{
+		{
+			name:           "Base64 in JSON",
+			request:        validRequest,
+			encode:         jsonBody,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Multipart upload",
+			request:        validRequest,
+			encode:         multipartBody,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Not a PDF",
+			request: func() SignRequest {
+				req := validRequest()
+				req.DocumentPDF = []byte("hello")
+				return req
+			},
+			encode:          multipartBody,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedDetails: map[string]string{"document_pdf": "is not a valid PDF"},
+		},
+		{
+			name: "No signature fields",
+			request: func() SignRequest {
+				req := validRequest()
+				req.SignatureFields = nil
+				return req
+			},
+			encode:          jsonBody,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedDetails: map[string]string{"signature_fields": "are required with document_pdf"},
+		},
+		{
+			name: "Invalid signature fields",
+			request: func() SignRequest {
+				req := validRequest()
+				req.SignatureFields = []models.SignatureField{
+					field,
+					{Page: 2, X: 56, Y: 100, Width: 200, Height: 80},
+					{Page: 1, X: 500, Y: 100, Width: 200, Height: 80},
+					{Page: 1, X: 56, Y: 100, Width: 0, Height: 80},
+				}
+				return req
+			},
+			encode:         jsonBody,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedDetails: map[string]string{
+				"signature_fields[1]": "page must be between 1 and 1",
+				"signature_fields[2]": "must lie within the page",
+				"signature_fields[3]": "must have a positive width and height",
+			},
+		},
+		{
+			name: "Signature fields without a PDF",
+			request: func() SignRequest {
+				req := validRequest()
+				req.DocumentPDF = nil
+				return req
+			},
+			encode:          jsonBody,
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedDetails: map[string]string{"signature_fields": "require document_pdf"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := models.NewInMemoryDocumentStore()
+			contentType, body := tt.encode(tt.request())
+			req := httptest.NewRequest(http.MethodPost, "/api/documents/sign-request", bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			SignRequestHandler(w, req, store, nil)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				var response ErrorResponse
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				assert.Equal(t, ErrCodeValidation, response.Code)
+				assert.Equal(t, tt.expectedDetails, response.Details)
+				return
+			}
+
+			var response SignResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			doc, err := store.GetDocument(response.RequestID)
+			assert.NoError(t, err)
+			assert.True(t, doc.IsPDF())
+			assert.Equal(t, hex.EncodeToString(digest[:]), doc.DocumentPDFSHA256)
+			assert.Equal(t, []models.SignatureField{field}, doc.SignatureFields)
+			stored, err := store.GetDocumentPDF(response.RequestID)
+			assert.NoError(t, err)
+			assert.Equal(t, documentPDF, stored)
+		})
+	}
+}
+
+func TestSignRequestHandler_Attachments(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	text := []byte("Price list\n")
+
+	type file struct {
+		name string
+		data []byte
+	}
+	tests := []struct {
+		name            string
+		files           []file
+		expectedStatus  int
+		expectedDetails map[string]string
+	}{
+		{
+			name:           "Image and text",
+			files:          []file{{"photo.png", png}, {`C:\Users\jan\prices.txt`, text}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:            "Too many files",
+			files:           []file{{"1.txt", text}, {"2.txt", text}, {"3.txt", text}, {"4.txt", text}, {"5.txt", text}, {"6.txt", text}, {"7.txt", text}, {"8.txt", text}, {"9.txt", text}, {"10.txt", text}, {"11.txt", text}},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedDetails: map[string]string{"attachments": "must be at most 10 files"},
+		},
+		{
+			name:           "Empty and unsupported files",
+			files:          []file{{"photo.png", png}, {"empty.txt", nil}, {"archive.zip", []byte("PK\x03\x04\x14\x00\x00\x00")}},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedDetails: map[string]string{
+				"attachments[1]": "must not be empty",
+				"attachments[2]": "has unsupported content type application/zip",
+			},
+		},
+		{
+			name:            "Too large",
+			files:           []file{{"large.txt", bytes.Repeat([]byte("a"), maxAttachmentBytes+1)}},
+			expectedStatus:  http.StatusUnprocessableEntity,
+			expectedDetails: map[string]string{"attachments[0]": "must be at most 10 MB"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, _ := json.Marshal(SignRequest{
+				DocumentTitle: "Order",
+				SignerName:    "Test User",
+				SignerEmail:   "test@example.com",
+				DeviceID:      "test_device_id",
+				CallbackURL:   "https://client.example.com/callback",
+			})
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			mw.WriteField("request", string(fields))
+			for _, f := range tt.files {
+				part, _ := mw.CreateFormFile("attachments", f.name)
+				part.Write(f.data)
+			}
+			mw.Close()
+
+			store := models.NewInMemoryDocumentStore()
+			req := httptest.NewRequest(http.MethodPost, "/api/documents/sign-request", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			w := httptest.NewRecorder()
+
+			SignRequestHandler(w, req, store, nil)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				var response ErrorResponse
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				assert.Equal(t, ErrCodeValidation, response.Code)
+				assert.Equal(t, tt.expectedDetails, response.Details)
+				return
+			}
+
+			var response SignResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			doc, err := store.GetDocument(response.RequestID)
+			assert.NoError(t, err)
+			if assert.Len(t, doc.Attachments, 2) {
+				assert.Equal(t, "photo.png", doc.Attachments[0].Filename)
+				assert.Equal(t, "image/png", doc.Attachments[0].ContentType)
+				assert.Equal(t, "prices.txt", doc.Attachments[1].Filename)
+				assert.Equal(t, "text/plain; charset=utf-8", doc.Attachments[1].ContentType)
+				assert.Equal(t, len(text), doc.Attachments[1].Size)
+
+				attachment, err := store.GetAttachment(response.RequestID, doc.Attachments[1].ID)
+				assert.NoError(t, err)
+				assert.Equal(t, text, attachment.Data)
+			}
+		})
+	}
+}
+
+func TestSignRequestHandler_Locale(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+
+	tests := []struct {
+		name             string
+		locale           string
+		timezone         string
+		expectedStatus   int
+		expectedLocale   string
+		expectedTimezone string
+		invalidField     string
+	}{
+		{name: "No locale", expectedStatus: http.StatusOK},
+		{name: "Supported language", locale: "pl", expectedStatus: http.StatusOK, expectedLocale: "pl"},
+		{name: "Regional variant", locale: "en-gb", expectedStatus: http.StatusOK, expectedLocale: "en-GB"},
+		{name: "Unsupported language", locale: "ja", expectedStatus: http.StatusUnprocessableEntity, invalidField: "locale"},
+		{name: "Malformed tag", locale: "not a language", expectedStatus: http.StatusUnprocessableEntity, invalidField: "locale"},
+		{name: "Time zone", timezone: "Europe/Warsaw", expectedStatus: http.StatusOK, expectedTimezone: "Europe/Warsaw"},
+		{name: "Unknown time zone", timezone: "Mars/Olympus_Mons", expectedStatus: http.StatusUnprocessableEntity, invalidField: "timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := models.NewInMemoryDocumentStore()
+			body, _ := json.Marshal(SignRequest{
+				DocumentTitle: "Terms",
+				SignerName:    "Test User",
+				SignerEmail:   "test@example.com",
+				DeviceID:      "test_device_id",
+				CallbackURL:   "https://client.example.com/callback",
+				Locale:        tt.locale,
+				Timezone:      tt.timezone,
+			})
+			w := httptest.NewRecorder()
+			SignRequestHandler(w, httptest.NewRequest(http.MethodPost, "/api/documents/signatures/request", bytes.NewReader(body)), store, nil)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus != http.StatusOK {
+				var response ErrorResponse
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				assert.Equal(t, ErrCodeValidation, response.Code)
+				assert.Contains(t, response.Details, tt.invalidField)
+				return
+			}
+			var response SignResponse
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			doc, err := store.GetDocument(response.RequestID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedLocale, doc.Locale)
+			assert.Equal(t, tt.expectedTimezone, doc.Timezone)
+		})
+	}
+}
@@ -1,32 +1,151 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
 	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/qr"
+	"github.com/jakubsacha/signature-collector/render"
 	"github.com/jakubsacha/signature-collector/templates"
+	"github.com/jakubsacha/signature-collector/tsa"
 )
 
+// maxSignatureRequestBytes bounds the body of a signature submission, leaving room for the
+// base64-encoded image, its stroke data and the consents
+const maxSignatureRequestBytes = 4 * render.MaxSignatureBytes
+
+// maxSubmissionIDLength bounds the ID a tablet gives a signature submission
+const maxSubmissionIDLength = 64
+
+// Signer copy modes: after signing, the signer is shown a link to download their copy of the
+// document, on its own or with a QR code of it to scan with a phone
+const (
+	SignerCopyLink = "link"
+	SignerCopyQR   = "qr"
+)
+
+// maxClockSkew is how far ahead of the service's clock a tablet's may be when it reports when a
+// signature was captured
+const maxClockSkew = 5 * time.Minute
+
 type SignatureRequest struct {
-	SignatureData string           `json:"signature_data"`
-	Consents      []models.Consent `json:"consents"`
+	SignatureData    string                   `json:"signature_data"`
+	SignatureStrokes *models.SignatureStrokes `json:"signature_strokes"`
+	Consents         []models.Consent         `json:"consents"`
+	// SubmissionID identifies the submission, so that a tablet replaying it after a lost
+	// connection is answered as the first time instead of with a conflict
+	SubmissionID string `json:"submission_id,omitempty"`
+	// CapturedAt is when the signer submitted the signature on the tablet, which is earlier than
+	// when the service receives it for a submission queued while offline
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+}
+
+// submissionErrors validates the submission ID and the capture time, which can be neither in
+// the future nor before the document was created
+func (req SignatureRequest) submissionErrors(doc models.Document, now time.Time) map[string]string {
+	invalid := map[string]string{}
+	if len(req.SubmissionID) > maxSubmissionIDLength {
+		invalid["submission_id"] = fmt.Sprintf("must be at most %d characters", maxSubmissionIDLength)
+	}
+	if req.CapturedAt != nil {
+		if req.CapturedAt.After(now.Add(maxClockSkew)) {
+			invalid["captured_at"] = "is in the future"
+		} else if req.CapturedAt.Before(doc.CreatedAt.Add(-maxClockSkew)) {
+			invalid["captured_at"] = "is before the document was created"
+		}
+	}
+	return invalid
 }
 
 type SignatureResponse struct {
 	Status            string `json:"status"`
 	ConsentsProcessed bool   `json:"consents_processed"`
 	DeviceID          string `json:"device_id"`
+	// CopyURL is the link the signer downloads their copy of the document from, and CopyQRURL
+	// an image of its QR code, when the service offers signers a copy
+	CopyURL   string `json:"copy_url,omitempty"`
+	CopyQRURL string `json:"copy_qr_url,omitempty"`
 }
 
 type SignatureHandler struct {
-	store models.DocumentStore
+	store           models.DocumentStore
+	ledger          models.ConsentLedger
+	sealer          *models.Sealer
+	timestamper     tsa.Timestamper
+	publicURL       string
+	inlineSignature bool
+	signerCopy      string
+	signerCopyTTL   time.Duration
+	mailer          *SignerMailer
+	audit           models.AuditLog
+	timeNow         func() time.Time
 }
 
 func NewSignatureHandler(store models.DocumentStore) *SignatureHandler {
-	return &SignatureHandler{store: store}
+	return &SignatureHandler{store: store, timeNow: time.Now}
+}
+
+// WithConsentLedger records every consent captured with a signature in the given ledger
+func (h *SignatureHandler) WithConsentLedger(ledger models.ConsentLedger) *SignatureHandler {
+	h.ledger = ledger
+	return h
+}
+
+// WithSealer seals the integrity hash of every completed document with the service's key
+func (h *SignatureHandler) WithSealer(sealer *models.Sealer) *SignatureHandler {
+	h.sealer = sealer
+	return h
+}
+
+// WithTimestamper obtains an RFC 3161 timestamp over the integrity hash of every completed document
+func (h *SignatureHandler) WithTimestamper(timestamper tsa.Timestamper) *SignatureHandler {
+	h.timestamper = timestamper
+	return h
+}
+
+// WithPublicURL sets the public URL of this service, used to link callbacks to the signature image
+func (h *SignatureHandler) WithPublicURL(publicURL string) *SignatureHandler {
+	h.publicURL = publicURL
+	return h
+}
+
+// WithInlineSignature embeds the signature data URL in callbacks in addition to its URL
+func (h *SignatureHandler) WithInlineSignature(inline bool) *SignatureHandler {
+	h.inlineSignature = inline
+	return h
+}
+
+// WithSignerCopy offers the signer a link to download their copy of the document once it is
+// signed, valid for ttl. The mode is SignerCopyLink to show the link or SignerCopyQR to show a
+// QR code of it too; an empty mode offers no copy.
+func (h *SignatureHandler) WithSignerCopy(mode string, ttl time.Duration) *SignatureHandler {
+	h.signerCopy = mode
+	h.signerCopyTTL = ttl
+	return h
+}
+
+// WithSignerMailer emails signers their copy of the document once it is signed, when the sign
+// request asks for it. Links in emails are valid for the signer copy's ttl.
+func (h *SignatureHandler) WithSignerMailer(mailer *SignerMailer) *SignatureHandler {
+	h.mailer = mailer
+	return h
+}
+
+// WithAuditLog records in audit whether signers proved who they are, for sign requests asking for
+// verification
+func (h *SignatureHandler) WithAuditLog(audit models.AuditLog) *SignatureHandler {
+	h.audit = audit
+	return h
 }
 
 // ShowSignaturePage handles GET /documents/sign/{request_id}
@@ -56,9 +175,88 @@ func (h *SignatureHandler) ShowSignaturePage(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Render the signature page
-	component := templates.Layout(templates.SignaturePage(doc, requestID))
-	component.Render(r.Context(), w)
+	// The signer proves who they are before the signature pad is shown
+	if doc.VerificationRequired() {
+		status := http.StatusOK
+		if doc.VerificationOutcome == models.VerificationFailed {
+			status = http.StatusForbidden
+		}
+		h.renderVerification(w, r, status, doc, "")
+		return
+	}
+
+	// Render the signature page in the signer's language and time zone, if the request set them
+	ctx := i18n.WithTimezone(i18n.WithLanguage(r.Context(), doc.Locale), doc.Timezone)
+	component := templates.Layout(templates.SignaturePage(doc, requestID, h.timeNow()))
+	component.Render(ctx, w)
+}
+
+// ServeDocumentPDF handles GET /documents/sign/{request_id}/pdf, serving the original of a
+// pending PDF document to the signature page
+func (h *SignatureHandler) ServeDocumentPDF(w http.ResponseWriter, r *http.Request) {
+	requestID := mux.Vars(r)["request_id"]
+
+	doc, err := h.store.GetDocument(requestID)
+	if err != nil {
+		log.Printf("Error getting document: %v", err)
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if doc.Status != models.StatusPending {
+		http.Error(w, "Document already signed", http.StatusBadRequest)
+		return
+	}
+	data, err := h.store.GetDocumentPDF(requestID)
+	if err != nil {
+		log.Printf("Error getting document PDF: %v", err)
+		http.Error(w, "Error getting document PDF", http.StatusInternalServerError)
+		return
+	}
+	if data == nil {
+		http.Error(w, "Document has no PDF", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// ServeAttachment handles GET /documents/sign/{request_id}/attachments/{attachment_id}, serving
+// an attachment of a pending document to the signature page
+func (h *SignatureHandler) ServeAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	requestID := vars["request_id"]
+
+	doc, err := h.store.GetDocument(requestID)
+	if err != nil {
+		log.Printf("Error getting document: %v", err)
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if doc.Status != models.StatusPending {
+		http.Error(w, "Document already signed", http.StatusBadRequest)
+		return
+	}
+	attachment, err := h.store.GetAttachment(requestID, vars["attachment_id"])
+	if errors.Is(err, models.ErrAttachmentNotFound) {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting attachment: %v", err)
+		http.Error(w, "Error getting attachment", http.StatusInternalServerError)
+		return
+	}
+
+	// The content type was detected on upload; nosniff keeps the browser from guessing another
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", attachment.Filename))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(attachment.Data)))
+	w.Write(attachment.Data)
 }
 
 // ProcessSignature handles POST /documents/sign/{request_id}
@@ -67,16 +265,71 @@ func (h *SignatureHandler) ProcessSignature(w http.ResponseWriter, r *http.Reque
 	requestID := vars["request_id"]
 
 	var req SignatureRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSignatureRequestBytes)).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			WriteError(w, r, http.StatusRequestEntityTooLarge, ErrCodeInvalidSignature, "Signature submission is too large", map[string]string{
+				"reason": render.ReasonTooLarge,
+			})
+			return
+		}
+		WriteError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Invalid request body", nil)
 		return
 	}
 
 	// Get document to verify consents
 	doc, err := h.store.GetDocument(requestID)
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Document not found", nil)
+		return
+	}
 	if err != nil {
 		log.Printf("Error getting document: %v", err)
-		http.Error(w, "Document not found", http.StatusNotFound)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error getting document", nil)
+		return
+	}
+
+	// A submission replayed after its response was lost gets the response it was first given,
+	// except for the copy link, of which only the token's hash is stored. One that completed the
+	// document but failed before it was stored in full is stored again.
+	resumed := doc.Status == models.StatusCompleted && req.SubmissionID != "" && req.SubmissionID == doc.SubmissionID
+	if resumed && doc.CompletedAt != nil {
+		log.Printf("Signature submission %s for document %s was already received", req.SubmissionID, requestID)
+		h.writeSignatureResponse(w, r, doc, "")
+		return
+	}
+
+	if doc.Status != models.StatusPending && !resumed {
+		WriteError(w, r, http.StatusConflict, ErrCodeConflict, "Document is no longer pending", map[string]string{
+			"status": doc.Status,
+		})
+		return
+	}
+
+	if doc.VerificationRequired() {
+		WriteError(w, r, http.StatusForbidden, ErrCodeVerificationRequired, "Signer has not been verified", map[string]string{
+			"method": doc.VerificationMethod,
+		})
+		return
+	}
+
+	if invalid := req.submissionErrors(doc, h.timeNow()); len(invalid) > 0 {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid submission", invalid)
+		return
+	}
+
+	// The browser refuses to submit an empty pad, but the data can be posted directly
+	signature, err := render.ValidateSignature(req.SignatureData)
+	if err != nil {
+		var signatureErr *render.SignatureError
+		if !errors.As(err, &signatureErr) {
+			log.Printf("Error validating signature: %v", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error validating signature", nil)
+			return
+		}
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeInvalidSignature, "Invalid signature: "+signatureErr.Message, map[string]string{
+			"reason": signatureErr.Reason,
+		})
 		return
 	}
 
@@ -87,7 +340,9 @@ func (h *SignatureHandler) ProcessSignature(w http.ResponseWriter, r *http.Reque
 			for _, consent := range req.Consents {
 				if consent.ConsentType == *section.ConsentType {
 					if !consent.Granted {
-						http.Error(w, "Mandatory consent not granted", http.StatusBadRequest)
+						WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Mandatory consent not granted", map[string]string{
+							"consent_type": consent.ConsentType,
+						})
 						return
 					}
 					consentFound = true
@@ -95,39 +350,120 @@ func (h *SignatureHandler) ProcessSignature(w http.ResponseWriter, r *http.Reque
 				}
 			}
 			if !consentFound {
-				http.Error(w, "Missing mandatory consent", http.StatusBadRequest)
+				WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Missing mandatory consent", map[string]string{
+					"consent_type": *section.ConsentType,
+				})
 				return
 			}
 		}
 	}
 
+	if req.SignatureStrokes != nil {
+		if err := req.SignatureStrokes.Validate(); err != nil {
+			WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid signature strokes", map[string]string{
+				"signature_strokes": err.Error(),
+			})
+			return
+		}
+		if req.SignatureStrokes.IsTrivial() {
+			WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeInvalidSignature, "Invalid signature: too few strokes to be a signature", map[string]string{
+				"reason": render.ReasonInsufficientStrokes,
+			})
+			return
+		}
+	}
+
+	// Store the signature normalised: trimmed, on a transparent background and size-limited
+	normalized, err := render.Normalize(signature)
+	if err == nil {
+		req.SignatureData, err = render.PNGDataURL(normalized)
+	}
+	if err != nil {
+		log.Printf("Error normalising signature for %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error storing signature", nil)
+		return
+	}
+
+	// Complete the document, with when the signature was captured on the tablet, unless another
+	// submission completed it since it was read
+	if !resumed {
+		var capturedAt *time.Time
+		if req.CapturedAt != nil {
+			captured := req.CapturedAt.UTC().Truncate(time.Second)
+			capturedAt = &captured
+		}
+		completed, err := h.store.CompleteSubmission(requestID, req.SubmissionID, capturedAt)
+		if err != nil {
+			log.Printf("Error updating document status: %v", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error updating document status", nil)
+			return
+		}
+		if !completed {
+			h.writeCompletedElsewhere(w, r, requestID, req.SubmissionID)
+			return
+		}
+	}
+
 	// Store signature data and update document status
 	if err := h.store.UpdateDocumentSignature(requestID, req.SignatureData); err != nil {
 		log.Printf("Error storing signature: %v", err)
-		http.Error(w, "Error storing signature", http.StatusInternalServerError)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error storing signature", nil)
 		return
 	}
 
-	// Update document status
-	if err := h.store.UpdateDocumentStatus(requestID, "completed"); err != nil {
-		log.Printf("Error updating document status: %v", err)
-		http.Error(w, "Error updating document status", http.StatusInternalServerError)
-		return
+	// Store the vector stroke data alongside the image
+	if req.SignatureStrokes != nil {
+		if err := h.store.StoreSignatureStrokes(requestID, *req.SignatureStrokes); err != nil {
+			log.Printf("Error storing signature strokes: %v", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error storing signature", nil)
+			return
+		}
 	}
 
 	// Store consents
 	if err := h.store.StoreConsents(requestID, req.Consents); err != nil {
 		log.Printf("Error storing consents: %v", err)
-		http.Error(w, "Error storing consents", http.StatusInternalServerError)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error storing consents", nil)
+		return
+	}
+
+	// Record the time zone the signer saw dates in, so that the callback and the PDF use it too
+	if doc.Timezone == "" {
+		if err := h.store.StoreTimezone(requestID, i18n.Timezone(r.Context()).String()); err != nil {
+			log.Printf("Error storing time zone: %v", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error storing signature", nil)
+			return
+		}
+	}
+
+	// Bind what was shown, who signed and the consents given to an integrity hash of the stored record
+	completed, err := h.completeDocument(r.Context(), requestID)
+	if err != nil {
+		log.Printf("Error recording completion of %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error storing signature", nil)
 		return
 	}
 
+	// Record consents in the ledger
+	if h.ledger != nil {
+		events := models.ConsentEventsFromSignature(doc, req.Consents, h.timeNow().UTC())
+		if err := h.ledger.RecordConsentEvents(events); err != nil {
+			log.Printf("Error recording consent events: %v", err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error storing consents", nil)
+			return
+		}
+	}
+
 	// Send callback if configured
 	if doc.CallbackURL != "" {
 		go func() {
 			log.Printf("Sending callback for document %s", requestID)
-			var callbackSender = models.NewCallbackSender()
-			if err := callbackSender.SendCallback(doc, req.SignatureData, req.Consents); err != nil {
+			var callbackSender = models.NewCallbackSender().WithBaseURL(h.publicURL)
+			signatureData := ""
+			if h.inlineSignature {
+				signatureData = req.SignatureData
+			}
+			if err := callbackSender.SendCallback(completed, signatureData, req.Consents); err != nil {
 				// Log the error but don't fail the request
 				log.Printf("Error sending callback for document %s: %v", requestID, err)
 			}
@@ -136,12 +472,198 @@ func (h *SignatureHandler) ProcessSignature(w http.ResponseWriter, r *http.Reque
 		log.Printf("No callback URL configured for document %s", requestID)
 	}
 
+	// The signature is stored whether or not the signer can be offered a copy
+	emailLink := completed.EmailCopy && h.mailer != nil && h.mailer.mode == SignerEmailLink
+	var copyToken string
+	if h.signerCopy != "" || emailLink {
+		if copyToken, err = h.createCopyLink(&completed); err != nil {
+			log.Printf("Error creating copy link for document %s: %v", requestID, err)
+		}
+	}
+	if completed.EmailCopy {
+		h.emailCopy(r, completed, copyToken)
+	}
+
+	h.writeSignatureResponse(w, r, completed, copyToken)
+}
+
+// writeCompletedElsewhere answers a submission for a document another request completed while
+// it was being processed: a copy of the submission that did gets its response, any other a conflict
+func (h *SignatureHandler) writeCompletedElsewhere(w http.ResponseWriter, r *http.Request, requestID, submissionID string) {
+	doc, err := h.store.GetDocument(requestID)
+	if err != nil {
+		log.Printf("Error getting document: %v", err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Error getting document", nil)
+		return
+	}
+	if submissionID != "" && submissionID == doc.SubmissionID {
+		log.Printf("Signature submission %s for document %s was already received", submissionID, requestID)
+		h.writeSignatureResponse(w, r, doc, "")
+		return
+	}
+	WriteError(w, r, http.StatusConflict, ErrCodeConflict, "Document is no longer pending", map[string]string{
+		"status": doc.Status,
+	})
+}
+
+// emailCopy sends the signer their copy of a completed document in the background, with the
+// copy link of the given token in link mode, recording the delivery as pending until it is sent
+// or fails
+func (h *SignatureHandler) emailCopy(r *http.Request, doc models.Document, copyToken string) {
+	if h.mailer == nil {
+		log.Printf("Document %s asks for an email copy, but email is not configured", doc.ID)
+		if err := h.store.StoreEmailStatus(doc.ID, models.EmailFailed, h.timeNow().UTC(), "email is not configured"); err != nil {
+			log.Printf("Error storing email status for document %s: %v", doc.ID, err)
+		}
+		return
+	}
+	if err := h.store.StoreEmailStatus(doc.ID, models.EmailPending, h.timeNow().UTC(), ""); err != nil {
+		log.Printf("Error storing email status for document %s: %v", doc.ID, err)
+	}
+	ctx := context.WithoutCancel(r.Context())
+	var copyURL string
+	if copyToken != "" {
+		copyURL = models.CopyURL(h.baseURL(r), copyToken)
+	}
+	go func() {
+		if err := h.mailer.SendCopy(ctx, doc, copyURL); err != nil {
+			log.Printf("Error emailing copy of document %s: %v", doc.ID, err)
+		}
+	}()
+}
+
+// createCopyLink gives a completed document a new link for the signer to download their copy and
+// returns its token. Only the token's hash is stored.
+func (h *SignatureHandler) createCopyLink(doc *models.Document) (string, error) {
+	token, err := models.NewCopyToken()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := h.timeNow().UTC().Add(h.signerCopyTTL).Truncate(time.Second)
+	tokenHash := models.HashCopyToken(token)
+	if err := h.store.StoreCopyLink(doc.ID, tokenHash, expiresAt); err != nil {
+		return "", err
+	}
+	doc.CopyToken = tokenHash
+	doc.CopyExpiresAt = &expiresAt
+	return token, nil
+}
+
+// writeSignatureResponse answers a completed submission, with the copy link of the given token
+// when the signer is offered one
+func (h *SignatureHandler) writeSignatureResponse(w http.ResponseWriter, r *http.Request, doc models.Document, copyToken string) {
 	response := SignatureResponse{
 		Status:            "completed",
 		ConsentsProcessed: true,
 		DeviceID:          doc.DeviceID,
 	}
+	if h.signerCopy != "" && copyToken != "" && !doc.CopyLinkExpired(h.timeNow()) {
+		response.CopyURL = models.CopyURL(h.baseURL(r), copyToken)
+		if h.signerCopy == SignerCopyQR {
+			response.CopyQRURL = "/documents/sign/" + url.PathEscape(doc.ID) + "/copy.svg?token=" + url.QueryEscape(copyToken)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// baseURL returns the public URL of this service or, when none is configured, the URL the
+// tablet reached it at
+func (h *SignatureHandler) baseURL(r *http.Request) string {
+	return requestBaseURL(r, h.publicURL)
+}
+
+// requestBaseURL returns publicURL or, when it is empty, the URL r reached this service at
+func requestBaseURL(r *http.Request, publicURL string) string {
+	if publicURL != "" {
+		return publicURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// ServeCopyQR handles GET /documents/sign/{request_id}/copy.svg?token={token}, serving the QR code
+// of the link the signer of a completed document downloads their copy from. The token is passed in
+// as only its hash is stored.
+func (h *SignatureHandler) ServeCopyQR(w http.ResponseWriter, r *http.Request) {
+	requestID := mux.Vars(r)["request_id"]
+	token := r.URL.Query().Get("token")
+
+	doc, err := h.store.GetDocument(requestID)
+	if err != nil {
+		log.Printf("Error getting document: %v", err)
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if h.signerCopy != SignerCopyQR || !doc.HasCopyToken(token) || doc.CopyLinkExpired(h.timeNow()) {
+		http.Error(w, "Document has no copy link", http.StatusNotFound)
+		return
+	}
+	code, err := qr.Encode(models.CopyURL(h.baseURL(r), token), qr.M)
+	if err != nil {
+		log.Printf("Error encoding copy link of document %s: %v", requestID, err)
+		http.Error(w, "Error encoding copy link", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write([]byte(code.SVG()))
+}
+
+// completeDocument stamps a signed document with its completion time and the integrity hash of
+// its record as stored, so the hash can later be recomputed from the same data, then seals and
+// timestamps the hash
+func (h *SignatureHandler) completeDocument(ctx context.Context, requestID string) (models.Document, error) {
+	doc, err := h.store.GetDocument(requestID)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if doc.SignatureData, err = h.store.GetSignatureData(requestID); err != nil {
+		return models.Document{}, err
+	}
+	completedAt := h.timeNow().UTC().Truncate(time.Second)
+	doc.CompletedAt = &completedAt
+
+	doc.IntegrityHash, err = models.IntegrityHash(doc)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if h.sealer != nil {
+		seal := h.sealer.Seal(doc.IntegrityHash)
+		doc.Seal = &seal
+	}
+	if err := h.store.StoreCompletion(requestID, completedAt, doc.IntegrityHash, doc.Seal); err != nil {
+		return models.Document{}, err
+	}
+
+	// An unreachable timestamp authority must not lose the signature; the document is completed
+	// without a timestamp instead
+	if h.timestamper != nil {
+		timestamp, err := h.timestamp(ctx, doc.IntegrityHash)
+		if err != nil {
+			log.Printf("Error timestamping document %s: %v", requestID, err)
+		} else if err := h.store.StoreTimestamp(requestID, *timestamp); err != nil {
+			log.Printf("Error storing timestamp of document %s: %v", requestID, err)
+		} else {
+			doc.Timestamp = timestamp
+		}
+	}
+	return doc, nil
+}
+
+func (h *SignatureHandler) timestamp(ctx context.Context, integrityHash string) (*models.Timestamp, error) {
+	digest, err := models.TimestampDigest(integrityHash)
+	if err != nil {
+		return nil, err
+	}
+	token, err := h.timestamper.Timestamp(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewTimestamp(token)
+}
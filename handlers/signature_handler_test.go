@@ -0,0 +1,772 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/notify"
+	"github.com/jakubsacha/signature-collector/render"
+	"github.com/jakubsacha/signature-collector/tsa"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessSignature_Validation(t *testing.T) {
+	signature := models.SignatureStroke{}
+	for i := 0; i < 20; i++ {
+		signature.Points = append(signature.Points, models.SignaturePoint{X: float64(50 + i*10), Y: 75, Time: int64(i * 10)})
+	}
+	tap := models.SignatureStroke{Points: []models.SignaturePoint{{X: 50, Y: 75}}}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedReason string
+	}{
+		{
+			name:           "Valid signature",
+			body:           mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t)}),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Valid signature with strokes",
+			body: mustJSON(t, SignatureRequest{
+				SignatureData:    testSignatureDataURL(t),
+				SignatureStrokes: &models.SignatureStrokes{Width: 300, Height: 150, Strokes: []models.SignatureStroke{signature}},
+			}),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing signature",
+			body:           `{"consents": []}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedReason: render.ReasonMissing,
+		},
+		{
+			name:           "Not an image",
+			body:           mustJSON(t, SignatureRequest{SignatureData: "base64_encoded_signature_data"}),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedReason: render.ReasonMalformed,
+		},
+		{
+			name:           "Disallowed type",
+			body:           mustJSON(t, SignatureRequest{SignatureData: "data:image/gif;base64,R0lGODlhAQABAAAAACw="}),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedReason: render.ReasonUnsupportedType,
+		},
+		{
+			name: "Trivial strokes",
+			body: mustJSON(t, SignatureRequest{
+				SignatureData:    testSignatureDataURL(t),
+				SignatureStrokes: &models.SignatureStrokes{Width: 300, Height: 150, Strokes: []models.SignatureStroke{tap}},
+			}),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedReason: render.ReasonInsufficientStrokes,
+		},
+		{
+			name:           "Body too large",
+			body:           `{"signature_data": "` + strings.Repeat("A", maxSignatureRequestBytes) + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedReason: render.ReasonTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := models.NewInMemoryDocumentStore()
+			requestID, _ := store.AddDocument(models.Document{SignerEmail: "user@example.com", Status: models.StatusPending})
+
+			router := mux.NewRouter()
+			router.HandleFunc("/documents/sign/{request_id}", NewSignatureHandler(store).ProcessSignature).Methods(http.MethodPost)
+
+			req := httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			doc, err := store.GetDocument(requestID)
+			assert.NoError(t, err)
+			if tt.expectedReason == "" {
+				assert.Equal(t, models.StatusCompleted, doc.Status)
+				doc.SignatureData, err = store.GetSignatureData(requestID)
+				assert.NoError(t, err)
+				assert.True(t, strings.HasPrefix(doc.SignatureData, "data:image/png;base64,"))
+				assert.NotNil(t, doc.CompletedAt)
+				verification, err := models.VerifyDocument(doc, nil, models.VerifyOptions{})
+				assert.NoError(t, err)
+				assert.True(t, verification.Valid)
+				return
+			}
+
+			var response ErrorResponse
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			assert.Equal(t, ErrCodeInvalidSignature, response.Code)
+			assert.Equal(t, tt.expectedReason, response.Details["reason"])
+			assert.Equal(t, models.StatusPending, doc.Status)
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	var buf bytes.Buffer
+	assert.NoError(t, json.NewEncoder(&buf).Encode(v))
+	return buf.String()
+}
+
+func TestProcessSignature_Submission(t *testing.T) {
+	store := models.NewInMemoryDocumentStore()
+	createdAt := time.Now().Add(-2 * time.Hour)
+	requestID, _ := store.AddDocument(models.Document{SignerEmail: "user@example.com", Status: models.StatusPending, CreatedAt: createdAt})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/documents/sign/{request_id}", NewSignatureHandler(store).ProcessSignature).Methods(http.MethodPost)
+	submit := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A signature queued on the tablet while offline is received later than it was captured
+	capturedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	body := mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t), SubmissionID: "submission-1", CapturedAt: &capturedAt})
+	rr := submit(body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	doc, err := store.GetDocument(requestID)
+	assert.NoError(t, err)
+	assert.Equal(t, "submission-1", doc.SubmissionID)
+	if assert.NotNil(t, doc.CapturedAt) && assert.NotNil(t, doc.CompletedAt) {
+		assert.Equal(t, capturedAt, *doc.CapturedAt)
+		assert.True(t, doc.CompletedAt.After(capturedAt))
+	}
+	record, err := models.NewCompletionRecord(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, capturedAt.Format(time.RFC3339), record.CapturedAt)
+
+	// Replaying the submission after its response was lost answers as the first time
+	rr = submit(body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response SignatureResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, "completed", response.Status)
+	replayed, _ := store.GetDocument(requestID)
+	assert.Equal(t, doc.CompletedAt, replayed.CompletedAt)
+	assert.Equal(t, doc.IntegrityHash, replayed.IntegrityHash)
+
+	// Another submission for the completed document is a conflict
+	rr = submit(mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t), SubmissionID: "submission-2"}))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = submit(mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t)}))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+// submissionStore completes documents with another submission just before the handler does, as a
+// concurrent request would, and fails storing consents once, as a lost database connection would
+type submissionStore struct {
+	*models.InMemoryDocumentStore
+	concurrentSubmission string
+	failConsents         bool
+}
+
+func (s *submissionStore) CompleteSubmission(requestID string, submissionID string, capturedAt *time.Time) (bool, error) {
+	if s.concurrentSubmission != "" {
+		s.InMemoryDocumentStore.CompleteSubmission(requestID, s.concurrentSubmission, nil)
+	}
+	return s.InMemoryDocumentStore.CompleteSubmission(requestID, submissionID, capturedAt)
+}
+
+func (s *submissionStore) StoreConsents(requestID string, consents []models.Consent) error {
+	if s.failConsents {
+		s.failConsents = false
+		return errors.New("connection lost")
+	}
+	return s.InMemoryDocumentStore.StoreConsents(requestID, consents)
+}
+
+func TestProcessSignature_ConcurrentSubmission(t *testing.T) {
+	tests := []struct {
+		name                 string
+		concurrentSubmission string
+		expectedStatus       int
+	}{
+		{name: "Same submission", concurrentSubmission: "submission-1", expectedStatus: http.StatusOK},
+		{name: "Other submission", concurrentSubmission: "submission-2", expectedStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &submissionStore{InMemoryDocumentStore: models.NewInMemoryDocumentStore(), concurrentSubmission: tt.concurrentSubmission}
+			requestID, _ := store.AddDocument(models.Document{SignerEmail: "user@example.com", Status: models.StatusPending})
+
+			router := mux.NewRouter()
+			router.HandleFunc("/documents/sign/{request_id}", NewSignatureHandler(store).ProcessSignature).Methods(http.MethodPost)
+			body := mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t), SubmissionID: "submission-1"})
+			req := httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			// Only the request that completed the document stores its signature
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			signatureData, err := store.GetSignatureData(requestID)
+			assert.NoError(t, err)
+			assert.Empty(t, signatureData)
+		})
+	}
+}
+
+func TestProcessSignature_ResumedSubmission(t *testing.T) {
+	store := &submissionStore{InMemoryDocumentStore: models.NewInMemoryDocumentStore(), failConsents: true}
+	requestID, _ := store.AddDocument(models.Document{SignerEmail: "user@example.com", Status: models.StatusPending})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/documents/sign/{request_id}", NewSignatureHandler(store).ProcessSignature).Methods(http.MethodPost)
+	submit := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	consents := []models.Consent{{ConsentType: "marketing_email", Granted: true, Timestamp: time.Now()}}
+	body := mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t), Consents: consents, SubmissionID: "submission-1"})
+
+	// The document is completed by the submission before storing it fails
+	rr := submit(body)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	doc, _ := store.GetDocument(requestID)
+	assert.Equal(t, models.StatusCompleted, doc.Status)
+	assert.Nil(t, doc.CompletedAt)
+
+	// Other submissions are still a conflict, while replaying it stores it in full
+	rr = submit(mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t), SubmissionID: "submission-2"}))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = submit(body)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	doc, _ = store.GetDocument(requestID)
+	assert.NotNil(t, doc.CompletedAt)
+	assert.Len(t, doc.Consents, 1)
+	doc.SignatureData, _ = store.GetSignatureData(requestID)
+	verification, err := models.VerifyDocument(doc, nil, models.VerifyOptions{})
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid)
+}
+
+func TestProcessSignature_InvalidSubmission(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	beforeCreation := time.Now().Add(-3 * time.Hour)
+	skewed := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name           string
+		request        SignatureRequest
+		expectedStatus int
+		invalidField   string
+	}{
+		{name: "Clock slightly ahead", request: SignatureRequest{CapturedAt: &skewed}, expectedStatus: http.StatusOK},
+		{name: "Captured in the future", request: SignatureRequest{CapturedAt: &future}, expectedStatus: http.StatusUnprocessableEntity, invalidField: "captured_at"},
+		{name: "Captured before the document was created", request: SignatureRequest{CapturedAt: &beforeCreation}, expectedStatus: http.StatusUnprocessableEntity, invalidField: "captured_at"},
+		{name: "Submission ID too long", request: SignatureRequest{SubmissionID: strings.Repeat("a", maxSubmissionIDLength+1)}, expectedStatus: http.StatusUnprocessableEntity, invalidField: "submission_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := models.NewInMemoryDocumentStore()
+			requestID, _ := store.AddDocument(models.Document{SignerEmail: "user@example.com", Status: models.StatusPending, CreatedAt: time.Now().Add(-2 * time.Hour)})
+
+			router := mux.NewRouter()
+			router.HandleFunc("/documents/sign/{request_id}", NewSignatureHandler(store).ProcessSignature).Methods(http.MethodPost)
+
+			tt.request.SignatureData = testSignatureDataURL(t)
+			req := httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(mustJSON(t, tt.request)))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.invalidField == "" {
+				return
+			}
+			var response ErrorResponse
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			assert.Equal(t, ErrCodeValidation, response.Code)
+			assert.Contains(t, response.Details, tt.invalidField)
+			doc, _ := store.GetDocument(requestID)
+			assert.Equal(t, models.StatusPending, doc.Status)
+		})
+	}
+}
+
+// failingTimestamper stands in for an unreachable timestamp authority
+type failingTimestamper struct{}
+
+func (failingTimestamper) Timestamp(ctx context.Context, digest []byte) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestProcessSignature_Timestamp(t *testing.T) {
+	localTSA, err := tsa.NewLocalTSA()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name              string
+		timestamper       tsa.Timestamper
+		expectedTimestamp bool
+	}{
+		{name: "Timestamped", timestamper: localTSA, expectedTimestamp: true},
+		{name: "Authority unavailable", timestamper: failingTimestamper{}, expectedTimestamp: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := models.NewInMemoryDocumentStore()
+			requestID, _ := store.AddDocument(models.Document{SignerEmail: "user@example.com", Status: models.StatusPending})
+
+			router := mux.NewRouter()
+			router.HandleFunc("/documents/sign/{request_id}", NewSignatureHandler(store).WithTimestamper(tt.timestamper).ProcessSignature).Methods(http.MethodPost)
+
+			body := mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t)})
+			req := httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			doc, err := store.GetDocument(requestID)
+			assert.NoError(t, err)
+			assert.Equal(t, models.StatusCompleted, doc.Status)
+			if !tt.expectedTimestamp {
+				assert.Nil(t, doc.Timestamp)
+				return
+			}
+
+			if assert.NotNil(t, doc.Timestamp) {
+				doc.SignatureData, _ = store.GetSignatureData(requestID)
+				roots := x509.NewCertPool()
+				roots.AddCert(localTSA.Root())
+				verification, err := models.VerifyDocument(doc, nil, models.VerifyOptions{TimestampRoots: roots})
+				assert.NoError(t, err)
+				assert.True(t, verification.Valid)
+				assert.Equal(t, models.CheckTimestamp, verification.Checks[len(verification.Checks)-1].Name)
+			}
+		})
+	}
+}
+
+func TestSignatureHandler_PDFDocument(t *testing.T) {
+	i18n.Init("en")
+	documentPDF := testDocumentPDF(t)
+	store := models.NewInMemoryDocumentStore()
+	pdfID, _ := store.AddDocument(models.Document{
+		DocumentTitle:     "Contract",
+		DocumentPDF:       documentPDF,
+		DocumentPDFSHA256: "digest",
+		SignatureFields:   []models.SignatureField{{Page: 1, X: 56, Y: 100, Width: 200, Height: 80}},
+		Status:            models.StatusPending,
+	})
+	textID, _ := store.AddDocument(models.Document{DocumentTitle: "Terms", Status: models.StatusPending})
+	signedID, _ := store.AddDocument(models.Document{DocumentPDF: documentPDF, DocumentPDFSHA256: "digest", Status: models.StatusCompleted})
+
+	handler := NewSignatureHandler(store)
+	router := mux.NewRouter()
+	router.HandleFunc("/documents/sign/{request_id}", handler.ShowSignaturePage).Methods(http.MethodGet)
+	router.HandleFunc("/documents/sign/{request_id}/pdf", handler.ServeDocumentPDF).Methods(http.MethodGet)
+
+	// The page draws the PDF and marks its signature fields
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+pdfID, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `data-pdf-url="/documents/sign/`+pdfID+`/pdf"`)
+	assert.Contains(t, rr.Body.String(), `{"page":1,"x":56,"y":100,"width":200,"height":80}`)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+textID, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), `id="documentPages"`)
+
+	tests := []struct {
+		name           string
+		requestID      string
+		expectedStatus int
+	}{
+		{name: "PDF document", requestID: pdfID, expectedStatus: http.StatusOK},
+		{name: "Text document", requestID: textID, expectedStatus: http.StatusNotFound},
+		{name: "Signed document", requestID: signedID, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown document", requestID: "unknown", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+tt.requestID+"/pdf", nil))
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+				assert.Equal(t, documentPDF, rr.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestSignatureHandler_Attachments(t *testing.T) {
+	i18n.Init("en")
+	photo := models.NewAttachment("photo.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	prices := models.NewAttachment("prices.txt", []byte("Price list\n"))
+	store := models.NewInMemoryDocumentStore()
+	pendingID, _ := store.AddDocument(models.Document{
+		DocumentTitle: "Order",
+		Attachments:   []models.Attachment{photo, prices},
+		Status:        models.StatusPending,
+	})
+	signedID, _ := store.AddDocument(models.Document{Attachments: []models.Attachment{prices}, Status: models.StatusCompleted})
+
+	handler := NewSignatureHandler(store)
+	router := mux.NewRouter()
+	router.HandleFunc("/documents/sign/{request_id}", handler.ShowSignaturePage).Methods(http.MethodGet)
+	router.HandleFunc("/documents/sign/{request_id}/attachments/{attachment_id}", handler.ServeAttachment).Methods(http.MethodGet)
+
+	// Images are shown inline and every attachment is linked
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+pendingID, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<img src="/documents/sign/`+pendingID+`/attachments/`+photo.ID+`" alt="photo.png"`)
+	assert.Contains(t, rr.Body.String(), `href="/documents/sign/`+pendingID+`/attachments/`+prices.ID+`"`)
+	assert.NotContains(t, rr.Body.String(), `<img src="/documents/sign/`+pendingID+`/attachments/`+prices.ID)
+
+	tests := []struct {
+		name           string
+		requestID      string
+		attachmentID   string
+		expectedStatus int
+	}{
+		{name: "Attachment", requestID: pendingID, attachmentID: prices.ID, expectedStatus: http.StatusOK},
+		{name: "Unknown attachment", requestID: pendingID, attachmentID: "unknown", expectedStatus: http.StatusNotFound},
+		{name: "Signed document", requestID: signedID, attachmentID: prices.ID, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown document", requestID: "unknown", attachmentID: prices.ID, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+tt.requestID+"/attachments/"+tt.attachmentID, nil))
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+				assert.Equal(t, `inline; filename="prices.txt"`, rr.Header().Get("Content-Disposition"))
+				assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+				assert.Equal(t, "Price list\n", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestSignatureHandler_Locale(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	store := models.NewInMemoryDocumentStore()
+	polishID, _ := store.AddDocument(models.Document{DocumentTitle: "Umowa", Locale: "pl", Status: models.StatusPending})
+	defaultID, _ := store.AddDocument(models.Document{DocumentTitle: "Terms", Status: models.StatusPending})
+
+	handler := NewSignatureHandler(store)
+	router := mux.NewRouter()
+	router.Use(i18n.Middleware)
+	router.HandleFunc("/documents/sign/{request_id}", handler.ShowSignaturePage).Methods(http.MethodGet)
+
+	tests := []struct {
+		name           string
+		requestID      string
+		acceptLanguage string
+		cookie         string
+		expectedLang   string
+		expectedDir    string
+		expectedText   string
+	}{
+		{name: "Default language", requestID: defaultID, expectedLang: "en", expectedDir: "ltr", expectedText: "Sign here"},
+		{name: "Accept-Language", requestID: defaultID, acceptLanguage: "pl-PL,pl;q=0.9,en;q=0.8", expectedLang: "pl-PL", expectedDir: "ltr", expectedText: "Podpisz tutaj"},
+		{name: "Unsupported Accept-Language", requestID: defaultID, acceptLanguage: "ja", expectedLang: "en", expectedDir: "ltr", expectedText: "Sign here"},
+		{name: "Tablet language over Accept-Language", requestID: defaultID, acceptLanguage: "en", cookie: "pl", expectedLang: "pl", expectedDir: "ltr", expectedText: "Podpisz tutaj"},
+		{name: "Document locale over tablet language", requestID: polishID, cookie: "en", expectedLang: "pl", expectedDir: "ltr", expectedText: "Podpisz tutaj"},
+		{name: "Right-to-left language", requestID: defaultID, acceptLanguage: "ar-EG,ar;q=0.9", expectedLang: "ar-EG", expectedDir: "rtl", expectedText: "وقّع هنا"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/documents/sign/"+tt.requestID, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: i18n.LanguageCookieName, Value: tt.cookie})
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Contains(t, rr.Body.String(), `<html lang="`+tt.expectedLang+`" dir="`+tt.expectedDir+`">`)
+			assert.Contains(t, rr.Body.String(), tt.expectedText)
+			assert.Contains(t, rr.Body.String(), `<link rel="stylesheet" href="/static/app.css?v=`)
+			assert.Contains(t, rr.Body.String(), `data-service-worker="/static/sw.js?v=`)
+		})
+	}
+}
+
+func TestSignatureHandler_Timezone(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	store := models.NewInMemoryDocumentStore()
+	newYorkID, _ := store.AddDocument(models.Document{DocumentTitle: "Terms", Timezone: "America/New_York", Status: models.StatusPending})
+	tabletID, _ := store.AddDocument(models.Document{DocumentTitle: "Terms", SignerEmail: "user@example.com", Status: models.StatusPending})
+
+	handler := NewSignatureHandler(store)
+	handler.timeNow = func() time.Time { return time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC) }
+	router := mux.NewRouter()
+	router.Use(i18n.Middleware)
+	router.HandleFunc("/documents/sign/{request_id}", handler.ShowSignaturePage).Methods(http.MethodGet)
+	router.HandleFunc("/documents/sign/{request_id}", handler.ProcessSignature).Methods(http.MethodPost)
+
+	// The current date is shown in the time zone of the document, else of the tablet
+	tests := []struct {
+		name         string
+		requestID    string
+		lang         string
+		timezone     string
+		expectedDate string
+	}{
+		{name: "Default time zone", requestID: tabletID, lang: "en", expectedDate: "Jun 1, 2024"},
+		{name: "Tablet time zone", requestID: tabletID, lang: "en", timezone: "Europe/Warsaw", expectedDate: "Jun 2, 2024"},
+		{name: "Date format of the language", requestID: tabletID, lang: "pl", timezone: "Europe/Warsaw", expectedDate: "02.06.2024"},
+		{name: "Document time zone over tablet time zone", requestID: newYorkID, lang: "en", timezone: "Europe/Warsaw", expectedDate: "Jun 1, 2024"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/documents/sign/"+tt.requestID, nil)
+			req.AddCookie(&http.Cookie{Name: i18n.LanguageCookieName, Value: tt.lang})
+			if tt.timezone != "" {
+				req.AddCookie(&http.Cookie{Name: i18n.TimezoneCookieName, Value: tt.timezone})
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.expectedDate)
+		})
+	}
+
+	// Signing records the tablet's time zone for the callback and the PDF
+	req := httptest.NewRequest(http.MethodPost, "/documents/sign/"+tabletID, strings.NewReader(mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t)})))
+	req.AddCookie(&http.Cookie{Name: i18n.TimezoneCookieName, Value: "Europe/Warsaw"})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	doc, err := store.GetDocument(tabletID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Warsaw", doc.Timezone)
+}
+
+func TestSignatureHandler_Review(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	store := models.NewInMemoryDocumentStore()
+	reviewID, _ := store.AddDocument(models.Document{DocumentTitle: "Terms", Review: true, Status: models.StatusPending})
+	directID, _ := store.AddDocument(models.Document{DocumentTitle: "Terms", Status: models.StatusPending})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/documents/sign/{request_id}", NewSignatureHandler(store).ShowSignaturePage).Methods(http.MethodGet)
+
+	// The summary is only on the page of a document reviewed before it is submitted
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+reviewID, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<div id="reviewPanel" class="mb-8" hidden>`)
+	assert.Contains(t, rr.Body.String(), "Confirm and submit")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+directID, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), `id="reviewPanel"`)
+}
+
+func TestSignatureHandler_SignerCopy(t *testing.T) {
+	tests := []struct {
+		name             string
+		mode             string
+		publicURL        string
+		expectedCopyURL  string
+		expectedQR       bool
+		expectedQRStatus int
+	}{
+		{name: "No copy", expectedQRStatus: http.StatusNotFound},
+		{name: "Link", mode: SignerCopyLink, publicURL: "https://sign.example.com/", expectedCopyURL: `^https://sign\.example\.com/c/[0-9A-Za-z]{12}$`, expectedQRStatus: http.StatusNotFound},
+		{name: "QR code", mode: SignerCopyQR, publicURL: "https://sign.example.com", expectedCopyURL: `^https://sign\.example\.com/c/[0-9A-Za-z]{12}$`, expectedQR: true, expectedQRStatus: http.StatusOK},
+		{name: "URL of the request", mode: SignerCopyQR, expectedCopyURL: `^http://example\.com/c/[0-9A-Za-z]{12}$`, expectedQR: true, expectedQRStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			store := models.NewInMemoryDocumentStore()
+			requestID, _ := store.AddDocument(models.Document{SignerEmail: "user@example.com", Status: models.StatusPending, CreatedAt: now})
+
+			handler := NewSignatureHandler(store).WithPublicURL(tt.publicURL).WithSignerCopy(tt.mode, time.Hour)
+			handler.timeNow = func() time.Time { return now }
+			router := mux.NewRouter()
+			router.HandleFunc("/documents/sign/{request_id}", handler.ProcessSignature).Methods(http.MethodPost)
+			router.HandleFunc("/documents/sign/{request_id}/copy.svg", handler.ServeCopyQR).Methods(http.MethodGet)
+			submit := func() SignatureResponse {
+				body := mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t), SubmissionID: "submission-1"})
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(body)))
+				assert.Equal(t, http.StatusOK, rr.Code)
+				var response SignatureResponse
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				return response
+			}
+			var token string
+			copyQR := func(token string) *httptest.ResponseRecorder {
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/documents/sign/"+requestID+"/copy.svg?token="+token, nil))
+				return rr
+			}
+
+			response := submit()
+			if tt.expectedCopyURL == "" {
+				assert.Empty(t, response.CopyURL)
+				assert.Empty(t, response.CopyQRURL)
+			} else {
+				assert.Regexp(t, tt.expectedCopyURL, response.CopyURL)
+				token = response.CopyURL[strings.LastIndex(response.CopyURL, "/")+1:]
+				// Only the token's hash is stored
+				doc, _ := store.GetDocument(requestID)
+				assert.Equal(t, models.HashCopyToken(token), doc.CopyToken)
+				if assert.NotNil(t, doc.CopyExpiresAt) {
+					assert.Equal(t, now.Add(time.Hour).UTC().Truncate(time.Second), *doc.CopyExpiresAt)
+				}
+			}
+			if tt.expectedQR {
+				assert.Equal(t, "/documents/sign/"+requestID+"/copy.svg?token="+token, response.CopyQRURL)
+			} else {
+				assert.Empty(t, response.CopyQRURL)
+			}
+
+			rr := copyQR(token)
+			assert.Equal(t, tt.expectedQRStatus, rr.Code)
+			if tt.expectedQRStatus == http.StatusOK {
+				assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+				assert.True(t, strings.HasPrefix(rr.Body.String(), "<svg "))
+			}
+			assert.Equal(t, http.StatusNotFound, copyQR("unknown00000").Code)
+
+			// A replayed submission cannot show the link again, and the QR code is served until
+			// the link expires
+			replayed := submit()
+			assert.Empty(t, replayed.CopyURL)
+			assert.Empty(t, replayed.CopyQRURL)
+			now = now.Add(2 * time.Hour)
+			assert.Equal(t, http.StatusNotFound, copyQR(token).Code)
+		})
+	}
+}
+
+// emailStatusStore serializes reading documents with recording their email status in the
+// background, as the in-memory store is not safe for concurrent use
+type emailStatusStore struct {
+	*models.InMemoryDocumentStore
+	mu sync.Mutex
+}
+
+func (s *emailStatusStore) GetDocument(requestID string) (models.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.InMemoryDocumentStore.GetDocument(requestID)
+}
+
+func (s *emailStatusStore) StoreEmailStatus(requestID string, status string, at time.Time, deliveryError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.InMemoryDocumentStore.StoreEmailStatus(requestID, status, at, deliveryError)
+}
+
+func TestSignatureHandler_EmailCopy(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	local, err := notify.NewLocalServer("", "")
+	assert.NoError(t, err)
+	defer local.Close()
+	sender, err := notify.NewSMTPSender(local.Host(), local.Port(), "", "", "noreply@example.com")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		emailCopy      bool
+		mode           string
+		expectedStatus string
+		expectedError  string
+		expectedLink   bool
+	}{
+		{name: "Not asked for"},
+		{name: "Attachment", emailCopy: true, mode: SignerEmailAttachment, expectedStatus: models.EmailSent},
+		{name: "Link", emailCopy: true, mode: SignerEmailLink, expectedStatus: models.EmailSent, expectedLink: true},
+		{name: "Email not configured", emailCopy: true, expectedStatus: models.EmailFailed, expectedError: "email is not configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &emailStatusStore{InMemoryDocumentStore: models.NewInMemoryDocumentStore()}
+			requestID, _ := store.AddDocument(models.Document{
+				DocumentTitle:   "Agreement",
+				DocumentContent: []models.DocumentSection{{ID: "s1", Type: "text", Content: "Terms"}},
+				SignerName:      "John Smith",
+				SignerEmail:     "john@example.com",
+				EmailCopy:       tt.emailCopy,
+				Status:          models.StatusPending,
+			})
+			handler := NewSignatureHandler(store).WithPublicURL("https://sign.example.com").WithSignerCopy("", time.Hour)
+			if tt.mode != "" {
+				handler.WithSignerMailer(NewSignerMailer(store, sender, NewPDFHandler(store), tt.mode))
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/documents/sign/{request_id}", handler.ProcessSignature).Methods(http.MethodPost)
+
+			received := len(local.Messages())
+			body := mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t)})
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/documents/sign/"+requestID, strings.NewReader(body)))
+			assert.Equal(t, http.StatusOK, rr.Code)
+			var response SignatureResponse
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+			// The link in the email is not shown on the tablet
+			assert.Empty(t, response.CopyURL)
+
+			if tt.expectedStatus == "" {
+				doc, _ := store.GetDocument(requestID)
+				assert.Empty(t, doc.EmailStatus)
+				assert.Empty(t, doc.CopyToken)
+				assert.Len(t, local.Messages(), received)
+				return
+			}
+			assert.Eventually(t, func() bool {
+				doc, _ := store.GetDocument(requestID)
+				return doc.EmailStatus == tt.expectedStatus
+			}, 5*time.Second, 10*time.Millisecond)
+			doc, _ := store.GetDocument(requestID)
+			assert.Equal(t, tt.expectedError, doc.EmailError)
+			if tt.expectedLink {
+				assert.NotEmpty(t, doc.CopyToken)
+				messages := local.Messages()
+				if assert.Len(t, messages, received+1) {
+					link := regexp.MustCompile(`https://sign\.example\.com/c/([0-9A-Za-z]{12})`).FindStringSubmatch(string(messages[received].Data))
+					if assert.NotNil(t, link) {
+						assert.Equal(t, models.HashCopyToken(link[1]), doc.CopyToken)
+					}
+				}
+			} else {
+				assert.Empty(t, doc.CopyToken)
+			}
+		})
+	}
+}
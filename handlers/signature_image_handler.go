@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/render"
+)
+
+// SignatureImageHandler handles GET /api/documents/signatures/{request_id}/signature. It renders
+// the stored signature as PNG, WebP or SVG, scaled to fit the requested width and height. SVG is
+// drawn from the vector stroke data when it was captured.
+func SignatureImageHandler(w http.ResponseWriter, r *http.Request, store models.DocumentStore) {
+	requestID := mux.Vars(r)["request_id"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = render.FormatPNG
+	}
+	if format != render.FormatPNG && format != render.FormatWebP && format != render.FormatSVG {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Unsupported image format", map[string]string{
+			"format": "must be png, webp or svg",
+		})
+		return
+	}
+
+	width, height, ok := parseImageSize(w, r)
+	if !ok {
+		return
+	}
+
+	doc, err := store.GetDocument(requestID)
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Signature request not found", nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	if format == render.FormatSVG && doc.Strokes != nil {
+		w.Header().Set("Content-Type", render.ContentType(format))
+		w.Write([]byte(doc.Strokes.SVG(width, height)))
+		return
+	}
+
+	signatureData, err := store.GetSignatureData(requestID)
+	if err != nil {
+		log.Printf("Error getting signature of document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	if signatureData == "" {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "No signature captured for this request", nil)
+		return
+	}
+
+	// Signatures are normalised when captured; normalising again also covers older rows
+	img, err := render.DecodeDataURL(signatureData)
+	if err == nil {
+		img, err = render.Normalize(img)
+	}
+	if err != nil {
+		log.Printf("Error decoding stored signature for %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Stored signature cannot be rendered", nil)
+		return
+	}
+
+	data, err := render.Render(img, format, width, height)
+	if err != nil {
+		log.Printf("Error rendering signature for %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", render.ContentType(format))
+	w.Write(data)
+}
+
+// parseImageSize reads the optional width and height query parameters, writing a validation
+// error and returning false when either is invalid
+func parseImageSize(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	dimensions := map[string]int{}
+	for _, name := range []string{"width", "height"} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 || size > render.MaxRenderSize {
+			WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Invalid image size", map[string]string{
+				name: "must be an integer between 1 and " + strconv.Itoa(render.MaxRenderSize),
+			})
+			return 0, 0, false
+		}
+		dimensions[name] = size
+	}
+	return dimensions["width"], dimensions["height"], true
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSignatureDataURL(t *testing.T) string {
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 150))
+	for x := 50; x < 250; x++ {
+		for y := 70; y < 80; y++ {
+			img.SetNRGBA(x, y, color.NRGBA{A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestSignatureImageHandler(t *testing.T) {
+	store := models.NewInMemoryDocumentStore()
+
+	signedID, _ := store.AddDocument(models.Document{SignerEmail: "user1@example.com", Status: models.StatusCompleted})
+	store.UpdateDocumentSignature(signedID, testSignatureDataURL(t))
+	withStrokesID, _ := store.AddDocument(models.Document{SignerEmail: "user2@example.com", Status: models.StatusCompleted})
+	store.UpdateDocumentSignature(withStrokesID, testSignatureDataURL(t))
+	store.StoreSignatureStrokes(withStrokesID, models.SignatureStrokes{
+		Width:   300,
+		Height:  150,
+		Strokes: []models.SignatureStroke{{Points: []models.SignaturePoint{{X: 50, Y: 75}, {X: 250, Y: 75, Time: 100}}}},
+	})
+	pendingID, _ := store.AddDocument(models.Document{SignerEmail: "user3@example.com", Status: models.StatusPending})
+	legacyID, _ := store.AddDocument(models.Document{SignerEmail: "user4@example.com", Status: models.StatusCompleted})
+	store.UpdateDocumentSignature(legacyID, "base64_encoded_signature_data")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/documents/signatures/{request_id}/signature", func(w http.ResponseWriter, r *http.Request) {
+		SignatureImageHandler(w, r, store)
+	})
+
+	tests := []struct {
+		name            string
+		url             string
+		expectedStatus  int
+		expectedType    string
+		expectedContent string
+		expectedBounds  image.Rectangle
+	}{
+		{
+			name:           "Trimmed PNG by default",
+			url:            "/api/documents/signatures/" + signedID + "/signature",
+			expectedStatus: http.StatusOK,
+			expectedType:   "image/png",
+			expectedBounds: image.Rect(0, 0, 200, 10),
+		},
+		{
+			name:           "PNG scaled to width",
+			url:            "/api/documents/signatures/" + signedID + "/signature?width=100",
+			expectedStatus: http.StatusOK,
+			expectedType:   "image/png",
+			expectedBounds: image.Rect(0, 0, 100, 5),
+		},
+		{
+			name:            "WebP",
+			url:             "/api/documents/signatures/" + signedID + "/signature?format=webp",
+			expectedStatus:  http.StatusOK,
+			expectedType:    "image/webp",
+			expectedContent: "RIFF",
+		},
+		{
+			name:            "SVG embedding the bitmap",
+			url:             "/api/documents/signatures/" + signedID + "/signature?format=svg",
+			expectedStatus:  http.StatusOK,
+			expectedType:    "image/svg+xml",
+			expectedContent: `<image width="200" height="10" href="data:image/png;base64,`,
+		},
+		{
+			name:            "SVG from stroke data",
+			url:             "/api/documents/signatures/" + withStrokesID + "/signature?format=svg",
+			expectedStatus:  http.StatusOK,
+			expectedType:    "image/svg+xml",
+			expectedContent: `<line x1="50" y1="75" x2="250" y2="75"`,
+		},
+		{
+			name:           "Unsupported format",
+			url:            "/api/documents/signatures/" + signedID + "/signature?format=gif",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Size too large",
+			url:            "/api/documents/signatures/" + signedID + "/signature?height=5000",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Not signed yet",
+			url:            "/api/documents/signatures/" + pendingID + "/signature",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Stored data is not an image",
+			url:            "/api/documents/signatures/" + legacyID + "/signature",
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Document not found",
+			url:            "/api/documents/signatures/nonexistent_id/signature",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			assert.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedType != "" {
+				assert.Equal(t, tt.expectedType, rr.Header().Get("Content-Type"))
+			}
+			if tt.expectedContent != "" {
+				assert.True(t, strings.Contains(rr.Body.String(), tt.expectedContent))
+			}
+			if !tt.expectedBounds.Empty() {
+				img, err := png.Decode(rr.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedBounds, img.Bounds())
+			}
+		})
+	}
+}
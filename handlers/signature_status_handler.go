@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jakubsacha/signature-collector/models"
@@ -13,6 +16,17 @@ type SignatureStatusResponse struct {
 	RequestID         string `json:"request_id"`
 	Status            string `json:"status"`
 	SignedDocumentURL string `json:"signed_document_url,omitempty"`
+	SignatureURL      string `json:"signature_url,omitempty"`
+	// Email is the delivery of the signer's copy by email, for requests asking for it
+	Email *EmailDelivery `json:"email,omitempty"`
+}
+
+// EmailDelivery is whether the signer's copy of a document was emailed: pending, sent or failed,
+// with the error of a failed delivery
+type EmailDelivery struct {
+	Status    string     `json:"status"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
 }
 
 // SignatureStatusHandler handles the signature-status endpoint
@@ -22,8 +36,13 @@ func SignatureStatusHandler(w http.ResponseWriter, r *http.Request, store models
 
 	// Get the signature status from the store
 	status, signedDocumentURL, err := store.GetSignatureStatus(requestID)
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Signature request not found", nil)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error getting signature status for %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
 		return
 	}
 
@@ -32,6 +51,18 @@ func SignatureStatusHandler(w http.ResponseWriter, r *http.Request, store models
 		Status:            status,
 		SignedDocumentURL: signedDocumentURL,
 	}
+	if status == models.StatusCompleted {
+		response.SignatureURL = models.SignatureImageURL("", requestID)
+		doc, err := store.GetDocument(requestID)
+		if err != nil {
+			log.Printf("Error getting document %s: %v", requestID, err)
+			WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+			return
+		}
+		if doc.EmailStatus != "" {
+			response.Email = &EmailDelivery{Status: doc.EmailStatus, UpdatedAt: doc.EmailStatusAt, Error: doc.EmailError}
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jakubsacha/signature-collector/models"
@@ -29,6 +30,13 @@ func TestSignatureStatusHandler(t *testing.T) {
 		CallbackURL: "https://client.example.com/callback",
 		Status:      "completed",
 	})
+	emailedID, _ := store.AddDocument(models.Document{
+		SignerEmail: "user2@example.com",
+		EmailCopy:   true,
+		Status:      "completed",
+	})
+	emailedAt := time.Date(2024, 6, 10, 9, 15, 0, 0, time.UTC)
+	store.StoreEmailStatus(emailedID, models.EmailFailed, emailedAt, "550 mailbox unavailable")
 
 	// Create a new router and register the handler
 	router := mux.NewRouter()
@@ -50,12 +58,24 @@ func TestSignatureStatusHandler(t *testing.T) {
 				RequestID:         docID,
 				Status:            "completed",
 				SignedDocumentURL: "https://example.com/doc1.pdf",
+				SignatureURL:      "/api/documents/signatures/" + docID + "/signature",
+			},
+		},
+		{
+			name:           "Email delivery",
+			requestID:      emailedID,
+			expectedStatus: http.StatusOK,
+			expectedResp: &SignatureStatusResponse{
+				RequestID:    emailedID,
+				Status:       "completed",
+				SignatureURL: "/api/documents/signatures/" + emailedID + "/signature",
+				Email:        &EmailDelivery{Status: models.EmailFailed, UpdatedAt: &emailedAt, Error: "550 mailbox unavailable"},
 			},
 		},
 		{
 			name:           "Document not found",
 			requestID:      "nonexistent_id",
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusNotFound,
 			expectedResp:   nil,
 		},
 	}
@@ -74,6 +94,11 @@ func TestSignatureStatusHandler(t *testing.T) {
 				err := json.NewDecoder(w.Body).Decode(&response)
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedResp, &response)
+			} else {
+				var response ErrorResponse
+				err := json.NewDecoder(w.Body).Decode(&response)
+				assert.NoError(t, err)
+				assert.Equal(t, ErrCodeNotFound, response.Code)
 			}
 		})
 	}
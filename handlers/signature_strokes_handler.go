@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+)
+
+// SignatureStrokesHandler handles GET /api/documents/signatures/{request_id}/strokes. It returns
+// the captured stroke data as JSON, or with ?format=svg renders it at the requested width and height.
+func SignatureStrokesHandler(w http.ResponseWriter, r *http.Request, store models.DocumentStore) {
+	requestID := mux.Vars(r)["request_id"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "svg" {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Unsupported strokes format", map[string]string{
+			"format": "must be json or svg",
+		})
+		return
+	}
+
+	width, height, ok := parseImageSize(w, r)
+	if !ok {
+		return
+	}
+
+	doc, err := store.GetDocument(requestID)
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "Signature request not found", nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting document %s: %v", requestID, err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	if doc.Strokes == nil {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "No stroke data captured for this signature", nil)
+		return
+	}
+
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte(doc.Strokes.SVG(width, height)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc.Strokes)
+}
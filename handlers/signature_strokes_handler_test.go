@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureStrokesHandler(t *testing.T) {
+	store := models.NewInMemoryDocumentStore()
+	strokes := models.SignatureStrokes{
+		Width:  600,
+		Height: 200,
+		Strokes: []models.SignatureStroke{
+			{Points: []models.SignaturePoint{
+				{X: 10, Y: 20, Time: 1718000000000, Pressure: 0.5},
+				{X: 40, Y: 25, Time: 1718000000016, Pressure: 0.6},
+			}},
+		},
+	}
+
+	signedID, _ := store.AddDocument(models.Document{SignerEmail: "user1@example.com", Status: models.StatusCompleted})
+	store.StoreSignatureStrokes(signedID, strokes)
+	bitmapOnlyID, _ := store.AddDocument(models.Document{SignerEmail: "user2@example.com", Status: models.StatusCompleted})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/documents/signatures/{request_id}/strokes", func(w http.ResponseWriter, r *http.Request) {
+		SignatureStrokesHandler(w, r, store)
+	})
+
+	tests := []struct {
+		name            string
+		url             string
+		expectedStatus  int
+		expectedType    string
+		expectedContent string
+	}{
+		{
+			name:           "Stroke data as JSON",
+			url:            "/api/documents/signatures/" + signedID + "/strokes",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/json",
+		},
+		{
+			name:            "Stroke data as SVG keeping aspect ratio",
+			url:             "/api/documents/signatures/" + signedID + "/strokes?format=svg&width=300",
+			expectedStatus:  http.StatusOK,
+			expectedType:    "image/svg+xml",
+			expectedContent: `width="300" height="100" viewBox="0 0 600 200"`,
+		},
+		{
+			name:           "Unsupported format",
+			url:            "/api/documents/signatures/" + signedID + "/strokes?format=gif",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Invalid size",
+			url:            "/api/documents/signatures/" + signedID + "/strokes?format=svg&width=0",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "No stroke data captured",
+			url:            "/api/documents/signatures/" + bitmapOnlyID + "/strokes",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Document not found",
+			url:            "/api/documents/signatures/nonexistent_id/strokes",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			assert.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedType != "" {
+				assert.Equal(t, tt.expectedType, rr.Header().Get("Content-Type"))
+			}
+			if tt.expectedContent != "" {
+				assert.Contains(t, rr.Body.String(), tt.expectedContent)
+			}
+			if tt.expectedType == "application/json" {
+				var got models.SignatureStrokes
+				assert.NoError(t, json.NewDecoder(strings.NewReader(rr.Body.String())).Decode(&got))
+				assert.Equal(t, strokes, got)
+			}
+		})
+	}
+}
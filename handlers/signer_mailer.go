@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/notify"
+)
+
+// Signer email modes: the signer's copy of a document is attached to the email, or the email
+// links to it
+const (
+	SignerEmailAttachment = "attachment"
+	SignerEmailLink       = "link"
+)
+
+// SignerMailer emails signers their copy of the documents they signed, for sign requests asking
+// for it with email_copy
+type SignerMailer struct {
+	store   models.DocumentStore
+	sender  notify.Sender
+	pdfs    *PDFHandler
+	mode    string
+	timeNow func() time.Time
+}
+
+// NewSignerMailer creates a mailer sending the copies rendered by pdfs with sender, attached or
+// linked depending on the mode
+func NewSignerMailer(store models.DocumentStore, sender notify.Sender, pdfs *PDFHandler, mode string) *SignerMailer {
+	return &SignerMailer{store: store, sender: sender, pdfs: pdfs, mode: mode, timeNow: time.Now}
+}
+
+// SendCopy emails the signer of a completed document their copy, linked with copyURL in link
+// mode, and records on the document whether it was sent
+func (m *SignerMailer) SendCopy(ctx context.Context, doc models.Document, copyURL string) error {
+	message, err := m.compose(ctx, doc, copyURL)
+	if err == nil {
+		err = m.sender.Send(ctx, message)
+	}
+	status, deliveryError := models.EmailSent, ""
+	if err != nil {
+		status, deliveryError = models.EmailFailed, err.Error()
+	}
+	if storeErr := m.store.StoreEmailStatus(doc.ID, status, m.timeNow().UTC(), deliveryError); storeErr != nil {
+		return fmt.Errorf("error storing email status: %v", storeErr)
+	}
+	return err
+}
+
+// SendSigningLink emails a signer the link to sign a document remotely, valid until expiresAt
+func (m *SignerMailer) SendSigningLink(ctx context.Context, doc models.Document, link string, expiresAt time.Time) error {
+	ctx = signerContext(ctx, doc)
+	data := map[string]interface{}{
+		"Name":      doc.SignerName,
+		"Title":     doc.DocumentTitle,
+		"ExpiresAt": i18n.FormatDateTime(ctx, expiresAt),
+	}
+	return m.sender.Send(ctx, letter(ctx, doc, i18n.T(ctx, "SigningLinkSubject", data), i18n.T(ctx, "SigningLinkBody", data)+"\n"+link))
+}
+
+// SendCode emails a signer the one-time code verifying their email address, valid until
+// expiresAt
+func (m *SignerMailer) SendCode(ctx context.Context, doc models.Document, code string, expiresAt time.Time) error {
+	ctx = signerContext(ctx, doc)
+	data := map[string]interface{}{
+		"Name":      doc.SignerName,
+		"Title":     doc.DocumentTitle,
+		"Code":      code,
+		"ExpiresAt": i18n.FormatDateTime(ctx, expiresAt),
+	}
+	return m.sender.Send(ctx, letter(ctx, doc, i18n.T(ctx, "VerificationCodeSubject", data), i18n.T(ctx, "VerificationCodeBody", data)))
+}
+
+// compose writes the email sending a signer their copy of a document
+func (m *SignerMailer) compose(ctx context.Context, doc models.Document, copyURL string) (notify.Message, error) {
+	ctx = signerContext(ctx, doc)
+	signedAt := doc.CreatedAt
+	if doc.CapturedAt != nil {
+		signedAt = *doc.CapturedAt
+	} else if doc.CompletedAt != nil {
+		signedAt = *doc.CompletedAt
+	}
+	data := map[string]interface{}{
+		"Name":  doc.SignerName,
+		"Title": doc.DocumentTitle,
+		"Date":  i18n.FormatDateTime(ctx, signedAt),
+	}
+	paragraphs := []string{i18n.T(ctx, "EmailCopySigned", data)}
+
+	var attachments []notify.Attachment
+	switch m.mode {
+	case SignerEmailLink:
+		if copyURL == "" || doc.CopyExpiresAt == nil {
+			return notify.Message{}, fmt.Errorf("document has no copy link")
+		}
+		data["ExpiresAt"] = i18n.FormatDateTime(ctx, *doc.CopyExpiresAt)
+		paragraphs = append(paragraphs, i18n.T(ctx, "EmailCopyLink", data)+"\n"+copyURL)
+	default:
+		pdf, err := m.pdfs.renderPDF(ctx, doc)
+		if err != nil {
+			return notify.Message{}, fmt.Errorf("error rendering PDF: %v", err)
+		}
+		attachments = []notify.Attachment{{Filename: "document-" + doc.ID + ".pdf", ContentType: "application/pdf", Data: pdf}}
+		paragraphs = append(paragraphs, i18n.T(ctx, "EmailCopyAttached", data))
+	}
+
+	message := letter(ctx, doc, i18n.T(ctx, "EmailCopySubject", data), paragraphs...)
+	message.Attachments = attachments
+	return message, nil
+}
+
+// signerContext returns ctx in the signer's language and time zone, if the sign request set them
+func signerContext(ctx context.Context, doc models.Document) context.Context {
+	return i18n.WithTimezone(i18n.WithLanguage(ctx, doc.Locale), doc.Timezone)
+}
+
+// letter writes an email to the signer from the Email* messages of the locale files: a greeting,
+// the paragraphs and a footer
+func letter(ctx context.Context, doc models.Document, subject string, paragraphs ...string) notify.Message {
+	greeting := i18n.T(ctx, "EmailGreeting", map[string]interface{}{"Name": doc.SignerName})
+	paragraphs = append(append([]string{greeting}, paragraphs...), i18n.T(ctx, "EmailFooter", nil))
+	return notify.Message{
+		To:      mail.Address{Name: doc.SignerName, Address: doc.SignerEmail},
+		Subject: subject,
+		Body:    strings.Join(paragraphs, "\n\n") + "\n",
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignerMailer_SendCopy(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	local, err := notify.NewLocalServer("mailer", "mailer-secret")
+	assert.NoError(t, err)
+	defer local.Close()
+
+	tests := []struct {
+		name               string
+		mode               string
+		locale             string
+		password           string
+		expectedSubject    string
+		expectedBody       []string
+		expectedAttachment bool
+		expectedStatus     string
+	}{
+		{
+			name:               "Attachment",
+			mode:               SignerEmailAttachment,
+			password:           "mailer-secret",
+			expectedSubject:    "Your signed copy of Agreement",
+			expectedBody:       []string{"Hello John Smith,", `Thank you for signing "Agreement"`, "attached to this email"},
+			expectedAttachment: true,
+			expectedStatus:     models.EmailSent,
+		},
+		{
+			name:            "Link",
+			mode:            SignerEmailLink,
+			password:        "mailer-secret",
+			expectedSubject: "Your signed copy of Agreement",
+			expectedBody:    []string{"until Jun 11, 2024 09:15:00 UTC:\nhttps://sign.example.com/c/abcdefABCDEF"},
+			expectedStatus:  models.EmailSent,
+		},
+		{
+			name:            "Language of the signer",
+			mode:            SignerEmailLink,
+			locale:          "pl",
+			password:        "mailer-secret",
+			expectedSubject: "Twoja podpisana kopia: Agreement",
+			expectedBody:    []string{"Dzień dobry John Smith,", "do 11.06.2024"},
+			expectedStatus:  models.EmailSent,
+		},
+		{
+			name:           "Delivery failure",
+			mode:           SignerEmailAttachment,
+			password:       "wrong",
+			expectedStatus: models.EmailFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := models.NewInMemoryDocumentStore()
+			requestID := addCompletedDocument(t, store)
+			expiresAt := time.Date(2024, 6, 11, 9, 15, 0, 0, time.UTC)
+			assert.NoError(t, store.StoreCopyLink(requestID, models.HashCopyToken("abcdefABCDEF"), expiresAt))
+			doc, _ := store.GetDocument(requestID)
+			doc.SignatureData, _ = store.GetSignatureData(requestID)
+			doc.Locale = tt.locale
+
+			sender, err := notify.NewSMTPSender(local.Host(), local.Port(), "mailer", tt.password, "noreply@example.com")
+			assert.NoError(t, err)
+			received := len(local.Messages())
+			err = NewSignerMailer(store, sender, NewPDFHandler(store), tt.mode).SendCopy(context.Background(), doc, models.CopyURL("https://sign.example.com", "abcdefABCDEF"))
+
+			doc, _ = store.GetDocument(requestID)
+			assert.Equal(t, tt.expectedStatus, doc.EmailStatus)
+			assert.NotNil(t, doc.EmailStatusAt)
+			if tt.expectedStatus == models.EmailFailed {
+				assert.Error(t, err)
+				assert.NotEmpty(t, doc.EmailError)
+				assert.Len(t, local.Messages(), received)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Empty(t, doc.EmailError)
+
+			messages := local.Messages()
+			if !assert.Len(t, messages, received+1) {
+				return
+			}
+			assert.Equal(t, []string{"john@example.com"}, messages[received].To)
+			parsed, err := mail.ReadMessage(bytes.NewReader(messages[received].Data))
+			assert.NoError(t, err)
+			subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedSubject, subject)
+
+			mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+			assert.NoError(t, err)
+			var body []byte
+			if tt.expectedAttachment {
+				assert.Equal(t, "multipart/mixed", mediaType)
+				parts := multipart.NewReader(parsed.Body, params["boundary"])
+				part, err := parts.NextPart()
+				assert.NoError(t, err)
+				body, _ = io.ReadAll(part)
+				part, err = parts.NextPart()
+				if assert.NoError(t, err) {
+					assert.Equal(t, "document-"+requestID+".pdf", part.FileName())
+					encoded, _ := io.ReadAll(part)
+					pdf, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+					assert.NoError(t, err)
+					assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-")))
+				}
+			} else {
+				assert.Equal(t, "text/plain", mediaType)
+				body, _ = io.ReadAll(quotedprintable.NewReader(parsed.Body))
+			}
+			for _, expected := range tt.expectedBody {
+				assert.Contains(t, strings.ReplaceAll(string(body), "\r\n", "\n"), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/templates"
+)
+
+// VerifySigner handles POST /documents/sign/{request_id}/verification, checking the answer the
+// signer gave to the verification the sign request asked for. Once it is right the signature page
+// shows the signature pad; after too many wrong answers the document can no longer be signed.
+func (h *SignatureHandler) VerifySigner(w http.ResponseWriter, r *http.Request) {
+	doc, ok := h.verificationDocument(w, r)
+	if !ok {
+		return
+	}
+	signingPath := "/documents/sign/" + doc.ID
+	if !doc.VerificationRequired() {
+		http.Redirect(w, r, signingPath, http.StatusSeeOther)
+		return
+	}
+	if doc.VerificationOutcome == models.VerificationFailed {
+		h.renderVerification(w, r, http.StatusForbidden, doc, "")
+		return
+	}
+
+	// Attempts are counted before the answer is checked, so that concurrent guesses cannot
+	// exceed the limit
+	err := h.store.RecordVerificationAttempt(doc.ID)
+	if err == nil && doc.VerificationMethod == models.VerificationEmailCode {
+		err = h.store.RecordOTPAttempt(doc.ID)
+	}
+	if err != nil {
+		log.Printf("Error recording verification attempt: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	doc, err = h.store.GetDocument(doc.ID)
+	if err != nil {
+		log.Printf("Error getting document: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := h.timeNow()
+	err = doc.CheckVerification(r.FormValue("answer"), now)
+	outcome := models.VerificationVerified
+	if errors.Is(err, models.ErrVerificationFailed) || (errors.Is(err, models.ErrVerificationIncorrect) && doc.VerificationAttempts >= models.VerificationMaxAttempts) {
+		outcome = models.VerificationFailed
+	} else if err != nil {
+		message := i18n.T(signerContext(r.Context(), doc), "VerificationIncorrect", nil)
+		if doc.VerificationMethod == models.VerificationEmailCode {
+			message = otpMessage(signerContext(r.Context(), doc), err)
+		}
+		h.renderVerification(w, r, http.StatusUnprocessableEntity, doc, message)
+		return
+	}
+
+	if err := h.store.StoreVerificationOutcome(doc.ID, outcome, now); err != nil {
+		log.Printf("Error storing verification outcome: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	doc.VerificationOutcome = outcome
+	recordVerification(h.audit, doc, now)
+	if outcome == models.VerificationFailed {
+		h.renderVerification(w, r, http.StatusForbidden, doc, "")
+		return
+	}
+	http.Redirect(w, r, signingPath, http.StatusSeeOther)
+}
+
+// SendVerificationCode handles POST /documents/sign/{request_id}/verification/code, emailing the
+// signer a new code for a document verified with email_code
+func (h *SignatureHandler) SendVerificationCode(w http.ResponseWriter, r *http.Request) {
+	doc, ok := h.verificationDocument(w, r)
+	if !ok {
+		return
+	}
+	if doc.VerificationMethod != models.VerificationEmailCode || !doc.VerificationRequired() {
+		http.Redirect(w, r, "/documents/sign/"+doc.ID, http.StatusSeeOther)
+		return
+	}
+	if h.mailer == nil {
+		log.Printf("Cannot send verification code of %s: email is not configured", doc.ID)
+		h.renderVerification(w, r, http.StatusServiceUnavailable, doc, i18n.T(signerContext(r.Context(), doc), "CodeSendFailed", nil))
+		return
+	}
+	if status, message := sendCode(r, h.store, h.mailer, doc, h.timeNow()); status != 0 {
+		h.renderVerification(w, r, status, doc, message)
+		return
+	}
+	http.Redirect(w, r, "/documents/sign/"+doc.ID, http.StatusSeeOther)
+}
+
+// verificationDocument returns the pending document of r, or answers that it cannot be signed
+func (h *SignatureHandler) verificationDocument(w http.ResponseWriter, r *http.Request) (models.Document, bool) {
+	doc, err := h.store.GetDocument(mux.Vars(r)["request_id"])
+	if errors.Is(err, models.ErrDocumentNotFound) {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return models.Document{}, false
+	}
+	if err != nil {
+		log.Printf("Error getting document: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return models.Document{}, false
+	}
+	if doc.Status != models.StatusPending {
+		http.Error(w, "Document already signed", http.StatusBadRequest)
+		return models.Document{}, false
+	}
+	return doc, true
+}
+
+// renderVerification shows the step in which the signer proves who they are, in their language,
+// in place of the signature page
+func (h *SignatureHandler) renderVerification(w http.ResponseWriter, r *http.Request, status int, doc models.Document, message string) {
+	codeSent := doc.OTPHash != "" && doc.OTPExpiresAt != nil && h.timeNow().Before(*doc.OTPExpiresAt)
+	page := templates.SignerVerificationPage(doc, doc.ID, models.MaskEmail(doc.SignerEmail), codeSent, message)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	templates.Layout(page).Render(signerContext(r.Context(), doc), w)
+}
+
+// sendCode emails the signer of doc a new one-time code at now. When no code was sent it returns
+// the status and message to show the signer, and otherwise status 0.
+func sendCode(r *http.Request, store models.DocumentStore, mailer *SignerMailer, doc models.Document, now time.Time) (int, string) {
+	ctx := signerContext(r.Context(), doc)
+	if err := doc.CheckOTPSend(now); errors.Is(err, models.ErrOTPSendLimit) {
+		return http.StatusTooManyRequests, i18n.T(ctx, "CodeSendLimit", nil)
+	} else if err != nil {
+		return http.StatusTooManyRequests, i18n.T(ctx, "CodeResendWait", nil)
+	}
+
+	code, err := models.NewOTP()
+	if err != nil {
+		log.Printf("Error generating code: %v", err)
+		return http.StatusInternalServerError, i18n.T(ctx, "CodeSendFailed", nil)
+	}
+	expiresAt := now.UTC().Add(models.OTPTTL).Truncate(time.Second)
+	if err := store.StoreOTP(doc.ID, models.HashOTP(doc.ID, code), now.UTC(), expiresAt); err != nil {
+		log.Printf("Error storing code: %v", err)
+		return http.StatusInternalServerError, i18n.T(ctx, "CodeSendFailed", nil)
+	}
+	if err := mailer.SendCode(r.Context(), doc, code, expiresAt); err != nil {
+		log.Printf("Error emailing code of %s: %v", doc.ID, err)
+		return http.StatusBadGateway, i18n.T(ctx, "CodeSendFailed", nil)
+	}
+	return 0, ""
+}
+
+// recordVerification records the outcome of the signer's verification in audit, if not nil
+func recordVerification(audit models.AuditLog, doc models.Document, now time.Time) {
+	if audit == nil {
+		return
+	}
+	action := models.AuditActionSignerVerified
+	if doc.VerificationOutcome == models.VerificationFailed {
+		action = models.AuditActionSignerVerificationFailed
+	}
+	channel := "tablet"
+	if doc.Remote {
+		channel = "remote"
+	}
+	err := audit.RecordAudit(models.AuditEntry{
+		RequestID: doc.ID,
+		Action:    action,
+		Details:   map[string]string{"method": doc.VerificationMethod, "channel": channel, "attempts": strconv.Itoa(doc.VerificationAttempts)},
+		CreatedAt: now.UTC(),
+	})
+	if err != nil {
+		log.Printf("Error recording audit entry: %v", err)
+	}
+}
+
+// otpMessage returns the message telling the signer why their code was not accepted
+func otpMessage(ctx context.Context, err error) string {
+	switch {
+	case errors.Is(err, models.ErrOTPExpired):
+		return i18n.T(ctx, "CodeExpired", nil)
+	case errors.Is(err, models.ErrOTPAttempts):
+		return i18n.T(ctx, "CodeTooManyAttempts", nil)
+	case errors.Is(err, models.ErrOTPNotSent):
+		return i18n.T(ctx, "CodeNotSent", nil)
+	default:
+		return i18n.T(ctx, "CodeIncorrect", nil)
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+// newVerificationRouter serves the signature page of handler with its verification routes
+func newVerificationRouter(handler *SignatureHandler) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/documents/sign/{request_id}", handler.ShowSignaturePage).Methods(http.MethodGet)
+	router.HandleFunc("/documents/sign/{request_id}", handler.ProcessSignature).Methods(http.MethodPost)
+	router.HandleFunc("/documents/sign/{request_id}/verification", handler.VerifySigner).Methods(http.MethodPost)
+	router.HandleFunc("/documents/sign/{request_id}/verification/code", handler.SendVerificationCode).Methods(http.MethodPost)
+	return router
+}
+
+func serveForm(router *mux.Router, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSignatureHandler_Verification(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	secret, err := models.HashIDDigits("482X")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		doc    models.Document
+		prompt string
+		wrong  string
+		right  string
+	}{
+		{
+			name:   "Name",
+			doc:    models.Document{SignerName: "José Kowalski", VerificationMethod: models.VerificationName},
+			prompt: "type your full name",
+			wrong:  "Jan Kowalski",
+			right:  "jose kowalski",
+		},
+		{
+			name:   "ID digits",
+			doc:    models.Document{SignerName: "José Kowalski", VerificationMethod: models.VerificationIDDigits, VerificationSecret: secret},
+			prompt: "enter the last 4 characters",
+			wrong:  "4821",
+			right:  "482x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := models.NewInMemoryDocumentStore()
+			audit := models.NewInMemoryAuditLog()
+			tt.doc.DocumentTitle, tt.doc.Status = "Agreement", models.StatusPending
+			requestID, _ := store.AddDocument(tt.doc)
+			router := newVerificationRouter(NewSignatureHandler(store).WithAuditLog(audit))
+			signingPath := "/documents/sign/" + requestID
+
+			rr := serveForm(router, http.MethodGet, signingPath, "")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.prompt)
+			assert.NotContains(t, rr.Body.String(), "signatureCanvas")
+
+			// The signature cannot be submitted directly
+			rr = serveForm(router, http.MethodPost, signingPath, mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t)}))
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+			var errResponse ErrorResponse
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(&errResponse))
+			assert.Equal(t, ErrCodeVerificationRequired, errResponse.Code)
+
+			rr = serveForm(router, http.MethodPost, signingPath+"/verification", "answer="+url.QueryEscape(tt.wrong))
+			assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			assert.Contains(t, rr.Body.String(), "This does not match.")
+
+			rr = serveForm(router, http.MethodPost, signingPath+"/verification", "answer="+url.QueryEscape(tt.right))
+			assert.Equal(t, http.StatusSeeOther, rr.Code)
+			assert.Equal(t, signingPath, rr.Header().Get("Location"))
+
+			doc, _ := store.GetDocument(requestID)
+			if assert.NotNil(t, doc.VerificationResult()) {
+				assert.Equal(t, models.VerificationVerified, doc.VerificationResult().Outcome)
+				assert.Equal(t, 2, doc.VerificationResult().Attempts)
+			}
+			entries, _ := audit.ListAuditEntries(requestID)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, models.AuditActionSignerVerified, entries[0].Action)
+				assert.Equal(t, map[string]string{"method": tt.doc.VerificationMethod, "channel": "tablet", "attempts": "2"}, entries[0].Details)
+			}
+
+			rr = serveForm(router, http.MethodGet, signingPath, "")
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.NotContains(t, rr.Body.String(), tt.prompt)
+			assert.Contains(t, rr.Body.String(), "signatureCanvas")
+			rr = serveForm(router, http.MethodPost, signingPath, mustJSON(t, SignatureRequest{SignatureData: testSignatureDataURL(t)}))
+			assert.Equal(t, http.StatusOK, rr.Code)
+		})
+	}
+}
+
+func TestSignatureHandler_VerificationLocked(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	store := models.NewInMemoryDocumentStore()
+	audit := models.NewInMemoryAuditLog()
+	requestID, _ := store.AddDocument(models.Document{SignerName: "Jan Kowalski", VerificationMethod: models.VerificationName, Status: models.StatusPending})
+	router := newVerificationRouter(NewSignatureHandler(store).WithAuditLog(audit))
+	signingPath := "/documents/sign/" + requestID
+
+	for i := 1; i < models.VerificationMaxAttempts; i++ {
+		rr := serveForm(router, http.MethodPost, signingPath+"/verification", "answer=Anna")
+		assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	}
+	rr := serveForm(router, http.MethodPost, signingPath+"/verification", "answer=Anna")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Too many incorrect answers.")
+
+	// The right answer is no longer accepted
+	rr = serveForm(router, http.MethodPost, signingPath+"/verification", "answer=Jan+Kowalski")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = serveForm(router, http.MethodGet, signingPath, "")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.NotContains(t, rr.Body.String(), `name="answer"`)
+
+	doc, _ := store.GetDocument(requestID)
+	assert.Equal(t, models.VerificationFailed, doc.VerificationOutcome)
+	entries, _ := audit.ListAuditEntries(requestID)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.AuditActionSignerVerificationFailed, entries[0].Action)
+		assert.Equal(t, "5", entries[0].Details["attempts"])
+	}
+}
+
+func TestSignatureHandler_VerificationEmailCode(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+	local, err := notify.NewLocalServer("", "")
+	assert.NoError(t, err)
+	defer local.Close()
+	sender, err := notify.NewSMTPSender(local.Host(), local.Port(), "", "", "noreply@example.com")
+	assert.NoError(t, err)
+
+	store := models.NewInMemoryDocumentStore()
+	audit := models.NewInMemoryAuditLog()
+	requestID, _ := store.AddDocument(models.Document{
+		DocumentTitle:      "Agreement",
+		SignerEmail:        "john@example.com",
+		VerificationMethod: models.VerificationEmailCode,
+		Status:             models.StatusPending,
+	})
+	mailer := NewSignerMailer(store, sender, NewPDFHandler(store), SignerEmailAttachment)
+	router := newVerificationRouter(NewSignatureHandler(store).WithSignerMailer(mailer).WithAuditLog(audit))
+	signingPath := "/documents/sign/" + requestID
+
+	rr := serveForm(router, http.MethodGet, signingPath, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "j•••@example.com")
+	assert.NotContains(t, rr.Body.String(), `name="answer"`)
+
+	rr = serveForm(router, http.MethodPost, signingPath+"/verification/code", "")
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	s := &remoteSigningSetup{local: local}
+	code := regexp.MustCompile(`\b[0-9]{6}\b`).FindString(s.lastEmail(t))
+	assert.NotEmpty(t, code)
+
+	rr = serveForm(router, http.MethodGet, signingPath, "")
+	assert.Contains(t, rr.Body.String(), `name="answer"`)
+
+	rr = serveForm(router, http.MethodPost, signingPath+"/verification", "answer="+code)
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	doc, _ := store.GetDocument(requestID)
+	assert.False(t, doc.VerificationRequired())
+	entries, _ := audit.ListAuditEntries(requestID)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.AuditActionSignerVerified, entries[0].Action)
+	}
+
+	// Without email no code can be sent
+	requestID, _ = store.AddDocument(models.Document{SignerEmail: "john@example.com", VerificationMethod: models.VerificationEmailCode, Status: models.StatusPending})
+	router = newVerificationRouter(NewSignatureHandler(store))
+	rr = serveForm(router, http.MethodPost, "/documents/sign/"+requestID+"/verification/code", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
+
+func TestSignRequestHandler_Verification(t *testing.T) {
+	store := models.NewInMemoryDocumentStore()
+	body := mustJSON(t, SignRequest{
+		SignerName:   "Jan Kowalski",
+		SignerEmail:  "jan@example.com",
+		DeviceID:     "device-1",
+		CallbackURL:  "https://client.example.com/callback",
+		Verification: &VerificationRequest{Method: models.VerificationIDDigits, IDDigits: "482x"},
+	})
+	rr := httptest.NewRecorder()
+	SignRequestHandler(rr, httptest.NewRequest(http.MethodPost, "/api/documents/signatures/request", strings.NewReader(body)), store, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var response SignResponse
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	doc, err := store.GetDocument(response.RequestID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.VerificationIDDigits, doc.VerificationMethod)
+	assert.NotContains(t, strings.ToUpper(doc.VerificationSecret), "482X")
+	assert.NoError(t, doc.CheckVerification("482X", time.Now()))
+}
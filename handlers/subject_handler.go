@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+)
+
+// SubjectErasureRequest represents the request body for the subject-erasure endpoint
+type SubjectErasureRequest struct {
+	Mode string `json:"mode"`
+}
+
+type SubjectHandler struct {
+	store         models.DocumentStore
+	ledger        models.ConsentLedger
+	audit         models.AuditLog
+	pseudonymizer *models.Pseudonymizer
+	timeNow       func() time.Time
+}
+
+func NewSubjectHandler(store models.DocumentStore, ledger models.ConsentLedger, audit models.AuditLog, pseudonymizer *models.Pseudonymizer) *SubjectHandler {
+	return &SubjectHandler{
+		store:         store,
+		ledger:        ledger,
+		audit:         audit,
+		pseudonymizer: pseudonymizer,
+		timeNow:       time.Now,
+	}
+}
+
+// ExportSubjectData handles GET /api/subjects/{subject_id}/export
+func (h *SubjectHandler) ExportSubjectData(w http.ResponseWriter, r *http.Request) {
+	subjectID := mux.Vars(r)["subject_id"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Unsupported export format", map[string]string{
+			"format": "must be json or zip",
+		})
+		return
+	}
+
+	now := h.timeNow().UTC()
+	export, err := models.ExportSubject(h.store, h.ledger, subjectID, now)
+	if err != nil {
+		log.Printf("Error exporting subject data: %v", err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	if export.IsEmpty() {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "No data held for subject", nil)
+		return
+	}
+
+	subjectRef := h.pseudonymizer.Pseudonym(export.SubjectID)
+	for _, doc := range export.Documents {
+		err := h.audit.RecordAudit(models.AuditEntry{
+			RequestID:  doc.ID,
+			Action:     models.AuditActionSubjectExported,
+			SubjectRef: subjectRef,
+			Details:    map[string]string{"format": format},
+			CreatedAt:  now,
+		})
+		if err != nil {
+			log.Printf("Error recording export audit entry for %s: %v", doc.ID, err)
+		}
+	}
+
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, subjectRef))
+		if err := writeSubjectExportZip(w, export); err != nil {
+			log.Printf("Error writing subject export archive: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(export)
+}
+
+// EraseSubjectData handles POST /api/subjects/{subject_id}/erasure
+func (h *SubjectHandler) EraseSubjectData(w http.ResponseWriter, r *http.Request) {
+	subjectID := mux.Vars(r)["subject_id"]
+
+	var req SubjectErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "Request body is not valid JSON", nil)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = models.ErasureModeErase
+	}
+	if req.Mode != models.ErasureModeErase && req.Mode != models.ErasureModePseudonymise {
+		WriteError(w, r, http.StatusUnprocessableEntity, ErrCodeValidation, "Unsupported erasure mode", map[string]string{
+			"mode": "must be erase or pseudonymise",
+		})
+		return
+	}
+
+	now := h.timeNow().UTC()
+	export, err := models.ExportSubject(h.store, h.ledger, subjectID, now)
+	if err != nil {
+		log.Printf("Error looking up subject data: %v", err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+	if export.IsEmpty() {
+		WriteError(w, r, http.StatusNotFound, ErrCodeNotFound, "No data held for subject", nil)
+		return
+	}
+
+	erasure, err := models.EraseSubject(h.store, h.ledger, h.audit, h.pseudonymizer, subjectID, req.Mode, now)
+	if err != nil {
+		log.Printf("Error erasing subject data: %v", err)
+		WriteError(w, r, http.StatusInternalServerError, ErrCodeInternal, "Internal server error", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(erasure)
+}
+
+// writeSubjectExportZip writes the export as subject.json plus one image file per captured signature
+func writeSubjectExportZip(w http.ResponseWriter, export models.SubjectExport) error {
+	archive := zip.NewWriter(w)
+
+	file, err := archive.Create("subject.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+
+	for _, doc := range export.Documents {
+		extension, data, ok := decodeImageDataURL(doc.SignatureData)
+		if !ok {
+			continue
+		}
+		file, err := archive.Create("signatures/" + doc.ID + "." + extension)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// decodeImageDataURL decodes a base64 "data:image/..." URL into its file extension and bytes
+func decodeImageDataURL(dataURL string) (string, []byte, bool) {
+	header, payload, found := strings.Cut(dataURL, ",")
+	if !found || !strings.HasPrefix(header, "data:image/") || !strings.HasSuffix(header, ";base64") {
+		return "", nil, false
+	}
+	extension := strings.TrimSuffix(strings.TrimPrefix(header, "data:image/"), ";base64")
+	if extension == "svg+xml" {
+		extension = "svg"
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, false
+	}
+	return extension, data, true
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSubjectTestRouter(t *testing.T) (*mux.Router, *models.InMemoryDocumentStore, *models.InMemoryAuditLog, string) {
+	store := models.NewInMemoryDocumentStore()
+	ledger := models.NewInMemoryConsentLedger()
+	audit := models.NewInMemoryAuditLog()
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	docID, _ := store.AddDocument(models.Document{
+		DocumentTitle: "Consent form",
+		DocumentContent: []models.DocumentSection{
+			{ID: "section1", Type: "text", Content: "Jane Doe, Main Street 1"},
+		},
+		SignerName:  "Jane Doe",
+		SignerEmail: "Jane@Example.com",
+		DeviceID:    "tablet1",
+		CallbackURL: "https://client.example.com/callback",
+		Status:      "pending",
+	})
+	// "hello" as a PNG data URL is enough to exercise the archive layout
+	store.UpdateDocumentSignature(docID, "data:image/png;base64,aGVsbG8=")
+	store.StoreConsents(docID, []models.Consent{{ConsentType: "marketing_email", Granted: true, Timestamp: now}})
+	doc, _ := store.GetDocument(docID)
+	ledger.RecordConsentEvents(models.ConsentEventsFromSignature(doc, doc.Consents, now))
+
+	handler := NewSubjectHandler(store, ledger, audit, models.NewPseudonymizer([]byte("secret")))
+	handler.timeNow = func() time.Time { return now }
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/subjects/{subject_id}/export", handler.ExportSubjectData).Methods(http.MethodGet)
+	router.HandleFunc("/api/subjects/{subject_id}/erasure", handler.EraseSubjectData).Methods(http.MethodPost)
+	return router, store, audit, docID
+}
+
+func TestSubjectHandler_Export(t *testing.T) {
+	router, _, audit, docID := newSubjectTestRouter(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedType   string
+	}{
+		{
+			name:           "JSON export",
+			path:           "/api/subjects/jane@example.com/export",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/json",
+		},
+		{
+			name:           "ZIP export",
+			path:           "/api/subjects/jane@example.com/export?format=zip",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/zip",
+		},
+		{
+			name:           "Unsupported format",
+			path:           "/api/subjects/jane@example.com/export?format=xml",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedType:   "application/json",
+		},
+		{
+			name:           "Unknown subject",
+			path:           "/api/subjects/nobody@example.com/export",
+			expectedStatus: http.StatusNotFound,
+			expectedType:   "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+		})
+	}
+
+	// JSON bundle contains the document, its signature and the consent history
+	req := httptest.NewRequest(http.MethodGet, "/api/subjects/jane@example.com/export", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var export models.SubjectExport
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&export))
+	assert.Equal(t, "jane@example.com", export.SubjectID)
+	assert.Len(t, export.Documents, 1)
+	assert.Equal(t, "Jane Doe", export.Documents[0].SignerName)
+	assert.Equal(t, "data:image/png;base64,aGVsbG8=", export.Documents[0].SignatureData)
+	assert.Len(t, export.ConsentHistory, 1)
+	assert.Len(t, export.ConsentState, 1)
+
+	// ZIP bundle contains the JSON and the decoded signature image
+	req = httptest.NewRequest(http.MethodGet, "/api/subjects/jane@example.com/export?format=zip", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		rc, _ := file.Open()
+		files[file.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	assert.Contains(t, files, "subject.json")
+	assert.Equal(t, []byte("hello"), files["signatures/"+docID+".png"])
+
+	entries, _ := audit.ListAuditEntries(docID)
+	assert.NotEmpty(t, entries)
+	assert.Equal(t, models.AuditActionSubjectExported, entries[0].Action)
+}
+
+func TestSubjectHandler_Erasure(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           string
+		expectedStatus int
+		expectedSigner func(ref string) string
+	}{
+		{
+			name:           "Erase",
+			mode:           models.ErasureModeErase,
+			expectedStatus: http.StatusOK,
+			expectedSigner: func(ref string) string { return "" },
+		},
+		{
+			name:           "Pseudonymise",
+			mode:           models.ErasureModePseudonymise,
+			expectedStatus: http.StatusOK,
+			expectedSigner: func(ref string) string { return ref },
+		},
+		{
+			name:           "Unsupported mode",
+			mode:           "shred",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store, audit, docID := newSubjectTestRouter(t)
+
+			body, _ := json.Marshal(SubjectErasureRequest{Mode: tt.mode})
+			req := httptest.NewRequest(http.MethodPost, "/api/subjects/jane@example.com/erasure", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var erasure models.SubjectErasure
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&erasure))
+			assert.Equal(t, []string{docID}, erasure.ErasedRequestIDs)
+			assert.Equal(t, 1, erasure.ConsentEventsErased)
+
+			doc, err := store.GetDocument(docID)
+			assert.NoError(t, err)
+			assert.Equal(t, models.StatusErased, doc.Status)
+			assert.Equal(t, tt.expectedSigner(erasure.SubjectRef), doc.SignerName)
+			assert.Equal(t, tt.expectedSigner(erasure.SubjectRef), doc.SignerEmail)
+			signatureData, err := store.GetSignatureData(docID)
+			assert.NoError(t, err)
+			assert.Empty(t, signatureData)
+			assert.Empty(t, doc.Consents)
+			assert.Empty(t, doc.DocumentContent)
+			assert.Equal(t, "Consent form", doc.DocumentTitle)
+
+			entries, _ := audit.ListAuditEntries(docID)
+			assert.Len(t, entries, 1)
+			assert.Equal(t, models.AuditActionSubjectErased, entries[0].Action)
+			assert.Equal(t, erasure.SubjectRef, entries[0].SubjectRef)
+
+			// Nothing is left to export afterwards
+			req = httptest.NewRequest(http.MethodGet, "/api/subjects/jane@example.com/export", nil)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		})
+	}
+}
@@ -1,53 +1,391 @@
 package i18n
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"fmt"
+	"io/fs"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
 )
 
 //go:embed locales/*.json
 var localeFS embed.FS
 
-var bundle *i18n.Bundle
-var localizer *i18n.Localizer
-var currentLang string
+// LanguageCookieName is the cookie holding the language chosen on a tablet
+const LanguageCookieName = "lang"
 
+// referenceLang is the language whose messages every other language should translate
+const referenceLang = "en"
+
+// catalog is a loaded set of translations. Reloading replaces the current catalog as a whole,
+// so requests in progress keep translating with the one they started with.
+type catalog struct {
+	defaultLang string
+	matcher     language.Matcher
+	supported   []language.Tag
+	localizers  map[language.Tag]*i18n.Localizer
+	// missing lists, per language, the message IDs of the reference language it lacks
+	missing map[string][]string
+}
+
+var current atomic.Pointer[catalog]
+
+// source is what the current catalog was loaded from, for Reload
+var source struct {
+	mu   sync.Mutex
+	lang string
+	dir  string
+}
+
+// languageKey is the context key of the language set by WithLanguage
+type languageKey struct{}
+
+// locale is the language of a context: the requested tag and the localizer of the closest
+// supported language
+type locale struct {
+	tag       string
+	localizer *i18n.Localizer
+}
+
+// Init loads the bundled translations with lang as the default language, used when a request
+// asks for none or for one that is not supported
 func Init(lang string) error {
-	currentLang = lang
-	// Create bundle with the requested language as default
-	bundle = i18n.NewBundle(language.MustParse(lang))
-	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+	return Load(lang, "")
+}
+
+// Load loads the bundled translations and then the *.json files in dir, if set. A file in dir
+// named after a bundled language, such as en.json, overrides the bundled messages it contains;
+// one named after another language, such as de.json, adds that language. Message IDs of en.json
+// missing from another language are logged; they are shown in the default language instead.
+func Load(lang, dir string) error {
+	source.mu.Lock()
+	defer source.mu.Unlock()
 
-	// Load all locale files
-	entries, err := localeFS.ReadDir("locales")
+	c, err := loadCatalog(lang, dir)
 	if err != nil {
 		return err
 	}
+	current.Store(c)
+	source.lang, source.dir = lang, dir
+
+	for _, name := range sortedKeys(c.missing) {
+		log.Printf("Language %s is missing %d messages: %s", name, len(c.missing[name]), strings.Join(c.missing[name], ", "))
+	}
+	log.Printf("Initialized i18n with languages %s, default language: %s", strings.Join(Languages(), ", "), c.defaultLang)
+	return nil
+}
+
+// Reload loads the translations again from where Load last loaded them. When they cannot be
+// loaded, the current translations are kept.
+func Reload() error {
+	source.mu.Lock()
+	lang, dir := source.lang, source.dir
+	source.mu.Unlock()
+	return Load(lang, dir)
+}
+
+// Watch reloads the translations whenever a *.json file in the directory they were loaded from
+// is added, changed or removed, checking every interval until the returned stop function is
+// called
+func Watch(interval time.Duration) func() {
+	source.mu.Lock()
+	dir := source.dir
+	source.mu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	state := dirState(dir)
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if next := dirState(dir); next != state {
+					state = next
+					log.Printf("Locale files in %s changed, reloading translations", dir)
+					if err := Reload(); err != nil {
+						log.Printf("Error reloading translations, keeping the current ones: %v", err)
+					}
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
+
+// dirState summarizes the names, sizes and modification times of the locale files in dir
+func dirState(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	var state strings.Builder
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&state, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return state.String()
+}
+
+func loadCatalog(lang, dir string) (*catalog, error) {
+	defaultTag, err := language.Parse(lang)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default language %q: %v", lang, err)
+	}
+	// Create bundle with the requested language as default
+	bundle := i18n.NewBundle(defaultTag)
+	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+	messageIDs := map[language.Tag]map[string]bool{}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			_, err = bundle.LoadMessageFileFS(localeFS, "locales/"+entry.Name())
+	// Load all locale files, the bundled ones first so that those in dir override them
+	bundled, err := fs.Glob(localeFS, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range bundled {
+		data, err := localeFS.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := addMessageFile(bundle, messageIDs, path, data); err != nil {
+			return nil, err
+		}
+	}
+	if dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
 			if err != nil {
-				return err
+				return nil, err
+			}
+			if err := addMessageFile(bundle, messageIDs, path, data); err != nil {
+				return nil, err
 			}
 		}
 	}
 
-	// Set the localizer with fallback languages
-	localizer = i18n.NewLocalizer(bundle, lang, "en")
-	log.Printf("Initialized i18n with language: %s", lang)
+	c := &catalog{
+		defaultLang: defaultTag.String(),
+		localizers:  map[language.Tag]*i18n.Localizer{},
+		missing:     map[string][]string{},
+	}
+
+	// The matcher falls back to its first tag, so the default language goes first
+	c.supported = []language.Tag{defaultTag}
+	for _, tag := range bundle.LanguageTags() {
+		if tag != defaultTag {
+			c.supported = append(c.supported, tag)
+		}
+	}
+	others := c.supported[1:]
+	sort.Slice(others, func(i, j int) bool { return others[i].String() < others[j].String() })
+	c.matcher = language.NewMatcher(c.supported)
+
+	// Set the localizers with fallback languages
+	for _, tag := range c.supported {
+		c.localizers[tag] = i18n.NewLocalizer(bundle, tag.String(), c.defaultLang, referenceLang)
+	}
+
+	reference := messageIDs[language.Make(referenceLang)]
+	for tag, ids := range messageIDs {
+		var missing []string
+		for id := range reference {
+			if !ids[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			c.missing[tag.String()] = missing
+		}
+	}
+	return c, nil
+}
+
+// addMessageFile adds the messages of a locale file to bundle, recording their IDs
+func addMessageFile(bundle *i18n.Bundle, messageIDs map[language.Tag]map[string]bool, path string, data []byte) error {
+	file, err := bundle.ParseMessageFileBytes(data, path)
+	if err != nil {
+		return fmt.Errorf("error loading locale file %s: %v", path, err)
+	}
+	if file.Tag == language.Und {
+		return fmt.Errorf("error loading locale file %s: its name is not a language, such as de.json", path)
+	}
+	if messageIDs[file.Tag] == nil {
+		messageIDs[file.Tag] = map[string]bool{}
+	}
+	for _, message := range file.Messages {
+		messageIDs[file.Tag][message.ID] = true
+	}
 	return nil
 }
 
-func GetLanguage() string {
-	return currentLang
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func T(messageID string, templateData map[string]interface{}) string {
+// Languages returns the supported languages, the default first
+func Languages() []string {
+	c := current.Load()
+	languages := make([]string, len(c.supported))
+	for i, tag := range c.supported {
+		languages[i] = tag.String()
+	}
+	return languages
+}
+
+// LanguageName returns the name of a language in that language, such as "polski" for pl
+func LanguageName(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return lang
+	}
+	if name := display.Self.Name(tag); name != "" {
+		return name
+	}
+	return lang
+}
+
+// Match parses a BCP 47 language tag such as en-GB and reports whether a supported language
+// is close enough to it. The tag is returned in canonical form.
+func Match(lang string) (string, bool) {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return "", false
+	}
+	_, _, confidence := current.Load().matcher.Match(tag)
+	return tag.String(), confidence != language.No
+}
+
+// WithLanguage returns a context that translates into lang. An empty or unsupported lang
+// leaves the context's language unchanged.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	if lang == "" {
+		return ctx
+	}
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ctx
+	}
+	return withTags(ctx, tag)
+}
+
+// withTags sets the language of a context to the best supported match for tags, in order of
+// preference. Without a match the context is unchanged.
+func withTags(ctx context.Context, tags ...language.Tag) context.Context {
+	if len(tags) == 0 {
+		return ctx
+	}
+	c := current.Load()
+	_, index, confidence := c.matcher.Match(tags...)
+	if confidence == language.No {
+		return ctx
+	}
+	// The requested tag is kept, with its region, for formatting dates and numbers
+	requested := tags[0]
+	for _, tag := range tags {
+		if _, _, confidence := c.matcher.Match(tag); confidence != language.No {
+			requested = tag
+			break
+		}
+	}
+	return context.WithValue(ctx, languageKey{}, locale{
+		tag:       requested.String(),
+		localizer: c.localizers[c.supported[index]],
+	})
+}
+
+// Language returns the language of a context, or the default language when none is set
+func Language(ctx context.Context) string {
+	if l, ok := ctx.Value(languageKey{}).(locale); ok {
+		return l.tag
+	}
+	return current.Load().defaultLang
+}
+
+// rightToLeftScripts are the scripts written from right to left, by ISO 15924 code
+var rightToLeftScripts = map[string]bool{
+	"Adlm": true, "Arab": true, "Hebr": true, "Mand": true, "Nkoo": true,
+	"Rohg": true, "Samr": true, "Syrc": true, "Thaa": true, "Yezi": true,
+}
+
+// Direction returns the direction in which the language of a context is written, "rtl" for
+// languages such as Arabic and Hebrew and "ltr" for the others, for the dir attribute of HTML
+func Direction(ctx context.Context) string {
+	if IsRightToLeft(ctx) {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// IsRightToLeft reports whether the language of a context is written from right to left
+func IsRightToLeft(ctx context.Context) bool {
+	tag, err := language.Parse(Language(ctx))
+	if err != nil {
+		return false
+	}
+	script, _ := tag.Script()
+	return rightToLeftScripts[script.String()]
+}
+
+// Middleware sets the language of each request to the one chosen on the tablet, stored in the
+// lang cookie, or else the best match for the Accept-Language header. The time zone is the
+// tablet's, stored in the tz cookie, if known.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if cookie, err := r.Cookie(TimezoneCookieName); err == nil {
+			ctx = WithTimezone(ctx, cookie.Value)
+		}
+		if cookie, err := r.Cookie(LanguageCookieName); err == nil && cookie.Value != "" {
+			ctx = WithLanguage(ctx, cookie.Value)
+		}
+		if _, ok := ctx.Value(languageKey{}).(locale); !ok {
+			if tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil {
+				ctx = withTags(ctx, tags...)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// T translates a message into the language of ctx
+func T(ctx context.Context, messageID string, templateData map[string]interface{}) string {
+	var localizer *i18n.Localizer
+	if l, ok := ctx.Value(languageKey{}).(locale); ok {
+		localizer = l.localizer
+	} else {
+		c := current.Load()
+		localizer = c.localizers[c.supported[0]]
+	}
 	msg, err := localizer.Localize(&i18n.LocalizeConfig{
 		MessageID:    messageID,
 		TemplateData: templateData,
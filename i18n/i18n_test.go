@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLocale(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en.json", `{"AppTitle": "Acme Sign"}`)
+	writeLocale(t, dir, "de.json", `{"AppTitle": "Dokumentensignatur", "SignHere": "Hier unterschreiben"}`)
+	assert.NoError(t, Load("en", dir))
+	t.Cleanup(func() { Init("en") })
+
+	assert.Equal(t, []string{"en", "ar", "de", "he", "pl"}, Languages())
+
+	tests := []struct {
+		name      string
+		lang      string
+		messageID string
+		expected  string
+	}{
+		{name: "Overridden message", lang: "en", messageID: "AppTitle", expected: "Acme Sign"},
+		{name: "Bundled message", lang: "en", messageID: "SignHere", expected: "Sign here"},
+		{name: "Added language", lang: "de", messageID: "SignHere", expected: "Hier unterschreiben"},
+		{name: "Missing message falls back", lang: "de", messageID: "Submit", expected: "Submit"},
+		{name: "Bundled language", lang: "pl", messageID: "AppTitle", expected: "System Podpisywania Dokumentów"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithLanguage(context.Background(), tt.lang)
+			assert.Equal(t, tt.expected, T(ctx, tt.messageID, nil))
+		})
+	}
+
+	// Every message of en.json but the two translated is reported missing from de.json
+	missing := current.Load().missing
+	assert.NotContains(t, missing, "en")
+	assert.NotContains(t, missing, "pl")
+	assert.Contains(t, missing["de"], "Submit")
+	assert.NotContains(t, missing["de"], "AppTitle")
+	assert.NotContains(t, missing["de"], "SignHere")
+}
+
+func TestDirection(t *testing.T) {
+	assert.NoError(t, Init("en"))
+
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{lang: "en", expected: "ltr"},
+		{lang: "pl", expected: "ltr"},
+		{lang: "ar", expected: "rtl"},
+		{lang: "ar-EG", expected: "rtl"},
+		{lang: "he", expected: "rtl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Direction(WithLanguage(context.Background(), tt.lang)))
+		})
+	}
+}
+
+func TestLoad_InvalidFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "Malformed JSON", file: "de.json", content: `{"AppTitle": `},
+		{name: "Not a language", file: "brand.json", content: `{"AppTitle": "Acme Sign"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLocale(t, dir, tt.file, tt.content)
+			assert.Error(t, Load("en", dir))
+		})
+	}
+	assert.Error(t, Load("not a language", ""))
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en.json", `{"AppTitle": "Acme Sign"}`)
+	assert.NoError(t, Load("en", dir))
+	t.Cleanup(func() { Init("en") })
+
+	writeLocale(t, dir, "en.json", `{"AppTitle": "Acme eSign"}`)
+	assert.NoError(t, Reload())
+	assert.Equal(t, "Acme eSign", T(context.Background(), "AppTitle", nil))
+
+	// A broken file keeps the translations loaded before it
+	writeLocale(t, dir, "en.json", `{"AppTitle": `)
+	assert.Error(t, Reload())
+	assert.Equal(t, "Acme eSign", T(context.Background(), "AppTitle", nil))
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, Load("en", dir))
+	t.Cleanup(func() { Init("en") })
+	stop := Watch(10 * time.Millisecond)
+	defer stop()
+
+	writeLocale(t, dir, "de.json", `{"SignHere": "Hier unterschreiben"}`)
+	assert.Eventually(t, func() bool {
+		return T(WithLanguage(context.Background(), "de"), "SignHere", nil) == "Hier unterschreiben"
+	}, time.Second, 10*time.Millisecond)
+}
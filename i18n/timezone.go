@@ -0,0 +1,74 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// TimezoneCookieName is the cookie holding the time zone of a tablet
+const TimezoneCookieName = "tz"
+
+var defaultLocation atomic.Pointer[time.Location]
+
+// timezoneKey is the context key of the time zone set by WithTimezone
+type timezoneKey struct{}
+
+// SetTimezone sets the default time zone, an IANA name such as Europe/Warsaw, used when a
+// request has none. Without it times are shown in UTC.
+func SetTimezone(name string) error {
+	location, err := LoadTimezone(name)
+	if err != nil {
+		return err
+	}
+	defaultLocation.Store(location)
+	return nil
+}
+
+// LoadTimezone returns the time zone with an IANA name such as Europe/Warsaw. Unlike
+// time.LoadLocation, it does not accept the empty name or Local, whose meaning depends on
+// the server.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %v", name, err)
+	}
+	return location, nil
+}
+
+// WithTimezone returns a context that shows times in the time zone with the given IANA name.
+// An empty or unknown name leaves the context's time zone unchanged.
+func WithTimezone(ctx context.Context, name string) context.Context {
+	location, err := LoadTimezone(name)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, timezoneKey{}, location)
+}
+
+// Timezone returns the time zone of a context, or the default time zone when none is set
+func Timezone(ctx context.Context) *time.Location {
+	if location, ok := ctx.Value(timezoneKey{}).(*time.Location); ok {
+		return location
+	}
+	if location := defaultLocation.Load(); location != nil {
+		return location
+	}
+	return time.UTC
+}
+
+// FormatDate formats the date of t in the time zone of ctx, with the DateFormat layout of its
+// language
+func FormatDate(ctx context.Context, t time.Time) string {
+	return t.In(Timezone(ctx)).Format(T(ctx, "DateFormat", nil))
+}
+
+// FormatDateTime formats the date and time of t in the time zone of ctx, with the
+// DateTimeFormat layout of its language
+func FormatDateTime(ctx context.Context, t time.Time) string {
+	return t.In(Timezone(ctx)).Format(T(ctx, "DateTimeFormat", nil))
+}
@@ -0,0 +1,47 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDate(t *testing.T) {
+	assert.NoError(t, Init("en"))
+	at := time.Date(2024, 6, 1, 23, 30, 5, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		lang             string
+		timezone         string
+		expectedDate     string
+		expectedDateTime string
+	}{
+		{name: "Default time zone", lang: "en", expectedDate: "Jun 1, 2024", expectedDateTime: "Jun 1, 2024 23:30:05 UTC"},
+		{name: "Time zone", lang: "en", timezone: "Europe/Warsaw", expectedDate: "Jun 2, 2024", expectedDateTime: "Jun 2, 2024 01:30:05 CEST"},
+		{name: "Language", lang: "pl", timezone: "Europe/Warsaw", expectedDate: "02.06.2024", expectedDateTime: "02.06.2024 01:30:05 CEST"},
+		{name: "Unknown time zone", lang: "pl", timezone: "Mars/Olympus_Mons", expectedDate: "01.06.2024", expectedDateTime: "01.06.2024 23:30:05 UTC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithTimezone(WithLanguage(context.Background(), tt.lang), tt.timezone)
+			assert.Equal(t, tt.expectedDate, FormatDate(ctx, at))
+			assert.Equal(t, tt.expectedDateTime, FormatDateTime(ctx, at))
+		})
+	}
+}
+
+func TestSetTimezone(t *testing.T) {
+	assert.NoError(t, SetTimezone("America/New_York"))
+	t.Cleanup(func() { defaultLocation.Store(nil) })
+	assert.Equal(t, "America/New_York", Timezone(context.Background()).String())
+	assert.Equal(t, "Asia/Tokyo", Timezone(WithTimezone(context.Background(), "Asia/Tokyo")).String())
+
+	for _, name := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		assert.Error(t, SetTimezone(name), name)
+	}
+	assert.Equal(t, "America/New_York", Timezone(context.Background()).String())
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jakubsacha/signature-collector/handlers"
 	"github.com/jakubsacha/signature-collector/i18n"
 	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/notify"
+	"github.com/jakubsacha/signature-collector/pdf"
+	"github.com/jakubsacha/signature-collector/s3"
+	"github.com/jakubsacha/signature-collector/static"
+	"github.com/jakubsacha/signature-collector/tsa"
 	"github.com/joho/godotenv"
 )
 
@@ -36,11 +47,31 @@ func main() {
 
 	// Initialize i18n
 	log.Println("Initializing i18n...")
-	err = i18n.Init(os.Getenv("LANGUAGE"))
+	localesDir := os.Getenv("LOCALES_DIR")
+	err = i18n.Load(os.Getenv("LANGUAGE"), localesDir)
 	if err != nil {
 		log.Fatalf("Error initializing i18n: %v", err)
 	}
+	if timezone := os.Getenv("TIMEZONE"); timezone != "" {
+		if err := i18n.SetTimezone(timezone); err != nil {
+			log.Fatalf("Error parsing TIMEZONE: %v", err)
+		}
+	}
 	log.Println("i18n initialized successfully")
+	if localesDir != "" {
+		// Pick up edited locale files on SIGHUP, or by themselves a few seconds after they change
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go func() {
+			for range hangup {
+				log.Println("Received SIGHUP, reloading translations...")
+				if err := i18n.Reload(); err != nil {
+					log.Printf("Error reloading translations, keeping the current ones: %v", err)
+				}
+			}
+		}()
+		i18n.Watch(5 * time.Second)
+	}
 
 	// Initialize the database
 	log.Println("Setting up database configuration...")
@@ -72,51 +103,315 @@ func main() {
 	log.Println("Database initialized successfully")
 
 	log.Println("Setting up document store...")
-	store := models.NewDBDocumentStore(db)
+	keyring, err := models.LoadKeyring(os.Getenv("ENCRYPTION_KEY_FILE"), os.Getenv("ENCRYPTION_KEY"), os.Getenv("ENCRYPTION_KEY_ID"))
+	if err != nil {
+		log.Fatalf("Error loading encryption keys: %v", err)
+	}
+	var storeOptions []models.DBDocumentStoreOption
+	if keyring != nil {
+		log.Printf("Encrypting personal data at rest with key %s", keyring.PrimaryID())
+		storeOptions = append(storeOptions, models.WithKeyring(keyring))
+	} else {
+		log.Println("ENCRYPTION_KEY not set, personal data is stored unencrypted")
+	}
+	blobs, err := loadBlobStore()
+	if err != nil {
+		log.Fatalf("Error setting up blob store: %v", err)
+	}
+	storeOptions = append(storeOptions, models.WithBlobStore(blobs))
+	store := models.NewDBDocumentStore(db, storeOptions...)
+	consentLedger := models.NewDBConsentLedger(db, keyring)
+
+	sealer, err := models.LoadSealer(os.Getenv("SEAL_KEY_FILE"))
+	if err != nil {
+		log.Fatalf("Error loading seal keys: %v", err)
+	}
+	if sealer != nil {
+		log.Printf("Sealing completed documents with key %s", sealer.KeyID())
+	} else {
+		log.Println("SEAL_KEY_FILE not set, completed documents are not sealed")
+	}
+
+	timestampRoots, err := tsa.LoadRoots(os.Getenv("TSA_ROOTS_FILE"))
+	if err != nil {
+		log.Fatalf("Error loading timestamp roots: %v", err)
+	}
+	var timestamper tsa.Timestamper
+	switch tsaURL := os.Getenv("TSA_URL"); tsaURL {
+	case "":
+		log.Println("TSA_URL not set, completed documents are not timestamped")
+	case "local":
+		localTSA, err := tsa.NewLocalTSA()
+		if err != nil {
+			log.Fatalf("Error starting local TSA: %v", err)
+		}
+		log.Println("Timestamping completed documents with the built-in local TSA, for development only")
+		timestamper = localTSA
+	default:
+		log.Printf("Timestamping completed documents with %s", tsaURL)
+		timestamper = tsa.NewClient(tsaURL).WithCredentials(os.Getenv("TSA_USERNAME"), os.Getenv("TSA_PASSWORD"))
+	}
+
+	pdfSigner, err := pdf.LoadSigner(os.Getenv("PDF_SIGNING_CERT_FILE"), os.Getenv("PDF_SIGNING_KEY_FILE"))
+	if err != nil {
+		log.Fatalf("Error loading PDF signing certificate: %v", err)
+	}
+	if pdfSigner != nil {
+		log.Printf("Signing PDFs as %s", pdfSigner.Certificate().Subject)
+		if timestamper != nil {
+			pdfSigner.WithTimestamper(timestamper)
+		}
+	} else {
+		log.Println("PDF_SIGNING_CERT_FILE not set, PDFs are not digitally signed")
+	}
+	var pdfFonts []pdf.FontFile
+	for _, path := range strings.Split(os.Getenv("PDF_FALLBACK_FONTS"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		font, err := pdf.ReadFontFile(path)
+		if err != nil {
+			log.Fatalf("Error loading PDF fallback font: %v", err)
+		}
+		log.Printf("Using font %s for characters the built-in PDF fonts lack", font.Name)
+		pdfFonts = append(pdfFonts, font)
+	}
+	auditLog := models.NewDBAuditLog(db)
+
+	pseudonymKey := []byte(os.Getenv("PSEUDONYM_KEY"))
+	if len(pseudonymKey) == 0 {
+		log.Println("PSEUDONYM_KEY not set, deriving the pseudonym key from API_TOKEN")
+		pseudonymKey = models.DerivePseudonymKey([]byte(os.Getenv("API_TOKEN")))
+	}
+	pseudonymizer := models.NewPseudonymizer(pseudonymKey)
+
+	retentionPolicy := models.RetentionPolicy{}
+	if policyFile := os.Getenv("RETENTION_POLICY_FILE"); policyFile != "" {
+		log.Printf("Loading retention policy from %s...", policyFile)
+		retentionPolicy, err = models.LoadRetentionPolicy(policyFile)
+		if err != nil {
+			log.Fatalf("Error loading retention policy: %v", err)
+		}
+	}
+	retentionJob := models.NewRetentionJob(store, auditLog, retentionPolicy)
+	stopRetention := func() {}
+	if len(retentionPolicy.Rules) > 0 {
+		interval := 24 * time.Hour
+		if value := os.Getenv("RETENTION_INTERVAL"); value != "" {
+			interval, err = time.ParseDuration(value)
+			if err != nil {
+				log.Fatalf("Error parsing RETENTION_INTERVAL: %v", err)
+			}
+		}
+		dryRun := os.Getenv("RETENTION_DRY_RUN") == "true"
+		log.Printf("Scheduling retention job with %d rules every %s (dry run: %t)", len(retentionPolicy.Rules), interval, dryRun)
+		stopRetention = retentionJob.Start(interval, dryRun)
+	}
+
+	pdfHandler := handlers.NewPDFHandler(store).WithSigner(pdfSigner).WithFonts(pdfFonts)
+
+	smtpFrom := os.Getenv("SMTP_FROM")
+	var smtpSender *notify.SMTPSender
+	switch smtpHost := os.Getenv("SMTP_HOST"); smtpHost {
+	case "":
+		log.Println("SMTP_HOST not set, signers cannot be emailed their copy or sign remotely")
+	case "local":
+		localSMTP, err := notify.NewLocalServer("", "")
+		if err != nil {
+			log.Fatalf("Error starting local SMTP server: %v", err)
+		}
+		if smtpFrom == "" {
+			smtpFrom = "Signature Collector <noreply@localhost>"
+		}
+		smtpSender, err = notify.NewSMTPSender(localSMTP.Host(), localSMTP.Port(), "", "", smtpFrom)
+		if err != nil {
+			log.Fatalf("Error configuring SMTP: %v", err)
+		}
+		log.Println("Emailing signers through the built-in local SMTP server, which keeps messages instead of delivering them, for development only")
+	default:
+		smtpSender, err = notify.LoadSMTPSender(smtpHost, os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), smtpFrom)
+		if err != nil {
+			log.Fatalf("Error configuring SMTP: %v", err)
+		}
+		log.Printf("Emailing signers through %s", smtpHost)
+	}
+	signerEmail := os.Getenv("SIGNER_EMAIL")
+	if signerEmail == "" {
+		signerEmail = handlers.SignerEmailAttachment
+	}
+	if signerEmail != handlers.SignerEmailAttachment && signerEmail != handlers.SignerEmailLink {
+		log.Fatalf("SIGNER_EMAIL must be %q or %q", handlers.SignerEmailAttachment, handlers.SignerEmailLink)
+	}
+	var signerMailer *handlers.SignerMailer
+	if smtpSender != nil {
+		signerMailer = handlers.NewSignerMailer(store, smtpSender, pdfHandler, signerEmail)
+	}
+
+	remoteSigningTTL := 72 * time.Hour
+	if value := os.Getenv("REMOTE_SIGNING_TTL"); value != "" {
+		remoteSigningTTL, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Error parsing REMOTE_SIGNING_TTL: %v", err)
+		}
+	}
+	// Remote signers verify their email address, so remote signing needs email
+	var remoteSigningHandler *handlers.RemoteSigningHandler
+	if signerMailer != nil {
+		remoteSigningHandler = handlers.NewRemoteSigningHandler(store, auditLog, signerMailer, remoteSigningTTL).
+			WithPublicURL(os.Getenv("PUBLIC_URL"))
+		log.Printf("Remote signing enabled, with links valid for %s", remoteSigningTTL)
+	}
 
 	log.Println("Configuring router...")
 	router := mux.NewRouter()
+	router.Use(handlers.RequestIDMiddleware)
+	router.Use(i18n.Middleware)
 
 	// API routes with token authentication
 	router.HandleFunc("/api/documents/signatures/request", tokenAuth(func(w http.ResponseWriter, r *http.Request) {
-		handlers.SignRequestHandler(w, r, store)
+		handlers.SignRequestHandler(w, r, store, remoteSigningHandler)
 	})).Methods(http.MethodPost)
 
 	router.HandleFunc("/api/documents/signatures/{request_id}/status", tokenAuth(func(w http.ResponseWriter, r *http.Request) {
 		handlers.SignatureStatusHandler(w, r, store)
 	})).Methods(http.MethodGet)
 
+	router.HandleFunc("/api/documents/signatures/{request_id}/signature", tokenAuth(func(w http.ResponseWriter, r *http.Request) {
+		handlers.SignatureImageHandler(w, r, store)
+	})).Methods(http.MethodGet)
+
+	router.HandleFunc("/api/documents/signatures/{request_id}/strokes", tokenAuth(func(w http.ResponseWriter, r *http.Request) {
+		handlers.SignatureStrokesHandler(w, r, store)
+	})).Methods(http.MethodGet)
+
 	router.HandleFunc("/api/documents/signatures/{request_id}", tokenAuth(func(w http.ResponseWriter, r *http.Request) {
 		handlers.DeleteSignatureHandler(w, r, store)
 	})).Methods(http.MethodDelete)
 
+	certificateHandler := handlers.NewCertificateHandler(store).
+		WithSealer(sealer).
+		WithTimestampRoots(timestampRoots)
+	router.HandleFunc("/api/documents/signatures/{request_id}/certificate", tokenAuth(certificateHandler.GetCertificate)).Methods(http.MethodGet)
+	router.HandleFunc("/api/certificates/verify", tokenAuth(certificateHandler.VerifyCertificate)).Methods(http.MethodPost)
+
+	router.HandleFunc("/api/documents/signatures/{request_id}/pdf", tokenAuth(pdfHandler.GetPDF)).Methods(http.MethodGet)
+
+	// Seal public keys are published without authentication so auditors can verify offline
+	router.HandleFunc("/api/seal/keys", func(w http.ResponseWriter, r *http.Request) {
+		handlers.SealKeysHandler(w, r, sealer)
+	}).Methods(http.MethodGet)
+
+	consentHandler := handlers.NewConsentHandler(consentLedger, store)
+	router.HandleFunc("/api/consents/{subject_id}", tokenAuth(consentHandler.GetConsentState)).Methods(http.MethodGet)
+	router.HandleFunc("/api/consents/{subject_id}/history", tokenAuth(consentHandler.GetConsentHistory)).Methods(http.MethodGet)
+	router.HandleFunc("/api/consents/{subject_id}/withdrawals", tokenAuth(consentHandler.WithdrawConsent)).Methods(http.MethodPost)
+
+	subjectHandler := handlers.NewSubjectHandler(store, consentLedger, auditLog, pseudonymizer)
+	router.HandleFunc("/api/subjects/{subject_id}/export", tokenAuth(subjectHandler.ExportSubjectData)).Methods(http.MethodGet)
+	router.HandleFunc("/api/subjects/{subject_id}/erasure", tokenAuth(subjectHandler.EraseSubjectData)).Methods(http.MethodPost)
+
+	router.HandleFunc("/api/retention/report", tokenAuth(func(w http.ResponseWriter, r *http.Request) {
+		handlers.RetentionReportHandler(w, r, retentionJob)
+	})).Methods(http.MethodGet)
+
+	signerCopy := os.Getenv("SIGNER_COPY")
+	if signerCopy != "" && signerCopy != handlers.SignerCopyLink && signerCopy != handlers.SignerCopyQR {
+		log.Fatalf("SIGNER_COPY must be %q or %q", handlers.SignerCopyLink, handlers.SignerCopyQR)
+	}
+	signerCopyTTL := 24 * time.Hour
+	if value := os.Getenv("SIGNER_COPY_TTL"); value != "" {
+		signerCopyTTL, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Error parsing SIGNER_COPY_TTL: %v", err)
+		}
+	}
+	if signerCopy != "" {
+		log.Printf("Offering signers a %s to download their copy, valid for %s", signerCopy, signerCopyTTL)
+	}
+
 	// Web routes with basic authentication
 	deviceEntryHandler := handlers.NewDeviceEntryHandler()
 	documentsHandler := handlers.NewDocumentsHandler(store)
-	signatureHandler := handlers.NewSignatureHandler(store)
+	signatureHandler := handlers.NewSignatureHandler(store).
+		WithConsentLedger(consentLedger).
+		WithSealer(sealer).
+		WithTimestamper(timestamper).
+		WithPublicURL(os.Getenv("PUBLIC_URL")).
+		WithInlineSignature(os.Getenv("CALLBACK_INLINE_SIGNATURE") == "true").
+		WithSignerCopy(signerCopy, signerCopyTTL).
+		WithSignerMailer(signerMailer).
+		WithAuditLog(auditLog)
 
 	// Register the documents handler routes
 	router.HandleFunc("/documents/{device_id}", basicAuth(documentsHandler.ListDocuments)).Methods("GET")
 
-	// Register signature handler routes
-	router.HandleFunc("/documents/sign/{request_id}", basicAuth(signatureHandler.ShowSignaturePage)).Methods("GET")
-	router.HandleFunc("/documents/sign/{request_id}", basicAuth(signatureHandler.ProcessSignature)).Methods("POST")
+	// Register signature handler routes, which remote signers reach with their session instead of
+	// credentials
+	signerAuth := basicAuth
+	if remoteSigningHandler != nil {
+		signerAuth = func(next http.HandlerFunc) http.HandlerFunc {
+			return remoteSigningHandler.SignerOrStaff(next, basicAuth(next))
+		}
+	}
+	router.HandleFunc("/documents/sign/{request_id}", signerAuth(signatureHandler.ShowSignaturePage)).Methods("GET")
+	router.HandleFunc("/documents/sign/{request_id}", signerAuth(signatureHandler.ProcessSignature)).Methods("POST")
+	router.HandleFunc("/documents/sign/{request_id}/verification", signerAuth(signatureHandler.VerifySigner)).Methods("POST")
+	router.HandleFunc("/documents/sign/{request_id}/verification/code", signerAuth(signatureHandler.SendVerificationCode)).Methods("POST")
+	router.HandleFunc("/documents/sign/{request_id}/pdf", signerAuth(signatureHandler.ServeDocumentPDF)).Methods("GET")
+	router.HandleFunc("/documents/sign/{request_id}/attachments/{attachment_id}", signerAuth(signatureHandler.ServeAttachment)).Methods("GET")
+	router.HandleFunc("/documents/sign/{request_id}/copy.svg", signerAuth(signatureHandler.ServeCopyQR)).Methods("GET")
+
+	// A remote signer opens their link and verifies their email address without credentials; the
+	// link can be used once and expires
+	if remoteSigningHandler != nil {
+		router.HandleFunc("/r/{token}", remoteSigningHandler.ShowVerification).Methods("GET")
+		router.HandleFunc("/r/{token}", remoteSigningHandler.Verify).Methods("POST")
+		router.HandleFunc("/r/{token}/code", remoteSigningHandler.SendCode).Methods("POST")
+	}
+
+	// A signer downloads their copy on their own phone, without credentials; the link expires
+	router.HandleFunc("/c/{token}", pdfHandler.ServeCopy).Methods("GET")
 
 	// Register root handler routes
 	router.HandleFunc("/", basicAuth(deviceEntryHandler.ShowForm)).Methods("GET")
 	router.HandleFunc("/", basicAuth(deviceEntryHandler.ProcessForm)).Methods("POST")
 
+	// Register the stylesheet and scripts of the tablet pages, which hold nothing secret
+	router.PathPrefix(static.Prefix).Handler(static.Handler()).Methods("GET", "HEAD")
+	if missing := static.Missing(); len(missing) > 0 {
+		log.Fatalf("Scripts %s are not vendored; run `make vendor` to embed them", strings.Join(missing, ", "))
+	}
+
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	// On SIGINT or SIGTERM, finish the requests in flight and stop the retention job before exiting
+	server := &http.Server{Addr: ":" + port, Handler: router}
+	shutdown, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		<-shutdown.Done()
+		log.Println("Shutting down server...")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+		}
+	}()
+
 	log.Printf("Attempting to start server on port %s...\n", port)
-	err = http.ListenAndServe(":"+port, router)
-	if err != nil {
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("Error starting server: %v", err)
 	}
+	<-closed
+	stopRetention()
+	log.Println("Server stopped")
 }
 
 // basicAuth is a middleware for basic authentication
@@ -125,6 +420,10 @@ func basicAuth(next http.HandlerFunc) http.HandlerFunc {
 		user, pass, ok := r.BasicAuth()
 		if !ok || !validateBasicAuth(user, pass) {
 			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			if handlers.WantsJSON(r) {
+				handlers.WriteError(w, r, http.StatusUnauthorized, handlers.ErrCodeUnauthorized, "Missing or invalid credentials", nil)
+				return
+			}
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -145,7 +444,7 @@ func tokenAuth(next http.HandlerFunc) http.HandlerFunc {
 		token := r.Header.Get("Authorization")
 
 		if !validateToken(token) {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			handlers.WriteError(w, r, http.StatusUnauthorized, handlers.ErrCodeUnauthorized, "Missing or invalid API token", nil)
 			return
 		}
 		next(w, r)
@@ -157,3 +456,22 @@ func validateToken(token string) bool {
 	expectedToken := os.Getenv("API_TOKEN")
 	return token == "Bearer "+expectedToken
 }
+
+// loadBlobStore returns the S3 bucket configured with S3_BUCKET, or the BLOB_DIR directory
+func loadBlobStore() (models.BlobStore, error) {
+	bucket, err := s3.LoadClient(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_BUCKET"), os.Getenv("S3_REGION"),
+		os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	if bucket != nil {
+		log.Printf("Storing blobs in S3 bucket %s", os.Getenv("S3_BUCKET"))
+		return bucket, nil
+	}
+	blobDir := os.Getenv("BLOB_DIR")
+	if blobDir == "" {
+		blobDir = "blobs"
+	}
+	log.Printf("Storing blobs in %s", blobDir)
+	return models.NewFileBlobStore(blobDir)
+}
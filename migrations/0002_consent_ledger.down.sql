@@ -0,0 +1 @@
+DROP TABLE IF EXISTS consent_events;
\ No newline at end of file
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS consent_events;
+
+CREATE TABLE consent_events (
+    id VARCHAR(255) PRIMARY KEY,
+    subject_id VARCHAR(255) NOT NULL,
+    consent_type VARCHAR(100) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    granted BOOLEAN NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    source_request_id VARCHAR(255),
+    reason TEXT,
+    occurred_at DATETIME NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_consent_events_subject ON consent_events (subject_id, consent_type);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS audit_log;
+
+CREATE TABLE audit_log (
+    id VARCHAR(255) PRIMARY KEY,
+    request_id VARCHAR(255),
+    action VARCHAR(50) NOT NULL,
+    subject_ref VARCHAR(255),
+    details JSON,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_log_request ON audit_log (request_id);
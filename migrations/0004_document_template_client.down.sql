@@ -0,0 +1,3 @@
+ALTER TABLE documents DROP COLUMN client_id;
+
+ALTER TABLE documents DROP COLUMN template_id;
\ No newline at end of file
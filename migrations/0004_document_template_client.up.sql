@@ -0,0 +1,3 @@
+ALTER TABLE documents ADD COLUMN template_id VARCHAR(100);
+
+ALTER TABLE documents ADD COLUMN client_id VARCHAR(100);
@@ -0,0 +1,5 @@
+ALTER TABLE documents DROP COLUMN signer_email_index;
+
+ALTER TABLE documents DROP COLUMN wrapped_key;
+
+ALTER TABLE documents DROP COLUMN encryption_key_id;
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE documents ADD COLUMN encryption_key_id VARCHAR(100);
+
+ALTER TABLE documents ADD COLUMN wrapped_key TEXT;
+
+ALTER TABLE documents ADD COLUMN signer_email_index VARCHAR(64);
+
+ALTER TABLE documents ADD COLUMN signer_name_encrypted VARCHAR(512) NOT NULL DEFAULT '';
+UPDATE documents SET signer_name_encrypted = signer_name;
+ALTER TABLE documents DROP COLUMN signer_name;
+ALTER TABLE documents RENAME COLUMN signer_name_encrypted TO signer_name;
+
+ALTER TABLE documents ADD COLUMN signer_email_encrypted VARCHAR(512) NOT NULL DEFAULT '';
+UPDATE documents SET signer_email_encrypted = signer_email;
+ALTER TABLE documents DROP COLUMN signer_email;
+ALTER TABLE documents RENAME COLUMN signer_email_encrypted TO signer_email;
@@ -0,0 +1 @@
+ALTER TABLE documents DROP COLUMN signature_strokes;
\ No newline at end of file
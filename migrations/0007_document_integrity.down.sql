@@ -0,0 +1,3 @@
+ALTER TABLE documents DROP COLUMN integrity_hash;
+
+ALTER TABLE documents DROP COLUMN completed_at;
\ No newline at end of file
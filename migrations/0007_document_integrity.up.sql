@@ -0,0 +1,3 @@
+ALTER TABLE documents ADD COLUMN completed_at DATETIME;
+
+ALTER TABLE documents ADD COLUMN integrity_hash VARCHAR(100);
@@ -0,0 +1 @@
+ALTER TABLE documents DROP COLUMN seal;
\ No newline at end of file
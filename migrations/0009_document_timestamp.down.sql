@@ -0,0 +1 @@
+ALTER TABLE documents DROP COLUMN trusted_timestamp;
\ No newline at end of file
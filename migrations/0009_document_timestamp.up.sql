@@ -0,0 +1 @@
+ALTER TABLE documents ADD COLUMN trusted_timestamp TEXT;
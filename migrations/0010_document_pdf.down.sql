@@ -0,0 +1,5 @@
+ALTER TABLE documents DROP COLUMN signature_fields;
+
+ALTER TABLE documents DROP COLUMN document_pdf_sha256;
+
+ALTER TABLE documents DROP COLUMN document_pdf;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE documents ADD COLUMN document_pdf LONGBLOB;
+
+ALTER TABLE documents ADD COLUMN document_pdf_sha256 VARCHAR(100);
+
+ALTER TABLE documents ADD COLUMN signature_fields TEXT;
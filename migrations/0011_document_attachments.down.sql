@@ -0,0 +1 @@
+ALTER TABLE documents DROP COLUMN attachments;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE documents ADD COLUMN attachments TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE documents DROP COLUMN document_pdf_key;
+
+ALTER TABLE documents DROP COLUMN signature_key;
\ No newline at end of file
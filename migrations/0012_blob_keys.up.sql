@@ -0,0 +1,3 @@
+ALTER TABLE documents ADD COLUMN signature_key VARCHAR(255);
+
+ALTER TABLE documents ADD COLUMN document_pdf_key VARCHAR(255);
@@ -0,0 +1 @@
+ALTER TABLE documents DROP COLUMN locale;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE documents ADD COLUMN locale VARCHAR(35);
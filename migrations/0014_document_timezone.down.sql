@@ -0,0 +1 @@
+ALTER TABLE documents DROP COLUMN timezone;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE documents ADD COLUMN timezone VARCHAR(64);
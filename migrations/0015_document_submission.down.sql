@@ -0,0 +1,2 @@
+ALTER TABLE documents DROP COLUMN captured_at;
+ALTER TABLE documents DROP COLUMN submission_id;
\ No newline at end of file
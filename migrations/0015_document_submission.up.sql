@@ -0,0 +1,2 @@
+ALTER TABLE documents ADD COLUMN submission_id VARCHAR(64);
+ALTER TABLE documents ADD COLUMN captured_at DATETIME;
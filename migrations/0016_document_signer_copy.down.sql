@@ -0,0 +1,5 @@
+-- SQLite form, MySQL runs 0016_document_signer_copy.mysql.down.sql instead
+DROP INDEX idx_documents_copy_token;
+ALTER TABLE documents DROP COLUMN copy_expires_at;
+ALTER TABLE documents DROP COLUMN copy_token;
+ALTER TABLE documents DROP COLUMN review;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- MySQL names the table of the index, see 0016_document_signer_copy.down.sql for SQLite
+DROP INDEX idx_documents_copy_token ON documents;
+ALTER TABLE documents DROP COLUMN copy_expires_at;
+ALTER TABLE documents DROP COLUMN copy_token;
+ALTER TABLE documents DROP COLUMN review;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE documents ADD COLUMN review BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE documents ADD COLUMN copy_token VARCHAR(32);
+ALTER TABLE documents ADD COLUMN copy_expires_at DATETIME;
+CREATE UNIQUE INDEX idx_documents_copy_token ON documents (copy_token);
@@ -0,0 +1,4 @@
+ALTER TABLE documents DROP COLUMN email_error;
+ALTER TABLE documents DROP COLUMN email_status_at;
+ALTER TABLE documents DROP COLUMN email_status;
+ALTER TABLE documents DROP COLUMN email_copy;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE documents ADD COLUMN email_copy BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE documents ADD COLUMN email_status VARCHAR(20);
+ALTER TABLE documents ADD COLUMN email_status_at DATETIME;
+ALTER TABLE documents ADD COLUMN email_error TEXT;
@@ -0,0 +1,11 @@
+-- SQLite form, MySQL runs 0018_document_remote.mysql.down.sql instead
+DROP INDEX idx_documents_remote_token;
+ALTER TABLE documents DROP COLUMN otp_sends;
+ALTER TABLE documents DROP COLUMN otp_attempts;
+ALTER TABLE documents DROP COLUMN otp_expires_at;
+ALTER TABLE documents DROP COLUMN otp_sent_at;
+ALTER TABLE documents DROP COLUMN otp_hash;
+ALTER TABLE documents DROP COLUMN remote_session;
+ALTER TABLE documents DROP COLUMN remote_expires_at;
+ALTER TABLE documents DROP COLUMN remote_token;
+ALTER TABLE documents DROP COLUMN remote;
\ No newline at end of file
@@ -0,0 +1,10 @@
+ALTER TABLE documents ADD COLUMN remote BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE documents ADD COLUMN remote_token VARCHAR(64);
+ALTER TABLE documents ADD COLUMN remote_expires_at DATETIME;
+ALTER TABLE documents ADD COLUMN remote_session VARCHAR(64);
+ALTER TABLE documents ADD COLUMN otp_hash VARCHAR(64);
+ALTER TABLE documents ADD COLUMN otp_sent_at DATETIME;
+ALTER TABLE documents ADD COLUMN otp_expires_at DATETIME;
+ALTER TABLE documents ADD COLUMN otp_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE documents ADD COLUMN otp_sends INT NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX idx_documents_remote_token ON documents (remote_token);
@@ -0,0 +1,5 @@
+ALTER TABLE documents DROP COLUMN verification_at;
+ALTER TABLE documents DROP COLUMN verification_outcome;
+ALTER TABLE documents DROP COLUMN verification_attempts;
+ALTER TABLE documents DROP COLUMN verification_secret;
+ALTER TABLE documents DROP COLUMN verification_method;
\ No newline at end of file
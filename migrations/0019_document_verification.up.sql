@@ -0,0 +1,5 @@
+ALTER TABLE documents ADD COLUMN verification_method VARCHAR(16);
+ALTER TABLE documents ADD COLUMN verification_secret VARCHAR(512);
+ALTER TABLE documents ADD COLUMN verification_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE documents ADD COLUMN verification_outcome VARCHAR(16);
+ALTER TABLE documents ADD COLUMN verification_at DATETIME;
@@ -0,0 +1 @@
+-- Copy links invalidated by the up migration cannot be restored
@@ -0,0 +1,2 @@
+-- Copy links were stored with their plain token and are now looked up by its hash
+UPDATE documents SET copy_token = NULL, copy_expires_at = NULL;
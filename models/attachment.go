@@ -0,0 +1,88 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// maxAttachmentFilename bounds the length of an attachment's file name in bytes
+const maxAttachmentFilename = 255
+
+// ErrAttachmentNotFound is returned by a DocumentStore when a document has no attachment with
+// the requested ID
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// Attachment is supporting material shown to the signer beside the document, such as a price
+// list or a photo. Its content is kept in the blob store and covered by the completion record
+// through its digest.
+type Attachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	SHA256      string `json:"sha256"`
+	// Data is the content. It is only written by AddDocument; read it with GetAttachment.
+	Data []byte `json:"-"`
+}
+
+// NewAttachment creates an attachment with a new ID. The content type is detected from the
+// data rather than trusted from the upload.
+func NewAttachment(filename string, data []byte) Attachment {
+	digest := sha256.Sum256(data)
+	return Attachment{
+		ID:          uuid.NewString(),
+		Filename:    cleanFilename(filename),
+		ContentType: http.DetectContentType(data),
+		Size:        len(data),
+		SHA256:      hex.EncodeToString(digest[:]),
+		Data:        data,
+	}
+}
+
+// MediaType returns the content type without parameters, such as text/plain
+func (a Attachment) MediaType() string {
+	mediaType, _, err := mime.ParseMediaType(a.ContentType)
+	if err != nil {
+		return a.ContentType
+	}
+	return mediaType
+}
+
+// IsImage reports whether the tablet can show the attachment inline as an image
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MediaType(), "image/")
+}
+
+// cleanFilename keeps the base name of an uploaded file without control characters, so it is
+// safe to show and to send in a Content-Disposition header
+func cleanFilename(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, filename)
+	for len(filename) > maxAttachmentFilename {
+		_, size := utf8.DecodeLastRuneInString(filename)
+		filename = filename[:len(filename)-size]
+	}
+	if filename == "" || filename == "." || filename == "/" {
+		return "attachment"
+	}
+	return filename
+}
+
+// attachmentKey is the blob store key of an attachment's content
+func attachmentKey(requestID, attachmentID string) string {
+	return "attachments/" + requestID + "/" + attachmentID
+}
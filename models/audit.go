@@ -0,0 +1,139 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit log actions
+const (
+	AuditActionSubjectExported = "subject_exported"
+	AuditActionSubjectErased   = "subject_erased"
+	AuditActionSignerVerified  = "signer_verified"
+	// AuditActionSignerVerificationFailed is recorded when a signer gave too many wrong answers
+	AuditActionSignerVerificationFailed = "signer_verification_failed"
+)
+
+// AuditEntry is a minimal, non-personal record of an operation on a document or data subject
+type AuditEntry struct {
+	ID         string            `json:"id"`
+	RequestID  string            `json:"request_id,omitempty"`
+	Action     string            `json:"action"`
+	SubjectRef string            `json:"subject_ref,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// AuditLog stores audit entries.
+// This allows for mocking in tests.
+type AuditLog interface {
+	RecordAudit(entry AuditEntry) error
+	ListAuditEntries(requestID string) ([]AuditEntry, error)
+}
+
+func NewDBAuditLog(db *sql.DB) AuditLog {
+	return &DBAuditLog{db: db}
+}
+
+// DBAuditLog stores audit entries in the audit_log table
+type DBAuditLog struct {
+	db *sql.DB
+}
+
+// RecordAudit appends an entry to the audit log
+func (l DBAuditLog) RecordAudit(entry AuditEntry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return fmt.Errorf("error marshaling audit details: %v", err)
+	}
+
+	query := "INSERT INTO audit_log (id, request_id, action, subject_ref, details, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err = l.db.Exec(query, entry.ID, entry.RequestID, entry.Action, entry.SubjectRef, details, entry.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("error inserting audit entry: %v", err)
+	}
+	return nil
+}
+
+// ListAuditEntries lists all audit entries for a request, oldest first
+func (l DBAuditLog) ListAuditEntries(requestID string) ([]AuditEntry, error) {
+	query := `
+		SELECT id, request_id, action, subject_ref, details, created_at
+		FROM audit_log
+		WHERE request_id = ?
+		ORDER BY created_at ASC`
+
+	rows, err := l.db.Query(query, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying audit log: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var entryRequestID, subjectRef, details sql.NullString
+		if err := rows.Scan(&entry.ID, &entryRequestID, &entry.Action, &subjectRef, &details, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %v", err)
+		}
+		entry.RequestID = entryRequestID.String
+		entry.SubjectRef = subjectRef.String
+		if details.Valid && details.String != "" {
+			if err := json.Unmarshal([]byte(details.String), &entry.Details); err != nil {
+				return nil, fmt.Errorf("error unmarshaling audit details: %v", err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return entries, nil
+}
+
+// InMemoryAuditLog is an in-memory implementation of the AuditLog interface
+// for testing purposes.
+type InMemoryAuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func NewInMemoryAuditLog() *InMemoryAuditLog {
+	return &InMemoryAuditLog{}
+}
+
+func (m *InMemoryAuditLog) RecordAudit(entry AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *InMemoryAuditLog) ListAuditEntries(requestID string) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []AuditEntry
+	for _, entry := range m.entries {
+		if entry.RequestID == requestID {
+			result = append(result, entry)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
@@ -0,0 +1,204 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrBlobNotFound is returned by a BlobStore when nothing is stored under a key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps binary objects outside the database, such as signatures, PDF documents and
+// attachments. Keys are slash-separated paths like "attachments/<request ID>/<attachment ID>".
+type BlobStore interface {
+	PutBlob(key string, data []byte) error
+	GetBlob(key string) ([]byte, error)
+	DeleteBlob(key string) error
+}
+
+// signatureKey is the blob store key of a document's signature data
+func signatureKey(requestID string) string {
+	return "signatures/" + requestID
+}
+
+// signatureKeyFor is the blob store key of a document's signature data encrypted under a data
+// key wrapped with keyID, so re-encrypting never overwrites the blob the row points to
+func signatureKeyFor(requestID, keyID string) string {
+	return signatureKey(requestID) + "." + keyID
+}
+
+// documentPDFKey is the blob store key of a document's original PDF
+func documentPDFKey(requestID string) string {
+	return "documents/" + requestID + ".pdf"
+}
+
+// validBlobKey rejects keys that are empty, absolute or would escape the store
+func validBlobKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
+
+// FileBlobStore stores blobs as files below a directory
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore creates a blob store in dir, creating the directory if needed
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating blob directory: %v", err)
+	}
+	return &FileBlobStore{dir: dir}, nil
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	if err := validBlobKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// PutBlob writes the blob to a temporary file and renames it into place, so readers never see
+// a partly written blob
+func (s *FileBlobStore) PutBlob(key string, data []byte) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return fmt.Errorf("error creating blob directory: %v", err)
+	}
+	file, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating blob: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing blob: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing blob: %v", err)
+	}
+	return os.Rename(file.Name(), name)
+}
+
+func (s *FileBlobStore) GetBlob(key string) ([]byte, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return data, err
+}
+
+// DeleteBlob removes the blob. Deleting a missing blob is not an error.
+func (s *FileBlobStore) DeleteBlob(key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// InMemoryBlobStore is an in-memory implementation of the BlobStore interface
+// for testing purposes.
+type InMemoryBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func NewInMemoryBlobStore() *InMemoryBlobStore {
+	return &InMemoryBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (m *InMemoryBlobStore) PutBlob(key string, data []byte) error {
+	if err := validBlobKey(key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *InMemoryBlobStore) GetBlob(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrBlobNotFound
+	}
+	return data, nil
+}
+
+func (m *InMemoryBlobStore) DeleteBlob(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+// MoveToBlobStore moves signature data and PDF documents still held in the documents table
+// into the blob store, leaving a reference to their key, and returns the number of documents
+// moved. Values are moved as stored, so encrypted signatures stay encrypted. Running it again
+// after it completes is a no-op.
+func MoveToBlobStore(db *sql.DB, blobs BlobStore) (int, error) {
+	rows, err := db.Query("SELECT id FROM documents WHERE signature_data IS NOT NULL OR document_pdf IS NOT NULL")
+	if err != nil {
+		return 0, fmt.Errorf("error querying documents: %v", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning document: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	// Documents are moved one at a time, as PDFs may be large
+	for _, id := range ids {
+		var signatureData sql.NullString
+		var documentPDF []byte
+		if err := db.QueryRow("SELECT signature_data, document_pdf FROM documents WHERE id = ?", id).Scan(&signatureData, &documentPDF); err != nil {
+			return 0, fmt.Errorf("error reading document %s: %v", id, err)
+		}
+		if signatureData.Valid {
+			if err := blobs.PutBlob(signatureKey(id), []byte(signatureData.String)); err != nil {
+				return 0, fmt.Errorf("error storing signature of document %s: %v", id, err)
+			}
+			if _, err := db.Exec("UPDATE documents SET signature_data = NULL, signature_key = ? WHERE id = ?", signatureKey(id), id); err != nil {
+				return 0, fmt.Errorf("error updating document %s: %v", id, err)
+			}
+		}
+		if documentPDF != nil {
+			if err := blobs.PutBlob(documentPDFKey(id), documentPDF); err != nil {
+				return 0, fmt.Errorf("error storing PDF of document %s: %v", id, err)
+			}
+			if _, err := db.Exec("UPDATE documents SET document_pdf = NULL, document_pdf_key = ? WHERE id = ?", documentPDFKey(id), id); err != nil {
+				return 0, fmt.Errorf("error updating document %s: %v", id, err)
+			}
+		}
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobStores(t *testing.T) {
+	fileStore, err := NewFileBlobStore(t.TempDir())
+	assert.NoError(t, err)
+
+	stores := map[string]BlobStore{
+		"file":      fileStore,
+		"in-memory": NewInMemoryBlobStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			assert.NoError(t, store.PutBlob("attachments/abc/1", []byte("first")))
+			assert.NoError(t, store.PutBlob("attachments/abc/1", []byte("replaced")))
+			data, err := store.GetBlob("attachments/abc/1")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("replaced"), data)
+
+			assert.NoError(t, store.DeleteBlob("attachments/abc/1"))
+			_, err = store.GetBlob("attachments/abc/1")
+			assert.ErrorIs(t, err, ErrBlobNotFound)
+			assert.NoError(t, store.DeleteBlob("attachments/abc/1"))
+
+			for _, key := range []string{"", "/etc/passwd", "../outside", "attachments/../../outside", "attachments//1"} {
+				assert.Error(t, store.PutBlob(key, []byte("data")), key)
+			}
+		})
+	}
+}
+
+func TestNewAttachment(t *testing.T) {
+	tests := []struct {
+		filename            string
+		data                []byte
+		expectedFilename    string
+		expectedContentType string
+		expectedImage       bool
+	}{
+		{"photo.png", []byte("\x89PNG\r\n\x1a\n"), "photo.png", "image/png", true},
+		{`C:\Users\jan\prices.txt`, []byte("Prices"), "prices.txt", "text/plain; charset=utf-8", false},
+		{"../../etc/\"passwd\"\r\n", []byte("%PDF-1.7"), "passwd", "application/pdf", false},
+		{"", []byte("Prices"), "attachment", "text/plain; charset=utf-8", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			attachment := NewAttachment(tt.filename, tt.data)
+			assert.NotEmpty(t, attachment.ID)
+			assert.Equal(t, tt.expectedFilename, attachment.Filename)
+			assert.Equal(t, tt.expectedContentType, attachment.ContentType)
+			assert.Equal(t, tt.expectedImage, attachment.IsImage())
+			assert.Equal(t, len(tt.data), attachment.Size)
+			assert.Equal(t, sha256Hex(tt.data), attachment.SHA256)
+		})
+	}
+}
@@ -11,13 +11,34 @@ import (
 
 // CallbackPayload represents the data sent to the callback URL
 type CallbackPayload struct {
-	RequestID     string    `json:"request_id"`
-	Status        string    `json:"status"`
-	SignerName    string    `json:"signer_name"`
-	SignerEmail   string    `json:"signer_email"`
-	SignatureData string    `json:"signature_data"`
-	Consents      []Consent `json:"consents"`
-	CompletedAt   time.Time `json:"completed_at"`
+	RequestID      string        `json:"request_id"`
+	Status         string        `json:"status"`
+	SignerName     string        `json:"signer_name"`
+	SignerEmail    string        `json:"signer_email"`
+	SignatureURL   string        `json:"signature_url"`
+	SignatureData  string        `json:"signature_data,omitempty"`
+	Consents       []Consent     `json:"consents"`
+	CompletedAt    time.Time     `json:"completed_at"`
+	CapturedAt     *time.Time    `json:"captured_at,omitempty"`
+	Verification   *Verification `json:"verification,omitempty"`
+	Timezone       string        `json:"timezone,omitempty"`
+	IntegrityHash  string        `json:"integrity_hash,omitempty"`
+	Seal           *Seal         `json:"seal,omitempty"`
+	Timestamp      *Timestamp    `json:"timestamp,omitempty"`
+	CertificateURL string        `json:"certificate_url,omitempty"`
+	PDFURL         string        `json:"pdf_url,omitempty"`
+}
+
+// ConsentWithdrawalPayload represents the data sent to the callback URL when a consent is withdrawn
+type ConsentWithdrawalPayload struct {
+	Event          string    `json:"event"`
+	RequestID      string    `json:"request_id"`
+	SubjectID      string    `json:"subject_id"`
+	ConsentType    string    `json:"consent_type"`
+	Granted        bool      `json:"granted"`
+	Reason         string    `json:"reason,omitempty"`
+	WithdrawnAt    time.Time `json:"withdrawn_at"`
+	ConsentEventID string    `json:"consent_event_id"`
 }
 
 // retryConfig holds configuration for retry behavior
@@ -30,6 +51,7 @@ type retryConfig struct {
 // CallbackSender handles sending callbacks with configurable behavior
 type CallbackSender struct {
 	client    *http.Client
+	baseURL   string
 	cfg       retryConfig
 	timeNow   func() time.Time
 	sleepFunc func(time.Duration)
@@ -57,6 +79,12 @@ func (s *CallbackSender) WithClient(client *http.Client) *CallbackSender {
 	return s
 }
 
+// WithBaseURL sets the public URL of this service, used to build absolute signature URLs
+func (s *CallbackSender) WithBaseURL(baseURL string) *CallbackSender {
+	s.baseURL = baseURL
+	return s
+}
+
 // WithRetryConfig sets custom retry configuration
 func (s *CallbackSender) WithRetryConfig(cfg retryConfig) *CallbackSender {
 	s.cfg = cfg
@@ -88,7 +116,8 @@ func calculateBackoff(attempt int, cfg retryConfig) time.Duration {
 	return delay
 }
 
-// SendCallback sends a POST request to the callback URL with signature details
+// SendCallback sends a POST request to the callback URL with signature details. The signature
+// image is referenced by URL; signatureData is only embedded in the payload when not empty.
 func (s *CallbackSender) SendCallback(doc Document, signatureData string, consents []Consent) error {
 	if doc.CallbackURL == "" {
 		return fmt.Errorf("no callback URL provided")
@@ -99,20 +128,79 @@ func (s *CallbackSender) SendCallback(doc Document, signatureData string, consen
 		Status:        doc.Status,
 		SignerName:    doc.SignerName,
 		SignerEmail:   doc.SignerEmail,
+		SignatureURL:  SignatureImageURL(s.baseURL, doc.ID),
 		SignatureData: signatureData,
 		Consents:      consents,
 		CompletedAt:   s.timeNow(),
 	}
+	if doc.CompletedAt != nil {
+		payload.CompletedAt = *doc.CompletedAt
+	}
+	// A signature queued on the tablet while it was offline is captured before it is completed
+	if doc.CapturedAt != nil {
+		capturedAt := *doc.CapturedAt
+		payload.CapturedAt = &capturedAt
+	}
+	payload.Verification = doc.VerificationResult()
+	// The completion time is given in the time zone the document was signed in
+	if doc.Timezone != "" {
+		payload.CompletedAt = payload.CompletedAt.In(doc.Location())
+		if payload.CapturedAt != nil {
+			capturedAt := payload.CapturedAt.In(doc.Location())
+			payload.CapturedAt = &capturedAt
+		}
+		if payload.Verification != nil {
+			payload.Verification.At = payload.Verification.At.In(doc.Location())
+		}
+		payload.Timezone = doc.Timezone
+	}
+	if doc.IntegrityHash != "" {
+		payload.IntegrityHash = doc.IntegrityHash
+		payload.Seal = doc.Seal
+		payload.Timestamp = doc.Timestamp
+		payload.CertificateURL = CertificateURL(s.baseURL, doc.ID)
+		payload.PDFURL = PDFURL(s.baseURL, doc.ID)
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("error marshaling callback payload: %v", err)
 	}
 
+	return s.deliver(doc.CallbackURL, doc.ID, jsonData)
+}
+
+// SendConsentWithdrawal notifies the callback URL of the request a consent was originally granted in
+func (s *CallbackSender) SendConsentWithdrawal(callbackURL string, event ConsentEvent) error {
+	if callbackURL == "" {
+		return fmt.Errorf("no callback URL provided")
+	}
+
+	payload := ConsentWithdrawalPayload{
+		Event:          "consent.withdrawn",
+		RequestID:      event.SourceRequestID,
+		SubjectID:      event.SubjectID,
+		ConsentType:    event.ConsentType,
+		Granted:        event.Granted,
+		Reason:         event.Reason,
+		WithdrawnAt:    event.OccurredAt,
+		ConsentEventID: event.ID,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling consent withdrawal payload: %v", err)
+	}
+
+	return s.deliver(callbackURL, event.SourceRequestID, jsonData)
+}
+
+// deliver posts the payload to the callback URL, retrying with exponential backoff
+func (s *CallbackSender) deliver(url string, requestID string, jsonData []byte) error {
 	var lastErr error
 	for attempt := 0; attempt < s.cfg.maxRetries; attempt++ {
-		log.Printf("Sending callback for document %s, attempt %d", doc.ID, attempt)
-		err := s.makeCallbackRequest(doc.CallbackURL, jsonData)
+		log.Printf("Sending callback for document %s, attempt %d", requestID, attempt)
+		err := s.makeCallbackRequest(url, jsonData)
 		if err == nil {
 			return nil
 		}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -206,3 +207,68 @@ func TestCallbackSender_WithRetryConfig(t *testing.T) {
 	sender := NewCallbackSender().WithRetryConfig(customConfig)
 	assert.Equal(t, customConfig, sender.cfg)
 }
+
+func TestCallbackSender_SignatureURL(t *testing.T) {
+	var payload map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	doc := Document{ID: "123", Status: "completed", CallbackURL: ts.URL}
+
+	// The signature is referenced by URL and not embedded by default
+	err := NewCallbackSender().WithBaseURL("https://sign.example.com/").SendCallback(doc, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sign.example.com/api/documents/signatures/123/signature", payload["signature_url"])
+	assert.NotContains(t, payload, "signature_data")
+
+	payload = nil
+	err = NewCallbackSender().SendCallback(doc, "data:image/png;base64,AA==", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/documents/signatures/123/signature", payload["signature_url"])
+	assert.Equal(t, "data:image/png;base64,AA==", payload["signature_data"])
+}
+
+func TestCallbackSender_Completion(t *testing.T) {
+	var payload map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	completedAt := time.Date(2024, 6, 10, 12, 30, 0, 0, time.UTC)
+	doc := Document{ID: "123", Status: "completed", CallbackURL: ts.URL, CompletedAt: &completedAt, IntegrityHash: "sha256:abc"}
+
+	err := NewCallbackSender().WithBaseURL("https://sign.example.com").SendCallback(doc, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-06-10T12:30:00Z", payload["completed_at"])
+	assert.Equal(t, "sha256:abc", payload["integrity_hash"])
+	assert.Equal(t, "https://sign.example.com/api/documents/signatures/123/certificate", payload["certificate_url"])
+	assert.Equal(t, "https://sign.example.com/api/documents/signatures/123/pdf", payload["pdf_url"])
+}
+
+func TestCallbackSender_Timezone(t *testing.T) {
+	var payload map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	completedAt := time.Date(2024, 6, 10, 12, 30, 0, 0, time.UTC)
+	capturedAt := time.Date(2024, 6, 10, 9, 15, 0, 0, time.UTC)
+	verifiedAt := time.Date(2024, 6, 10, 9, 14, 0, 0, time.UTC)
+	doc := Document{ID: "123", Status: "completed", CallbackURL: ts.URL, CompletedAt: &completedAt, CapturedAt: &capturedAt, Timezone: "Europe/Warsaw",
+		VerificationMethod: VerificationIDDigits, VerificationOutcome: VerificationVerified, VerificationAttempts: 2, VerificationAt: &verifiedAt}
+
+	// The completion, capture and verification times are in the time zone the document was signed in
+	err := NewCallbackSender().SendCallback(doc, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-06-10T14:30:00+02:00", payload["completed_at"])
+	assert.Equal(t, "2024-06-10T11:15:00+02:00", payload["captured_at"])
+	assert.Equal(t, map[string]interface{}{"method": "id_digits", "outcome": "verified", "attempts": float64(2), "at": "2024-06-10T11:14:00+02:00"}, payload["verification"])
+	assert.Equal(t, "Europe/Warsaw", payload["timezone"])
+}
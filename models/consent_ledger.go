@@ -0,0 +1,269 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Consent ledger actions
+const (
+	ConsentActionGrant    = "grant"
+	ConsentActionDeny     = "deny"
+	ConsentActionWithdraw = "withdraw"
+)
+
+// Consent ledger sources
+const (
+	ConsentSourceSignature = "signature"
+	ConsentSourceAPI       = "api"
+)
+
+// ConsentEvent is a single entry in the consent ledger
+type ConsentEvent struct {
+	ID              string    `json:"id"`
+	SubjectID       string    `json:"subject_id"`
+	ConsentType     string    `json:"consent_type"`
+	Action          string    `json:"action"`
+	Granted         bool      `json:"granted"`
+	Source          string    `json:"source"`
+	SourceRequestID string    `json:"source_request_id,omitempty"`
+	Reason          string    `json:"reason,omitempty"`
+	OccurredAt      time.Time `json:"occurred_at"`
+}
+
+// ConsentState is the current state of one consent type for a subject
+type ConsentState struct {
+	ConsentType     string    `json:"consent_type"`
+	Granted         bool      `json:"granted"`
+	LastAction      string    `json:"last_action"`
+	SourceRequestID string    `json:"source_request_id,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// ConsentLedger records consent grants and withdrawals per data subject.
+// This allows for mocking in tests.
+type ConsentLedger interface {
+	RecordConsentEvents(events []ConsentEvent) error
+	ListConsentHistory(subjectID string) ([]ConsentEvent, error)
+	EraseSubject(subjectID string, pseudonym string) (int, error)
+}
+
+// NormalizeSubjectID returns the canonical ledger key for a signer email or subject ID
+func NormalizeSubjectID(subjectID string) string {
+	return strings.ToLower(strings.TrimSpace(subjectID))
+}
+
+// ConsentEventsFromSignature converts the consents captured with a signature into ledger events.
+// Events are stamped with the server's receipt time: the timestamps sent by the tablet are kept
+// on the document but cannot move an event ahead of later withdrawals.
+func ConsentEventsFromSignature(doc Document, consents []Consent, now time.Time) []ConsentEvent {
+	events := make([]ConsentEvent, 0, len(consents))
+	for _, consent := range consents {
+		action := ConsentActionDeny
+		if consent.Granted {
+			action = ConsentActionGrant
+		}
+		events = append(events, ConsentEvent{
+			SubjectID:       NormalizeSubjectID(doc.SignerEmail),
+			ConsentType:     consent.ConsentType,
+			Action:          action,
+			Granted:         consent.Granted,
+			Source:          ConsentSourceSignature,
+			SourceRequestID: doc.ID,
+			OccurredAt:      now,
+		})
+	}
+	return events
+}
+
+// CurrentConsentState folds a subject's consent history, oldest first as returned by
+// ListConsentHistory, into the latest state per consent type. The last recorded event wins.
+func CurrentConsentState(history []ConsentEvent) []ConsentState {
+	latest := map[string]ConsentEvent{}
+	for _, event := range history {
+		latest[event.ConsentType] = event
+	}
+
+	states := make([]ConsentState, 0, len(latest))
+	for _, event := range latest {
+		states = append(states, ConsentState{
+			ConsentType:     event.ConsentType,
+			Granted:         event.Granted,
+			LastAction:      event.Action,
+			SourceRequestID: event.SourceRequestID,
+			UpdatedAt:       event.OccurredAt,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].ConsentType < states[j].ConsentType
+	})
+	return states
+}
+
+// NewDBConsentLedger returns a ledger backed by the consent_events table. With a keyring, events
+// are keyed by the blind index of the subject ID instead of the ID itself; keyring may be nil.
+func NewDBConsentLedger(db *sql.DB, keyring *Keyring) ConsentLedger {
+	return &DBConsentLedger{db: db, keyring: keyring}
+}
+
+// DBConsentLedger stores consent events in the consent_events table
+type DBConsentLedger struct {
+	db      *sql.DB
+	keyring *Keyring
+}
+
+// subjectKey returns the subject_id new events for a subject are stored under
+func (l DBConsentLedger) subjectKey(subjectID string) string {
+	subjectID = NormalizeSubjectID(subjectID)
+	if l.keyring == nil {
+		return subjectID
+	}
+	return l.keyring.BlindIndex(subjectID)
+}
+
+// subjectCondition matches every subject_id a subject's events may be stored under: the plain ID,
+// for events recorded before encryption was enabled, and its blind index under each key
+func (l DBConsentLedger) subjectCondition(subjectID string) (string, []any) {
+	subjectID = NormalizeSubjectID(subjectID)
+	condition := "subject_id = ?"
+	args := []any{subjectID}
+	if l.keyring != nil {
+		for _, index := range l.keyring.BlindIndexes(subjectID) {
+			condition += " OR subject_id = ?"
+			args = append(args, index)
+		}
+	}
+	return "(" + condition + ")", args
+}
+
+// RecordConsentEvents appends events to the ledger in a single transaction
+func (l DBConsentLedger) RecordConsentEvents(events []ConsentEvent) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO consent_events (id, subject_id, consent_type, action, granted, source, source_request_id, reason, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, event := range events {
+		if event.ID == "" {
+			event.ID = uuid.NewString()
+		}
+		_, err := tx.Exec(query, event.ID, l.subjectKey(event.SubjectID), event.ConsentType, event.Action, event.Granted,
+			event.Source, event.SourceRequestID, event.Reason, event.OccurredAt.UTC())
+		if err != nil {
+			return fmt.Errorf("error inserting consent event: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListConsentHistory lists all consent events for a subject in the order they were recorded
+func (l DBConsentLedger) ListConsentHistory(subjectID string) ([]ConsentEvent, error) {
+	condition, args := l.subjectCondition(subjectID)
+	query := `
+		SELECT id, consent_type, action, granted, source, source_request_id, reason, occurred_at
+		FROM consent_events
+		WHERE ` + condition + `
+		ORDER BY occurred_at ASC, created_at ASC`
+
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying consent events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []ConsentEvent
+	for rows.Next() {
+		event := ConsentEvent{SubjectID: NormalizeSubjectID(subjectID)}
+		var sourceRequestID, reason sql.NullString
+		if err := rows.Scan(&event.ID, &event.ConsentType, &event.Action, &event.Granted,
+			&event.Source, &sourceRequestID, &reason, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("error scanning consent event: %v", err)
+		}
+		event.SourceRequestID = sourceRequestID.String
+		event.Reason = reason.String
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return events, nil
+}
+
+// EraseSubject replaces the subject ID of all of a subject's events with the given pseudonym and
+// clears free-text reasons, returning the number of events affected
+func (l DBConsentLedger) EraseSubject(subjectID string, pseudonym string) (int, error) {
+	condition, args := l.subjectCondition(subjectID)
+	query := "UPDATE consent_events SET subject_id = ?, reason = NULL WHERE " + condition
+	result, err := l.db.Exec(query, append([]any{pseudonym}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("error erasing consent events: %v", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error counting erased consent events: %v", err)
+	}
+	return int(affected), nil
+}
+
+// InMemoryConsentLedger is an in-memory implementation of the ConsentLedger interface
+// for testing purposes.
+type InMemoryConsentLedger struct {
+	mu     sync.Mutex
+	events []ConsentEvent
+}
+
+func NewInMemoryConsentLedger() *InMemoryConsentLedger {
+	return &InMemoryConsentLedger{}
+}
+
+func (m *InMemoryConsentLedger) RecordConsentEvents(events []ConsentEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, event := range events {
+		if event.ID == "" {
+			event.ID = uuid.NewString()
+		}
+		event.SubjectID = NormalizeSubjectID(event.SubjectID)
+		m.events = append(m.events, event)
+	}
+	return nil
+}
+
+func (m *InMemoryConsentLedger) ListConsentHistory(subjectID string) ([]ConsentEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []ConsentEvent
+	for _, event := range m.events {
+		if event.SubjectID == NormalizeSubjectID(subjectID) {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (m *InMemoryConsentLedger) EraseSubject(subjectID string, pseudonym string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	affected := 0
+	for i, event := range m.events {
+		if event.SubjectID == NormalizeSubjectID(subjectID) {
+			m.events[i].SubjectID = pseudonym
+			m.events[i].Reason = ""
+			affected++
+		}
+	}
+	return affected, nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentConsentState(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		history  []ConsentEvent
+		expected []ConsentState
+	}{
+		{
+			name:     "empty history",
+			history:  nil,
+			expected: []ConsentState{},
+		},
+		{
+			name: "grant then withdraw",
+			history: []ConsentEvent{
+				{ConsentType: "marketing_email", Action: ConsentActionGrant, Granted: true, SourceRequestID: "req1", OccurredAt: t0},
+				{ConsentType: "marketing_email", Action: ConsentActionWithdraw, Granted: false, SourceRequestID: "req1", OccurredAt: t0.Add(time.Hour)},
+			},
+			expected: []ConsentState{
+				{ConsentType: "marketing_email", Granted: false, LastAction: ConsentActionWithdraw, SourceRequestID: "req1", UpdatedAt: t0.Add(time.Hour)},
+			},
+		},
+		{
+			name: "last recorded event wins",
+			history: []ConsentEvent{
+				{ConsentType: "terms", Action: ConsentActionGrant, Granted: true, SourceRequestID: "req2", OccurredAt: t0.Add(2 * time.Hour)},
+				{ConsentType: "marketing_sms", Action: ConsentActionDeny, Granted: false, SourceRequestID: "req1", OccurredAt: t0},
+				{ConsentType: "terms", Action: ConsentActionDeny, Granted: false, SourceRequestID: "req1", OccurredAt: t0},
+			},
+			expected: []ConsentState{
+				{ConsentType: "marketing_sms", Granted: false, LastAction: ConsentActionDeny, SourceRequestID: "req1", UpdatedAt: t0},
+				{ConsentType: "terms", Granted: false, LastAction: ConsentActionDeny, SourceRequestID: "req1", UpdatedAt: t0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CurrentConsentState(tt.history))
+		})
+	}
+}
+
+func TestInMemoryConsentLedger_NormalizesSubject(t *testing.T) {
+	ledger := NewInMemoryConsentLedger()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	doc := Document{ID: "req1", SignerEmail: " John@Example.com "}
+	events := ConsentEventsFromSignature(doc, []Consent{
+		{ConsentType: "marketing_email", Granted: true},
+		{ConsentType: "marketing_sms", Granted: false, Timestamp: now.Add(-time.Minute)},
+	}, now)
+
+	assert.NoError(t, ledger.RecordConsentEvents(events))
+
+	history, err := ledger.ListConsentHistory("john@example.com")
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "marketing_email", history[0].ConsentType)
+	assert.Equal(t, ConsentActionGrant, history[0].Action)
+	assert.Equal(t, "marketing_sms", history[1].ConsentType)
+	assert.Equal(t, ConsentActionDeny, history[1].Action)
+	assert.Equal(t, now, history[1].OccurredAt)
+	assert.Equal(t, ConsentSourceSignature, history[1].Source)
+	assert.Equal(t, "req1", history[1].SourceRequestID)
+}
+
+func TestConsentEventsFromSignature_IgnoresClientTimestamp(t *testing.T) {
+	ledger := NewInMemoryConsentLedger()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	doc := Document{ID: "req1", SignerEmail: "john@example.com"}
+	events := ConsentEventsFromSignature(doc, []Consent{
+		{ConsentType: "marketing_email", Granted: true, Timestamp: now.AddDate(10, 0, 0)},
+	}, now)
+	assert.Equal(t, now, events[0].OccurredAt)
+	assert.NoError(t, ledger.RecordConsentEvents(events))
+
+	assert.NoError(t, ledger.RecordConsentEvents([]ConsentEvent{{
+		SubjectID:   "john@example.com",
+		ConsentType: "marketing_email",
+		Action:      ConsentActionWithdraw,
+		Source:      ConsentSourceAPI,
+		OccurredAt:  now.Add(time.Hour),
+	}}))
+
+	history, err := ledger.ListConsentHistory("john@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []ConsentState{
+		{ConsentType: "marketing_email", Granted: false, LastAction: ConsentActionWithdraw, UpdatedAt: now.Add(time.Hour)},
+	}, CurrentConsentState(history))
+}
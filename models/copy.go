@@ -0,0 +1,68 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tokenAlphabet is the alphabet of link tokens, which are typed by hand when a copy link is read
+// off the tablet rather than scanned
+const tokenAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// copyTokenLength is the length of a copy link token, about 71 random bits
+const copyTokenLength = 12
+
+// NewCopyToken returns a random token for the link a signer downloads their copy of a document
+// with. The link needs no credentials, so the token is all that protects the document.
+func NewCopyToken() (string, error) {
+	return randomToken(copyTokenLength)
+}
+
+// randomToken returns a random token of length characters from tokenAlphabet
+func randomToken(length int) (string, error) {
+	var token strings.Builder
+	limit := big.NewInt(int64(len(tokenAlphabet)))
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		token.WriteByte(tokenAlphabet[n.Int64()])
+	}
+	return token.String(), nil
+}
+
+// HashCopyToken returns the hash a copy link's token is stored and looked up by, so that the
+// database holds no link to a signed document. It is cut to the copy_token column, which still
+// leaves it far more bits than the token has.
+func HashCopyToken(token string) string {
+	return hashToken(token)[:32]
+}
+
+// hashToken returns the hex SHA-256 hash of a token
+func hashToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// CopyURL returns the short URL a signer downloads their copy of a completed document from.
+// With an empty baseURL the URL is relative to this service.
+func CopyURL(baseURL, token string) string {
+	return strings.TrimRight(baseURL, "/") + "/c/" + url.PathEscape(token)
+}
+
+// CopyLinkExpired reports whether the copy link of a document can no longer be used at now
+func (d Document) CopyLinkExpired(now time.Time) bool {
+	return d.CopyExpiresAt == nil || !now.Before(*d.CopyExpiresAt)
+}
+
+// HasCopyToken reports whether token is the token of the copy link of a document
+func (d Document) HasCopyToken(token string) bool {
+	return d.CopyToken != "" && subtle.ConstantTimeCompare([]byte(HashCopyToken(token)), []byte(d.CopyToken)) == 1
+}
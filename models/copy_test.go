@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCopyToken(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		token, err := NewCopyToken()
+		assert.NoError(t, err)
+		assert.Regexp(t, `^[0-9A-Za-z]{12}$`, token)
+		assert.False(t, seen[token])
+		seen[token] = true
+	}
+}
+
+func TestHashCopyToken(t *testing.T) {
+	hash := HashCopyToken("Ab3xK9pQ2mZt")
+	assert.Regexp(t, `^[0-9a-f]{32}$`, hash)
+	assert.Equal(t, hash, HashCopyToken("Ab3xK9pQ2mZt"))
+	assert.NotEqual(t, hash, HashCopyToken("Ab3xK9pQ2mZT"))
+}
+
+func TestCopyURL(t *testing.T) {
+	assert.Equal(t, "https://sign.example.com/c/Ab3xK9pQ2mZt", CopyURL("https://sign.example.com/", "Ab3xK9pQ2mZt"))
+	assert.Equal(t, "/c/Ab3xK9pQ2mZt", CopyURL("", "Ab3xK9pQ2mZt"))
+}
+
+func TestDocument_CopyLinkExpired(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Minute)
+	doc := Document{CopyExpiresAt: &expiresAt}
+
+	assert.False(t, doc.CopyLinkExpired(now))
+	assert.True(t, doc.CopyLinkExpired(expiresAt))
+	assert.True(t, Document{}.CopyLinkExpired(now))
+}
@@ -0,0 +1,500 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptedPrefix marks a column value encrypted with a row data key
+const encryptedPrefix = "enc:v1:"
+
+// Keyring holds the key-encryption keys (KEKs) used to wrap per-row data keys. New rows are
+// wrapped with the primary key; the remaining keys are kept so rows can still be decrypted
+// and re-encrypted after a rotation.
+type Keyring struct {
+	primaryID string
+	keys      map[string][]byte
+	order     []string
+}
+
+// NewKeyring creates a keyring from 32-byte AES keys; primaryID must be one of them
+func NewKeyring(primaryID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primaryID]; !ok {
+		return nil, fmt.Errorf("primary key %q not found in keyring", primaryID)
+	}
+	k := &Keyring{primaryID: primaryID, keys: map[string][]byte{}, order: []string{primaryID}}
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, got %d", id, len(key))
+		}
+		k.keys[id] = key
+		if id != primaryID {
+			k.order = append(k.order, id)
+		}
+	}
+	return k, nil
+}
+
+// LoadKeyring builds a keyring from a key file or, when no file is given, a single base64 key.
+// It returns nil when neither is configured.
+//
+// The key file has one "<key id> <base64 key>" pair per line; the first key is the primary.
+// Empty lines and lines starting with # are ignored.
+func LoadKeyring(keyFile, key, keyID string) (*Keyring, error) {
+	if keyFile == "" {
+		if key == "" {
+			return nil, nil
+		}
+		if keyID == "" {
+			keyID = "default"
+		}
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding encryption key: %v", err)
+		}
+		return NewKeyring(keyID, map[string][]byte{keyID: decoded})
+	}
+
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %v", err)
+	}
+
+	var primaryID string
+	keys := map[string][]byte{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("key file line %d: expected \"<key id> <base64 key>\"", lineNumber)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("key file line %d: error decoding key: %v", lineNumber, err)
+		}
+		if primaryID == "" {
+			primaryID = fields[0]
+		}
+		keys[fields[0]] = decoded
+	}
+	if primaryID == "" {
+		return nil, fmt.Errorf("key file %s contains no keys", keyFile)
+	}
+
+	return NewKeyring(primaryID, keys)
+}
+
+// PrimaryID returns the ID of the key used to wrap new data keys
+func (k *Keyring) PrimaryID() string {
+	return k.primaryID
+}
+
+// NewDataKey generates a random data key and returns it together with its wrapped form
+func (k *Keyring) NewDataKey() (dataKey []byte, wrapped string, keyID string, err error) {
+	dataKey = make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", "", fmt.Errorf("error generating data key: %v", err)
+	}
+	sealed, err := seal(k.keys[k.primaryID], dataKey, []byte(k.primaryID))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("error wrapping data key: %v", err)
+	}
+	return dataKey, base64.StdEncoding.EncodeToString(sealed), k.primaryID, nil
+}
+
+// UnwrapDataKey decrypts a data key wrapped with the given key
+func (k *Keyring) UnwrapDataKey(keyID, wrapped string) ([]byte, error) {
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", keyID)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding wrapped data key: %v", err)
+	}
+	dataKey, err := open(kek, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+// BlindIndexes returns the lookup hashes of a value under every key in the keyring, primary
+// first, so rows indexed before a key rotation can still be found
+func (k *Keyring) BlindIndexes(value string) []string {
+	indexes := make([]string, 0, len(k.order))
+	for _, id := range k.order {
+		indexes = append(indexes, blindIndex(k.keys[id], value))
+	}
+	return indexes
+}
+
+// BlindIndex returns the lookup hash of a value under the primary key
+func (k *Keyring) BlindIndex(value string) string {
+	return blindIndex(k.keys[k.primaryID], value)
+}
+
+func blindIndex(kek []byte, value string) string {
+	indexKey := hmac.New(sha256.New, kek)
+	indexKey.Write([]byte("blind-index"))
+	mac := hmac.New(sha256.New, indexKey.Sum(nil))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// encryptField encrypts a column value with a row data key. The additional data binds the
+// ciphertext to its row and column so values cannot be swapped between them.
+func encryptField(dataKey []byte, plaintext string, additionalData string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	sealed, err := seal(dataKey, []byte(plaintext), []byte(additionalData))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptField reverses encryptField. Values without the encrypted prefix are returned as-is
+// so rows written before encryption was enabled remain readable.
+func decryptField(dataKey []byte, value string, additionalData string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	if dataKey == nil {
+		return "", fmt.Errorf("value is encrypted but no data key is available")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("error decoding encrypted value: %v", err)
+	}
+	plaintext, err := open(dataKey, sealed, []byte(additionalData))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting value: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// seal encrypts with AES-256-GCM and prepends the random nonce
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+}
+
+// rowDataKey returns the data key of a document row, generating and storing one for rows
+// written before encryption was enabled
+func (ds DBDocumentStore) rowDataKey(requestID string) ([]byte, error) {
+	var keyID, wrappedKey sql.NullString
+	err := ds.db.QueryRow("SELECT encryption_key_id, wrapped_key FROM documents WHERE id = ?", requestID).Scan(&keyID, &wrappedKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if keyID.Valid && wrappedKey.Valid {
+		return ds.keyring.UnwrapDataKey(keyID.String, wrappedKey.String)
+	}
+
+	dataKey, wrapped, id, err := ds.keyring.NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+	query := "UPDATE documents SET encryption_key_id = ?, wrapped_key = ? WHERE id = ?"
+	if _, err := ds.db.Exec(query, id, wrapped, requestID); err != nil {
+		return nil, fmt.Errorf("error storing data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+// encryptColumn encrypts a value about to be written to a document column. Encrypted
+// consents are stored as a JSON string so the column stays valid JSON on MySQL.
+// Without a keyring the value is returned unchanged.
+func (ds DBDocumentStore) encryptColumn(requestID, column, value string) (string, error) {
+	if ds.keyring == nil || value == "" {
+		return value, nil
+	}
+	dataKey, err := ds.rowDataKey(requestID)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := encryptField(dataKey, value, requestID+"|"+column)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting %s: %v", column, err)
+	}
+	if column == "consents" {
+		quoted, err := json.Marshal(encrypted)
+		if err != nil {
+			return "", err
+		}
+		return string(quoted), nil
+	}
+	return encrypted, nil
+}
+
+// dataKey unwraps the data key of a document row, returning nil for rows in plaintext
+func (ds DBDocumentStore) dataKey(keyID, wrappedKey string) ([]byte, error) {
+	if keyID == "" {
+		return nil, nil
+	}
+	if ds.keyring == nil {
+		return nil, fmt.Errorf("document is encrypted but no encryption key is configured")
+	}
+	return ds.keyring.UnwrapDataKey(keyID, wrappedKey)
+}
+
+// decryptDocument decrypts the personal fields of a scanned document in place
+func (ds DBDocumentStore) decryptDocument(doc *Document, consents, strokes *string, keyID, wrappedKey string) error {
+	dataKey, err := ds.dataKey(keyID, wrappedKey)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(*consents, `"`) {
+		var quoted string
+		if err := json.Unmarshal([]byte(*consents), &quoted); err != nil {
+			return fmt.Errorf("error decoding consents: %v", err)
+		}
+		*consents = quoted
+	}
+
+	fields := []struct {
+		column string
+		value  *string
+	}{
+		{"signer_name", &doc.SignerName},
+		{"signer_email", &doc.SignerEmail},
+		{"signature_data", &doc.SignatureData},
+		{"signature_strokes", strokes},
+		{"consents", consents},
+		{"verification_secret", &doc.VerificationSecret},
+	}
+	for _, field := range fields {
+		plaintext, err := decryptField(dataKey, *field.value, doc.ID+"|"+field.column)
+		if err != nil {
+			return fmt.Errorf("%s: %v", field.column, err)
+		}
+		*field.value = plaintext
+	}
+	return nil
+}
+
+// ReencryptDocuments encrypts every document row that is in plaintext or whose data key is
+// wrapped with a key other than the keyring's primary key, and returns the number of rows
+// rewritten. Running it again after it completes is a no-op.
+//
+// Signatures kept in the blob store are re-encrypted into a new blob, which the row points to
+// once it holds the new data key, so an interrupted run leaves every row readable. Consent
+// events are then keyed by the blind index of their subject under the primary key.
+func ReencryptDocuments(db *sql.DB, keyring *Keyring, blobs BlobStore) (int, error) {
+	ds := DBDocumentStore{db: db, keyring: keyring, blobs: blobs}
+
+	query := `
+		SELECT id, signer_name, signer_email, signature_data, signature_key, signature_strokes, consents, verification_secret, status, encryption_key_id, wrapped_key
+		FROM documents
+		WHERE encryption_key_id IS NULL OR encryption_key_id <> ?`
+	rows, err := db.Query(query, keyring.PrimaryID())
+	if err != nil {
+		return 0, fmt.Errorf("error querying documents: %v", err)
+	}
+
+	type pendingRow struct {
+		doc          Document
+		signatureKey string
+		strokes      string
+		consents     string
+	}
+	var pending []pendingRow
+	for rows.Next() {
+		var row pendingRow
+		var signatureData, signatureKey, strokes, consents, verificationSecret, keyID, wrappedKey sql.NullString
+		if err := rows.Scan(&row.doc.ID, &row.doc.SignerName, &row.doc.SignerEmail, &signatureData, &signatureKey, &strokes, &consents,
+			&verificationSecret, &row.doc.Status, &keyID, &wrappedKey); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning document: %v", err)
+		}
+		row.doc.SignatureData = signatureData.String
+		row.signatureKey = signatureKey.String
+		row.strokes = strokes.String
+		row.consents = consents.String
+		row.doc.VerificationSecret = verificationSecret.String
+		if row.signatureKey != "" {
+			data, err := ds.getBlob(row.signatureKey)
+			if err != nil {
+				rows.Close()
+				return 0, fmt.Errorf("error reading signature of document %s: %v", row.doc.ID, err)
+			}
+			row.doc.SignatureData = string(data)
+		}
+		if err := ds.decryptDocument(&row.doc, &row.consents, &row.strokes, keyID.String, wrappedKey.String); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error decrypting document %s: %v", row.doc.ID, err)
+		}
+		pending = append(pending, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	update := `
+		UPDATE documents
+		SET signer_name = ?, signer_email = ?, signature_data = ?, signature_key = ?, signature_strokes = ?, consents = ?, verification_secret = ?, encryption_key_id = ?, wrapped_key = ?, signer_email_index = ?
+		WHERE id = ?`
+	var subjectIDs []string
+	for _, row := range pending {
+		dataKey, wrapped, keyID, err := keyring.NewDataKey()
+		if err != nil {
+			return 0, err
+		}
+
+		values := map[string]string{
+			"signer_name":         row.doc.SignerName,
+			"signer_email":        row.doc.SignerEmail,
+			"signature_data":      row.doc.SignatureData,
+			"signature_strokes":   row.strokes,
+			"consents":            row.consents,
+			"verification_secret": row.doc.VerificationSecret,
+		}
+		for column, value := range values {
+			if values[column], err = encryptField(dataKey, value, row.doc.ID+"|"+column); err != nil {
+				return 0, fmt.Errorf("error encrypting %s of document %s: %v", column, row.doc.ID, err)
+			}
+		}
+
+		var signatureData, signatureKey, strokes, consents, emailIndex sql.NullString
+		if row.signatureKey != "" {
+			key := signatureKeyFor(row.doc.ID, keyID)
+			if err := blobs.PutBlob(key, []byte(values["signature_data"])); err != nil {
+				return 0, fmt.Errorf("error storing signature of document %s: %v", row.doc.ID, err)
+			}
+			signatureKey = sql.NullString{String: key, Valid: true}
+		} else if values["signature_data"] != "" {
+			signatureData = sql.NullString{String: values["signature_data"], Valid: true}
+		}
+		if values["signature_strokes"] != "" {
+			strokes = sql.NullString{String: values["signature_strokes"], Valid: true}
+		}
+		if values["consents"] != "" {
+			quoted, err := json.Marshal(values["consents"])
+			if err != nil {
+				return 0, err
+			}
+			consents = sql.NullString{String: string(quoted), Valid: true}
+		}
+		// Erased rows only hold a pseudonym, which must not be searchable as an email
+		if row.doc.Status != StatusErased && row.doc.SignerEmail != "" {
+			emailIndex = sql.NullString{String: keyring.BlindIndex(NormalizeSubjectID(row.doc.SignerEmail)), Valid: true}
+			subjectIDs = append(subjectIDs, row.doc.SignerEmail)
+		}
+
+		_, err = db.Exec(update, values["signer_name"], values["signer_email"], signatureData, signatureKey, strokes, consents, values["verification_secret"], keyID, wrapped, emailIndex, row.doc.ID)
+		if err != nil {
+			return 0, fmt.Errorf("error updating document %s: %v", row.doc.ID, err)
+		}
+		if row.signatureKey != "" && row.signatureKey != signatureKey.String {
+			if err := blobs.DeleteBlob(row.signatureKey); err != nil {
+				return 0, fmt.Errorf("error deleting signature of document %s: %v", row.doc.ID, err)
+			}
+		}
+	}
+
+	if err := reindexConsentEvents(db, keyring, subjectIDs); err != nil {
+		return len(pending), err
+	}
+
+	return len(pending), nil
+}
+
+// reindexConsentEvents keys consent events by the blind index of their subject under the primary
+// key. Events stored under a plain subject ID are converted directly. A blind index cannot be
+// reversed, so events indexed under an older key are only converted for the given subject IDs.
+func reindexConsentEvents(db *sql.DB, keyring *Keyring, subjectIDs []string) error {
+	ledger := DBConsentLedger{db: db, keyring: keyring}
+	for _, subjectID := range subjectIDs {
+		condition, args := ledger.subjectCondition(subjectID)
+		query := "UPDATE consent_events SET subject_id = ? WHERE " + condition
+		if _, err := db.Exec(query, append([]any{ledger.subjectKey(subjectID)}, args...)...); err != nil {
+			return fmt.Errorf("error re-indexing consent events: %v", err)
+		}
+	}
+
+	rows, err := db.Query("SELECT DISTINCT subject_id FROM consent_events")
+	if err != nil {
+		return fmt.Errorf("error querying consent events: %v", err)
+	}
+	var plain []string
+	for rows.Next() {
+		var subjectID string
+		if err := rows.Scan(&subjectID); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning consent event: %v", err)
+		}
+		// Erased events only hold a pseudonym, which must not be searchable as a subject
+		if !isBlindIndex(subjectID) && !strings.HasPrefix(subjectID, pseudonymPrefix) {
+			plain = append(plain, subjectID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	for _, subjectID := range plain {
+		query := "UPDATE consent_events SET subject_id = ? WHERE subject_id = ?"
+		if _, err := db.Exec(query, keyring.BlindIndex(subjectID), subjectID); err != nil {
+			return fmt.Errorf("error re-indexing consent events: %v", err)
+		}
+	}
+	return nil
+}
+
+// isBlindIndex reports whether a value has the form of a blind index
+func isBlindIndex(value string) bool {
+	decoded, err := hex.DecodeString(value)
+	return err == nil && len(decoded) == sha256.Size
+}
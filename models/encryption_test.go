@@ -0,0 +1,105 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	content := "# current key first\n2024 " + base64.StdEncoding.EncodeToString(testKey(1)) + "\n\n2023 " + base64.StdEncoding.EncodeToString(testKey(2)) + "\n"
+	assert.NoError(t, os.WriteFile(keyFile, []byte(content), 0600))
+
+	tests := []struct {
+		name      string
+		keyFile   string
+		key       string
+		keyID     string
+		wantNil   bool
+		wantID    string
+		wantError bool
+	}{
+		{name: "Not configured", wantNil: true},
+		{name: "Single key with default ID", key: base64.StdEncoding.EncodeToString(testKey(1)), wantID: "default"},
+		{name: "Single key with ID", key: base64.StdEncoding.EncodeToString(testKey(1)), keyID: "k1", wantID: "k1"},
+		{name: "Key file", keyFile: keyFile, wantID: "2024"},
+		{name: "Short key", key: base64.StdEncoding.EncodeToString([]byte("short")), wantError: true},
+		{name: "Invalid base64", key: "not base64!", wantError: true},
+		{name: "Missing key file", keyFile: filepath.Join(t.TempDir(), "missing"), wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := LoadKeyring(tt.keyFile, tt.key, tt.keyID)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, keyring)
+				return
+			}
+			assert.Equal(t, tt.wantID, keyring.PrimaryID())
+		})
+	}
+}
+
+func TestKeyring_DataKeyRoundTrip(t *testing.T) {
+	old, _ := NewKeyring("old", map[string][]byte{"old": testKey(1)})
+	rotated, _ := NewKeyring("new", map[string][]byte{"new": testKey(2), "old": testKey(1)})
+
+	dataKey, wrapped, keyID, err := old.NewDataKey()
+	assert.NoError(t, err)
+	assert.Equal(t, "old", keyID)
+
+	// Data keys wrapped before a rotation can still be unwrapped
+	unwrapped, err := rotated.UnwrapDataKey(keyID, wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	_, err = old.UnwrapDataKey("new", wrapped)
+	assert.Error(t, err)
+
+	// Blind indexes under every key allow lookups of rows indexed before the rotation
+	indexes := rotated.BlindIndexes("jan@example.com")
+	assert.Equal(t, []string{rotated.BlindIndex("jan@example.com"), old.BlindIndex("jan@example.com")}, indexes)
+	assert.NotEqual(t, indexes[0], indexes[1])
+}
+
+func TestEncryptField(t *testing.T) {
+	dataKey := testKey(3)
+
+	encrypted, err := encryptField(dataKey, "Jan Kowalski", "doc-1|signer_name")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, encryptedPrefix))
+	assert.NotContains(t, encrypted, "Jan")
+
+	decrypted, err := decryptField(dataKey, encrypted, "doc-1|signer_name")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jan Kowalski", decrypted)
+
+	// Ciphertext is bound to its row and column
+	_, err = decryptField(dataKey, encrypted, "doc-2|signer_name")
+	assert.Error(t, err)
+	_, err = decryptField(dataKey, encrypted, "doc-1|signer_email")
+	assert.Error(t, err)
+
+	// Plaintext written before encryption was enabled is passed through
+	decrypted, err = decryptField(nil, "jan@example.com", "doc-1|signer_email")
+	assert.NoError(t, err)
+	assert.Equal(t, "jan@example.com", decrypted)
+
+	_, err = decryptField(nil, encrypted, "doc-1|signer_name")
+	assert.Error(t, err)
+}
@@ -0,0 +1,323 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CompletionRecordVersion identifies the canonical form hashed into a document's integrity hash
+const CompletionRecordVersion = "1"
+
+// integrityHashPrefix names the algorithm of an integrity hash
+const integrityHashPrefix = "sha256:"
+
+// ErrDocumentNotCompleted is returned when a certificate is requested for a document that has no
+// integrity hash yet
+var ErrDocumentNotCompleted = errors.New("document is not completed")
+
+// CompletionRecord is the canonical form of what was signed: the exact sections shown to the
+// signer, who signed, the consents given and when. It is serialised as compact JSON with fields
+// in declaration order and timestamps in UTC, so the same record always hashes the same way.
+// When the tablet reported when the signature was captured, which for a submission queued
+// offline is before it was completed, the record holds that time too, and when the signer had to
+// prove who they are, how they did.
+// The signature image, strokes, the original of a PDF document and attachments are included by
+// their SHA-256 digests.
+type CompletionRecord struct {
+	Version           string              `json:"version"`
+	RequestID         string              `json:"request_id"`
+	DocumentTitle     string              `json:"document_title"`
+	DocumentContent   []DocumentSection   `json:"document_content"`
+	DocumentPDFSHA256 string              `json:"document_pdf_sha256,omitempty"`
+	SignatureFields   []SignatureField    `json:"signature_fields,omitempty"`
+	Attachments       []Attachment        `json:"attachments,omitempty"`
+	SignerName        string              `json:"signer_name"`
+	SignerEmail       string              `json:"signer_email"`
+	DeviceID          string              `json:"device_id"`
+	Consents          []RecordConsent     `json:"consents"`
+	SignatureSHA256   string              `json:"signature_sha256"`
+	StrokesSHA256     string              `json:"strokes_sha256,omitempty"`
+	CreatedAt         string              `json:"created_at"`
+	CompletedAt       string              `json:"completed_at"`
+	CapturedAt        string              `json:"captured_at,omitempty"`
+	Verification      *RecordVerification `json:"verification,omitempty"`
+}
+
+// RecordVerification is the verification of the signer as it appears in a CompletionRecord
+type RecordVerification struct {
+	Method   string `json:"method"`
+	Outcome  string `json:"outcome"`
+	Attempts int    `json:"attempts"`
+	At       string `json:"at"`
+}
+
+// RecordConsent is a consent as it appears in a CompletionRecord
+type RecordConsent struct {
+	ConsentType string `json:"consent_type"`
+	Granted     bool   `json:"granted"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// NewCompletionRecord builds the canonical record of a completed document
+func NewCompletionRecord(doc Document) (CompletionRecord, error) {
+	if doc.CompletedAt == nil {
+		return CompletionRecord{}, ErrDocumentNotCompleted
+	}
+
+	record := CompletionRecord{
+		Version:           CompletionRecordVersion,
+		RequestID:         doc.ID,
+		DocumentTitle:     doc.DocumentTitle,
+		DocumentContent:   doc.DocumentContent,
+		DocumentPDFSHA256: doc.DocumentPDFSHA256,
+		SignatureFields:   doc.SignatureFields,
+		Attachments:       doc.Attachments,
+		SignerName:        doc.SignerName,
+		SignerEmail:       doc.SignerEmail,
+		DeviceID:          doc.DeviceID,
+		Consents:          []RecordConsent{},
+		SignatureSHA256:   sha256Hex([]byte(doc.SignatureData)),
+		CreatedAt:         canonicalTime(doc.CreatedAt),
+		CompletedAt:       canonicalTime(*doc.CompletedAt),
+	}
+	if record.DocumentContent == nil {
+		record.DocumentContent = []DocumentSection{}
+	}
+	for _, consent := range doc.Consents {
+		record.Consents = append(record.Consents, RecordConsent{
+			ConsentType: consent.ConsentType,
+			Granted:     consent.Granted,
+			Timestamp:   consent.Timestamp.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	if doc.CapturedAt != nil {
+		record.CapturedAt = canonicalTime(*doc.CapturedAt)
+	}
+	if verification := doc.VerificationResult(); verification != nil {
+		record.Verification = &RecordVerification{
+			Method:   verification.Method,
+			Outcome:  verification.Outcome,
+			Attempts: verification.Attempts,
+			At:       canonicalTime(verification.At),
+		}
+	}
+	if doc.Strokes != nil {
+		strokes, err := json.Marshal(doc.Strokes)
+		if err != nil {
+			return CompletionRecord{}, fmt.Errorf("error marshaling signature strokes: %v", err)
+		}
+		record.StrokesSHA256 = sha256Hex(strokes)
+	}
+	return record, nil
+}
+
+// Hash returns the integrity hash of the record, as "sha256:" followed by the hex digest of
+// its canonical JSON
+func (r CompletionRecord) Hash() (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling completion record: %v", err)
+	}
+	return integrityHashPrefix + sha256Hex(data), nil
+}
+
+// IntegrityHash returns the integrity hash of a completed document
+func IntegrityHash(doc Document) (string, error) {
+	record, err := NewCompletionRecord(doc)
+	if err != nil {
+		return "", err
+	}
+	return record.Hash()
+}
+
+// Certificate is the certificate of completion of a signed document. It carries the full
+// canonical record so the hash can be recomputed by anyone holding the certificate.
+type Certificate struct {
+	RequestID     string           `json:"request_id"`
+	IntegrityHash string           `json:"integrity_hash"`
+	Record        CompletionRecord `json:"record"`
+	Seal          *Seal            `json:"seal,omitempty"`
+	Timestamp     *Timestamp       `json:"timestamp,omitempty"`
+	IssuedAt      time.Time        `json:"issued_at"`
+}
+
+// NewCertificate issues a certificate of completion for a completed document
+func NewCertificate(doc Document, issuedAt time.Time) (Certificate, error) {
+	if doc.IntegrityHash == "" {
+		return Certificate{}, ErrDocumentNotCompleted
+	}
+	record, err := NewCompletionRecord(doc)
+	if err != nil {
+		return Certificate{}, err
+	}
+	return Certificate{
+		RequestID:     doc.ID,
+		IntegrityHash: doc.IntegrityHash,
+		Record:        record,
+		Seal:          doc.Seal,
+		Timestamp:     doc.Timestamp,
+		IssuedAt:      issuedAt.UTC(),
+	}, nil
+}
+
+// Verification checks
+const (
+	// CheckRecordIntegrity compares the stored record with the hash stored at completion
+	CheckRecordIntegrity = "record_integrity"
+	// CheckCertificateHash compares the record in a certificate with the certificate's hash
+	CheckCertificateHash = "certificate_hash"
+	// CheckCertificateMatchesRecord compares a certificate's hash with the hash stored at completion
+	CheckCertificateMatchesRecord = "certificate_matches_record"
+	// CheckSeal verifies the service's seal over the integrity hash with the published keys
+	CheckSeal = "seal"
+	// CheckTimestamp verifies the authority's RFC 3161 timestamp over the integrity hash
+	CheckTimestamp = "timestamp"
+)
+
+// VerifyOptions holds what seals and timestamps are verified against
+type VerifyOptions struct {
+	// SealKeys are the published seal keys. When nil, seals of certificates are not checked.
+	SealKeys []SealKey
+	// TimestampRoots are the trusted roots of timestamp authorities. When nil, timestamps are
+	// checked to be validly signed for the integrity hash, but the authority is not checked.
+	TimestampRoots *x509.CertPool
+}
+
+// VerificationCheck is the outcome of one verification check
+type VerificationCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// CertificateVerification is the result of verifying a document, and optionally a certificate
+// presented for it, against the stored record
+type CertificateVerification struct {
+	RequestID     string              `json:"request_id"`
+	Valid         bool                `json:"valid"`
+	IntegrityHash string              `json:"integrity_hash"`
+	ComputedHash  string              `json:"computed_hash,omitempty"`
+	Checks        []VerificationCheck `json:"checks"`
+}
+
+// VerifyDocument recomputes the integrity hash of a stored document and compares it with the
+// hash stored at completion. When a certificate is given it is also checked to be internally
+// consistent and to match the stored record. A seal and a timestamp, from the certificate when
+// given and the stored record otherwise, are verified as well.
+func VerifyDocument(doc Document, certificate *Certificate, opts VerifyOptions) (CertificateVerification, error) {
+	if doc.IntegrityHash == "" {
+		return CertificateVerification{}, ErrDocumentNotCompleted
+	}
+
+	result := CertificateVerification{RequestID: doc.ID, IntegrityHash: doc.IntegrityHash}
+	computed, err := IntegrityHash(doc)
+	if err != nil {
+		return CertificateVerification{}, err
+	}
+	result.ComputedHash = computed
+	result.addCheck(CheckRecordIntegrity, computed == doc.IntegrityHash, "the stored record has changed since it was completed")
+
+	seal, timestamp := doc.Seal, doc.Timestamp
+	if certificate != nil {
+		if err := result.checkCertificate(*certificate, nil); err != nil {
+			return CertificateVerification{}, err
+		}
+		result.addCheck(CheckCertificateMatchesRecord, certificate.IntegrityHash == doc.IntegrityHash, "the certificate was not issued for the stored record")
+		if certificate.Seal != nil {
+			seal = certificate.Seal
+		}
+		if certificate.Timestamp != nil {
+			timestamp = certificate.Timestamp
+		}
+	}
+	if seal != nil {
+		result.checkSeal(*seal, doc.IntegrityHash, opts.SealKeys)
+	}
+	if timestamp != nil {
+		result.checkTimestamp(*timestamp, doc.IntegrityHash, opts.TimestampRoots)
+	}
+
+	result.complete()
+	return result, nil
+}
+
+// VerifyCertificate checks a certificate on its own, without the stored record: its record must
+// match its integrity hash, with seal keys it must be sealed with one of them, and a timestamp it
+// carries must be for its integrity hash
+func VerifyCertificate(certificate Certificate, opts VerifyOptions) (CertificateVerification, error) {
+	result := CertificateVerification{RequestID: certificate.RequestID, IntegrityHash: certificate.IntegrityHash}
+	if err := result.checkCertificate(certificate, opts.SealKeys); err != nil {
+		return CertificateVerification{}, err
+	}
+	if certificate.Timestamp != nil {
+		result.checkTimestamp(*certificate.Timestamp, certificate.IntegrityHash, opts.TimestampRoots)
+	}
+	result.complete()
+	return result, nil
+}
+
+// checkCertificate recomputes the hash of a certificate's record and, with keys, verifies its seal
+func (v *CertificateVerification) checkCertificate(certificate Certificate, keys []SealKey) error {
+	certificateHash, err := certificate.Record.Hash()
+	if err != nil {
+		return err
+	}
+	v.ComputedHash = certificateHash
+	v.addCheck(CheckCertificateHash, certificateHash == certificate.IntegrityHash, "the certificate record does not match its integrity hash")
+	if keys != nil {
+		if certificate.Seal == nil {
+			v.addCheck(CheckSeal, false, "the certificate is not sealed")
+		} else {
+			v.checkSeal(*certificate.Seal, certificate.IntegrityHash, keys)
+		}
+	}
+	return nil
+}
+
+func (v *CertificateVerification) checkSeal(seal Seal, integrityHash string, keys []SealKey) {
+	err := VerifySeal(seal, integrityHash, keys)
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	v.addCheck(CheckSeal, err == nil, message)
+}
+
+func (v *CertificateVerification) checkTimestamp(timestamp Timestamp, integrityHash string, roots *x509.CertPool) {
+	err := VerifyTimestamp(timestamp, integrityHash, roots)
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	v.addCheck(CheckTimestamp, err == nil, message)
+}
+
+func (v *CertificateVerification) addCheck(name string, passed bool, failure string) {
+	check := VerificationCheck{Name: name, Passed: passed}
+	if !passed {
+		check.Message = failure
+	}
+	v.Checks = append(v.Checks, check)
+}
+
+// complete marks the verification valid when every check passed
+func (v *CertificateVerification) complete() {
+	v.Valid = true
+	for _, check := range v.Checks {
+		v.Valid = v.Valid && check.Passed
+	}
+}
+
+func canonicalTime(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func completedTestDocument() Document {
+	consentType := "marketing_email"
+	completedAt := time.Date(2024, 6, 10, 12, 30, 0, 0, time.UTC)
+	return Document{
+		ID:            "request-1",
+		DocumentTitle: "Agreement",
+		DocumentContent: []DocumentSection{
+			{ID: "s1", Type: "text", Content: "Terms"},
+			{ID: "s2", Type: "consent", Content: "Marketing", ConsentType: &consentType},
+		},
+		SignerName:    "John Smith",
+		SignerEmail:   "john@example.com",
+		DeviceID:      "tablet-1",
+		Status:        StatusCompleted,
+		SignatureData: "data:image/png;base64,iVBORw0KGgo=",
+		Strokes:       &SignatureStrokes{Width: 100, Height: 50, Strokes: []SignatureStroke{{Points: []SignaturePoint{{X: 1, Y: 2}}}}},
+		Consents:      []Consent{{ConsentType: consentType, Granted: true, Timestamp: time.Date(2024, 6, 10, 12, 29, 0, 0, time.UTC)}},
+		CreatedAt:     time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC),
+		CompletedAt:   &completedAt,
+	}
+}
+
+func TestIntegrityHash(t *testing.T) {
+	doc := completedTestDocument()
+	hash, err := IntegrityHash(doc)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, hash)
+
+	// The same record in another time zone hashes the same
+	warsaw := time.FixedZone("CEST", 2*60*60)
+	sameDoc := completedTestDocument()
+	sameDoc.CreatedAt = sameDoc.CreatedAt.In(warsaw)
+	sameDoc.Consents[0].Timestamp = sameDoc.Consents[0].Timestamp.In(warsaw)
+	sameHash, err := IntegrityHash(sameDoc)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, sameHash)
+
+	// The status is not part of the record
+	sameDoc.Status = StatusRemoved
+	sameHash, _ = IntegrityHash(sameDoc)
+	assert.Equal(t, hash, sameHash)
+
+	changes := map[string]func(doc *Document){
+		"content":   func(doc *Document) { doc.DocumentContent[0].Content = "Other terms" },
+		"signer":    func(doc *Document) { doc.SignerEmail = "jane@example.com" },
+		"consent":   func(doc *Document) { doc.Consents[0].Granted = false },
+		"signature": func(doc *Document) { doc.SignatureData = "" },
+		"strokes":   func(doc *Document) { doc.Strokes = nil },
+		"completed": func(doc *Document) { completed := doc.CompletedAt.Add(time.Second); doc.CompletedAt = &completed },
+		"captured":  func(doc *Document) { captured := doc.CompletedAt.Add(-time.Hour); doc.CapturedAt = &captured },
+		"verified":  func(doc *Document) { verify(doc, VerificationName, doc.CompletedAt) },
+		"pdf":       func(doc *Document) { doc.DocumentPDFSHA256 = sha256Hex([]byte("%PDF")) },
+		"fields":    func(doc *Document) { doc.SignatureFields = []SignatureField{{Page: 1, Width: 100, Height: 40}} },
+		"attached":  func(doc *Document) { doc.Attachments = []Attachment{NewAttachment("prices.txt", []byte("Prices"))} },
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			changed := completedTestDocument()
+			change(&changed)
+			changedHash, err := IntegrityHash(changed)
+			assert.NoError(t, err)
+			assert.NotEqual(t, hash, changedHash)
+		})
+	}
+
+	doc.CompletedAt = nil
+	_, err = IntegrityHash(doc)
+	assert.ErrorIs(t, err, ErrDocumentNotCompleted)
+}
+
+func TestVerifyDocument(t *testing.T) {
+	doc := completedTestDocument()
+	doc.IntegrityHash, _ = IntegrityHash(doc)
+	certificate, err := NewCertificate(doc, time.Now())
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		change      func(doc *Document, certificate *Certificate)
+		wantValid   bool
+		wantFailing []string
+	}{
+		{name: "Intact", change: func(*Document, *Certificate) {}, wantValid: true},
+		{
+			name:        "Stored record changed",
+			change:      func(doc *Document, _ *Certificate) { doc.DocumentContent[0].Content = "Other terms" },
+			wantFailing: []string{CheckRecordIntegrity},
+		},
+		{
+			name:        "Certificate record altered",
+			change:      func(_ *Document, certificate *Certificate) { certificate.Record.SignerName = "Jane Smith" },
+			wantFailing: []string{CheckCertificateHash},
+		},
+		{
+			name: "Certificate for another record",
+			change: func(_ *Document, certificate *Certificate) {
+				certificate.Record.SignerName = "Jane Smith"
+				certificate.IntegrityHash, _ = certificate.Record.Hash()
+			},
+			wantFailing: []string{CheckCertificateMatchesRecord},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := completedTestDocument()
+			doc.IntegrityHash = certificate.IntegrityHash
+			presented := certificate
+			presented.Record.DocumentContent = append([]DocumentSection(nil), certificate.Record.DocumentContent...)
+			tt.change(&doc, &presented)
+
+			verification, err := VerifyDocument(doc, &presented, VerifyOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantValid, verification.Valid)
+			assert.Len(t, verification.Checks, 3)
+
+			var failing []string
+			for _, check := range verification.Checks {
+				if !check.Passed {
+					failing = append(failing, check.Name)
+					assert.NotEmpty(t, check.Message)
+				}
+			}
+			assert.Equal(t, tt.wantFailing, failing)
+		})
+	}
+}
+
+// verify records that the signer of doc was verified with method at
+func verify(doc *Document, method string, at *time.Time) {
+	doc.VerificationMethod, doc.VerificationOutcome, doc.VerificationAttempts, doc.VerificationAt = method, VerificationVerified, 1, at
+}
@@ -3,8 +3,11 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"sort"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -23,18 +26,125 @@ type DocumentSection struct {
 	ConsentDefault   *bool   `json:"consent_default,omitempty"`
 }
 
+// SignatureField is where the signature is stamped into a PDF document: a 1-based page number
+// and a rectangle in points from the bottom left corner of the page
+type SignatureField struct {
+	Page   int     `json:"page"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
 // Document represents a document to be signed
 type Document struct {
 	ID              string            `json:"id"`
 	DocumentTitle   string            `json:"document_title"`
 	DocumentContent []DocumentSection `json:"document_content"`
-	SignerName      string            `json:"signer_name"`
-	SignerEmail     string            `json:"signer_email"`
-	DeviceID        string            `json:"device_id"`
-	CallbackURL     string            `json:"callback_url"`
-	Status          string            `json:"status"`
+	// DocumentPDF is the original of a PDF document. It is only written by AddDocument; read
+	// it with GetDocumentPDF.
+	DocumentPDF       []byte           `json:"-"`
+	DocumentPDFSHA256 string           `json:"document_pdf_sha256,omitempty"`
+	SignatureFields   []SignatureField `json:"signature_fields,omitempty"`
+	Attachments       []Attachment     `json:"attachments,omitempty"`
+	SignerName        string           `json:"signer_name"`
+	SignerEmail       string           `json:"signer_email"`
+	DeviceID          string           `json:"device_id"`
+	CallbackURL       string           `json:"callback_url"`
+	Status            string           `json:"status"`
+	TemplateID        string           `json:"template_id,omitempty"`
+	ClientID          string           `json:"client_id,omitempty"`
+	Locale            string           `json:"locale,omitempty"`
+	Timezone          string           `json:"timezone,omitempty"`
+	Review            bool             `json:"review,omitempty"`
+	EmailCopy         bool             `json:"email_copy,omitempty"`
+	Remote            bool             `json:"remote,omitempty"`
+	// SignatureData is the signature as a data URL. It is written with UpdateDocumentSignature
+	// and not returned with documents; read it with GetSignatureData.
+	SignatureData   string            `json:"signature_data,omitempty"`
+	Strokes         *SignatureStrokes `json:"signature_strokes,omitempty"`
+	Consents        []Consent         `json:"consents,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	CompletedAt     *time.Time        `json:"completed_at,omitempty"`
+	SubmissionID    string            `json:"submission_id,omitempty"`
+	CapturedAt      *time.Time        `json:"captured_at,omitempty"`
+	CopyToken       string            `json:"-"`
+	CopyExpiresAt   *time.Time        `json:"-"`
+	EmailStatus     string            `json:"email_status,omitempty"`
+	EmailStatusAt   *time.Time        `json:"email_status_at,omitempty"`
+	EmailError      string            `json:"email_error,omitempty"`
+	RemoteToken     string            `json:"-"`
+	RemoteExpiresAt *time.Time        `json:"-"`
+	RemoteSession   string            `json:"-"`
+	OTPHash         string            `json:"-"`
+	OTPSentAt       *time.Time        `json:"-"`
+	OTPExpiresAt    *time.Time        `json:"-"`
+	OTPAttempts     int               `json:"-"`
+	OTPSends        int               `json:"-"`
+	IntegrityHash   string            `json:"integrity_hash,omitempty"`
+	Seal            *Seal             `json:"seal,omitempty"`
+	Timestamp       *Timestamp        `json:"timestamp,omitempty"`
+	// VerificationMethod is how the signer proves who they are before signing, if the sign
+	// request asked for it. VerificationSecret holds the hash of the expected ID digits, encrypted
+	// at rest with the signer's name and email.
+	VerificationMethod   string     `json:"verification_method,omitempty"`
+	VerificationSecret   string     `json:"-"`
+	VerificationAttempts int        `json:"verification_attempts,omitempty"`
+	VerificationOutcome  string     `json:"verification_outcome,omitempty"`
+	VerificationAt       *time.Time `json:"verification_at,omitempty"`
+}
+
+// Location returns the time zone the document was signed in, or UTC when it was not recorded
+func (d Document) Location() *time.Location {
+	if d.Timezone != "" {
+		if location, err := time.LoadLocation(d.Timezone); err == nil {
+			return location
+		}
+	}
+	return time.UTC
+}
+
+// IsPDF reports whether the document was submitted as a PDF, rather than as text sections only
+func (d Document) IsPDF() bool {
+	return d.DocumentPDFSHA256 != ""
+}
+
+// Document statuses
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusRemoved   = "removed"
+	StatusErased    = "erased"
+)
+
+// Delivery statuses of the email sending the signer their copy of a document
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+// SignatureImageURL returns the API URL serving a document's signature image. With an empty
+// baseURL the URL is relative to this service.
+func SignatureImageURL(baseURL, requestID string) string {
+	return strings.TrimRight(baseURL, "/") + "/api/documents/signatures/" + url.PathEscape(requestID) + "/signature"
 }
 
+// CertificateURL returns the API URL serving a document's certificate of completion. With an
+// empty baseURL the URL is relative to this service.
+func CertificateURL(baseURL, requestID string) string {
+	return strings.TrimRight(baseURL, "/") + "/api/documents/signatures/" + url.PathEscape(requestID) + "/certificate"
+}
+
+// PDFURL returns the API URL serving a completed document as a signed PDF. With an empty
+// baseURL the URL is relative to this service.
+func PDFURL(baseURL, requestID string) string {
+	return strings.TrimRight(baseURL, "/") + "/api/documents/signatures/" + url.PathEscape(requestID) + "/pdf"
+}
+
+// ErrDocumentNotFound is returned by a DocumentStore when no document matches the request ID
+var ErrDocumentNotFound = errors.New("document not found")
+
 // DBConfig holds the configuration for the database connection
 type DBConfig struct {
 	Driver   string
@@ -48,7 +158,7 @@ type DBConfig struct {
 func InitDB(config DBConfig) (*sql.DB, error) {
 	var dsn string
 	if config.Driver == "mysql" {
-		dsn = fmt.Sprintf("%s:%s@tcp(%s)/%s", config.User, config.Password, config.Host, config.Name)
+		dsn = fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", config.User, config.Password, config.Host, config.Name)
 	} else if config.Driver == "sqlite3" {
 		dsn = config.Name
 	} else {
@@ -71,18 +181,66 @@ type DocumentStore interface {
 	UpdateDocumentStatus(requestID, status string) error
 	GetSignatureStatus(requestID string) (string, string, error)
 	GetDocument(requestID string) (Document, error)
+	GetDocumentPDF(requestID string) ([]byte, error)
+	GetSignatureData(requestID string) (string, error)
+	GetAttachment(requestID, attachmentID string) (Attachment, error)
 	UpdateDocumentSignature(requestID string, signatureData string) error
 	StoreConsents(requestID string, consents []Consent) error
+	StoreSignatureStrokes(requestID string, strokes SignatureStrokes) error
+	StoreTimezone(requestID string, timezone string) error
+	CompleteSubmission(requestID string, submissionID string, capturedAt *time.Time) (bool, error)
+	StoreCopyLink(requestID string, tokenHash string, expiresAt time.Time) error
+	StoreEmailStatus(requestID string, status string, at time.Time, deliveryError string) error
+	GetDocumentByCopyToken(tokenHash string) (Document, error)
+	StoreRemoteLink(requestID string, tokenHash string, expiresAt time.Time) error
+	GetDocumentByRemoteToken(tokenHash string) (Document, error)
+	StoreRemoteSession(requestID string, sessionHash string, expiresAt time.Time) error
+	StoreOTP(requestID string, codeHash string, sentAt time.Time, expiresAt time.Time) error
+	RecordOTPAttempt(requestID string) error
+	RecordVerificationAttempt(requestID string) error
+	StoreVerificationOutcome(requestID string, outcome string, at time.Time) error
+	StoreCompletion(requestID string, completedAt time.Time, integrityHash string, seal *Seal) error
+	StoreTimestamp(requestID string, timestamp Timestamp) error
+	ListDocumentsBySigner(signerEmail string) ([]Document, error)
+	EraseDocument(requestID string, pseudonym string) error
+	ListRetentionCandidates(rule RetentionRule, createdBefore time.Time) ([]Document, error)
+	PurgeDocument(requestID string, action string) error
 }
 
-func NewDBDocumentStore(db *sql.DB) DocumentStore {
-	return &DBDocumentStore{db: db}
+// DBDocumentStoreOption configures optional behaviour of a DBDocumentStore
+type DBDocumentStoreOption func(*DBDocumentStore)
+
+// WithKeyring encrypts signer names, emails, signature data, consents and the hash of expected ID
+// digits at rest
+func WithKeyring(keyring *Keyring) DBDocumentStoreOption {
+	return func(ds *DBDocumentStore) {
+		ds.keyring = keyring
+	}
+}
+
+// WithBlobStore keeps signature data, PDF documents and the content of attachments in the
+// given blob store, referenced by key from the documents table. Documents with attachments
+// cannot be added without one.
+func WithBlobStore(blobs BlobStore) DBDocumentStoreOption {
+	return func(ds *DBDocumentStore) {
+		ds.blobs = blobs
+	}
+}
+
+func NewDBDocumentStore(db *sql.DB, opts ...DBDocumentStoreOption) DocumentStore {
+	ds := &DBDocumentStore{db: db}
+	for _, opt := range opts {
+		opt(ds)
+	}
+	return ds
 }
 
 // DefaultDocumentStore is the default implementation of DocumentStore
 // It uses the global DB connection.
 type DBDocumentStore struct {
-	db *sql.DB
+	db      *sql.DB
+	keyring *Keyring
+	blobs   BlobStore
 }
 
 func (ds DBDocumentStore) AddDocument(doc Document) (string, error) {
@@ -94,24 +252,252 @@ func (ds DBDocumentStore) AddDocument(doc Document) (string, error) {
 	// generate UUID
 	uuid := uuid.NewString()
 
-	query := "INSERT INTO documents (id, document_title, document_content, signer_name, signer_email, device_id, callback_url, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
-	_, err = ds.db.Exec(query, uuid, doc.DocumentTitle, documentContent, doc.SignerName, doc.SignerEmail, doc.DeviceID, doc.CallbackURL, doc.Status)
+	var keyID, wrappedKey, emailIndex sql.NullString
+	signerName, signerEmail, verificationSecret := doc.SignerName, doc.SignerEmail, doc.VerificationSecret
+	if ds.keyring != nil {
+		dataKey, wrapped, id, err := ds.keyring.NewDataKey()
+		if err != nil {
+			return "", err
+		}
+		keyID = sql.NullString{String: id, Valid: true}
+		wrappedKey = sql.NullString{String: wrapped, Valid: true}
+		emailIndex = sql.NullString{String: ds.keyring.BlindIndex(NormalizeSubjectID(doc.SignerEmail)), Valid: true}
+		if signerName, err = encryptField(dataKey, doc.SignerName, uuid+"|signer_name"); err != nil {
+			return "", fmt.Errorf("error encrypting signer name: %v", err)
+		}
+		if signerEmail, err = encryptField(dataKey, doc.SignerEmail, uuid+"|signer_email"); err != nil {
+			return "", fmt.Errorf("error encrypting signer email: %v", err)
+		}
+		// The hash of a few ID digits is quickly reversed, so it is not left readable either
+		if verificationSecret, err = encryptField(dataKey, doc.VerificationSecret, uuid+"|verification_secret"); err != nil {
+			return "", fmt.Errorf("error encrypting verification secret: %v", err)
+		}
+	}
+
+	// Blobs are written first, so a document never references a missing blob
+	var documentPDFSHA256, signatureFields, pdfKey sql.NullString
+	documentPDF := doc.DocumentPDF
+	var written []string
+	if doc.DocumentPDF != nil {
+		documentPDFSHA256 = sql.NullString{String: doc.DocumentPDFSHA256, Valid: true}
+		fields, err := json.Marshal(doc.SignatureFields)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling signature fields: %v", err)
+		}
+		signatureFields = sql.NullString{String: string(fields), Valid: true}
+		if ds.blobs != nil {
+			key := documentPDFKey(uuid)
+			if err := ds.blobs.PutBlob(key, doc.DocumentPDF); err != nil {
+				return "", fmt.Errorf("error storing document PDF: %v", err)
+			}
+			written = append(written, key)
+			documentPDF = nil
+			pdfKey = sql.NullString{String: key, Valid: true}
+		}
+	}
+
+	var attachments sql.NullString
+	if len(doc.Attachments) > 0 {
+		if ds.blobs == nil {
+			return "", fmt.Errorf("no blob store configured for attachments")
+		}
+		for _, attachment := range doc.Attachments {
+			key := attachmentKey(uuid, attachment.ID)
+			if err := ds.blobs.PutBlob(key, attachment.Data); err != nil {
+				ds.deleteBlobs(written)
+				return "", fmt.Errorf("error storing attachment: %v", err)
+			}
+			written = append(written, key)
+		}
+		metadata, err := json.Marshal(doc.Attachments)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling attachments: %v", err)
+		}
+		attachments = sql.NullString{String: string(metadata), Valid: true}
+	}
+
+	query := "INSERT INTO documents (id, document_title, document_content, document_pdf, document_pdf_key, document_pdf_sha256, signature_fields, attachments, signer_name, signer_email, device_id, callback_url, status, template_id, client_id, locale, timezone, review, email_copy, remote, verification_method, verification_secret, encryption_key_id, wrapped_key, signer_email_index) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = ds.db.Exec(query, uuid, doc.DocumentTitle, documentContent, documentPDF, pdfKey, documentPDFSHA256, signatureFields, attachments, signerName, signerEmail, doc.DeviceID, doc.CallbackURL, doc.Status, doc.TemplateID, doc.ClientID, doc.Locale, doc.Timezone, doc.Review, doc.EmailCopy, doc.Remote, doc.VerificationMethod, verificationSecret, keyID, wrappedKey, emailIndex)
 	if err != nil {
+		ds.deleteBlobs(written)
 		return "", fmt.Errorf("error inserting document: %v", err)
 	}
 
 	return uuid, nil
 }
 
-// ListDocuments lists all pending documents for a specific device
-func (ds DBDocumentStore) ListDocuments(deviceID string) ([]Document, error) {
-	query := `
-		SELECT id, document_title, document_content, signer_name, signer_email, device_id, callback_url, status 
-		FROM documents 
-		WHERE device_id = ? AND status = 'pending'
-		ORDER BY created_at DESC`
+// documentColumns lists the columns read by scanDocument, in order
+const documentColumns = "id, document_title, document_content, signer_name, signer_email, device_id, callback_url, status, template_id, client_id, signature_strokes, consents, created_at, encryption_key_id, wrapped_key, completed_at, integrity_hash, seal, trusted_timestamp, document_pdf_sha256, signature_fields, attachments, locale, timezone, submission_id, captured_at, review, copy_token, copy_expires_at, email_copy, email_status, email_status_at, email_error, remote, remote_token, remote_expires_at, remote_session, otp_hash, otp_sent_at, otp_expires_at, otp_attempts, otp_sends, verification_method, verification_secret, verification_attempts, verification_outcome, verification_at"
 
-	rows, err := ds.db.Query(query, deviceID)
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanDocument reads a document selected with documentColumns, decrypting encrypted fields
+func (ds DBDocumentStore) scanDocument(row rowScanner) (Document, error) {
+	var doc Document
+	var documentTitle, templateID, clientID, strokes, consents, keyID, wrappedKey, integrityHash, seal, timestamp, documentPDFSHA256, signatureFields, attachments, locale, timezone, submissionID, copyToken, emailStatus, emailError, remoteToken, remoteSession, otpHash, verificationMethod, verificationSecret, verificationOutcome sql.NullString
+	var completedAt, capturedAt, copyExpiresAt, emailStatusAt, remoteExpiresAt, otpSentAt, otpExpiresAt, verificationAt sql.NullTime
+	var review, emailCopy, remote sql.NullBool
+	var otpAttempts, otpSends, verificationAttempts sql.NullInt64
+	var documentContent []byte
+	err := row.Scan(
+		&doc.ID,
+		&documentTitle,
+		&documentContent,
+		&doc.SignerName,
+		&doc.SignerEmail,
+		&doc.DeviceID,
+		&doc.CallbackURL,
+		&doc.Status,
+		&templateID,
+		&clientID,
+		&strokes,
+		&consents,
+		&doc.CreatedAt,
+		&keyID,
+		&wrappedKey,
+		&completedAt,
+		&integrityHash,
+		&seal,
+		&timestamp,
+		&documentPDFSHA256,
+		&signatureFields,
+		&attachments,
+		&locale,
+		&timezone,
+		&submissionID,
+		&capturedAt,
+		&review,
+		&copyToken,
+		&copyExpiresAt,
+		&emailCopy,
+		&emailStatus,
+		&emailStatusAt,
+		&emailError,
+		&remote,
+		&remoteToken,
+		&remoteExpiresAt,
+		&remoteSession,
+		&otpHash,
+		&otpSentAt,
+		&otpExpiresAt,
+		&otpAttempts,
+		&otpSends,
+		&verificationMethod,
+		&verificationSecret,
+		&verificationAttempts,
+		&verificationOutcome,
+		&verificationAt,
+	)
+	if err != nil {
+		return Document{}, err
+	}
+	doc.DocumentTitle = documentTitle.String
+	doc.TemplateID = templateID.String
+	doc.ClientID = clientID.String
+	doc.Locale = locale.String
+	doc.Timezone = timezone.String
+	doc.SubmissionID = submissionID.String
+	doc.Review = review.Bool
+	doc.CopyToken = copyToken.String
+	doc.EmailCopy = emailCopy.Bool
+	doc.EmailStatus = emailStatus.String
+	doc.EmailError = emailError.String
+	doc.Remote = remote.Bool
+	doc.RemoteToken = remoteToken.String
+	doc.RemoteSession = remoteSession.String
+	doc.OTPHash = otpHash.String
+	doc.OTPAttempts = int(otpAttempts.Int64)
+	doc.OTPSends = int(otpSends.Int64)
+	doc.VerificationMethod = verificationMethod.String
+	doc.VerificationSecret = verificationSecret.String
+	doc.VerificationAttempts = int(verificationAttempts.Int64)
+	doc.VerificationOutcome = verificationOutcome.String
+	doc.IntegrityHash = integrityHash.String
+	doc.DocumentPDFSHA256 = documentPDFSHA256.String
+	if completedAt.Valid {
+		completed := completedAt.Time.UTC()
+		doc.CompletedAt = &completed
+	}
+	if capturedAt.Valid {
+		captured := capturedAt.Time.UTC()
+		doc.CapturedAt = &captured
+	}
+	if copyExpiresAt.Valid {
+		expiresAt := copyExpiresAt.Time.UTC()
+		doc.CopyExpiresAt = &expiresAt
+	}
+	if emailStatusAt.Valid {
+		statusAt := emailStatusAt.Time.UTC()
+		doc.EmailStatusAt = &statusAt
+	}
+	if remoteExpiresAt.Valid {
+		expiresAt := remoteExpiresAt.Time.UTC()
+		doc.RemoteExpiresAt = &expiresAt
+	}
+	if otpSentAt.Valid {
+		sentAt := otpSentAt.Time.UTC()
+		doc.OTPSentAt = &sentAt
+	}
+	if otpExpiresAt.Valid {
+		expiresAt := otpExpiresAt.Time.UTC()
+		doc.OTPExpiresAt = &expiresAt
+	}
+	if verificationAt.Valid {
+		at := verificationAt.Time.UTC()
+		doc.VerificationAt = &at
+	}
+	if seal.String != "" {
+		doc.Seal = &Seal{}
+		if err := json.Unmarshal([]byte(seal.String), doc.Seal); err != nil {
+			return Document{}, fmt.Errorf("error unmarshaling seal: %v", err)
+		}
+	}
+	if timestamp.String != "" {
+		doc.Timestamp = &Timestamp{}
+		if err := json.Unmarshal([]byte(timestamp.String), doc.Timestamp); err != nil {
+			return Document{}, fmt.Errorf("error unmarshaling timestamp: %v", err)
+		}
+	}
+	if signatureFields.String != "" {
+		if err := json.Unmarshal([]byte(signatureFields.String), &doc.SignatureFields); err != nil {
+			return Document{}, fmt.Errorf("error unmarshaling signature fields: %v", err)
+		}
+	}
+	if attachments.String != "" {
+		if err := json.Unmarshal([]byte(attachments.String), &doc.Attachments); err != nil {
+			return Document{}, fmt.Errorf("error unmarshaling attachments: %v", err)
+		}
+	}
+
+	if err := json.Unmarshal(documentContent, &doc.DocumentContent); err != nil {
+		return Document{}, fmt.Errorf("error unmarshaling document content: %v", err)
+	}
+
+	consentsJSON, strokesJSON := consents.String, strokes.String
+	if err := ds.decryptDocument(&doc, &consentsJSON, &strokesJSON, keyID.String, wrappedKey.String); err != nil {
+		return Document{}, fmt.Errorf("error decrypting document %s: %v", doc.ID, err)
+	}
+	if consentsJSON != "" {
+		if err := json.Unmarshal([]byte(consentsJSON), &doc.Consents); err != nil {
+			return Document{}, fmt.Errorf("error unmarshaling consents: %v", err)
+		}
+	}
+	if strokesJSON != "" {
+		doc.Strokes = &SignatureStrokes{}
+		if err := json.Unmarshal([]byte(strokesJSON), doc.Strokes); err != nil {
+			return Document{}, fmt.Errorf("error unmarshaling signature strokes: %v", err)
+		}
+	}
+
+	return doc, nil
+}
+
+// queryDocuments runs a query selecting documentColumns and scans every row
+func (ds DBDocumentStore) queryDocuments(query string, args ...any) ([]Document, error) {
+	rows, err := ds.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying documents: %v", err)
 	}
@@ -119,14 +505,10 @@ func (ds DBDocumentStore) ListDocuments(deviceID string) ([]Document, error) {
 
 	var documents []Document
 	for rows.Next() {
-		var doc Document
-		var documentContent []byte
-		if err := rows.Scan(&doc.ID, &doc.DocumentTitle, &documentContent, &doc.SignerName, &doc.SignerEmail, &doc.DeviceID, &doc.CallbackURL, &doc.Status); err != nil {
+		doc, err := ds.scanDocument(rows)
+		if err != nil {
 			return nil, fmt.Errorf("error scanning document: %v", err)
 		}
-		if err := json.Unmarshal(documentContent, &doc.DocumentContent); err != nil {
-			return nil, fmt.Errorf("error unmarshaling document content: %v", err)
-		}
 		documents = append(documents, doc)
 	}
 
@@ -137,6 +519,39 @@ func (ds DBDocumentStore) ListDocuments(deviceID string) ([]Document, error) {
 	return documents, nil
 }
 
+// ListDocuments lists all pending documents for a specific device
+func (ds DBDocumentStore) ListDocuments(deviceID string) ([]Document, error) {
+	query := `
+		SELECT ` + documentColumns + `
+		FROM documents 
+		WHERE device_id = ? AND status = 'pending'
+		ORDER BY created_at DESC`
+
+	return ds.queryDocuments(query, deviceID)
+}
+
+// ListDocumentsBySigner lists all documents addressed to a signer email, in any status.
+// Encrypted rows are matched through the blind index of the email.
+func (ds DBDocumentStore) ListDocumentsBySigner(signerEmail string) ([]Document, error) {
+	signerEmail = NormalizeSubjectID(signerEmail)
+	condition := "LOWER(signer_email) = ?"
+	args := []any{signerEmail}
+	if ds.keyring != nil {
+		for _, index := range ds.keyring.BlindIndexes(signerEmail) {
+			condition += " OR signer_email_index = ?"
+			args = append(args, index)
+		}
+	}
+
+	query := `
+		SELECT ` + documentColumns + `
+		FROM documents 
+		WHERE ` + condition + `
+		ORDER BY created_at ASC`
+
+	return ds.queryDocuments(query, args...)
+}
+
 // UpdateDocumentStatus updates the status of a document
 func (ds DBDocumentStore) UpdateDocumentStatus(requestID, status string) error {
 	query := "UPDATE documents SET status = ? WHERE id = ?"
@@ -150,6 +565,9 @@ func (ds DBDocumentStore) GetSignatureStatus(requestID string) (string, string,
 	var status string
 	var documentContent []byte
 	err := ds.db.QueryRow(query, requestID).Scan(&status, &documentContent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrDocumentNotFound
+	}
 	if err != nil {
 		return "", "", err
 	}
@@ -174,37 +592,160 @@ func (ds DBDocumentStore) GetSignatureStatus(requestID string) (string, string,
 // GetDocument retrieves a document by its ID
 func (ds DBDocumentStore) GetDocument(requestID string) (Document, error) {
 	query := `
-		SELECT id, document_title, document_content, signer_name, signer_email, device_id, callback_url, status 
+		SELECT ` + documentColumns + `
 		FROM documents 
 		WHERE id = ?`
 
-	var doc Document
-	var documentContent []byte
-	err := ds.db.QueryRow(query, requestID).Scan(
-		&doc.ID,
-		&doc.DocumentTitle,
-		&documentContent,
-		&doc.SignerName,
-		&doc.SignerEmail,
-		&doc.DeviceID,
-		&doc.CallbackURL,
-		&doc.Status,
-	)
+	doc, err := ds.scanDocument(ds.db.QueryRow(query, requestID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Document{}, ErrDocumentNotFound
+	}
 	if err != nil {
 		return Document{}, err
 	}
 
-	if err := json.Unmarshal(documentContent, &doc.DocumentContent); err != nil {
-		return Document{}, fmt.Errorf("error unmarshaling document content: %v", err)
+	return doc, nil
+}
+
+// GetDocumentPDF retrieves the original PDF of a document. It returns nil for documents without
+// a PDF and for PDFs removed by erasure or retention.
+func (ds DBDocumentStore) GetDocumentPDF(requestID string) ([]byte, error) {
+	var data []byte
+	var key sql.NullString
+	err := ds.db.QueryRow("SELECT document_pdf, document_pdf_key FROM documents WHERE id = ?", requestID).Scan(&data, &key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentNotFound
 	}
+	if err != nil || key.String == "" {
+		return data, err
+	}
+	return ds.getBlob(key.String)
+}
 
-	return doc, nil
+// GetSignatureData retrieves the signature of a document as a data URL. It returns an empty
+// string for documents not signed yet and for signatures removed by erasure or retention.
+func (ds DBDocumentStore) GetSignatureData(requestID string) (string, error) {
+	var data, key, keyID, wrappedKey sql.NullString
+	query := "SELECT signature_data, signature_key, encryption_key_id, wrapped_key FROM documents WHERE id = ?"
+	err := ds.db.QueryRow(query, requestID).Scan(&data, &key, &keyID, &wrappedKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrDocumentNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	signatureData := data.String
+	if key.String != "" {
+		blob, err := ds.getBlob(key.String)
+		if err != nil {
+			return "", fmt.Errorf("error reading signature of document %s: %v", requestID, err)
+		}
+		signatureData = string(blob)
+	}
+	dataKey, err := ds.dataKey(keyID.String, wrappedKey.String)
+	if err != nil {
+		return "", fmt.Errorf("error decrypting signature of document %s: %v", requestID, err)
+	}
+	signatureData, err = decryptField(dataKey, signatureData, requestID+"|signature_data")
+	if err != nil {
+		return "", fmt.Errorf("error decrypting signature of document %s: %v", requestID, err)
+	}
+	return signatureData, nil
+}
+
+// GetAttachment retrieves an attachment of a document together with its content
+func (ds DBDocumentStore) GetAttachment(requestID, attachmentID string) (Attachment, error) {
+	doc, err := ds.GetDocument(requestID)
+	if err != nil {
+		return Attachment{}, err
+	}
+	for _, attachment := range doc.Attachments {
+		if attachment.ID != attachmentID {
+			continue
+		}
+		attachment.Data, err = ds.getBlob(attachmentKey(requestID, attachment.ID))
+		if err != nil {
+			return Attachment{}, fmt.Errorf("error reading attachment %s: %v", attachment.ID, err)
+		}
+		return attachment, nil
+	}
+	return Attachment{}, ErrAttachmentNotFound
 }
 
-// UpdateDocumentSignature updates the signature data for a document
+// getBlob reads a blob a document references
+func (ds DBDocumentStore) getBlob(key string) ([]byte, error) {
+	if ds.blobs == nil {
+		return nil, fmt.Errorf("document references blob %s but no blob store is configured", key)
+	}
+	return ds.blobs.GetBlob(key)
+}
+
+// blobKeys lists the blobs a document references, so they can be deleted with the data. The
+// signature is always included; the PDF and attachments only with content.
+func (ds DBDocumentStore) blobKeys(requestID string, content bool) ([]string, error) {
+	var signatureKey, documentPDFKey, metadata sql.NullString
+	err := ds.db.QueryRow("SELECT signature_key, document_pdf_key, attachments FROM documents WHERE id = ?", requestID).
+		Scan(&signatureKey, &documentPDFKey, &metadata)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if signatureKey.String != "" {
+		keys = append(keys, signatureKey.String)
+	}
+	if !content {
+		return keys, nil
+	}
+	if documentPDFKey.String != "" {
+		keys = append(keys, documentPDFKey.String)
+	}
+	if metadata.String != "" {
+		var attachments []Attachment
+		if err := json.Unmarshal([]byte(metadata.String), &attachments); err != nil {
+			return nil, fmt.Errorf("error unmarshaling attachments: %v", err)
+		}
+		for _, attachment := range attachments {
+			keys = append(keys, attachmentKey(requestID, attachment.ID))
+		}
+	}
+	return keys, nil
+}
+
+// deleteBlobs removes blobs from the blob store
+func (ds DBDocumentStore) deleteBlobs(keys []string) error {
+	if ds.blobs == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if err := ds.blobs.DeleteBlob(key); err != nil {
+			return fmt.Errorf("error deleting blob %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// UpdateDocumentSignature updates the signature data for a document. With a blob store the
+// data is written there, encrypted as it would be in the column.
 func (ds DBDocumentStore) UpdateDocumentSignature(requestID string, signatureData string) error {
-	query := "UPDATE documents SET signature_data = ? WHERE id = ?"
-	_, err := ds.db.Exec(query, signatureData, requestID)
+	signatureData, err := ds.encryptColumn(requestID, "signature_data", signatureData)
+	if err != nil {
+		return err
+	}
+
+	if ds.blobs == nil || signatureData == "" {
+		query := "UPDATE documents SET signature_data = ? WHERE id = ?"
+		_, err = ds.db.Exec(query, signatureData, requestID)
+		return err
+	}
+	key := signatureKey(requestID)
+	if err := ds.blobs.PutBlob(key, []byte(signatureData)); err != nil {
+		return fmt.Errorf("error storing signature: %v", err)
+	}
+	query := "UPDATE documents SET signature_data = NULL, signature_key = ? WHERE id = ?"
+	_, err = ds.db.Exec(query, key, requestID)
 	return err
 }
 
@@ -223,26 +764,303 @@ func (ds DBDocumentStore) StoreConsents(requestID string, consents []Consent) er
 		return fmt.Errorf("error marshaling consents: %v", err)
 	}
 
+	storedConsents, err := ds.encryptColumn(requestID, "consents", string(consentsJSON))
+	if err != nil {
+		return err
+	}
+
 	query := "UPDATE documents SET consents = ? WHERE id = ?"
-	_, err = ds.db.Exec(query, consentsJSON, requestID)
+	_, err = ds.db.Exec(query, storedConsents, requestID)
+	return err
+}
+
+// StoreSignatureStrokes stores the vector stroke data captured with a signature
+func (ds DBDocumentStore) StoreSignatureStrokes(requestID string, strokes SignatureStrokes) error {
+	strokesJSON, err := json.Marshal(strokes)
+	if err != nil {
+		return fmt.Errorf("error marshaling signature strokes: %v", err)
+	}
+
+	storedStrokes, err := ds.encryptColumn(requestID, "signature_strokes", string(strokesJSON))
+	if err != nil {
+		return err
+	}
+
+	query := "UPDATE documents SET signature_strokes = ? WHERE id = ?"
+	_, err = ds.db.Exec(query, storedStrokes, requestID)
+	return err
+}
+
+// StoreTimezone records the time zone, an IANA name such as Europe/Warsaw, a document is
+// signed in
+func (ds DBDocumentStore) StoreTimezone(requestID string, timezone string) error {
+	_, err := ds.db.Exec("UPDATE documents SET timezone = ? WHERE id = ?", timezone, requestID)
+	return err
+}
+
+// CompleteSubmission marks a pending document completed by a signature submission. It records
+// the ID the tablet gave the submission, which makes replaying it after a lost connection safe,
+// and when the signer submitted it on the tablet. For a submission queued while the tablet was
+// offline, this capture time is earlier than the completion time, when the service received it.
+// Either may be unset. It reports false, changing nothing, when the document is no longer
+// pending, so that of two submissions racing for a document only one completes it.
+func (ds DBDocumentStore) CompleteSubmission(requestID string, submissionID string, capturedAt *time.Time) (bool, error) {
+	var captured sql.NullTime
+	if capturedAt != nil {
+		captured = sql.NullTime{Time: capturedAt.UTC(), Valid: true}
+	}
+	query := "UPDATE documents SET status = ?, submission_id = ?, captured_at = ? WHERE id = ? AND status = ?"
+	result, err := ds.db.Exec(query, StatusCompleted, sql.NullString{String: submissionID, Valid: submissionID != ""}, captured, requestID, StatusPending)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// StoreCopyLink records the hash of the token of the link a signer downloads their copy of a
+// completed document with, and when the link expires
+func (ds DBDocumentStore) StoreCopyLink(requestID string, tokenHash string, expiresAt time.Time) error {
+	query := "UPDATE documents SET copy_token = ?, copy_expires_at = ? WHERE id = ?"
+	_, err := ds.db.Exec(query, tokenHash, expiresAt.UTC(), requestID)
+	return err
+}
+
+// StoreEmailStatus records the delivery status of the email sending the signer their copy of a
+// document, when it was reached and, for a failed delivery, why
+func (ds DBDocumentStore) StoreEmailStatus(requestID string, status string, at time.Time, deliveryError string) error {
+	query := "UPDATE documents SET email_status = ?, email_status_at = ?, email_error = ? WHERE id = ?"
+	_, err := ds.db.Exec(query, status, at.UTC(), sql.NullString{String: deliveryError, Valid: deliveryError != ""}, requestID)
+	return err
+}
+
+// GetDocumentByCopyToken retrieves the document whose copy link has the token with the given
+// hash, expired or not
+func (ds DBDocumentStore) GetDocumentByCopyToken(tokenHash string) (Document, error) {
+	query := `
+		SELECT ` + documentColumns + `
+		FROM documents
+		WHERE copy_token = ?`
+
+	doc, err := ds.scanDocument(ds.db.QueryRow(query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Document{}, ErrDocumentNotFound
+	}
+	return doc, err
+}
+
+// StoreRemoteLink records the hash of the token of the link a signer opens to sign a document
+// remotely, and when the link expires
+func (ds DBDocumentStore) StoreRemoteLink(requestID string, tokenHash string, expiresAt time.Time) error {
+	query := "UPDATE documents SET remote_token = ?, remote_expires_at = ? WHERE id = ?"
+	_, err := ds.db.Exec(query, tokenHash, expiresAt.UTC(), requestID)
+	return err
+}
+
+// GetDocumentByRemoteToken retrieves the document whose remote signing link has the token with the
+// hash, expired or not
+func (ds DBDocumentStore) GetDocumentByRemoteToken(tokenHash string) (Document, error) {
+	query := `
+		SELECT ` + documentColumns + `
+		FROM documents
+		WHERE remote_token = ?`
+
+	doc, err := ds.scanDocument(ds.db.QueryRow(query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Document{}, ErrDocumentNotFound
+	}
+	return doc, err
+}
+
+// StoreRemoteSession records the hash of the session a signer verified with a code signs a
+// document remotely in, and when it expires. The link the session was opened with and the code
+// can no longer be used.
+func (ds DBDocumentStore) StoreRemoteSession(requestID string, sessionHash string, expiresAt time.Time) error {
+	query := "UPDATE documents SET remote_token = NULL, remote_session = ?, remote_expires_at = ?, otp_hash = NULL, otp_expires_at = NULL WHERE id = ?"
+	_, err := ds.db.Exec(query, sessionHash, expiresAt.UTC(), requestID)
+	return err
+}
+
+// StoreOTP records the hash of a one-time code sent to the signer, when it was sent and when it
+// expires, replacing any code sent before and its attempts and counting the codes sent
+func (ds DBDocumentStore) StoreOTP(requestID string, codeHash string, sentAt time.Time, expiresAt time.Time) error {
+	query := "UPDATE documents SET otp_hash = ?, otp_sent_at = ?, otp_expires_at = ?, otp_attempts = 0, otp_sends = otp_sends + 1 WHERE id = ?"
+	_, err := ds.db.Exec(query, codeHash, sentAt.UTC(), expiresAt.UTC(), requestID)
+	return err
+}
+
+// RecordOTPAttempt counts an attempt at entering the one-time code sent to the signer
+func (ds DBDocumentStore) RecordOTPAttempt(requestID string) error {
+	_, err := ds.db.Exec("UPDATE documents SET otp_attempts = otp_attempts + 1 WHERE id = ?", requestID)
+	return err
+}
+
+// RecordVerificationAttempt counts an attempt of the signer at proving who they are
+func (ds DBDocumentStore) RecordVerificationAttempt(requestID string) error {
+	_, err := ds.db.Exec("UPDATE documents SET verification_attempts = verification_attempts + 1 WHERE id = ?", requestID)
+	return err
+}
+
+// StoreVerificationOutcome records whether the signer proved who they are, and when
+func (ds DBDocumentStore) StoreVerificationOutcome(requestID string, outcome string, at time.Time) error {
+	_, err := ds.db.Exec("UPDATE documents SET verification_outcome = ?, verification_at = ? WHERE id = ?", outcome, at.UTC(), requestID)
+	return err
+}
+
+// StoreCompletion records when a document was completed, the integrity hash of its completion
+// record and, when sealing is enabled, the service's seal over that hash
+func (ds DBDocumentStore) StoreCompletion(requestID string, completedAt time.Time, integrityHash string, seal *Seal) error {
+	var sealJSON sql.NullString
+	if seal != nil {
+		data, err := json.Marshal(seal)
+		if err != nil {
+			return fmt.Errorf("error marshaling seal: %v", err)
+		}
+		sealJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	query := "UPDATE documents SET completed_at = ?, integrity_hash = ?, seal = ? WHERE id = ?"
+	_, err := ds.db.Exec(query, completedAt.UTC(), integrityHash, sealJSON, requestID)
+	return err
+}
+
+// StoreTimestamp records the trusted timestamp obtained for a completed document's integrity hash
+func (ds DBDocumentStore) StoreTimestamp(requestID string, timestamp Timestamp) error {
+	data, err := json.Marshal(timestamp)
+	if err != nil {
+		return fmt.Errorf("error marshaling timestamp: %v", err)
+	}
+
+	query := "UPDATE documents SET trusted_timestamp = ? WHERE id = ?"
+	_, err = ds.db.Exec(query, string(data), requestID)
 	return err
 }
 
+// EraseDocument irreversibly removes the personal data held in a document row. The signer
+// name and email are replaced with the given pseudonym (empty to erase them), the content,
+// PDF, attachments, signature, strokes and consents are cleared, together with their blobs,
+// and the status is set to erased. The ID, title, device, callback URL and creation time are
+// kept as a minimal audit record.
+func (ds DBDocumentStore) EraseDocument(requestID string, pseudonym string) error {
+	keys, err := ds.blobKeys(requestID, true)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE documents
+		SET signer_name = ?, signer_email = ?, signer_email_index = NULL, document_content = '[]', document_pdf = NULL, document_pdf_key = NULL, attachments = NULL, signature_data = NULL, signature_key = NULL, signature_strokes = NULL, consents = NULL, copy_token = NULL, copy_expires_at = NULL, email_error = NULL, remote_token = NULL, remote_session = NULL, otp_hash = NULL, verification_secret = NULL, status = ?
+		WHERE id = ?`
+	if _, err := ds.db.Exec(query, pseudonym, pseudonym, StatusErased, requestID); err != nil {
+		return err
+	}
+	return ds.deleteBlobs(keys)
+}
+
+// ListRetentionCandidates lists documents created before the cutoff that match the rule's filters
+// and still hold data the rule's action would remove. Pending documents only match rules that
+// name the pending status.
+func (ds DBDocumentStore) ListRetentionCandidates(rule RetentionRule, createdBefore time.Time) ([]Document, error) {
+	query := `
+		SELECT ` + documentColumns + `
+		FROM documents
+		WHERE created_at < ?`
+	args := []any{createdBefore.UTC()}
+
+	if rule.Status != "" {
+		query += " AND status = ?"
+		args = append(args, rule.Status)
+	} else {
+		query += " AND status != ?"
+		args = append(args, StatusPending)
+	}
+	if rule.TemplateID != "" {
+		query += " AND template_id = ?"
+		args = append(args, rule.TemplateID)
+	}
+	if rule.ClientID != "" {
+		query += " AND client_id = ?"
+		args = append(args, rule.ClientID)
+	}
+
+	switch rule.Action {
+	case RetentionActionPurgeSignature:
+		query += " AND (signature_data IS NOT NULL OR signature_key IS NOT NULL OR signature_strokes IS NOT NULL)"
+	case RetentionActionPurgeContent:
+		query += " AND (document_content != '[]' OR document_pdf IS NOT NULL OR document_pdf_key IS NOT NULL OR attachments IS NOT NULL OR signature_data IS NOT NULL OR signature_key IS NOT NULL OR signature_strokes IS NOT NULL OR consents IS NOT NULL)"
+	}
+	query += " ORDER BY created_at ASC"
+
+	return ds.queryDocuments(query, args...)
+}
+
+// PurgeDocument applies a retention action to a document
+func (ds DBDocumentStore) PurgeDocument(requestID string, action string) error {
+	var query string
+	switch action {
+	case RetentionActionPurgeSignature:
+		query = "UPDATE documents SET signature_data = NULL, signature_key = NULL, signature_strokes = NULL WHERE id = ?"
+	case RetentionActionPurgeContent:
+		query = "UPDATE documents SET document_content = '[]', document_pdf = NULL, document_pdf_key = NULL, attachments = NULL, signature_data = NULL, signature_key = NULL, signature_strokes = NULL, consents = NULL WHERE id = ?"
+	case RetentionActionDelete:
+		query = "DELETE FROM documents WHERE id = ?"
+	default:
+		return fmt.Errorf("unsupported retention action: %s", action)
+	}
+
+	keys, err := ds.blobKeys(requestID, action != RetentionActionPurgeSignature)
+	if err != nil {
+		return err
+	}
+	if _, err := ds.db.Exec(query, requestID); err != nil {
+		return err
+	}
+	return ds.deleteBlobs(keys)
+}
+
 // InMemoryDocumentStore is an in-memory implementation of the DocumentStore interface
 // for testing purposes.
 type InMemoryDocumentStore struct {
-	documents map[string]Document
+	documents  map[string]Document
+	pdfs       map[string][]byte
+	signatures map[string]string
+	blobs      map[string][]byte
 }
 
 func NewInMemoryDocumentStore() *InMemoryDocumentStore {
 	return &InMemoryDocumentStore{
-		documents: make(map[string]Document),
+		documents:  make(map[string]Document),
+		pdfs:       make(map[string][]byte),
+		signatures: make(map[string]string),
+		blobs:      make(map[string][]byte),
 	}
 }
 
 func (m *InMemoryDocumentStore) AddDocument(doc Document) (string, error) {
 	id := uuid.NewString()
 	doc.ID = id
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	if doc.DocumentPDF != nil {
+		m.pdfs[id] = doc.DocumentPDF
+		doc.DocumentPDF = nil
+	}
+	if doc.SignatureData != "" {
+		m.signatures[id] = doc.SignatureData
+		doc.SignatureData = ""
+	}
+	attachments := make([]Attachment, len(doc.Attachments))
+	for i, attachment := range doc.Attachments {
+		m.blobs[attachmentKey(id, attachment.ID)] = attachment.Data
+		attachment.Data = nil
+		attachments[i] = attachment
+	}
+	if len(attachments) > 0 {
+		doc.Attachments = attachments
+	}
 	m.documents[id] = doc
 	return id, nil
 }
@@ -264,7 +1082,7 @@ func (m *InMemoryDocumentStore) ListDocuments(deviceID string) ([]Document, erro
 func (m *InMemoryDocumentStore) UpdateDocumentStatus(requestID, status string) error {
 	doc, exists := m.documents[requestID]
 	if !exists {
-		return fmt.Errorf("document not found")
+		return ErrDocumentNotFound
 	}
 	doc.Status = status
 	m.documents[requestID] = doc
@@ -274,7 +1092,7 @@ func (m *InMemoryDocumentStore) UpdateDocumentStatus(requestID, status string) e
 func (m *InMemoryDocumentStore) GetSignatureStatus(requestID string) (string, string, error) {
 	doc, exists := m.documents[requestID]
 	if !exists {
-		return "", "", fmt.Errorf("document not found")
+		return "", "", ErrDocumentNotFound
 	}
 
 	// Find the first section with type "text" to use as document URL
@@ -292,28 +1110,334 @@ func (m *InMemoryDocumentStore) GetSignatureStatus(requestID string) (string, st
 func (m *InMemoryDocumentStore) GetDocument(requestID string) (Document, error) {
 	doc, exists := m.documents[requestID]
 	if !exists {
-		return Document{}, fmt.Errorf("document not found")
+		return Document{}, ErrDocumentNotFound
 	}
 	return doc, nil
 }
 
+func (m *InMemoryDocumentStore) GetDocumentPDF(requestID string) ([]byte, error) {
+	if _, exists := m.documents[requestID]; !exists {
+		return nil, ErrDocumentNotFound
+	}
+	return m.pdfs[requestID], nil
+}
+
+func (m *InMemoryDocumentStore) GetSignatureData(requestID string) (string, error) {
+	if _, exists := m.documents[requestID]; !exists {
+		return "", ErrDocumentNotFound
+	}
+	return m.signatures[requestID], nil
+}
+
+func (m *InMemoryDocumentStore) GetAttachment(requestID, attachmentID string) (Attachment, error) {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return Attachment{}, ErrDocumentNotFound
+	}
+	for _, attachment := range doc.Attachments {
+		if attachment.ID == attachmentID {
+			attachment.Data = m.blobs[attachmentKey(requestID, attachmentID)]
+			return attachment, nil
+		}
+	}
+	return Attachment{}, ErrAttachmentNotFound
+}
+
+// deleteAttachments removes a document's attachments
+func (m *InMemoryDocumentStore) deleteAttachments(doc *Document) {
+	for _, attachment := range doc.Attachments {
+		delete(m.blobs, attachmentKey(doc.ID, attachment.ID))
+	}
+	doc.Attachments = nil
+}
+
 func (m *InMemoryDocumentStore) UpdateDocumentSignature(requestID string, signatureData string) error {
 	doc, exists := m.documents[requestID]
 	if !exists {
-		return fmt.Errorf("document not found")
+		return ErrDocumentNotFound
 	}
-	// In a real implementation, we would store the signature data in a proper field
-	// For now, we'll just update the status
+	m.signatures[requestID] = signatureData
 	doc.Status = "completed"
 	m.documents[requestID] = doc
 	return nil
 }
 
 func (m *InMemoryDocumentStore) StoreConsents(requestID string, consents []Consent) error {
-	_, exists := m.documents[requestID]
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	doc.Consents = consents
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) StoreSignatureStrokes(requestID string, strokes SignatureStrokes) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	doc.Strokes = &strokes
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) StoreTimezone(requestID string, timezone string) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	doc.Timezone = timezone
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) CompleteSubmission(requestID string, submissionID string, capturedAt *time.Time) (bool, error) {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return false, ErrDocumentNotFound
+	}
+	if doc.Status != StatusPending {
+		return false, nil
+	}
+	doc.Status = StatusCompleted
+	doc.SubmissionID = submissionID
+	if capturedAt != nil {
+		captured := capturedAt.UTC()
+		capturedAt = &captured
+	}
+	doc.CapturedAt = capturedAt
+	m.documents[requestID] = doc
+	return true, nil
+}
+
+func (m *InMemoryDocumentStore) StoreCopyLink(requestID string, tokenHash string, expiresAt time.Time) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	expiresAt = expiresAt.UTC()
+	doc.CopyToken = tokenHash
+	doc.CopyExpiresAt = &expiresAt
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) StoreEmailStatus(requestID string, status string, at time.Time, deliveryError string) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	at = at.UTC()
+	doc.EmailStatus = status
+	doc.EmailStatusAt = &at
+	doc.EmailError = deliveryError
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) GetDocumentByCopyToken(tokenHash string) (Document, error) {
+	for _, doc := range m.documents {
+		if tokenHash != "" && doc.CopyToken == tokenHash {
+			return doc, nil
+		}
+	}
+	return Document{}, ErrDocumentNotFound
+}
+
+func (m *InMemoryDocumentStore) StoreRemoteLink(requestID string, tokenHash string, expiresAt time.Time) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	expiresAt = expiresAt.UTC()
+	doc.RemoteToken = tokenHash
+	doc.RemoteExpiresAt = &expiresAt
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) GetDocumentByRemoteToken(tokenHash string) (Document, error) {
+	for _, doc := range m.documents {
+		if tokenHash != "" && doc.RemoteToken == tokenHash {
+			return doc, nil
+		}
+	}
+	return Document{}, ErrDocumentNotFound
+}
+
+func (m *InMemoryDocumentStore) StoreRemoteSession(requestID string, sessionHash string, expiresAt time.Time) error {
+	doc, exists := m.documents[requestID]
 	if !exists {
-		return fmt.Errorf("document not found")
+		return ErrDocumentNotFound
 	}
-	// In memory implementation doesn't actually store consents
+	expiresAt = expiresAt.UTC()
+	doc.RemoteToken = ""
+	doc.RemoteSession = sessionHash
+	doc.RemoteExpiresAt = &expiresAt
+	doc.OTPHash = ""
+	doc.OTPExpiresAt = nil
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) StoreOTP(requestID string, codeHash string, sentAt time.Time, expiresAt time.Time) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	sentAt, expiresAt = sentAt.UTC(), expiresAt.UTC()
+	doc.OTPHash = codeHash
+	doc.OTPSentAt = &sentAt
+	doc.OTPExpiresAt = &expiresAt
+	doc.OTPAttempts = 0
+	doc.OTPSends++
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) RecordOTPAttempt(requestID string) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	doc.OTPAttempts++
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) RecordVerificationAttempt(requestID string) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	doc.VerificationAttempts++
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) StoreVerificationOutcome(requestID string, outcome string, at time.Time) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	at = at.UTC()
+	doc.VerificationOutcome = outcome
+	doc.VerificationAt = &at
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) StoreCompletion(requestID string, completedAt time.Time, integrityHash string, seal *Seal) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	completedAt = completedAt.UTC()
+	doc.CompletedAt = &completedAt
+	doc.IntegrityHash = integrityHash
+	doc.Seal = seal
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) StoreTimestamp(requestID string, timestamp Timestamp) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	doc.Timestamp = &timestamp
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) ListDocumentsBySigner(signerEmail string) ([]Document, error) {
+	var result []Document
+	for _, doc := range m.documents {
+		if NormalizeSubjectID(doc.SignerEmail) == NormalizeSubjectID(signerEmail) {
+			result = append(result, doc)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+func (m *InMemoryDocumentStore) EraseDocument(requestID string, pseudonym string) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	doc.SignerName = pseudonym
+	doc.SignerEmail = pseudonym
+	doc.DocumentContent = []DocumentSection{}
+	doc.Strokes = nil
+	doc.Consents = nil
+	doc.CopyToken = ""
+	doc.CopyExpiresAt = nil
+	doc.EmailError = ""
+	doc.RemoteToken = ""
+	doc.RemoteSession = ""
+	doc.OTPHash = ""
+	doc.VerificationSecret = ""
+	doc.Status = StatusErased
+	delete(m.pdfs, requestID)
+	delete(m.signatures, requestID)
+	m.deleteAttachments(&doc)
+	m.documents[requestID] = doc
+	return nil
+}
+
+func (m *InMemoryDocumentStore) ListRetentionCandidates(rule RetentionRule, createdBefore time.Time) ([]Document, error) {
+	var result []Document
+	for _, doc := range m.documents {
+		if !doc.CreatedAt.Before(createdBefore) ||
+			(rule.Status != "" && doc.Status != rule.Status) ||
+			(rule.Status == "" && doc.Status == StatusPending) ||
+			(rule.TemplateID != "" && doc.TemplateID != rule.TemplateID) ||
+			(rule.ClientID != "" && doc.ClientID != rule.ClientID) {
+			continue
+		}
+		if rule.Action == RetentionActionPurgeSignature && m.signatures[doc.ID] == "" && doc.Strokes == nil {
+			continue
+		}
+		if rule.Action == RetentionActionPurgeContent && len(doc.DocumentContent) == 0 && m.pdfs[doc.ID] == nil && doc.Attachments == nil && m.signatures[doc.ID] == "" && doc.Strokes == nil && doc.Consents == nil {
+			continue
+		}
+		result = append(result, doc)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (m *InMemoryDocumentStore) PurgeDocument(requestID string, action string) error {
+	doc, exists := m.documents[requestID]
+	if !exists {
+		return ErrDocumentNotFound
+	}
+	switch action {
+	case RetentionActionPurgeSignature:
+		delete(m.signatures, requestID)
+		doc.Strokes = nil
+	case RetentionActionPurgeContent:
+		doc.DocumentContent = []DocumentSection{}
+		doc.Strokes = nil
+		doc.Consents = nil
+		delete(m.pdfs, requestID)
+		delete(m.signatures, requestID)
+		m.deleteAttachments(&doc)
+	case RetentionActionDelete:
+		m.deleteAttachments(&doc)
+		delete(m.documents, requestID)
+		delete(m.pdfs, requestID)
+		delete(m.signatures, requestID)
+		return nil
+	default:
+		return fmt.Errorf("unsupported retention action: %s", action)
+	}
+	m.documents[requestID] = doc
 	return nil
 }
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// One-time codes verify that a signer reads the email address a document is addressed to. A code
+// is valid for OTPTTL and OTPMaxAttempts attempts at entering it, and a new one can be sent
+// OTPResendInterval after the last, up to OTPMaxSends codes for a document, which bounds the
+// guesses at any of them.
+const (
+	OTPDigits         = 6
+	OTPTTL            = 10 * time.Minute
+	OTPMaxAttempts    = 5
+	OTPResendInterval = time.Minute
+	OTPMaxSends       = 5
+)
+
+// Reasons a one-time code is not accepted
+var (
+	ErrOTPNotSent   = errors.New("no code was sent")
+	ErrOTPExpired   = errors.New("code has expired")
+	ErrOTPAttempts  = errors.New("too many attempts")
+	ErrOTPIncorrect = errors.New("code is incorrect")
+)
+
+// Reasons a new one-time code is not sent
+var (
+	ErrOTPTooSoon   = errors.New("a code was sent too recently")
+	ErrOTPSendLimit = errors.New("too many codes were sent")
+)
+
+// NewOTP returns a random code of OTPDigits digits
+func NewOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Exp(big.NewInt(10), big.NewInt(OTPDigits), nil))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", OTPDigits, n.Int64()), nil
+}
+
+// HashOTP returns the hash the code sent for a document is stored as
+func HashOTP(requestID, code string) string {
+	digest := sha256.Sum256([]byte(requestID + ":" + code))
+	return hex.EncodeToString(digest[:])
+}
+
+// CheckOTP checks a code entered at now against the last code sent for the document. Attempts
+// are counted before checking, so OTPAttempts includes this one.
+func (d Document) CheckOTP(code string, now time.Time) error {
+	if d.OTPHash == "" || d.OTPExpiresAt == nil {
+		return ErrOTPNotSent
+	}
+	if d.OTPAttempts > OTPMaxAttempts {
+		return ErrOTPAttempts
+	}
+	if !now.Before(*d.OTPExpiresAt) {
+		return ErrOTPExpired
+	}
+	code = strings.Join(strings.Fields(code), "")
+	if subtle.ConstantTimeCompare([]byte(HashOTP(d.ID, code)), []byte(d.OTPHash)) != 1 {
+		return ErrOTPIncorrect
+	}
+	return nil
+}
+
+// CheckOTPSend checks whether a new code can be sent for the document at now
+func (d Document) CheckOTPSend(now time.Time) error {
+	if d.OTPSends >= OTPMaxSends {
+		return ErrOTPSendLimit
+	}
+	if d.OTPSentAt != nil && now.Before(d.OTPSentAt.Add(OTPResendInterval)) {
+		return ErrOTPTooSoon
+	}
+	return nil
+}
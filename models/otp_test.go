@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOTP(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		code, err := NewOTP()
+		assert.NoError(t, err)
+		assert.Regexp(t, `^[0-9]{6}$`, code)
+	}
+}
+
+func TestDocument_CheckOTP(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(OTPTTL)
+	sent := Document{ID: "doc-1", OTPHash: HashOTP("doc-1", "042137"), OTPExpiresAt: &expiresAt, OTPAttempts: 1}
+
+	tests := []struct {
+		name     string
+		doc      Document
+		code     string
+		at       time.Time
+		expected error
+	}{
+		{name: "Correct code", doc: sent, code: "042137", at: now},
+		{name: "Code with spaces", doc: sent, code: " 042 137 ", at: now},
+		{name: "Incorrect code", doc: sent, code: "042138", at: now, expected: ErrOTPIncorrect},
+		{name: "Code of another document", doc: Document{ID: "doc-2", OTPHash: sent.OTPHash, OTPExpiresAt: &expiresAt}, code: "042137", at: now, expected: ErrOTPIncorrect},
+		{name: "Expired code", doc: sent, code: "042137", at: expiresAt, expected: ErrOTPExpired},
+		{name: "Last attempt", doc: Document{ID: "doc-1", OTPHash: sent.OTPHash, OTPExpiresAt: &expiresAt, OTPAttempts: OTPMaxAttempts}, code: "042137", at: now},
+		{name: "Too many attempts", doc: Document{ID: "doc-1", OTPHash: sent.OTPHash, OTPExpiresAt: &expiresAt, OTPAttempts: OTPMaxAttempts + 1}, code: "042137", at: now, expected: ErrOTPAttempts},
+		{name: "No code sent", doc: Document{ID: "doc-1"}, code: "042137", at: now, expected: ErrOTPNotSent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.doc.CheckOTP(tt.code, tt.at))
+		})
+	}
+}
+
+func TestDocument_CheckOTPSend(t *testing.T) {
+	now := time.Now()
+	doc := Document{OTPSentAt: &now, OTPSends: 1}
+
+	assert.NoError(t, Document{}.CheckOTPSend(now))
+	assert.Equal(t, ErrOTPTooSoon, doc.CheckOTPSend(now.Add(OTPResendInterval-time.Second)))
+	assert.NoError(t, doc.CheckOTPSend(now.Add(OTPResendInterval)))
+
+	doc.OTPSends = OTPMaxSends
+	assert.Equal(t, ErrOTPSendLimit, doc.CheckOTPSend(now.Add(OTPResendInterval)))
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"crypto/subtle"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// remoteTokenLength is the length of remote signing link and session tokens, about 190 random
+// bits. They are never typed, so unlike copy links they need not be short.
+const remoteTokenLength = 32
+
+// RemoteSessionTTL is how long a signer who verified their email address with a code has to sign
+// the document in their browser
+const RemoteSessionTTL = time.Hour
+
+// NewRemoteToken returns a random token for the link a signer opens to sign a document remotely,
+// or for the session they sign it in once verified
+func NewRemoteToken() (string, error) {
+	return randomToken(remoteTokenLength)
+}
+
+// RemoteURL returns the URL a signer opens to sign a document remotely. With an empty baseURL the
+// URL is relative to this service.
+func RemoteURL(baseURL, token string) string {
+	return strings.TrimRight(baseURL, "/") + "/r/" + url.PathEscape(token)
+}
+
+// HashRemoteSession returns the hash a remote signing link or session token is stored as, so that
+// the database holds nothing a signer's browser could be impersonated with
+func HashRemoteSession(token string) string {
+	return hashToken(token)
+}
+
+// RemoteLinkExpired reports whether the remote signing link of a document can no longer be opened
+// at now, either because it expired or because it was already used
+func (d Document) RemoteLinkExpired(now time.Time) bool {
+	return d.RemoteToken == "" || d.RemoteExpiresAt == nil || !now.Before(*d.RemoteExpiresAt)
+}
+
+// HasRemoteSession reports whether a session token lets a signer's browser into the signing
+// pages of a document at now
+func (d Document) HasRemoteSession(token string, now time.Time) bool {
+	if d.RemoteSession == "" || d.RemoteExpiresAt == nil || !now.Before(*d.RemoteExpiresAt) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashRemoteSession(token)), []byte(d.RemoteSession)) == 1
+}
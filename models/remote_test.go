@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRemoteToken(t *testing.T) {
+	token, err := NewRemoteToken()
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[0-9A-Za-z]{32}$`, token)
+}
+
+func TestRemoteURL(t *testing.T) {
+	assert.Equal(t, "https://sign.example.com/r/Ab3xK9pQ2mZt", RemoteURL("https://sign.example.com/", "Ab3xK9pQ2mZt"))
+	assert.Equal(t, "/r/Ab3xK9pQ2mZt", RemoteURL("", "Ab3xK9pQ2mZt"))
+}
+
+func TestDocument_RemoteLinkExpired(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Minute)
+	doc := Document{RemoteToken: "Ab3xK9pQ2mZt", RemoteExpiresAt: &expiresAt}
+
+	assert.False(t, doc.RemoteLinkExpired(now))
+	assert.True(t, doc.RemoteLinkExpired(expiresAt))
+	assert.True(t, Document{RemoteExpiresAt: &expiresAt}.RemoteLinkExpired(now))
+}
+
+func TestDocument_HasRemoteSession(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(RemoteSessionTTL)
+	doc := Document{RemoteSession: HashRemoteSession("session-token"), RemoteExpiresAt: &expiresAt}
+
+	assert.True(t, doc.HasRemoteSession("session-token", now))
+	assert.False(t, doc.HasRemoteSession("other-token", now))
+	assert.False(t, doc.HasRemoteSession("session-token", expiresAt))
+	assert.False(t, Document{RemoteExpiresAt: &expiresAt}.HasRemoteSession("", now))
+}
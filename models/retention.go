@@ -0,0 +1,204 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Retention actions, from least to most destructive
+const (
+	// RetentionActionPurgeSignature removes the signature image
+	RetentionActionPurgeSignature = "purge_signature"
+	// RetentionActionPurgeContent removes the document content, signature image and consents
+	RetentionActionPurgeContent = "purge_content"
+	// RetentionActionDelete hard-deletes the document row
+	RetentionActionDelete = "delete"
+)
+
+// AuditActionRetentionPurge is recorded for every document a retention rule is applied to
+const AuditActionRetentionPurge = "retention_purge"
+
+// RetentionRule purges documents matching the filters once they are older than AfterDays.
+// Empty filters match any value, except that an empty status never matches pending documents:
+// requests still awaiting a signature are only purged by rules that name the pending status.
+type RetentionRule struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	TemplateID string `json:"template_id"`
+	ClientID   string `json:"client_id"`
+	AfterDays  int    `json:"after_days"`
+	Action     string `json:"action"`
+}
+
+// Validate checks that the rule can be applied
+func (r RetentionRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("retention rule is missing a name")
+	}
+	if r.AfterDays < 1 {
+		return fmt.Errorf("retention rule %s: after_days must be at least 1", r.Name)
+	}
+	switch r.Action {
+	case RetentionActionPurgeSignature, RetentionActionPurgeContent, RetentionActionDelete:
+		return nil
+	default:
+		return fmt.Errorf("retention rule %s: unsupported action %q", r.Name, r.Action)
+	}
+}
+
+// RetentionPolicy is the set of retention rules loaded from configuration
+type RetentionPolicy struct {
+	Rules []RetentionRule `json:"rules"`
+}
+
+// LoadRetentionPolicy reads and validates a JSON retention policy file
+func LoadRetentionPolicy(path string) (RetentionPolicy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return RetentionPolicy{}, fmt.Errorf("error reading retention policy: %v", err)
+	}
+
+	var policy RetentionPolicy
+	if err := json.Unmarshal(content, &policy); err != nil {
+		return RetentionPolicy{}, fmt.Errorf("error parsing retention policy: %v", err)
+	}
+	for _, rule := range policy.Rules {
+		if err := rule.Validate(); err != nil {
+			return RetentionPolicy{}, err
+		}
+	}
+
+	return policy, nil
+}
+
+// RetentionItem describes one document a rule applies (or, in a dry run, would apply) to
+type RetentionItem struct {
+	RequestID  string    `json:"request_id"`
+	Rule       string    `json:"rule"`
+	Action     string    `json:"action"`
+	Status     string    `json:"status"`
+	TemplateID string    `json:"template_id,omitempty"`
+	ClientID   string    `json:"client_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RetentionReport summarises a retention run
+type RetentionReport struct {
+	DryRun bool            `json:"dry_run"`
+	RunAt  time.Time       `json:"run_at"`
+	Items  []RetentionItem `json:"items"`
+}
+
+// RetentionJob applies a retention policy to the document store
+type RetentionJob struct {
+	store   DocumentStore
+	audit   AuditLog
+	policy  RetentionPolicy
+	timeNow func() time.Time
+}
+
+func NewRetentionJob(store DocumentStore, audit AuditLog, policy RetentionPolicy) *RetentionJob {
+	return &RetentionJob{
+		store:   store,
+		audit:   audit,
+		policy:  policy,
+		timeNow: time.Now,
+	}
+}
+
+// Run applies every rule in order. In a dry run nothing is purged and the report lists what
+// would have been. Each purge is recorded in the audit log.
+func (j *RetentionJob) Run(dryRun bool) (RetentionReport, error) {
+	now := j.timeNow().UTC()
+	report := RetentionReport{
+		DryRun: dryRun,
+		RunAt:  now,
+		Items:  []RetentionItem{},
+	}
+
+	for _, rule := range j.policy.Rules {
+		createdBefore := now.AddDate(0, 0, -rule.AfterDays)
+		documents, err := j.store.ListRetentionCandidates(rule, createdBefore)
+		if err != nil {
+			return report, fmt.Errorf("error listing candidates for rule %s: %v", rule.Name, err)
+		}
+
+		for _, doc := range documents {
+			item := RetentionItem{
+				RequestID:  doc.ID,
+				Rule:       rule.Name,
+				Action:     rule.Action,
+				Status:     doc.Status,
+				TemplateID: doc.TemplateID,
+				ClientID:   doc.ClientID,
+				CreatedAt:  doc.CreatedAt,
+			}
+			report.Items = append(report.Items, item)
+			if dryRun {
+				continue
+			}
+
+			if err := j.store.PurgeDocument(doc.ID, rule.Action); err != nil {
+				return report, fmt.Errorf("error purging document %s: %v", doc.ID, err)
+			}
+			err := j.audit.RecordAudit(AuditEntry{
+				RequestID: doc.ID,
+				Action:    AuditActionRetentionPurge,
+				Details: map[string]string{
+					"rule":            rule.Name,
+					"action":          rule.Action,
+					"previous_status": doc.Status,
+				},
+				CreatedAt: now,
+			})
+			if err != nil {
+				return report, fmt.Errorf("error recording audit entry for %s: %v", doc.ID, err)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// Start runs the job once right away and then every interval in the background, until the
+// returned stop function is called. Stopping waits for a run in progress to finish.
+func (j *RetentionJob) Start(interval time.Duration, dryRun bool) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+		j.runAndLog(dryRun)
+		for {
+			select {
+			case <-ticker.C:
+				j.runAndLog(dryRun)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// runAndLog runs the job and logs how many documents it purged
+func (j *RetentionJob) runAndLog(dryRun bool) {
+	report, err := j.Run(dryRun)
+	if err != nil {
+		log.Printf("Error running retention job: %v", err)
+	}
+	if dryRun {
+		log.Printf("Retention dry run: %d documents would be purged", len(report.Items))
+	} else {
+		log.Printf("Retention run: purged %d documents", len(report.Items))
+	}
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionJob_Run(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	policy := RetentionPolicy{Rules: []RetentionRule{
+		{Name: "vet-signatures", Status: StatusCompleted, ClientID: "vet", AfterDays: 30, Action: RetentionActionPurgeSignature},
+		{Name: "stale-pending", Status: StatusPending, AfterDays: 90, Action: RetentionActionDelete},
+	}}
+
+	store := NewInMemoryDocumentStore()
+	oldVet, _ := store.AddDocument(Document{Status: StatusCompleted, ClientID: "vet", SignatureData: "data:image/png;base64,AA==", CreatedAt: now.AddDate(0, 0, -31)})
+	recentVet, _ := store.AddDocument(Document{Status: StatusCompleted, ClientID: "vet", SignatureData: "data:image/png;base64,AA==", CreatedAt: now.AddDate(0, 0, -29)})
+	oldOther, _ := store.AddDocument(Document{Status: StatusCompleted, ClientID: "other", SignatureData: "data:image/png;base64,AA==", CreatedAt: now.AddDate(0, 0, -100)})
+	stalePending, _ := store.AddDocument(Document{Status: StatusPending, CreatedAt: now.AddDate(0, 0, -91)})
+
+	audit := NewInMemoryAuditLog()
+	job := NewRetentionJob(store, audit, policy)
+	job.timeNow = func() time.Time { return now }
+
+	// A dry run reports without purging
+	report, err := job.Run(true)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Len(t, report.Items, 2)
+	assert.Equal(t, oldVet, report.Items[0].RequestID)
+	assert.Equal(t, RetentionActionPurgeSignature, report.Items[0].Action)
+	assert.Equal(t, stalePending, report.Items[1].RequestID)
+	assert.Equal(t, RetentionActionDelete, report.Items[1].Action)
+
+	signatureData, _ := store.GetSignatureData(oldVet)
+	assert.NotEmpty(t, signatureData)
+	entries, _ := audit.ListAuditEntries(oldVet)
+	assert.Empty(t, entries)
+
+	// A real run purges and audits each document
+	report, err = job.Run(false)
+	assert.NoError(t, err)
+	assert.Len(t, report.Items, 2)
+
+	doc, _ := store.GetDocument(oldVet)
+	signatureData, _ = store.GetSignatureData(oldVet)
+	assert.Empty(t, signatureData)
+	assert.Equal(t, StatusCompleted, doc.Status)
+	entries, _ = audit.ListAuditEntries(oldVet)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, AuditActionRetentionPurge, entries[0].Action)
+	assert.Equal(t, "vet-signatures", entries[0].Details["rule"])
+
+	_, err = store.GetDocument(stalePending)
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	entries, _ = audit.ListAuditEntries(stalePending)
+	assert.Len(t, entries, 1)
+
+	for _, id := range []string{recentVet, oldOther} {
+		signatureData, err := store.GetSignatureData(id)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, signatureData)
+	}
+
+	// Already purged documents are not picked up again
+	report, err = job.Run(false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Items)
+}
+
+func TestRetentionJob_RuleWithoutStatusSkipsPending(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewInMemoryDocumentStore()
+	completed, _ := store.AddDocument(Document{Status: StatusCompleted, CreatedAt: now.AddDate(0, 0, -31)})
+	pending, _ := store.AddDocument(Document{Status: StatusPending, CreatedAt: now.AddDate(0, 0, -31)})
+
+	job := NewRetentionJob(store, NewInMemoryAuditLog(), RetentionPolicy{Rules: []RetentionRule{
+		{Name: "everything", AfterDays: 30, Action: RetentionActionDelete},
+	}})
+	job.timeNow = func() time.Time { return now }
+
+	report, err := job.Run(false)
+	assert.NoError(t, err)
+	assert.Len(t, report.Items, 1)
+	assert.Equal(t, completed, report.Items[0].RequestID)
+
+	_, err = store.GetDocument(completed)
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	_, err = store.GetDocument(pending)
+	assert.NoError(t, err)
+}
+
+func TestRetentionJob_Start(t *testing.T) {
+	store := NewInMemoryDocumentStore()
+	stalePending, _ := store.AddDocument(Document{Status: StatusPending, CreatedAt: time.Now().AddDate(0, 0, -91)})
+	job := NewRetentionJob(store, NewInMemoryAuditLog(), RetentionPolicy{Rules: []RetentionRule{
+		{Name: "stale-pending", Status: StatusPending, AfterDays: 90, Action: RetentionActionDelete},
+	}})
+
+	// The first run does not wait for the interval, and stopping waits for it
+	stop := job.Start(time.Hour, false)
+	stop()
+
+	_, err := store.GetDocument(stalePending)
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+}
+
+func TestLoadRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedError string
+		expectedRules int
+	}{
+		{
+			name:          "valid policy",
+			content:       `{"rules": [{"name": "signatures", "status": "completed", "after_days": 30, "action": "purge_signature"}]}`,
+			expectedRules: 1,
+		},
+		{
+			name:          "unsupported action",
+			content:       `{"rules": [{"name": "signatures", "after_days": 30, "action": "shred"}]}`,
+			expectedError: "unsupported action",
+		},
+		{
+			name:          "missing after_days",
+			content:       `{"rules": [{"name": "signatures", "action": "delete"}]}`,
+			expectedError: "after_days must be at least 1",
+		},
+		{
+			name:          "invalid JSON",
+			content:       `{"rules": [`,
+			expectedError: "error parsing retention policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "retention.json")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			policy, err := LoadRetentionPolicy(path)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, policy.Rules, tt.expectedRules)
+		})
+	}
+}
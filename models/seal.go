@@ -0,0 +1,310 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SealAlgorithm is the signature algorithm used for seals
+const SealAlgorithm = "Ed25519"
+
+// sealContext prefixes the sealed message, so a seal cannot be mistaken for an Ed25519
+// signature made with the same key for another purpose
+const sealContext = "signature-collector seal v1\n"
+
+// ErrInvalidSeal is returned when a seal does not verify against the published keys
+var ErrInvalidSeal = errors.New("invalid seal")
+
+// Seal is the service's signature over a document's integrity hash. As the hash covers the
+// canonical completion record, the seal binds the service to everything in the record.
+type Seal struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	// Signature is the base64 Ed25519 signature of "signature-collector seal v1\n" followed
+	// by the integrity hash
+	Signature string `json:"signature"`
+}
+
+// SealKey is a published public key that seals can be verified with
+type SealKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	// PublicKey is the PEM encoded public key
+	PublicKey string `json:"public_key"`
+	// Certificate is the PEM encoded X.509 certificate issued for the key, if any
+	Certificate string `json:"certificate,omitempty"`
+	// Active is set on the key new documents are sealed with
+	Active bool `json:"active"`
+}
+
+// Sealer seals completed documents with the service's Ed25519 key. Retired keys are kept so
+// their public halves can still be published.
+type Sealer struct {
+	keyID      string
+	privateKey ed25519.PrivateKey
+	keys       []SealKey
+}
+
+// NewSealer creates a sealer signing with privateKey and additionally publishing the retired keys
+func NewSealer(privateKey ed25519.PrivateKey, retired ...ed25519.PublicKey) (*Sealer, error) {
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	active, err := newSealKey(publicKey, true)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sealer{keyID: active.KeyID, privateKey: privateKey, keys: []SealKey{active}}
+	for _, key := range retired {
+		if key.Equal(publicKey) {
+			continue
+		}
+		sealKey, err := newSealKey(key, false)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, sealKey)
+	}
+	return s, nil
+}
+
+// LoadSealer reads the sealing keys from a PEM file. It returns nil when no file is configured.
+//
+// The first PKCS#8 "PRIVATE KEY" block is the active key. Further private keys and "PUBLIC KEY"
+// blocks are retired keys that are still published. "CERTIFICATE" blocks are published with the
+// key they were issued for.
+func LoadSealer(keyFile string) (*Sealer, error) {
+	if keyFile == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading seal key file: %v", err)
+	}
+
+	var privateKey ed25519.PrivateKey
+	var retired []ed25519.PublicKey
+	var certificates []*x509.Certificate
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing seal private key: %v", err)
+			}
+			edKey, ok := key.(ed25519.PrivateKey)
+			if !ok {
+				return nil, fmt.Errorf("seal private key must be an Ed25519 key")
+			}
+			if privateKey == nil {
+				privateKey = edKey
+			} else {
+				retired = append(retired, edKey.Public().(ed25519.PublicKey))
+			}
+		case "PUBLIC KEY":
+			key, err := parseSealPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			retired = append(retired, key)
+		case "CERTIFICATE":
+			certificate, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing seal certificate: %v", err)
+			}
+			certificates = append(certificates, certificate)
+		}
+	}
+	if privateKey == nil {
+		return nil, fmt.Errorf("seal key file %s contains no private key", keyFile)
+	}
+
+	sealer, err := NewSealer(privateKey, retired...)
+	if err != nil {
+		return nil, err
+	}
+	for _, certificate := range certificates {
+		key, ok := certificate.PublicKey.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("seal certificate %q is not for an Ed25519 key", certificate.Subject)
+		}
+		found := false
+		for i := range sealer.keys {
+			if sealer.keys[i].KeyID == SealKeyID(key) {
+				sealer.keys[i].Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}))
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("seal certificate %q does not match any key", certificate.Subject)
+		}
+	}
+	return sealer, nil
+}
+
+// KeyID returns the ID of the key new documents are sealed with
+func (s *Sealer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKeys returns the active and retired public keys
+func (s *Sealer) PublicKeys() []SealKey {
+	return append([]SealKey(nil), s.keys...)
+}
+
+// Seal signs an integrity hash with the active key
+func (s *Sealer) Seal(integrityHash string) Seal {
+	signature := ed25519.Sign(s.privateKey, []byte(sealContext+integrityHash))
+	return Seal{
+		Algorithm: SealAlgorithm,
+		KeyID:     s.keyID,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}
+}
+
+// VerifySeal checks that a seal is a valid signature of the integrity hash by one of the keys
+func VerifySeal(seal Seal, integrityHash string, keys []SealKey) error {
+	if seal.Algorithm != SealAlgorithm {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSeal, seal.Algorithm)
+	}
+	signature, err := base64.StdEncoding.DecodeString(seal.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: signature is not valid base64", ErrInvalidSeal)
+	}
+
+	for _, key := range keys {
+		if key.KeyID != seal.KeyID {
+			continue
+		}
+		publicKey, err := key.ed25519Key()
+		if err != nil {
+			return err
+		}
+		if SealKeyID(publicKey) != seal.KeyID {
+			return fmt.Errorf("%w: published key does not match key ID %s", ErrInvalidSeal, seal.KeyID)
+		}
+		if !ed25519.Verify(publicKey, []byte(sealContext+integrityHash), signature) {
+			return fmt.Errorf("%w: signature does not match the integrity hash", ErrInvalidSeal)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown key %s", ErrInvalidSeal, seal.KeyID)
+}
+
+// ParseSealKeys reads public keys from the JSON served by the keys endpoint, or from PEM
+// "PUBLIC KEY" and "CERTIFICATE" blocks
+func ParseSealKeys(data []byte) ([]SealKey, error) {
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var response struct {
+			Keys []SealKey `json:"keys"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, fmt.Errorf("error parsing seal keys: %v", err)
+		}
+		return response.Keys, nil
+	}
+
+	var keys []SealKey
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		var key SealKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			var publicKey ed25519.PublicKey
+			if publicKey, err = parseSealPublicKey(block.Bytes); err == nil {
+				key, err = newSealKey(publicKey, false)
+			}
+		case "CERTIFICATE":
+			key, err = sealKeyFromCertificate(pem.EncodeToMemory(block))
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no seal keys found")
+	}
+	return keys, nil
+}
+
+// SealKeyID derives a key ID from the first 8 bytes of the SHA-256 digest of the public key
+func SealKeyID(publicKey ed25519.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+func newSealKey(publicKey ed25519.PublicKey, active bool) (SealKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return SealKey{}, fmt.Errorf("error encoding seal public key: %v", err)
+	}
+	return SealKey{
+		KeyID:     SealKeyID(publicKey),
+		Algorithm: SealAlgorithm,
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		Active:    active,
+	}, nil
+}
+
+func sealKeyFromCertificate(certificatePEM []byte) (SealKey, error) {
+	block, _ := pem.Decode(certificatePEM)
+	if block == nil {
+		return SealKey{}, fmt.Errorf("seal certificate is not PEM encoded")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return SealKey{}, fmt.Errorf("error parsing seal certificate: %v", err)
+	}
+	publicKey, ok := certificate.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return SealKey{}, fmt.Errorf("seal certificate %q is not for an Ed25519 key", certificate.Subject)
+	}
+	key, err := newSealKey(publicKey, false)
+	if err != nil {
+		return SealKey{}, err
+	}
+	key.Certificate = string(certificatePEM)
+	return key, nil
+}
+
+// ed25519Key returns the key's public key, taken from its certificate when only that is given
+func (k SealKey) ed25519Key() (ed25519.PublicKey, error) {
+	if k.PublicKey == "" && k.Certificate != "" {
+		key, err := sealKeyFromCertificate([]byte(k.Certificate))
+		if err != nil {
+			return nil, err
+		}
+		k = key
+	}
+	block, _ := pem.Decode([]byte(k.PublicKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("seal key %s has no PEM public key", k.KeyID)
+	}
+	return parseSealPublicKey(block.Bytes)
+}
+
+func parseSealPublicKey(der []byte) (ed25519.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing seal public key: %v", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("seal public key must be an Ed25519 key")
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSealKey(t *testing.T) ed25519.PrivateKey {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	return privateKey
+}
+
+func privateKeyPEM(t *testing.T, key ed25519.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicKeyPEM(t *testing.T, key ed25519.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func certificatePEM(t *testing.T, key ed25519.PrivateKey) []byte {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Signature Collector seal"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestLoadSealer(t *testing.T) {
+	active, retired, other := testSealKey(t), testSealKey(t), testSealKey(t)
+	dir := t.TempDir()
+	write := func(name string, blocks ...[]byte) string {
+		path := filepath.Join(dir, name)
+		var content []byte
+		for _, block := range blocks {
+			content = append(content, block...)
+		}
+		assert.NoError(t, os.WriteFile(path, content, 0600))
+		return path
+	}
+
+	sealer, err := LoadSealer("")
+	assert.NoError(t, err)
+	assert.Nil(t, sealer)
+
+	sealer, err = LoadSealer(write("keys.pem",
+		privateKeyPEM(t, active),
+		publicKeyPEM(t, retired.Public().(ed25519.PublicKey)),
+		certificatePEM(t, active),
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, SealKeyID(active.Public().(ed25519.PublicKey)), sealer.KeyID())
+	keys := sealer.PublicKeys()
+	if assert.Len(t, keys, 2) {
+		assert.True(t, keys[0].Active)
+		assert.Contains(t, keys[0].Certificate, "BEGIN CERTIFICATE")
+		assert.False(t, keys[1].Active)
+		assert.Equal(t, SealKeyID(retired.Public().(ed25519.PublicKey)), keys[1].KeyID)
+	}
+
+	_, err = LoadSealer(write("public.pem", publicKeyPEM(t, active.Public().(ed25519.PublicKey))))
+	assert.Error(t, err)
+
+	_, err = LoadSealer(write("mismatch.pem", privateKeyPEM(t, active), certificatePEM(t, other)))
+	assert.Error(t, err)
+
+	_, err = LoadSealer(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestSealer_Seal(t *testing.T) {
+	key := testSealKey(t)
+	sealer, err := NewSealer(key)
+	assert.NoError(t, err)
+	otherSealer, err := NewSealer(testSealKey(t))
+	assert.NoError(t, err)
+
+	hash := "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	seal := sealer.Seal(hash)
+	assert.Equal(t, SealAlgorithm, seal.Algorithm)
+	assert.Equal(t, sealer.KeyID(), seal.KeyID)
+
+	tampered := seal
+	tampered.Signature = otherSealer.Seal(hash).Signature
+
+	// A key published with only its certificate
+	certificateOnly := SealKey{KeyID: sealer.KeyID(), Algorithm: SealAlgorithm, Certificate: string(certificatePEM(t, key))}
+
+	tests := []struct {
+		name      string
+		seal      Seal
+		hash      string
+		keys      []SealKey
+		wantError bool
+	}{
+		{name: "Valid", seal: seal, hash: hash, keys: sealer.PublicKeys()},
+		{name: "Valid with certificate", seal: seal, hash: hash, keys: []SealKey{certificateOnly}},
+		{name: "Other hash", seal: seal, hash: "sha256:00", keys: sealer.PublicKeys(), wantError: true},
+		{name: "Other signature", seal: tampered, hash: hash, keys: sealer.PublicKeys(), wantError: true},
+		{name: "Unknown key", seal: seal, hash: hash, keys: otherSealer.PublicKeys(), wantError: true},
+		{name: "No keys", seal: seal, hash: hash, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySeal(tt.seal, tt.hash, tt.keys)
+			if tt.wantError {
+				assert.ErrorIs(t, err, ErrInvalidSeal)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseSealKeys(t *testing.T) {
+	key := testSealKey(t)
+	sealer, err := NewSealer(key)
+	assert.NoError(t, err)
+
+	response, err := json.Marshal(map[string]any{"keys": sealer.PublicKeys()})
+	assert.NoError(t, err)
+	keys, err := ParseSealKeys(response)
+	assert.NoError(t, err)
+	assert.Equal(t, sealer.PublicKeys(), keys)
+
+	keys, err = ParseSealKeys(append(publicKeyPEM(t, key.Public().(ed25519.PublicKey)), certificatePEM(t, testSealKey(t))...))
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, sealer.KeyID(), keys[0].KeyID)
+		assert.NotEmpty(t, keys[1].Certificate)
+	}
+
+	_, err = ParseSealKeys([]byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	sealer, err := NewSealer(testSealKey(t))
+	assert.NoError(t, err)
+
+	doc := completedTestDocument()
+	doc.IntegrityHash, _ = IntegrityHash(doc)
+	seal := sealer.Seal(doc.IntegrityHash)
+	doc.Seal = &seal
+	certificate, err := NewCertificate(doc, time.Now())
+	assert.NoError(t, err)
+
+	verification, err := VerifyCertificate(certificate, VerifyOptions{SealKeys: sealer.PublicKeys()})
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Len(t, verification.Checks, 2)
+
+	// Without keys only the hash is checked
+	verification, _ = VerifyCertificate(certificate, VerifyOptions{})
+	assert.True(t, verification.Valid)
+	assert.Len(t, verification.Checks, 1)
+
+	// Re-hashing an altered record does not produce a valid seal
+	altered := certificate
+	altered.Record.SignerName = "Jane Smith"
+	altered.IntegrityHash, _ = altered.Record.Hash()
+	verification, _ = VerifyCertificate(altered, VerifyOptions{SealKeys: sealer.PublicKeys()})
+	assert.False(t, verification.Valid)
+
+	unsealed := certificate
+	unsealed.Seal = nil
+	verification, _ = VerifyCertificate(unsealed, VerifyOptions{SealKeys: sealer.PublicKeys()})
+	assert.False(t, verification.Valid)
+
+	// The stored record is checked against the stored seal
+	stored, err := VerifyDocument(doc, nil, VerifyOptions{SealKeys: sealer.PublicKeys()})
+	assert.NoError(t, err)
+	assert.True(t, stored.Valid)
+	assert.Equal(t, CheckSeal, stored.Checks[len(stored.Checks)-1].Name)
+}
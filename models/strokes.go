@@ -0,0 +1,161 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Stroke rendering defaults, matching SignaturePad's own defaults
+const (
+	defaultPenColor             = "black"
+	defaultMinWidth             = 0.5
+	defaultMaxWidth             = 2.5
+	defaultVelocityFilterWeight = 0.7
+)
+
+// Least amount of drawing a captured signature must contain
+const (
+	// MinSignaturePoints is the least number of sampled points across all strokes
+	MinSignaturePoints = 10
+	// MinSignaturePathLength is the least total distance, in canvas pixels, the pen must travel
+	MinSignaturePathLength = 50
+)
+
+// SignaturePoint is a single sampled pen position
+type SignaturePoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	// Time is when the point was sampled, in milliseconds since the Unix epoch
+	Time int64 `json:"time"`
+	// Pressure is the reported pen pressure between 0 and 1; devices without pressure
+	// support report 0.5
+	Pressure float64 `json:"pressure"`
+}
+
+// SignatureStroke is one continuous pen-down movement
+type SignatureStroke struct {
+	PenColor string           `json:"pen_color,omitempty"`
+	MinWidth float64          `json:"min_width,omitempty"`
+	MaxWidth float64          `json:"max_width,omitempty"`
+	Points   []SignaturePoint `json:"points"`
+}
+
+// SignatureStrokes is the vector form of a signature as captured on the tablet. Coordinates
+// are in canvas pixels, with the origin at the top left of a Width x Height canvas.
+type SignatureStrokes struct {
+	Width   float64           `json:"width"`
+	Height  float64           `json:"height"`
+	Strokes []SignatureStroke `json:"strokes"`
+}
+
+// Validate checks that the stroke data describes a drawable signature
+func (s SignatureStrokes) Validate() error {
+	if s.Width <= 0 || s.Height <= 0 {
+		return fmt.Errorf("canvas width and height must be positive")
+	}
+	if len(s.Strokes) == 0 {
+		return fmt.Errorf("at least one stroke is required")
+	}
+	for i, stroke := range s.Strokes {
+		if len(stroke.Points) == 0 {
+			return fmt.Errorf("stroke %d has no points", i)
+		}
+		for _, point := range stroke.Points {
+			if math.IsNaN(point.X) || math.IsNaN(point.Y) || math.IsInf(point.X, 0) || math.IsInf(point.Y, 0) {
+				return fmt.Errorf("stroke %d has an invalid point", i)
+			}
+		}
+	}
+	return nil
+}
+
+// PointCount returns the total number of points across all strokes
+func (s SignatureStrokes) PointCount() int {
+	count := 0
+	for _, stroke := range s.Strokes {
+		count += len(stroke.Points)
+	}
+	return count
+}
+
+// PathLength returns the total distance the pen travelled across all strokes
+func (s SignatureStrokes) PathLength() float64 {
+	length := 0.0
+	for _, stroke := range s.Strokes {
+		for i := 1; i < len(stroke.Points); i++ {
+			from, to := stroke.Points[i-1], stroke.Points[i]
+			length += math.Hypot(to.X-from.X, to.Y-from.Y)
+		}
+	}
+	return length
+}
+
+// IsTrivial reports whether the strokes are too few or too short to be a signature, such as
+// a single tap or an accidental line
+func (s SignatureStrokes) IsTrivial() bool {
+	return s.PointCount() < MinSignaturePoints || s.PathLength() < MinSignaturePathLength
+}
+
+// SVG renders the strokes as an SVG document of the given size in pixels. A zero width or
+// height is derived from the other keeping the canvas aspect ratio; both zero render at the
+// canvas size. Line widths follow pen velocity the way SignaturePad draws them.
+func (s SignatureStrokes) SVG(width, height int) string {
+	w, h := float64(width), float64(height)
+	switch {
+	case w <= 0 && h <= 0:
+		w, h = s.Width, s.Height
+	case w <= 0:
+		w = h * s.Width / s.Height
+	case h <= 0:
+		h = w * s.Height / s.Width
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`,
+		formatSVGNumber(w), formatSVGNumber(h), formatSVGNumber(s.Width), formatSVGNumber(s.Height))
+	b.WriteString(`<g fill="none" stroke-linecap="round" stroke-linejoin="round">`)
+
+	for _, stroke := range s.Strokes {
+		color := stroke.PenColor
+		if color == "" || strings.ContainsAny(color, `"<>&`) {
+			color = defaultPenColor
+		}
+		minWidth, maxWidth := stroke.MinWidth, stroke.MaxWidth
+		if minWidth <= 0 {
+			minWidth = defaultMinWidth
+		}
+		if maxWidth <= 0 {
+			maxWidth = defaultMaxWidth
+		}
+
+		if len(stroke.Points) == 1 {
+			point := stroke.Points[0]
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`,
+				formatSVGNumber(point.X), formatSVGNumber(point.Y), formatSVGNumber((minWidth+maxWidth)/2), color)
+			continue
+		}
+
+		velocity := 0.0
+		for i := 1; i < len(stroke.Points); i++ {
+			from, to := stroke.Points[i-1], stroke.Points[i]
+			distance := math.Hypot(to.X-from.X, to.Y-from.Y)
+			if elapsed := to.Time - from.Time; elapsed > 0 {
+				velocity = defaultVelocityFilterWeight*(distance/float64(elapsed)) + (1-defaultVelocityFilterWeight)*velocity
+			}
+			// SignaturePad uses the computed width as a radius, so the line is twice as thick
+			lineWidth := 2 * math.Max(maxWidth/(velocity+1), minWidth)
+			fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"/>`,
+				formatSVGNumber(from.X), formatSVGNumber(from.Y), formatSVGNumber(to.X), formatSVGNumber(to.Y),
+				color, formatSVGNumber(lineWidth))
+		}
+	}
+
+	b.WriteString(`</g></svg>`)
+	return b.String()
+}
+
+func formatSVGNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignatureStrokes_Validate(t *testing.T) {
+	point := SignaturePoint{X: 1, Y: 2, Time: 1718000000000, Pressure: 0.5}
+
+	tests := []struct {
+		name      string
+		strokes   SignatureStrokes
+		wantError bool
+	}{
+		{name: "Valid", strokes: SignatureStrokes{Width: 100, Height: 50, Strokes: []SignatureStroke{{Points: []SignaturePoint{point}}}}},
+		{name: "Missing canvas size", strokes: SignatureStrokes{Strokes: []SignatureStroke{{Points: []SignaturePoint{point}}}}, wantError: true},
+		{name: "No strokes", strokes: SignatureStrokes{Width: 100, Height: 50}, wantError: true},
+		{name: "Empty stroke", strokes: SignatureStrokes{Width: 100, Height: 50, Strokes: []SignatureStroke{{}}}, wantError: true},
+		{name: "Invalid point", strokes: SignatureStrokes{Width: 100, Height: 50, Strokes: []SignatureStroke{{Points: []SignaturePoint{{X: math.Inf(1)}}}}}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.strokes.Validate()
+			if tt.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSignatureStrokes_IsTrivial(t *testing.T) {
+	line := func(points int, step float64) SignatureStroke {
+		stroke := SignatureStroke{}
+		for i := 0; i < points; i++ {
+			stroke.Points = append(stroke.Points, SignaturePoint{X: float64(i) * step, Y: 10, Time: int64(i) * 10})
+		}
+		return stroke
+	}
+
+	tests := []struct {
+		name    string
+		strokes []SignatureStroke
+		want    bool
+	}{
+		{name: "Signature", strokes: []SignatureStroke{line(12, 5), line(8, 4)}, want: false},
+		{name: "Single tap", strokes: []SignatureStroke{line(1, 0)}, want: true},
+		{name: "Too few points", strokes: []SignatureStroke{line(5, 20)}, want: true},
+		{name: "Too short", strokes: []SignatureStroke{line(20, 1)}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strokes := SignatureStrokes{Width: 400, Height: 200, Strokes: tt.strokes}
+			assert.Equal(t, tt.want, strokes.IsTrivial())
+		})
+	}
+}
+
+func TestSignatureStrokes_SVG(t *testing.T) {
+	strokes := SignatureStrokes{
+		Width:  400,
+		Height: 200,
+		Strokes: []SignatureStroke{
+			{PenColor: "rgb(0, 0, 128)", Points: []SignaturePoint{
+				{X: 10, Y: 10, Time: 0},
+				{X: 20, Y: 10, Time: 100},
+				{X: 120, Y: 10, Time: 110},
+			}},
+			{Points: []SignaturePoint{{X: 50, Y: 50, Time: 200}}},
+			{PenColor: `"><script>`, Points: []SignaturePoint{{X: 60, Y: 60}}},
+		},
+	}
+
+	svg := strokes.SVG(0, 0)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="400" height="200" viewBox="0 0 400 200">`))
+	assert.Equal(t, 2, strings.Count(svg, "<line "))
+	assert.Equal(t, 2, strings.Count(svg, "<circle "))
+	assert.Contains(t, svg, `stroke="rgb(0, 0, 128)"`)
+	assert.NotContains(t, svg, "<script>")
+
+	// Slow movement draws a thicker line than fast movement
+	assert.Contains(t, svg, `x1="10" y1="10" x2="20" y2="10" stroke="rgb(0, 0, 128)" stroke-width="4.67"`)
+	assert.Contains(t, svg, `x1="20" y1="10" x2="120" y2="10" stroke="rgb(0, 0, 128)" stroke-width="1"`)
+
+	assert.Contains(t, strokes.SVG(0, 100), `width="200" height="100"`)
+	assert.Contains(t, strokes.SVG(800, 0), `width="800" height="400"`)
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Erasure modes
+const (
+	// ErasureModeErase blanks the signer's name and email on every document
+	ErasureModeErase = "erase"
+	// ErasureModePseudonymise replaces the signer's name and email with a stable pseudonym
+	ErasureModePseudonymise = "pseudonymise"
+)
+
+// pseudonymPrefix starts every pseudonym, telling erased subject references apart from subject IDs
+const pseudonymPrefix = "subject-"
+
+// Pseudonymizer derives stable, non-reversible references for data subjects
+type Pseudonymizer struct {
+	key []byte
+}
+
+func NewPseudonymizer(key []byte) *Pseudonymizer {
+	return &Pseudonymizer{key: key}
+}
+
+// DerivePseudonymKey derives a pseudonym key from another secret with HKDF-SHA256 and a fixed
+// label, so pseudonyms never reveal anything about the secret they were derived from
+func DerivePseudonymKey(secret []byte) []byte {
+	return hkdfSHA256(secret, nil, []byte("signature-collector pseudonym key"))
+}
+
+// hkdfSHA256 returns the first 32 bytes of HKDF-SHA256 (RFC 5869) output
+func hkdfSHA256(secret []byte, salt []byte, info []byte) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+// Pseudonym returns the reference used in place of a subject's email once their data is erased
+func (p *Pseudonymizer) Pseudonym(subjectID string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(NormalizeSubjectID(subjectID)))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))[:24]
+}
+
+// SubjectExport is everything held about a data subject
+type SubjectExport struct {
+	SubjectID      string         `json:"subject_id"`
+	GeneratedAt    time.Time      `json:"generated_at"`
+	Documents      []Document     `json:"documents"`
+	ConsentState   []ConsentState `json:"consent_state"`
+	ConsentHistory []ConsentEvent `json:"consent_history"`
+}
+
+// IsEmpty reports whether nothing is held about the subject
+func (e SubjectExport) IsEmpty() bool {
+	return len(e.Documents) == 0 && len(e.ConsentHistory) == 0
+}
+
+// SubjectErasure summarises the result of erasing a data subject
+type SubjectErasure struct {
+	SubjectRef          string    `json:"subject_ref"`
+	Mode                string    `json:"mode"`
+	ErasedRequestIDs    []string  `json:"erased_request_ids"`
+	ConsentEventsErased int       `json:"consent_events_erased"`
+	ErasedAt            time.Time `json:"erased_at"`
+}
+
+// ExportSubject collects every document and consent event held for a signer email
+func ExportSubject(store DocumentStore, ledger ConsentLedger, subjectID string, now time.Time) (SubjectExport, error) {
+	subjectID = NormalizeSubjectID(subjectID)
+
+	documents, err := store.ListDocumentsBySigner(subjectID)
+	if err != nil {
+		return SubjectExport{}, fmt.Errorf("error listing documents: %v", err)
+	}
+	history, err := ledger.ListConsentHistory(subjectID)
+	if err != nil {
+		return SubjectExport{}, fmt.Errorf("error listing consent history: %v", err)
+	}
+
+	if documents == nil {
+		documents = []Document{}
+	}
+	for i := range documents {
+		if documents[i].SignatureData, err = store.GetSignatureData(documents[i].ID); err != nil {
+			return SubjectExport{}, fmt.Errorf("error reading signature of document %s: %v", documents[i].ID, err)
+		}
+	}
+	if history == nil {
+		history = []ConsentEvent{}
+	}
+
+	return SubjectExport{
+		SubjectID:      subjectID,
+		GeneratedAt:    now,
+		Documents:      documents,
+		ConsentState:   CurrentConsentState(history),
+		ConsentHistory: history,
+	}, nil
+}
+
+// EraseSubject irreversibly erases or pseudonymises a signer's personal data and signature
+// images on every document and consent event, recording an audit entry per document
+func EraseSubject(store DocumentStore, ledger ConsentLedger, audit AuditLog, pseudonymizer *Pseudonymizer, subjectID, mode string, now time.Time) (SubjectErasure, error) {
+	if mode != ErasureModeErase && mode != ErasureModePseudonymise {
+		return SubjectErasure{}, fmt.Errorf("unsupported erasure mode: %s", mode)
+	}
+
+	subjectID = NormalizeSubjectID(subjectID)
+	subjectRef := pseudonymizer.Pseudonym(subjectID)
+	replacement := ""
+	if mode == ErasureModePseudonymise {
+		replacement = subjectRef
+	}
+
+	documents, err := store.ListDocumentsBySigner(subjectID)
+	if err != nil {
+		return SubjectErasure{}, fmt.Errorf("error listing documents: %v", err)
+	}
+
+	erasure := SubjectErasure{
+		SubjectRef:       subjectRef,
+		Mode:             mode,
+		ErasedRequestIDs: []string{},
+		ErasedAt:         now,
+	}
+	for _, doc := range documents {
+		if err := store.EraseDocument(doc.ID, replacement); err != nil {
+			return erasure, fmt.Errorf("error erasing document %s: %v", doc.ID, err)
+		}
+		erasure.ErasedRequestIDs = append(erasure.ErasedRequestIDs, doc.ID)
+
+		err := audit.RecordAudit(AuditEntry{
+			RequestID:  doc.ID,
+			Action:     AuditActionSubjectErased,
+			SubjectRef: subjectRef,
+			Details: map[string]string{
+				"mode":            mode,
+				"previous_status": doc.Status,
+			},
+			CreatedAt: now,
+		})
+		if err != nil {
+			return erasure, fmt.Errorf("error recording audit entry for %s: %v", doc.ID, err)
+		}
+	}
+
+	erasure.ConsentEventsErased, err = ledger.EraseSubject(subjectID, subjectRef)
+	if err != nil {
+		return erasure, fmt.Errorf("error erasing consent events: %v", err)
+	}
+
+	return erasure, nil
+}
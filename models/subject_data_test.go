@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHKDFSHA256(t *testing.T) {
+	// RFC 5869 test case 1, first 32 bytes of the output
+	secret, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+
+	assert.Equal(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf",
+		hex.EncodeToString(hkdfSHA256(secret, salt, info)))
+}
+
+func TestDerivePseudonymKey(t *testing.T) {
+	key := DerivePseudonymKey([]byte("api-token"))
+
+	assert.Len(t, key, 32)
+	assert.Equal(t, key, DerivePseudonymKey([]byte("api-token")))
+	assert.NotEqual(t, key, DerivePseudonymKey([]byte("other-token")))
+	assert.NotEqual(t, NewPseudonymizer([]byte("api-token")).Pseudonym("john@example.com"),
+		NewPseudonymizer(key).Pseudonym("john@example.com"))
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/tsa"
+)
+
+// Timestamp is an RFC 3161 trusted timestamp over a document's integrity hash, proving the
+// completion record existed at GenTime according to an independent timestamp authority
+type Timestamp struct {
+	// Token is the base64 DER encoded RFC 3161 TimeStampToken
+	Token   string    `json:"token"`
+	GenTime time.Time `json:"gen_time"`
+	// Authority is the subject of the authority's signing certificate
+	Authority    string `json:"authority"`
+	SerialNumber string `json:"serial_number"`
+	Policy       string `json:"policy"`
+}
+
+// NewTimestamp describes a DER encoded timestamp token
+func NewTimestamp(token []byte) (*Timestamp, error) {
+	parsed, err := tsa.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := &Timestamp{
+		Token:        base64.StdEncoding.EncodeToString(token),
+		GenTime:      parsed.GenTime,
+		SerialNumber: parsed.SerialNumber.String(),
+		Policy:       parsed.Policy.String(),
+	}
+	if parsed.Certificate != nil {
+		timestamp.Authority = parsed.Certificate.Subject.String()
+	}
+	return timestamp, nil
+}
+
+// TimestampDigest returns the SHA-256 digest an integrity hash stands for, which is what is
+// sent to the timestamp authority
+func TimestampDigest(integrityHash string) ([]byte, error) {
+	digest, err := hex.DecodeString(strings.TrimPrefix(integrityHash, integrityHashPrefix))
+	if err != nil || !strings.HasPrefix(integrityHash, integrityHashPrefix) {
+		return nil, fmt.Errorf("invalid integrity hash %q", integrityHash)
+	}
+	return digest, nil
+}
+
+// VerifyTimestamp checks that a timestamp token is validly signed and was issued for the
+// integrity hash. With roots the authority's certificate must chain to one of them.
+func VerifyTimestamp(timestamp Timestamp, integrityHash string, roots *x509.CertPool) error {
+	der, err := base64.StdEncoding.DecodeString(timestamp.Token)
+	if err != nil {
+		return fmt.Errorf("%w: token is not valid base64", tsa.ErrInvalidToken)
+	}
+	token, err := tsa.ParseToken(der)
+	if err != nil {
+		return fmt.Errorf("%w: %v", tsa.ErrInvalidToken, err)
+	}
+	digest, err := TimestampDigest(integrityHash)
+	if err != nil {
+		return err
+	}
+	if !token.GenTime.Equal(timestamp.GenTime) {
+		return fmt.Errorf("%w: gen_time does not match the token", tsa.ErrInvalidToken)
+	}
+	return token.Verify(crypto.SHA256, digest, roots)
+}
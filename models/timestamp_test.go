@@ -0,0 +1,103 @@
+package models
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/tsa"
+	"github.com/stretchr/testify/assert"
+)
+
+func timestampedTestDocument(t *testing.T, authority *tsa.LocalTSA) Document {
+	doc := completedTestDocument()
+	doc.IntegrityHash, _ = IntegrityHash(doc)
+	digest, err := TimestampDigest(doc.IntegrityHash)
+	assert.NoError(t, err)
+	token, err := authority.Timestamp(context.Background(), digest)
+	assert.NoError(t, err)
+	doc.Timestamp, err = NewTimestamp(token)
+	assert.NoError(t, err)
+	return doc
+}
+
+func TestVerifyTimestamp(t *testing.T) {
+	authority, err := tsa.NewLocalTSA()
+	assert.NoError(t, err)
+	otherAuthority, err := tsa.NewLocalTSA()
+	assert.NoError(t, err)
+
+	doc := timestampedTestDocument(t, authority)
+	assert.Equal(t, "CN=Signature Collector local TSA", doc.Timestamp.Authority)
+	assert.Equal(t, tsa.LocalPolicy.String(), doc.Timestamp.Policy)
+	assert.WithinDuration(t, time.Now(), doc.Timestamp.GenTime, 2*time.Second)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Root())
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherAuthority.Root())
+
+	movedTime := *doc.Timestamp
+	movedTime.GenTime = movedTime.GenTime.Add(-time.Hour)
+	corrupted := *doc.Timestamp
+	corrupted.Token = "not base64!"
+
+	tests := []struct {
+		name          string
+		timestamp     Timestamp
+		integrityHash string
+		roots         *x509.CertPool
+		wantError     bool
+	}{
+		{name: "Valid", timestamp: *doc.Timestamp, integrityHash: doc.IntegrityHash},
+		{name: "Valid with roots", timestamp: *doc.Timestamp, integrityHash: doc.IntegrityHash, roots: roots},
+		{name: "Untrusted authority", timestamp: *doc.Timestamp, integrityHash: doc.IntegrityHash, roots: otherRoots, wantError: true},
+		{name: "Other hash", timestamp: *doc.Timestamp, integrityHash: "sha256:" + sha256Hex([]byte("other")), wantError: true},
+		{name: "Altered time", timestamp: movedTime, integrityHash: doc.IntegrityHash, wantError: true},
+		{name: "Corrupted token", timestamp: corrupted, integrityHash: doc.IntegrityHash, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyTimestamp(tt.timestamp, tt.integrityHash, tt.roots)
+			if tt.wantError {
+				assert.ErrorIs(t, err, tsa.ErrInvalidToken)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyCertificate_Timestamp(t *testing.T) {
+	authority, err := tsa.NewLocalTSA()
+	assert.NoError(t, err)
+	doc := timestampedTestDocument(t, authority)
+	certificate, err := NewCertificate(doc, time.Now())
+	assert.NoError(t, err)
+
+	verification, err := VerifyCertificate(certificate, VerifyOptions{})
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Equal(t, []string{CheckCertificateHash, CheckTimestamp}, checkNames(verification))
+
+	// A timestamp moved onto another record does not verify
+	other := completedTestDocument()
+	other.SignerName = "Jane Smith"
+	other.IntegrityHash, _ = IntegrityHash(other)
+	other.Timestamp = doc.Timestamp
+	altered, err := NewCertificate(other, time.Now())
+	assert.NoError(t, err)
+	verification, _ = VerifyCertificate(altered, VerifyOptions{})
+	assert.False(t, verification.Valid)
+	assert.False(t, verification.Checks[len(verification.Checks)-1].Passed)
+}
+
+func checkNames(verification CertificateVerification) []string {
+	var names []string
+	for _, check := range verification.Checks {
+		names = append(names, check.Name)
+	}
+	return names
+}
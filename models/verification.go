@@ -0,0 +1,163 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Methods a signer proves who they are with before the signature pad unlocks: typing their name
+// as the sign request gives it, entering the last IDDigitsLength characters of their ID number,
+// or entering a one-time code sent to their email address
+const (
+	VerificationName      = "name"
+	VerificationIDDigits  = "id_digits"
+	VerificationEmailCode = "email_code"
+)
+
+// Outcomes of a verification. A verification that failed cannot be retried; the document has to
+// be requested again.
+const (
+	VerificationVerified = "verified"
+	VerificationFailed   = "failed"
+)
+
+// IDDigitsLength is the number of trailing characters of their ID number a signer enters
+const IDDigitsLength = 4
+
+// VerificationMaxAttempts bounds the attempts at typing the name or ID digits. Email codes are
+// bounded by OTPMaxAttempts and OTPMaxSends instead.
+const VerificationMaxAttempts = 5
+
+// Reasons a verification answer is not accepted
+var (
+	ErrVerificationIncorrect = errors.New("answer does not match")
+	ErrVerificationFailed    = errors.New("verification failed")
+)
+
+// Verification is how the signer of a document proved who they are, as reported in callbacks
+type Verification struct {
+	Method   string    `json:"method"`
+	Outcome  string    `json:"outcome"`
+	Attempts int       `json:"attempts"`
+	At       time.Time `json:"at"`
+}
+
+// ValidVerificationMethod reports whether a sign request may ask for a verification method
+func ValidVerificationMethod(method string) bool {
+	return method == VerificationName || method == VerificationIDDigits || method == VerificationEmailCode
+}
+
+// ValidIDDigits reports whether digits are the IDDigitsLength letters or digits a signer can
+// be asked for
+func ValidIDDigits(digits string) bool {
+	digits = normalizeIDDigits(digits)
+	if len(digits) != IDDigitsLength {
+		return false
+	}
+	for _, r := range digits {
+		if (r < '0' || r > '9') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// HashIDDigits returns the salted hash the expected ID digits of a document are stored as
+func HashIDDigits(digits string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hashIDDigits(hex.EncodeToString(salt), digits), nil
+}
+
+func hashIDDigits(salt, digits string) string {
+	digest := sha256.Sum256([]byte(salt + ":" + normalizeIDDigits(digits)))
+	return salt + ":" + hex.EncodeToString(digest[:])
+}
+
+// MaskEmail hides most of the local part of an email address, so that a page shows which address
+// a document is for without disclosing it to whoever opens the page
+func MaskEmail(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 1 {
+		return "•••"
+	}
+	_, size := utf8.DecodeRuneInString(address)
+	return address[:size] + "•••" + address[at:]
+}
+
+// VerificationRequired reports whether the signer must still prove who they are before signing
+func (d Document) VerificationRequired() bool {
+	return d.VerificationMethod != "" && d.VerificationOutcome != VerificationVerified
+}
+
+// CheckVerification checks an answer given at now against the verification the document asks
+// for. Attempts are counted before checking, so VerificationAttempts, and for email codes
+// OTPAttempts, include this one.
+func (d Document) CheckVerification(answer string, now time.Time) error {
+	if d.VerificationOutcome == VerificationFailed {
+		return ErrVerificationFailed
+	}
+	var matches bool
+	switch d.VerificationMethod {
+	case VerificationEmailCode:
+		return d.CheckOTP(answer, now)
+	case VerificationName:
+		matches = normalizeName(answer) == normalizeName(d.SignerName) && normalizeName(answer) != ""
+	case VerificationIDDigits:
+		salt, _, _ := strings.Cut(d.VerificationSecret, ":")
+		matches = subtle.ConstantTimeCompare([]byte(hashIDDigits(salt, answer)), []byte(d.VerificationSecret)) == 1
+	}
+	if d.VerificationAttempts > VerificationMaxAttempts {
+		return ErrVerificationFailed
+	}
+	if !matches {
+		return ErrVerificationIncorrect
+	}
+	return nil
+}
+
+// VerificationResult returns how the signer was verified, or nil when the sign request asked
+// for no verification or it has no outcome yet
+func (d Document) VerificationResult() *Verification {
+	if d.VerificationMethod == "" || d.VerificationOutcome == "" || d.VerificationAt == nil {
+		return nil
+	}
+	return &Verification{
+		Method:   d.VerificationMethod,
+		Outcome:  d.VerificationOutcome,
+		Attempts: d.VerificationAttempts,
+		At:       *d.VerificationAt,
+	}
+}
+
+// normalizeName reduces a name to what a signer typing it on a tablet keyboard can be expected to
+// match: case, accents, punctuation and spacing are ignored
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// normalizeIDDigits ignores case, spaces and dashes in ID digits
+func normalizeIDDigits(digits string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(digits)))
+}
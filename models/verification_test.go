@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidIDDigits(t *testing.T) {
+	assert.True(t, ValidIDDigits("4821"))
+	assert.True(t, ValidIDDigits("48 2x"))
+	assert.False(t, ValidIDDigits("482"))
+	assert.False(t, ValidIDDigits("48210"))
+	assert.False(t, ValidIDDigits("48.1"))
+}
+
+func TestDocument_CheckVerification(t *testing.T) {
+	now := time.Now()
+	secret, err := HashIDDigits("482x")
+	assert.NoError(t, err)
+	byName := Document{ID: "doc-1", SignerName: "José Kowalski-Nowak", VerificationMethod: VerificationName, VerificationAttempts: 1}
+	byDigits := Document{ID: "doc-1", VerificationMethod: VerificationIDDigits, VerificationSecret: secret, VerificationAttempts: 1}
+	expiresAt := now.Add(OTPTTL)
+	byCode := Document{ID: "doc-1", VerificationMethod: VerificationEmailCode, OTPHash: HashOTP("doc-1", "042137"), OTPExpiresAt: &expiresAt, OTPAttempts: 1}
+
+	tests := []struct {
+		name     string
+		doc      Document
+		answer   string
+		expected error
+	}{
+		{name: "Name", doc: byName, answer: "José Kowalski-Nowak"},
+		{name: "Name without accents, case and punctuation", doc: byName, answer: "  jose kowalski nowak "},
+		{name: "Other name", doc: byName, answer: "Jan Kowalski", expected: ErrVerificationIncorrect},
+		{name: "Empty name", doc: Document{VerificationMethod: VerificationName}, answer: " ", expected: ErrVerificationIncorrect},
+		{name: "ID digits", doc: byDigits, answer: "482X"},
+		{name: "ID digits with spaces", doc: byDigits, answer: "48 2x"},
+		{name: "Other ID digits", doc: byDigits, answer: "4821", expected: ErrVerificationIncorrect},
+		{name: "Email code", doc: byCode, answer: "042137"},
+		{name: "Incorrect email code", doc: byCode, answer: "042138", expected: ErrOTPIncorrect},
+		{
+			name:     "Too many attempts",
+			doc:      Document{SignerName: "Jan", VerificationMethod: VerificationName, VerificationAttempts: VerificationMaxAttempts + 1},
+			answer:   "Jan",
+			expected: ErrVerificationFailed,
+		},
+		{
+			name:     "Failed before",
+			doc:      Document{SignerName: "Jan", VerificationMethod: VerificationName, VerificationOutcome: VerificationFailed},
+			answer:   "Jan",
+			expected: ErrVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.doc.CheckVerification(tt.answer, now))
+		})
+	}
+}
+
+func TestDocument_VerificationResult(t *testing.T) {
+	at := time.Date(2024, 6, 10, 9, 14, 0, 0, time.UTC)
+	doc := Document{VerificationMethod: VerificationName}
+
+	assert.True(t, doc.VerificationRequired())
+	assert.Nil(t, doc.VerificationResult())
+
+	doc.VerificationOutcome, doc.VerificationAttempts, doc.VerificationAt = VerificationVerified, 2, &at
+	assert.False(t, doc.VerificationRequired())
+	assert.Equal(t, &Verification{Method: VerificationName, Outcome: VerificationVerified, Attempts: 2, At: at}, doc.VerificationResult())
+
+	assert.False(t, Document{}.VerificationRequired())
+}
+
+func TestMaskEmail(t *testing.T) {
+	assert.Equal(t, "j•••@example.com", MaskEmail("john@example.com"))
+	assert.Equal(t, "ż•••@example.com", MaskEmail("żaneta@example.com"))
+	assert.Equal(t, "•••", MaskEmail("@example.com"))
+	assert.Equal(t, "•••", MaskEmail("not-an-address"))
+}
@@ -0,0 +1,173 @@
+package notify
+
+import (
+	"encoding/base64"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// LocalServer is an in-process stand-in for an SMTP server, for development and tests. It listens
+// on the loopback interface and accepts every message, keeping it in memory instead of delivering
+// it. With credentials it requires AUTH PLAIN with them, as a mail provider would.
+type LocalServer struct {
+	username string
+	password string
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []LocalMessage
+}
+
+// LocalMessage is a message received by the local server
+type LocalMessage struct {
+	From string
+	To   []string
+	// Data is the message as sent, with LF line breaks
+	Data []byte
+}
+
+// NewLocalServer starts a local server on a free port, requiring the given credentials if they
+// are not empty
+func NewLocalServer(username, password string) (*LocalServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	l := &LocalServer{username: username, password: password, listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go l.serve(conn)
+		}
+	}()
+	return l, nil
+}
+
+// Host returns the address the server listens on
+func (l *LocalServer) Host() string {
+	host, _, _ := net.SplitHostPort(l.listener.Addr().String())
+	return host
+}
+
+// Port returns the port the server listens on
+func (l *LocalServer) Port() string {
+	_, port, _ := net.SplitHostPort(l.listener.Addr().String())
+	return port
+}
+
+// Messages returns the messages received so far, in order
+func (l *LocalServer) Messages() []LocalMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LocalMessage(nil), l.messages...)
+}
+
+// Close stops the server
+func (l *LocalServer) Close() error {
+	return l.listener.Close()
+}
+
+// serve holds an SMTP session on a connection
+func (l *LocalServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) {
+		text.PrintfLine("%d %s", code, message)
+	}
+
+	reply(220, "localhost ESMTP stand-in")
+	authenticated := l.username == ""
+	var from string
+	var to []string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-localhost")
+			if l.username != "" {
+				text.PrintfLine("250-AUTH PLAIN")
+			}
+			reply(250, "8BITMIME")
+		case "HELO":
+			reply(250, "localhost")
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(argument, " ")
+			if !strings.EqualFold(mechanism, "PLAIN") {
+				reply(504, "Unrecognized authentication type")
+				continue
+			}
+			if response == "" {
+				reply(334, "")
+				if response, err = text.ReadLine(); err != nil {
+					return
+				}
+			}
+			decoded, err := base64.StdEncoding.DecodeString(response)
+			fields := strings.Split(string(decoded), "\x00")
+			if err != nil || len(fields) != 3 || fields[1] != l.username || fields[2] != l.password {
+				reply(535, "Authentication credentials invalid")
+				continue
+			}
+			authenticated = true
+			reply(235, "Authentication successful")
+		case "MAIL":
+			if !authenticated {
+				reply(530, "Authentication required")
+				continue
+			}
+			from, to = pathAddress(argument), nil
+			reply(250, "OK")
+		case "RCPT":
+			if from == "" {
+				reply(503, "Need MAIL command")
+				continue
+			}
+			to = append(to, pathAddress(argument))
+			reply(250, "OK")
+		case "DATA":
+			if len(to) == 0 {
+				reply(503, "Need RCPT command")
+				continue
+			}
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			l.mu.Lock()
+			l.messages = append(l.messages, LocalMessage{From: from, To: to, Data: data})
+			l.mu.Unlock()
+			log.Printf("Local SMTP server received a message for %s", strings.Join(to, ", "))
+			from, to = "", nil
+			reply(250, "OK: queued")
+		case "RSET":
+			from, to = "", nil
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, "Command not implemented")
+		}
+	}
+}
+
+// pathAddress returns the address of a MAIL FROM or RCPT TO argument, such as
+// FROM:<jane@example.com> BODY=8BITMIME
+func pathAddress(argument string) string {
+	_, path, _ := strings.Cut(argument, ":")
+	path, _, _ = strings.Cut(strings.TrimSpace(path), " ")
+	return strings.Trim(path, "<>")
+}
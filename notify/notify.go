@@ -0,0 +1,136 @@
+// Package notify sends email notifications to signers: an SMTP sender, and a local stand-in SMTP
+// server for development and tests.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Sender delivers email messages
+type Sender interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Message is a plain text email to one recipient, with optional attachments
+type Message struct {
+	To          mail.Address
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Compose encodes a message from an address as an RFC 5322 message, with a MIME multipart body
+// when it has attachments
+func Compose(message Message, from mail.Address, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from.String())
+	header("To", message.To.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", singleLine(message.Subject)))
+	header("Date", now.Format(time.RFC1123Z))
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, err
+	}
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+
+	if len(message.Attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, message.Body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": parts.Boundary()}))
+	buf.WriteString("\r\n")
+
+	body, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(body, message.Body); err != nil {
+		return nil, err
+	}
+	for _, attachment := range message.Attachments {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(attachment.ContentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(part, attachment.Data)
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// singleLine joins the lines of a header value, which must not start new headers
+func singleLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// writeQuotedPrintable writes text quoted-printable encoded, with CRLF line breaks
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data base64-encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// newMessageID returns a unique Message-ID in the domain of the sender's address
+func newMessageID(from string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">", nil
+}
@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompose(t *testing.T) {
+	from := mail.Address{Name: "Signature Collector", Address: "noreply@example.com"}
+	now := time.Date(2024, 6, 10, 9, 15, 0, 0, time.UTC)
+	pdf := bytes.Repeat([]byte("%PDF-1.7 "), 20)
+
+	tests := []struct {
+		name        string
+		message     Message
+		expectedPDF []byte
+	}{
+		{
+			name: "Plain text",
+			message: Message{
+				To:      mail.Address{Name: "Zażółć Gęślą", Address: "jane@example.com"},
+				Subject: "Twoja kopia: Umowa",
+				Body:    "Dzień dobry,\nw załączniku kopia.",
+			},
+		},
+		{
+			name: "Attachment",
+			message: Message{
+				To:          mail.Address{Address: "jane@example.com"},
+				Subject:     "Your copy",
+				Body:        "Dzień dobry,\nw załączniku kopia.",
+				Attachments: []Attachment{{Filename: "document-abc.pdf", ContentType: "application/pdf", Data: pdf}},
+			},
+			expectedPDF: pdf,
+		},
+		{
+			name: "Header injection",
+			message: Message{
+				To:      mail.Address{Address: "jane@example.com"},
+				Subject: "Your copy\r\nBcc: mallory@example.com",
+				Body:    "Dzień dobry,\nw załączniku kopia.",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Compose(tt.message, from, now)
+			assert.NoError(t, err)
+			parsed, err := mail.ReadMessage(bytes.NewReader(data))
+			assert.NoError(t, err)
+
+			assert.Equal(t, `"Signature Collector" <noreply@example.com>`, parsed.Header.Get("From"))
+			to, err := parsed.Header.AddressList("To")
+			assert.NoError(t, err)
+			assert.Equal(t, []*mail.Address{&tt.message.To}, to)
+			subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+			assert.NoError(t, err)
+			assert.Equal(t, singleLine(tt.message.Subject), subject)
+			assert.Empty(t, parsed.Header.Get("Bcc"))
+			assert.Equal(t, "Mon, 10 Jun 2024 09:15:00 +0000", parsed.Header.Get("Date"))
+			assert.Regexp(t, `^<[0-9a-f]{32}@example\.com>$`, parsed.Header.Get("Message-ID"))
+
+			mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+			assert.NoError(t, err)
+			if tt.expectedPDF == nil {
+				assert.Equal(t, "text/plain", mediaType)
+				body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+				assert.NoError(t, err)
+				assert.Equal(t, "Dzień dobry,\r\nw załączniku kopia.", string(body))
+				return
+			}
+
+			assert.Equal(t, "multipart/mixed", mediaType)
+			parts := multipart.NewReader(parsed.Body, params["boundary"])
+			part, err := parts.NextPart()
+			assert.NoError(t, err)
+			body, err := io.ReadAll(part)
+			assert.NoError(t, err)
+			assert.Equal(t, "Dzień dobry,\r\nw załączniku kopia.", string(body))
+
+			part, err = parts.NextPart()
+			assert.NoError(t, err)
+			assert.Equal(t, "document-abc.pdf", part.FileName())
+			encoded, err := io.ReadAll(part)
+			assert.NoError(t, err)
+			for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+				assert.LessOrEqual(t, len(line), 76)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedPDF, decoded)
+		})
+	}
+}
+
+func TestSMTPSender_LocalServer(t *testing.T) {
+	local, err := NewLocalServer("mailer", "mailer-secret")
+	assert.NoError(t, err)
+	defer local.Close()
+
+	sender, err := NewSMTPSender(local.Host(), local.Port(), "mailer", "mailer-secret", "Signature Collector <noreply@example.com>")
+	assert.NoError(t, err)
+	message := Message{To: mail.Address{Name: "Jane Doe", Address: "jane@example.com"}, Subject: "Your copy", Body: "Hello"}
+	assert.NoError(t, sender.Send(context.Background(), message))
+
+	messages := local.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "noreply@example.com", messages[0].From)
+		assert.Equal(t, []string{"jane@example.com"}, messages[0].To)
+		parsed, err := mail.ReadMessage(bytes.NewReader(messages[0].Data))
+		assert.NoError(t, err)
+		assert.Equal(t, "Your copy", parsed.Header.Get("Subject"))
+	}
+
+	// A server refusing the credentials fails the delivery
+	sender, err = NewSMTPSender(local.Host(), local.Port(), "mailer", "wrong", "noreply@example.com")
+	assert.NoError(t, err)
+	assert.Error(t, sender.Send(context.Background(), message))
+	assert.Len(t, local.Messages(), 1)
+}
+
+func TestLoadSMTPSender(t *testing.T) {
+	sender, err := LoadSMTPSender("", "", "", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, sender)
+
+	_, err = LoadSMTPSender("smtp.example.com", "", "", "", "")
+	assert.Error(t, err)
+
+	_, err = LoadSMTPSender("smtp.example.com", "", "", "", "not an address")
+	assert.Error(t, err)
+
+	sender, err = LoadSMTPSender("smtp.example.com", "", "", "", "noreply@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "587", sender.port)
+}
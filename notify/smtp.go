@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpTimeout bounds a delivery when the context has no deadline
+const smtpTimeout = time.Minute
+
+// SMTPSender delivers messages through an SMTP server. The connection is upgraded with STARTTLS
+// when the server offers it, or uses TLS from the start on port 465.
+type SMTPSender struct {
+	host      string
+	port      string
+	username  string
+	password  string
+	from      mail.Address
+	tlsConfig *tls.Config
+	timeNow   func() time.Time
+}
+
+// NewSMTPSender creates a sender delivering through the server at host and port, authenticating
+// with username and password if given, from the address from, such as
+// "Signature Collector <noreply@example.com>"
+func NewSMTPSender(host, port, username, password, from string) (*SMTPSender, error) {
+	if host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	if port == "" {
+		port = "587"
+	}
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %v", from, err)
+	}
+	return &SMTPSender{
+		host:      host,
+		port:      port,
+		username:  username,
+		password:  password,
+		from:      *address,
+		tlsConfig: &tls.Config{ServerName: host},
+		timeNow:   time.Now,
+	}, nil
+}
+
+// LoadSMTPSender creates a sender from its configuration. It returns nil when no host is
+// configured.
+func LoadSMTPSender(host, port, username, password, from string) (*SMTPSender, error) {
+	if host == "" {
+		return nil, nil
+	}
+	if from == "" {
+		return nil, fmt.Errorf("a sender address is required to send email")
+	}
+	return NewSMTPSender(host, port, username, password, from)
+}
+
+// WithTLSConfig sets the TLS configuration of connections to the server
+func (s *SMTPSender) WithTLSConfig(config *tls.Config) *SMTPSender {
+	s.tlsConfig = config
+	return s
+}
+
+// From returns the address messages are sent from
+func (s *SMTPSender) From() mail.Address {
+	return s.from
+}
+
+// Send delivers a message
+func (s *SMTPSender) Send(ctx context.Context, message Message) error {
+	data, err := Compose(message, s.from, s.timeNow())
+	if err != nil {
+		return fmt.Errorf("error composing message: %v", err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.host, s.port))
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if s.port == "465" {
+		conn = tls.Client(conn, s.tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && s.port != "465" {
+		if err := client.StartTLS(s.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
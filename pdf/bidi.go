@@ -0,0 +1,182 @@
+package pdf
+
+import (
+	"slices"
+	"unicode"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Directions characters resolve to when text is reordered
+type direction int
+
+const (
+	neutral direction = iota
+	leftToRight
+	rightToLeft
+	number
+)
+
+// mirrored maps brackets to their mirror images, which are shown in right-to-left runs
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<',
+	'«': '»', '»': '«', '‹': '›', '›': '‹',
+}
+
+func bidiClass(r rune) bidi.Class {
+	properties, _ := bidi.LookupRune(r)
+	return properties.Class()
+}
+
+// isRightToLeft reports whether a paragraph is written from right to left, which its first
+// letter with a strong direction decides. Without one, fallback is returned.
+func isRightToLeft(text string, fallback bool) bool {
+	for _, r := range text {
+		switch bidiClass(r) {
+		case bidi.L:
+			return false
+		case bidi.R, bidi.AL:
+			return true
+		}
+	}
+	return fallback
+}
+
+// visualOrder returns a line of text in the order its characters are drawn, from left to
+// right. It follows a simplified Unicode bidirectional algorithm without explicit embeddings:
+// runs of the other direction than the paragraph's are reversed, numbers keep their order in
+// right-to-left text, and brackets in right-to-left runs are mirrored.
+func visualOrder(line string, rtl bool) string {
+	// Vowel and other combining marks stay after the character they belong to
+	var clusters [][]rune
+	for _, r := range line {
+		if len(clusters) > 0 && unicode.Is(unicode.Mn, r) {
+			clusters[len(clusters)-1] = append(clusters[len(clusters)-1], r)
+			continue
+		}
+		clusters = append(clusters, []rune{r})
+	}
+
+	directions := make([]direction, len(clusters))
+	rightToLeftSeen := false
+	for i, cluster := range clusters {
+		switch bidiClass(cluster[0]) {
+		case bidi.L:
+			directions[i] = leftToRight
+		case bidi.R, bidi.AL:
+			directions[i] = rightToLeft
+			rightToLeftSeen = true
+		case bidi.EN, bidi.AN:
+			directions[i] = number
+		}
+	}
+	if len(clusters) == 0 || (!rtl && !rightToLeftSeen) {
+		return line
+	}
+
+	// Separators between digits and signs next to them belong to the number, as in 1,000.50
+	// or 15%
+	for i, cluster := range clusters {
+		switch bidiClass(cluster[0]) {
+		case bidi.ES, bidi.CS:
+			if i > 0 && i < len(clusters)-1 && directions[i-1] == number && directions[i+1] == number {
+				directions[i] = number
+			}
+		case bidi.ET:
+			if (i > 0 && directions[i-1] == number) || (i < len(clusters)-1 && directions[i+1] == number) {
+				directions[i] = number
+			}
+		}
+	}
+
+	base := leftToRight
+	if rtl {
+		base = rightToLeft
+	}
+	// In left-to-right text, numbers after a left-to-right letter continue it
+	strong := base
+	for i, d := range directions {
+		switch d {
+		case leftToRight, rightToLeft:
+			strong = d
+		case number:
+			if !rtl && strong == leftToRight {
+				directions[i] = leftToRight
+			}
+		}
+	}
+
+	// Neutral characters between two of the same direction take it, the others the paragraph's.
+	// Numbers count as right to left here.
+	resolved := func(d direction) direction {
+		if d == number {
+			return rightToLeft
+		}
+		return d
+	}
+	for i := 0; i < len(directions); {
+		if directions[i] != neutral {
+			i++
+			continue
+		}
+		end := i
+		for end < len(directions) && directions[end] == neutral {
+			end++
+		}
+		before, after := base, base
+		if i > 0 {
+			before = resolved(directions[i-1])
+		}
+		if end < len(directions) {
+			after = resolved(directions[end])
+		}
+		d := base
+		if before == after {
+			d = before
+		}
+		for ; i < end; i++ {
+			directions[i] = d
+		}
+	}
+
+	// Embedding levels: even levels run left to right, odd ones right to left
+	levels := make([]int, len(clusters))
+	for i, d := range directions {
+		switch {
+		case d == number:
+			levels[i] = 2
+		case rtl && d == leftToRight:
+			levels[i] = 2
+		case d == rightToLeft:
+			levels[i] = 1
+		}
+		if levels[i]%2 == 1 {
+			if m, ok := mirrored[clusters[i][0]]; ok {
+				clusters[i][0] = m
+			}
+		}
+	}
+
+	// From the highest level down, reverse every run at that level or above
+	for level := slices.Max(levels); level > 0; level-- {
+		for i := 0; i < len(levels); {
+			if levels[i] < level {
+				i++
+				continue
+			}
+			end := i
+			for end < len(levels) && levels[end] >= level {
+				end++
+			}
+			slices.Reverse(clusters[i:end])
+			slices.Reverse(levels[i:end])
+			i = end
+		}
+	}
+
+	visual := make([]rune, 0, len(line))
+	for _, cluster := range clusters {
+		visual = append(visual, cluster...)
+	}
+	return string(visual)
+}
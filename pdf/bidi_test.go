@@ -0,0 +1,39 @@
+package pdf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVisualOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		rtl      bool
+		expected string
+	}{
+		{name: "Left-to-right text", text: "Signer: Jan Kowalski", expected: "Signer: Jan Kowalski"},
+		{name: "Right-to-left text", text: "שלום עולם", rtl: true, expected: "םלוע םולש"},
+		{name: "Right-to-left words in left-to-right text", text: "Hello שלום עולם!", expected: "Hello םלוע םולש!"},
+		{name: "Left-to-right words in right-to-left text", text: "החותם: Jan Kowalski", rtl: true, expected: "Jan Kowalski :םתוחה"},
+		{name: "Numbers keep their order", text: "עמוד 12", rtl: true, expected: "12 דומע"},
+		{name: "Dates keep their order", text: "נחתם: 02.06.2024 01:30:05", rtl: true, expected: "01:30:05 02.06.2024 :םתחנ"},
+		{name: "Brackets are mirrored", text: "(שלום)", rtl: true, expected: "(םולש)"},
+		{name: "Marks stay after their letter", text: "ﺑَﺖ", rtl: true, expected: "ﺖﺑَ"},
+		{name: "Empty line", text: "", rtl: true, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, visualOrder(tt.text, tt.rtl))
+		})
+	}
+}
+
+func TestIsRightToLeft(t *testing.T) {
+	assert.False(t, isRightToLeft("Umowa", true))
+	assert.True(t, isRightToLeft("12. שלום", false))
+	assert.True(t, isRightToLeft("12.", true))
+	assert.False(t, isRightToLeft("12.", false))
+}
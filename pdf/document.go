@@ -0,0 +1,450 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Page geometry in points: A4 with 2 cm margins
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+	margin     = 56.7
+)
+
+// Type sizes and line heights in points
+const (
+	titleSize   = 16
+	bodySize    = 11
+	bodyLeading = 15
+	footerSize  = 7
+)
+
+// Size of the box the handwritten signature is placed in
+const (
+	signatureWidth  = 220
+	signatureHeight = 90
+)
+
+// Producer is recorded in the document information of every PDF this package writes
+const Producer = "Signature Collector"
+
+// Document is the content of a signed document laid out by Render. Labels are passed in
+// already translated.
+type Document struct {
+	Title    string
+	Sections []Section
+	// Signature is drawn into the signature box. Leave it nil when the box is filled by a
+	// visible digital signature instead.
+	Signature  image.Image
+	SignerName string
+	Date       string
+	// Footer lines are printed at the bottom of every page, such as the request ID
+	Footer    []string
+	CreatedAt time.Time
+	Labels    Labels
+	// RightToLeft lays the document out for a language written from right to left, such as
+	// Arabic or Hebrew: tick boxes and the signature box are on the right. Each paragraph is
+	// aligned to the side its own text starts from.
+	RightToLeft bool
+	// Fonts are tried in order for characters the Go fonts have no glyphs for, such as Arabic
+	// or Hebrew ones. Only the fonts used are embedded.
+	Fonts []FontFile
+}
+
+// Section is a paragraph of the document. Consent sections are shown with a tick box.
+type Section struct {
+	Text    string
+	Consent bool
+	Granted bool
+}
+
+// Labels are the translated captions printed around the document content
+type Labels struct {
+	Granted    string
+	NotGranted string
+	Signature  string
+	Signer     string
+	Date       string
+}
+
+// Placement is where a signature appears: a 1-based page number and a rectangle in points
+// from the bottom left corner of the page
+type Placement struct {
+	Page   int
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// rect returns the placement as a PDF rectangle
+func (p Placement) rect() Array {
+	return Array{p.X, p.Y, p.X + p.Width, p.Y + p.Height}
+}
+
+// typeface is a font with the fallback fonts for characters it has no glyphs for, and the
+// names of their page resources
+type typeface struct {
+	fonts     []*Font
+	resources []string
+}
+
+// fontRun is text set in one of the fonts of a typeface
+type fontRun struct {
+	font int
+	text string
+}
+
+// runs splits text into runs by font. Letters are set in the first font with a glyph for
+// them; spaces, punctuation and marks stay in the font of the letters before them if it has
+// them, so words and numbers are not split between fonts.
+func (t *typeface) runs(s string) []fontRun {
+	var runs []fontRun
+	var run strings.Builder
+	current := -1
+	for _, r := range s {
+		font := current
+		if current < 0 || unicode.IsLetter(r) || !t.fonts[current].has(r) {
+			font = 0
+			for i, f := range t.fonts {
+				if f.has(r) {
+					font = i
+					break
+				}
+			}
+		}
+		if font != current && run.Len() > 0 {
+			runs = append(runs, fontRun{font: current, text: run.String()})
+			run.Reset()
+		}
+		current = font
+		run.WriteRune(r)
+	}
+	if run.Len() > 0 {
+		runs = append(runs, fontRun{font: current, text: run.String()})
+	}
+	return runs
+}
+
+// Width returns the width of text set at size points
+func (t *typeface) Width(s string, size float64) float64 {
+	width := 0.0
+	for _, run := range t.runs(s) {
+		width += t.fonts[run.font].Width(run.text, size)
+	}
+	return width
+}
+
+// layout accumulates page content streams while the document is set
+type layout struct {
+	pages []*bytes.Buffer
+	y     float64
+	rtl   bool
+}
+
+func (l *layout) page() *bytes.Buffer {
+	return l.pages[len(l.pages)-1]
+}
+
+func (l *layout) newPage() {
+	l.pages = append(l.pages, &bytes.Buffer{})
+	l.y = pageHeight - margin
+}
+
+// ensure starts a new page unless height points are left above the footer
+func (l *layout) ensure(height float64) {
+	if l.y-height < margin+footerSize*4 {
+		l.newPage()
+	}
+}
+
+// text writes a line of text, in visual order, to a page with its baseline at (x, y)
+func text(page *bytes.Buffer, t *typeface, size, x, y float64, s string) {
+	for i, run := range t.runs(s) {
+		fmt.Fprintf(page, "/%s %s Tf ", t.resources[run.font], formatNumber(size))
+		if i == 0 {
+			fmt.Fprintf(page, "%s %s Td ", formatNumber(x), formatNumber(y))
+		}
+		writeObject(page, t.fonts[run.font].Encode(run.text))
+		page.WriteString(" Tj ")
+	}
+}
+
+// line writes a line of shaped text to a page with its baseline at y. Lines of a right-to-left
+// paragraph are aligned right. indent is left free beside tick boxes, on the side the document
+// starts from.
+func (l *layout) line(page *bytes.Buffer, t *typeface, size, indent, y float64, rtl bool, s string) {
+	x := margin
+	if !l.rtl {
+		x += indent
+	}
+	if rtl {
+		x = pageWidth - margin - t.Width(s, size)
+		if l.rtl {
+			x -= indent
+		}
+	}
+	page.WriteString("BT ")
+	text(page, t, size, x, y, visualOrder(s, rtl))
+	page.WriteString("ET\n")
+}
+
+// label writes a line of text at the current position
+func (l *layout) label(t *typeface, size float64, s string) {
+	s = shapeArabic(s)
+	l.line(l.page(), t, size, 0, l.y, isRightToLeft(s, l.rtl), s)
+}
+
+// paragraph writes wrapped text at the current position, starting new pages as needed
+func (l *layout) paragraph(t *typeface, size, leading, indent float64, s string) {
+	s = shapeArabic(s)
+	rtl := isRightToLeft(s, l.rtl)
+	for _, line := range wrap(t, s, size, pageWidth-2*margin-indent) {
+		l.ensure(leading)
+		l.y -= leading
+		l.line(l.page(), t, size, indent, l.y, rtl, line)
+	}
+}
+
+// wrap breaks text into lines no wider than width, keeping explicit line breaks. Words longer
+// than a line are broken between characters.
+func wrap(t *typeface, s string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.FieldsFunc(paragraph, unicode.IsSpace) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if t.Width(candidate, size) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = ""
+			for _, r := range word {
+				if line != "" && t.Width(line+string(r), size) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Render lays out a document as an A4 PDF and returns it together with the placement of the
+// signature box
+func Render(doc Document) ([]byte, Placement, error) {
+	regularGo, err := regularFont()
+	if err != nil {
+		return nil, Placement{}, err
+	}
+	boldGo, err := boldFont()
+	if err != nil {
+		return nil, Placement{}, err
+	}
+	regular := &typeface{fonts: []*Font{regularGo}, resources: []string{"F1"}}
+	bold := &typeface{fonts: []*Font{boldGo}, resources: []string{"F2"}}
+	// Regular and bold text share the fallback fonts
+	fallbacks := make([]*Font, len(doc.Fonts))
+	for i, file := range doc.Fonts {
+		if fallbacks[i], err = NewFont(file.Name, file.Data); err != nil {
+			return nil, Placement{}, err
+		}
+		resource := "F" + strconv.Itoa(i+3)
+		for _, t := range []*typeface{regular, bold} {
+			t.fonts = append(t.fonts, fallbacks[i])
+			t.resources = append(t.resources, resource)
+		}
+	}
+	l := &layout{rtl: doc.RightToLeft}
+	l.newPage()
+
+	l.paragraph(bold, titleSize, titleSize*1.4, 0, doc.Title)
+	l.y -= bodyLeading
+
+	// Tick boxes and the signature box are on the side the document starts from
+	boxX, signatureX := margin, margin
+	if doc.RightToLeft {
+		boxX, signatureX = pageWidth-margin-9, pageWidth-margin-signatureWidth
+	}
+	for _, section := range doc.Sections {
+		if !section.Consent {
+			l.paragraph(regular, bodySize, bodyLeading, 0, section.Text)
+			l.y -= bodyLeading / 2
+			continue
+		}
+		// The tick box is drawn beside the first line of the consent text
+		l.ensure(bodyLeading)
+		box := l.y - bodyLeading + 1
+		fmt.Fprintf(l.page(), "0.8 w %s %s 9 9 re S\n", formatNumber(boxX), formatNumber(box))
+		if section.Granted {
+			fmt.Fprintf(l.page(), "1.2 w %s %s m %s %s l %s %s l S\n",
+				formatNumber(boxX+1.8), formatNumber(box+4.5),
+				formatNumber(boxX+3.8), formatNumber(box+2),
+				formatNumber(boxX+7.5), formatNumber(box+7.5))
+		}
+		l.paragraph(regular, bodySize, bodyLeading, 16, section.Text)
+		label := doc.Labels.NotGranted
+		if section.Granted {
+			label = doc.Labels.Granted
+		}
+		l.paragraph(bold, bodySize-2, bodyLeading-2, 16, label)
+		l.y -= bodyLeading / 2
+	}
+
+	// Signature box, caption, signer and date are kept together on one page
+	l.ensure(signatureHeight + 4*bodyLeading)
+	l.y -= bodyLeading
+	l.label(bold, bodySize, doc.Labels.Signature)
+	l.y -= signatureHeight + 4
+	placement := Placement{Page: len(l.pages), X: signatureX, Y: l.y, Width: signatureWidth, Height: signatureHeight}
+	fmt.Fprintf(l.page(), "0.5 w %s %s m %s %s l S\n",
+		formatNumber(signatureX), formatNumber(l.y), formatNumber(signatureX+signatureWidth), formatNumber(l.y))
+	l.y -= bodyLeading
+	l.label(regular, bodySize-1, doc.Labels.Signer+": "+doc.SignerName)
+	l.y -= bodyLeading
+	l.label(regular, bodySize-1, doc.Labels.Date+": "+doc.Date)
+
+	for _, content := range l.pages {
+		footerY := margin - footerSize
+		for _, line := range doc.Footer {
+			line = shapeArabic(line)
+			content.WriteString("0.4 g ")
+			l.line(content, regular, footerSize, 0, footerY, isRightToLeft(line, l.rtl), line)
+			content.WriteString("0 g\n")
+			footerY -= footerSize + 2
+		}
+	}
+
+	w := NewWriter()
+	resources := Dict{}
+	fonts := Dict{}
+	if fonts["F1"], err = regularGo.write(w); err != nil {
+		return nil, Placement{}, err
+	}
+	if fonts["F2"], err = boldGo.write(w); err != nil {
+		return nil, Placement{}, err
+	}
+	for i, f := range fallbacks {
+		if len(f.used) == 0 {
+			continue
+		}
+		if fonts[Name(regular.resources[i+1])], err = f.write(w); err != nil {
+			return nil, Placement{}, err
+		}
+	}
+	resources["Font"] = fonts
+	if doc.Signature != nil {
+		ref, err := imageXObject(w, doc.Signature)
+		if err != nil {
+			return nil, Placement{}, err
+		}
+		resources["XObject"] = Dict{"Im1": ref}
+		fmt.Fprintf(l.pages[placement.Page-1], "q %s Q\n", fitImage(doc.Signature.Bounds(), placement, "Im1"))
+	}
+
+	pagesRef := w.Reserve()
+	var kids Array
+	for _, content := range l.pages {
+		stream, err := flateStream(Dict{}, content.Bytes())
+		if err != nil {
+			return nil, Placement{}, err
+		}
+		kids = append(kids, w.Add(Dict{
+			"Type":      Name("Page"),
+			"Parent":    pagesRef,
+			"MediaBox":  Array{0, 0, pageWidth, pageHeight},
+			"Resources": resources,
+			"Contents":  w.Add(stream),
+		}))
+	}
+	w.Set(pagesRef, Dict{"Type": Name("Pages"), "Kids": kids, "Count": len(kids)})
+	catalog := w.Add(Dict{"Type": Name("Catalog"), "Pages": pagesRef})
+
+	info := Dict{"Title": TextString(doc.Title), "Producer": String(Producer)}
+	if !doc.CreatedAt.IsZero() {
+		info["CreationDate"] = String(Date(doc.CreatedAt))
+	}
+	return w.Bytes(catalog, w.Add(info)), placement, nil
+}
+
+// Date formats a time as a PDF date string
+func Date(t time.Time) string {
+	t = t.UTC()
+	return fmt.Sprintf("D:%04d%02d%02d%02d%02d%02dZ", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+}
+
+// fitImage returns the operators drawing an image XObject as large as fits into placement,
+// centred and keeping its aspect ratio
+func fitImage(bounds image.Rectangle, placement Placement, name string) string {
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+	scale := min(placement.Width/width, placement.Height/height)
+	width, height = width*scale, height*scale
+	x := placement.X + (placement.Width-width)/2
+	y := placement.Y + (placement.Height-height)/2
+	return fmt.Sprintf("%s 0 0 %s %s %s cm /%s Do",
+		formatNumber(width), formatNumber(height), formatNumber(x), formatNumber(y), name)
+}
+
+// adder adds indirect objects, to a new PDF or to an incremental update
+type adder interface {
+	Add(object any) Ref
+}
+
+// imageXObject adds an image as an RGB image XObject, with its transparency as a soft mask
+func imageXObject(w adder, img image.Image) (Ref, error) {
+	bounds := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+
+	rgb := make([]byte, 0, 3*len(nrgba.Pix)/4)
+	alpha := make([]byte, 0, len(nrgba.Pix)/4)
+	opaque := true
+	for i := 0; i < len(nrgba.Pix); i += 4 {
+		rgb = append(rgb, nrgba.Pix[i], nrgba.Pix[i+1], nrgba.Pix[i+2])
+		alpha = append(alpha, nrgba.Pix[i+3])
+		opaque = opaque && nrgba.Pix[i+3] == 0xff
+	}
+
+	dict := Dict{
+		"Type":             Name("XObject"),
+		"Subtype":          Name("Image"),
+		"Width":            bounds.Dx(),
+		"Height":           bounds.Dy(),
+		"ColorSpace":       Name("DeviceRGB"),
+		"BitsPerComponent": 8,
+	}
+	if !opaque {
+		mask, err := flateStream(Dict{
+			"Type":             Name("XObject"),
+			"Subtype":          Name("Image"),
+			"Width":            bounds.Dx(),
+			"Height":           bounds.Dy(),
+			"ColorSpace":       Name("DeviceGray"),
+			"BitsPerComponent": 8,
+		}, alpha)
+		if err != nil {
+			return Ref{}, err
+		}
+		dict["SMask"] = w.Add(mask)
+	}
+	stream, err := flateStream(dict, rgb)
+	if err != nil {
+		return Ref{}, err
+	}
+	return w.Add(stream), nil
+}
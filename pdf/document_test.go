@@ -0,0 +1,213 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDocument() Document {
+	signature := image.NewNRGBA(image.Rect(0, 0, 120, 40))
+	for x := 10; x < 110; x++ {
+		signature.Set(x, 20, color.Black)
+	}
+	return Document{
+		Title: "Umowa o świadczenie usług",
+		Sections: []Section{
+			{Text: "Zażółć gęślą jaźń. " + strings.Repeat("The service is provided as described. ", 40)},
+			{Text: "I agree to receive marketing email", Consent: true, Granted: true},
+			{Text: "I agree to share my data with partners", Consent: true},
+		},
+		Signature:  signature,
+		SignerName: "Jan Kowalski",
+		Date:       "2024-01-02 15:04",
+		Footer:     []string{"Request ID: doc-1", "Integrity hash: sha256:abc"},
+		CreatedAt:  time.Date(2024, 1, 2, 14, 4, 5, 0, time.UTC),
+		Labels: Labels{
+			Granted:    "Granted",
+			NotGranted: "Not granted",
+			Signature:  "Signature",
+			Signer:     "Signer",
+			Date:       "Date",
+		},
+	}
+}
+
+// checkXref checks that every in-use cross reference entry points at its object
+func checkXref(t *testing.T, data []byte) {
+	t.Helper()
+	r, err := Open(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for num, entry := range r.entries {
+		if entry.free || entry.stream != 0 {
+			continue
+		}
+		assert.True(t, bytes.HasPrefix(data[entry.offset:], []byte(strconv.Itoa(num)+" 0 obj")), "object %d at offset %d", num, entry.offset)
+	}
+}
+
+func TestRender(t *testing.T) {
+	data, placement, err := Render(testDocument())
+	assert.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.7\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	checkXref(t, data)
+
+	r, err := Open(data)
+	assert.NoError(t, err)
+	pages, err := r.Pages()
+	assert.NoError(t, err)
+	assert.Len(t, pages, 1)
+	assert.Equal(t, Placement{Page: 1, X: margin, Y: placement.Y, Width: signatureWidth, Height: signatureHeight}, placement)
+	assert.Greater(t, placement.Y, margin)
+
+	info, err := r.Dict(r.Trailer()["Info"])
+	assert.NoError(t, err)
+	assert.Equal(t, "Umowa o świadczenie usług", textValue(info["Title"]))
+	assert.Equal(t, String("D:20240102140405Z"), info["CreationDate"])
+
+	page, err := r.Dict(pages[0])
+	assert.NoError(t, err)
+	resources, err := r.Dict(page["Resources"])
+	assert.NoError(t, err)
+	assert.Contains(t, resources, Name("XObject"))
+	contents, err := r.Resolve(page["Contents"])
+	assert.NoError(t, err)
+	zr, err := zlib.NewReader(bytes.NewReader(contents.(Stream).Data))
+	assert.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "/Im1 Do")
+	// One tick is drawn, for the granted consent
+	assert.Len(t, regexp.MustCompile(`9 9 re S`).FindAll(content, -1), 2)
+	assert.Len(t, regexp.MustCompile(`1\.2 w`).FindAll(content, -1), 1)
+
+	// Text is written as glyph IDs that map back to the text through the ToUnicode CMap
+	fonts, err := r.Dict(resources["Font"])
+	assert.NoError(t, err)
+	font, err := r.Dict(fonts["F1"])
+	assert.NoError(t, err)
+	assert.Equal(t, Name("Identity-H"), font["Encoding"])
+	toUnicode, err := r.Resolve(font["ToUnicode"])
+	assert.NoError(t, err)
+	zr, err = zlib.NewReader(bytes.NewReader(toUnicode.(Stream).Data))
+	assert.NoError(t, err)
+	cmap, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	// ż and ś
+	assert.Contains(t, string(cmap), "<017C>")
+	assert.Contains(t, string(cmap), "<015B>")
+}
+
+func TestRender_PageBreaks(t *testing.T) {
+	doc := testDocument()
+	for i := 0; i < 60; i++ {
+		doc.Sections = append(doc.Sections, Section{Text: "Paragraph " + strconv.Itoa(i) + " of a long agreement."})
+	}
+
+	data, placement, err := Render(doc)
+	assert.NoError(t, err)
+	checkXref(t, data)
+
+	r, err := Open(data)
+	assert.NoError(t, err)
+	pages, err := r.Pages()
+	assert.NoError(t, err)
+	assert.Greater(t, len(pages), 1)
+	assert.Equal(t, len(pages), placement.Page)
+}
+
+func TestWrap(t *testing.T) {
+	f, err := regularFont()
+	assert.NoError(t, err)
+
+	lines := wrap(&typeface{fonts: []*Font{f}, resources: []string{"F1"}}, "one two three\n\nfour "+strings.Repeat("x", 200), 11, 100)
+	assert.Equal(t, "one two three", lines[0])
+	assert.Equal(t, "", lines[1])
+	assert.Equal(t, "four", lines[2])
+	for _, line := range lines {
+		assert.LessOrEqual(t, f.Width(line, 11), 100.0)
+	}
+	assert.Equal(t, strings.Repeat("x", 200), strings.Join(lines[3:], ""))
+}
+
+func TestRender_RightToLeft(t *testing.T) {
+	fontFile, err := ReadFontFile("/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf")
+	if err != nil {
+		t.Skipf("DejaVu Sans is not installed: %v", err)
+	}
+	assert.Equal(t, "DejaVuSans", fontFile.Name)
+
+	doc := testDocument()
+	doc.Title = "اتفاقية الخدمة"
+	doc.Sections = []Section{
+		{Text: "סעיף 1. השירות ניתן כמתואר."},
+		{Text: "أوافق على تلقي رسائل البريد الإلكتروني", Consent: true, Granted: true},
+	}
+	doc.Labels = Labels{Granted: "ممنوحة", NotGranted: "غير ممنوحة", Signature: "التوقيع", Signer: "الموقّع", Date: "التاريخ"}
+	doc.RightToLeft = true
+	doc.Fonts = []FontFile{fontFile}
+
+	data, placement, err := Render(doc)
+	assert.NoError(t, err)
+	checkXref(t, data)
+	assert.Equal(t, pageWidth-margin-signatureWidth, placement.X)
+
+	r, err := Open(data)
+	assert.NoError(t, err)
+	pages, err := r.Pages()
+	assert.NoError(t, err)
+	page, err := r.Dict(pages[0])
+	assert.NoError(t, err)
+	resources, err := r.Dict(page["Resources"])
+	assert.NoError(t, err)
+	fonts, err := r.Dict(resources["Font"])
+	assert.NoError(t, err)
+	font, err := r.Dict(fonts["F3"])
+	assert.NoError(t, err)
+	assert.Equal(t, Name("DejaVuSans"), font["BaseFont"])
+
+	// Arabic is set in its joined forms, such as the lam-alef ligature of the consent
+	toUnicode, err := r.Resolve(font["ToUnicode"])
+	assert.NoError(t, err)
+	zr, err := zlib.NewReader(bytes.NewReader(toUnicode.(Stream).Data))
+	assert.NoError(t, err)
+	cmap, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Contains(t, string(cmap), "<FEF9>")
+	assert.NotContains(t, string(cmap), "<0627>")
+
+	// The tick box is on the right
+	contents, err := r.Resolve(page["Contents"])
+	assert.NoError(t, err)
+	zr, err = zlib.NewReader(bytes.NewReader(contents.(Stream).Data))
+	assert.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), formatNumber(pageWidth-margin-9)+" ")
+}
+
+func TestRender_UnusedFonts(t *testing.T) {
+	fontFile, err := ReadFontFile("/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf")
+	if err != nil {
+		t.Skipf("DejaVu Sans is not installed: %v", err)
+	}
+	doc := testDocument()
+	doc.Fonts = []FontFile{fontFile}
+
+	data, _, err := Render(doc)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "DejaVuSans")
+}
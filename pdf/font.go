@@ -0,0 +1,235 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Font is a TrueType font embedded as a composite font with two-byte glyph IDs, so text in
+// any script the font covers can be written. Glyphs are recorded as they are used, for the
+// widths and the ToUnicode map that lets readers copy and search the text.
+type Font struct {
+	name string
+	data []byte
+	sfnt *sfnt.Font
+	buf  sfnt.Buffer
+	ppem fixed.Int26_6
+
+	advances map[sfnt.GlyphIndex]int
+	used     map[sfnt.GlyphIndex]rune
+}
+
+// NewFont parses TrueType font data for embedding under the given PostScript name
+func NewFont(name string, data []byte) (*Font, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing font %s: %v", name, err)
+	}
+	return &Font{
+		name:     name,
+		data:     data,
+		sfnt:     f,
+		ppem:     fixed.I(int(f.UnitsPerEm())),
+		advances: map[sfnt.GlyphIndex]int{},
+		used:     map[sfnt.GlyphIndex]rune{},
+	}, nil
+}
+
+// FontFile is TrueType font data with the PostScript name it is embedded under
+type FontFile struct {
+	Name string
+	Data []byte
+}
+
+// ReadFontFile reads a TrueType font from a file, such as one of the DejaVu or Noto fonts, for
+// the characters the Go fonts documents are set in have no glyphs for
+func ReadFontFile(path string) (FontFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FontFile{}, fmt.Errorf("error reading font: %v", err)
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return FontFile{}, fmt.Errorf("error parsing font %s: %v", path, err)
+	}
+	name, err := f.Name(nil, sfnt.NameIDPostScript)
+	if err != nil || name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return FontFile{Name: name, Data: data}, nil
+}
+
+// regularFont and boldFont return the Go fonts documents are set in
+func regularFont() (*Font, error) {
+	return NewFont("GoRegular", goregular.TTF)
+}
+
+func boldFont() (*Font, error) {
+	return NewFont("GoBold", gobold.TTF)
+}
+
+// glyph returns the glyph for a rune and its advance in thousandths of the font size
+func (f *Font) glyph(r rune) (sfnt.GlyphIndex, int) {
+	index, err := f.sfnt.GlyphIndex(&f.buf, r)
+	if err != nil {
+		index = 0
+	}
+	if advance, ok := f.advances[index]; ok {
+		return index, advance
+	}
+	units, err := f.sfnt.GlyphAdvance(&f.buf, index, f.ppem, font.HintingNone)
+	advance := 0
+	if err == nil {
+		advance = int(units) * 1000 / int(f.ppem)
+	}
+	f.advances[index] = advance
+	return index, advance
+}
+
+// has reports whether the font has a glyph for a rune
+func (f *Font) has(r rune) bool {
+	index, err := f.sfnt.GlyphIndex(&f.buf, r)
+	return err == nil && index != 0
+}
+
+// Width returns the width of text set at size points
+func (f *Font) Width(text string, size float64) float64 {
+	total := 0
+	for _, r := range text {
+		_, advance := f.glyph(r)
+		total += advance
+	}
+	return float64(total) * size / 1000
+}
+
+// Encode encodes text as glyph IDs for a content stream show-text operator
+func (f *Font) Encode(text string) HexString {
+	encoded := make([]byte, 0, 2*len(text))
+	for _, r := range text {
+		index, _ := f.glyph(r)
+		if index != 0 {
+			if _, ok := f.used[index]; !ok {
+				f.used[index] = r
+			}
+		}
+		encoded = append(encoded, byte(index>>8), byte(index))
+	}
+	return HexString(encoded)
+}
+
+// write adds the font's objects to w and returns the reference of the font dictionary
+func (f *Font) write(w adder) (Ref, error) {
+	glyphs := make([]int, 0, len(f.used))
+	for index := range f.used {
+		glyphs = append(glyphs, int(index))
+	}
+	sort.Ints(glyphs)
+
+	fontFile, err := flateStream(Dict{"Length1": len(f.data)}, f.data)
+	if err != nil {
+		return Ref{}, err
+	}
+	metrics, err := f.sfnt.Metrics(&f.buf, f.ppem, font.HintingNone)
+	if err != nil {
+		return Ref{}, fmt.Errorf("error reading font metrics: %v", err)
+	}
+	bounds, err := f.sfnt.Bounds(&f.buf, f.ppem, font.HintingNone)
+	if err != nil {
+		return Ref{}, fmt.Errorf("error reading font bounds: %v", err)
+	}
+	scale := func(v fixed.Int26_6) int { return int(v) * 1000 / int(f.ppem) }
+
+	descriptor := w.Add(Dict{
+		"Type":     Name("FontDescriptor"),
+		"FontName": Name(f.name),
+		// Nonsymbolic
+		"Flags": 32,
+		// sfnt bounds grow downwards, PDF bounding boxes upwards
+		"FontBBox":    Array{scale(bounds.Min.X), -scale(bounds.Max.Y), scale(bounds.Max.X), -scale(bounds.Min.Y)},
+		"ItalicAngle": 0,
+		"Ascent":      scale(metrics.Ascent),
+		"Descent":     -scale(metrics.Descent),
+		"CapHeight":   scale(metrics.CapHeight),
+		"StemV":       80,
+		"FontFile2":   w.Add(fontFile),
+	})
+
+	widths := Array{}
+	for _, index := range glyphs {
+		widths = append(widths, index, Array{f.advances[sfnt.GlyphIndex(index)]})
+	}
+	_, notdef := f.glyph(0)
+	descendant := w.Add(Dict{
+		"Type":           Name("Font"),
+		"Subtype":        Name("CIDFontType2"),
+		"BaseFont":       Name(f.name),
+		"CIDSystemInfo":  Dict{"Registry": String("Adobe"), "Ordering": String("Identity"), "Supplement": 0},
+		"FontDescriptor": descriptor,
+		"DW":             notdef,
+		"W":              widths,
+		"CIDToGIDMap":    Name("Identity"),
+	})
+
+	toUnicode, err := flateStream(Dict{}, f.toUnicode(glyphs))
+	if err != nil {
+		return Ref{}, err
+	}
+	return w.Add(Dict{
+		"Type":            Name("Font"),
+		"Subtype":         Name("Type0"),
+		"BaseFont":        Name(f.name),
+		"Encoding":        Name("Identity-H"),
+		"DescendantFonts": Array{descendant},
+		"ToUnicode":       w.Add(toUnicode),
+	}), nil
+}
+
+// toUnicode writes the CMap mapping the used glyph IDs back to text
+func (f *Font) toUnicode(glyphs []int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	buf.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	buf.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	buf.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// A bfchar block holds at most 100 entries
+	for start := 0; start < len(glyphs); start += 100 {
+		end := min(start+100, len(glyphs))
+		fmt.Fprintf(&buf, "%d beginbfchar\n", end-start)
+		for _, index := range glyphs[start:end] {
+			fmt.Fprintf(&buf, "<%04X> <", index)
+			for _, unit := range utf16.Encode([]rune{f.used[sfnt.GlyphIndex(index)]}) {
+				fmt.Fprintf(&buf, "%04X", unit)
+			}
+			buf.WriteString(">\n")
+		}
+		buf.WriteString("endbfchar\n")
+	}
+	buf.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return buf.Bytes()
+}
+
+// flateStream compresses data into a stream with the given dictionary
+func flateStream(dict Dict, data []byte) (Stream, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return Stream{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return Stream{}, err
+	}
+	dict["Filter"] = Name("FlateDecode")
+	return Stream{Dict: dict, Data: buf.Bytes()}, nil
+}
@@ -0,0 +1,170 @@
+// Package pdf writes the signed document as a PDF and signs PDFs with PAdES baseline
+// signatures. It covers the subset of PDF this service produces and reads: classic cross
+// reference tables, dictionaries, arrays, strings, names, numbers and Flate streams.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Name is a PDF name object, written as /Name
+type Name string
+
+// String is a PDF string object, written as a literal string
+type String string
+
+// HexString is a PDF string object written in hexadecimal, as used for binary values
+type HexString []byte
+
+// Ref is an indirect object reference
+type Ref struct {
+	Num int
+	Gen int
+}
+
+// Array is a PDF array
+type Array []any
+
+// Dict is a PDF dictionary
+type Dict map[Name]any
+
+// Stream is a PDF stream. Length is set when it is written.
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// Raw is written as is, for content that is already PDF syntax
+type Raw string
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%d %d R", r.Num, r.Gen)
+}
+
+// writeObject serialises a PDF object. Dictionary keys are written in sorted order so the
+// same objects always produce the same bytes.
+func writeObject(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		buf.WriteString(formatNumber(v))
+	case Name:
+		writeName(buf, v)
+	case String:
+		writeString(buf, string(v))
+	case HexString:
+		fmt.Fprintf(buf, "<%X>", []byte(v))
+	case Ref:
+		buf.WriteString(v.String())
+	case Raw:
+		buf.WriteString(string(v))
+	case Array:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writeObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, string(key))
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, key := range keys {
+			writeName(buf, Name(key))
+			buf.WriteByte(' ')
+			writeObject(buf, v[Name(key)])
+		}
+		buf.WriteString(">>")
+	case Stream:
+		dict := Dict{}
+		for key, value := range v.Dict {
+			dict[key] = value
+		}
+		dict["Length"] = len(v.Data)
+		writeObject(buf, dict)
+		buf.WriteString("\nstream\n")
+		buf.Write(v.Data)
+		buf.WriteString("\nendstream")
+	default:
+		panic(fmt.Sprintf("pdf: cannot write %T", v))
+	}
+}
+
+// formatNumber writes a real number with at most four decimals and no exponent
+func formatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 4, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+func writeName(buf *bytes.Buffer, name Name) {
+	buf.WriteByte('/')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < '!' || c > '~' || strings.IndexByte("#()<>[]{}/%", c) >= 0 {
+			fmt.Fprintf(buf, "#%02X", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('(')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', ')', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte(')')
+}
+
+// TextString encodes text for use outside content streams, such as the signer's name in a
+// signature dictionary: ASCII as is, anything else as UTF-16BE with a byte order mark
+func TextString(s string) any {
+	ascii := true
+	for _, r := range s {
+		ascii = ascii && r < 0x80
+	}
+	if ascii {
+		return String(s)
+	}
+	encoded := []byte{0xfe, 0xff}
+	for _, r := range s {
+		if r > 0xffff {
+			r -= 0x10000
+			encoded = append(encoded, byte(0xd8|(r>>18)&0x03), byte(r>>10), byte(0xdc|(r>>8)&0x03), byte(r))
+			continue
+		}
+		encoded = append(encoded, byte(r>>8), byte(r))
+	}
+	return HexString(encoded)
+}
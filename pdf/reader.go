@@ -0,0 +1,883 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var (
+	// ErrMalformed is returned for PDFs that cannot be read
+	ErrMalformed = errors.New("malformed PDF")
+	// ErrEncrypted is returned for encrypted PDFs, which cannot be updated without the password
+	ErrEncrypted = errors.New("encrypted PDFs are not supported")
+)
+
+// maxObjectDepth bounds how many indirect objects may be read while reading another, such as
+// the length of a stream or the object stream holding an object
+const maxObjectDepth = 32
+
+// maxNesting bounds how deeply arrays and dictionaries may be nested
+const maxNesting = 256
+
+// maxDecodedStreamBytes bounds the decoded size of a stream
+const maxDecodedStreamBytes = 64 << 20
+
+// xrefEntry locates an object: at an offset in the file, or at an index in an object stream
+type xrefEntry struct {
+	offset int
+	stream int
+	index  int
+	free   bool
+}
+
+// Reader gives access to the objects of an existing PDF through its cross reference tables
+// or streams
+type Reader struct {
+	data       []byte
+	entries    map[int]xrefEntry
+	trailer    Dict
+	startxref  int
+	xrefStream bool
+	objStreams map[int]*objectStream
+	// reading holds the objects being read, so that an object whose reading needs itself is
+	// an error instead of endless recursion
+	reading map[int]bool
+}
+
+// objectStream is a decoded object stream with the offsets of the objects it holds
+type objectStream struct {
+	data    []byte
+	offsets []int
+}
+
+// Open reads the cross reference sections of a PDF, following /Prev through incremental updates
+func Open(data []byte) (*Reader, error) {
+	r := &Reader{data: data, entries: map[int]xrefEntry{}, objStreams: map[int]*objectStream{}, reading: map[int]bool{}}
+	tail := data
+	if len(tail) > 1024 {
+		tail = tail[len(tail)-1024:]
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no startxref", ErrMalformed)
+	}
+	p := &parser{data: tail, pos: i + len("startxref")}
+	offset, ok := p.next().(int)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid startxref", ErrMalformed)
+	}
+	r.startxref = offset
+
+	for seen := map[int]bool{}; !seen[offset]; {
+		seen[offset] = true
+		trailer, isStream, err := r.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if r.trailer == nil {
+			r.trailer = trailer
+			r.xrefStream = isStream
+		}
+		// Hybrid files list compressed objects in a stream next to the classic table
+		if stm, ok := trailer["XRefStm"].(int); ok && !seen[stm] {
+			seen[stm] = true
+			if _, _, err := r.readXref(stm); err != nil {
+				return nil, err
+			}
+		}
+		prev, ok := trailer["Prev"].(int)
+		if !ok {
+			break
+		}
+		offset = prev
+	}
+	if _, ok := r.trailer["Root"].(Ref); !ok {
+		return nil, fmt.Errorf("%w: trailer has no /Root", ErrMalformed)
+	}
+	if r.trailer["Encrypt"] != nil {
+		return nil, ErrEncrypted
+	}
+	return r, nil
+}
+
+// readXref reads the cross reference section at offset into r.entries, keeping entries already
+// read from newer sections, and returns its trailer and whether it is a cross reference stream
+func (r *Reader) readXref(offset int) (Dict, bool, error) {
+	if offset < 0 || offset >= len(r.data) {
+		return nil, false, fmt.Errorf("%w: cross reference offset %d out of range", ErrMalformed, offset)
+	}
+	p := &parser{data: r.data, pos: offset, reader: r}
+	token := p.next()
+	if kw, _ := token.(keyword); kw != "xref" {
+		p.pos = offset
+		trailer, err := r.readXrefStream(p)
+		return trailer, true, err
+	}
+	for {
+		token := p.next()
+		if kw, ok := token.(keyword); ok && kw == "trailer" {
+			break
+		}
+		first, ok1 := token.(int)
+		count, ok2 := p.next().(int)
+		if !ok1 || !ok2 {
+			return nil, false, fmt.Errorf("%w: invalid cross reference subsection", ErrMalformed)
+		}
+		for i := 0; i < count; i++ {
+			entryOffset, ok1 := p.next().(int)
+			_, ok2 := p.next().(int)
+			kind, ok3 := p.next().(keyword)
+			if !ok1 || !ok2 || !ok3 {
+				return nil, false, fmt.Errorf("%w: invalid cross reference entry", ErrMalformed)
+			}
+			r.addEntry(first+i, xrefEntry{offset: entryOffset, free: kind != "n"})
+		}
+	}
+	trailer, ok := p.object().(Dict)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: invalid trailer", ErrMalformed)
+	}
+	return trailer, false, nil
+}
+
+// readXrefStream reads a cross reference stream object at the parser's position
+func (r *Reader) readXrefStream(p *parser) (Dict, error) {
+	_, ok1 := p.next().(int)
+	_, ok2 := p.next().(int)
+	kw, ok3 := p.next().(keyword)
+	if !ok1 || !ok2 || !ok3 || kw != "obj" {
+		return nil, fmt.Errorf("%w: no cross reference table or stream at offset %d", ErrMalformed, p.pos)
+	}
+	stream, ok := p.object().(Stream)
+	if !ok || p.err != nil || stream.Dict["Type"] != Name("XRef") {
+		return nil, fmt.Errorf("%w: invalid cross reference stream", ErrMalformed)
+	}
+	data, err := DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	widthArray, _ := stream.Dict["W"].(Array)
+	if len(widthArray) != 3 {
+		return nil, fmt.Errorf("%w: invalid cross reference stream widths", ErrMalformed)
+	}
+	widths := make([]int, 3)
+	rowSize := 0
+	for i, v := range widthArray {
+		widths[i], _ = v.(int)
+		if widths[i] < 0 || widths[i] > 8 {
+			return nil, fmt.Errorf("%w: invalid cross reference stream widths", ErrMalformed)
+		}
+		rowSize += widths[i]
+	}
+	if rowSize == 0 {
+		return nil, fmt.Errorf("%w: invalid cross reference stream widths", ErrMalformed)
+	}
+	index, _ := stream.Dict["Index"].(Array)
+	if index == nil {
+		size, _ := stream.Dict["Size"].(int)
+		index = Array{0, size}
+	}
+
+	for i := 0; i+1 < len(index); i += 2 {
+		first, ok1 := index[i].(int)
+		count, ok2 := index[i+1].(int)
+		if !ok1 || !ok2 || count < 0 {
+			return nil, fmt.Errorf("%w: invalid cross reference stream index", ErrMalformed)
+		}
+		for j := 0; j < count; j++ {
+			if len(data) < rowSize {
+				return nil, fmt.Errorf("%w: truncated cross reference stream", ErrMalformed)
+			}
+			fields := make([]int, 3)
+			pos := 0
+			for k, width := range widths {
+				for _, b := range data[pos : pos+width] {
+					fields[k] = fields[k]<<8 | int(b)
+				}
+				pos += width
+			}
+			data = data[rowSize:]
+			// The type defaults to 1 when its width is zero
+			if widths[0] == 0 {
+				fields[0] = 1
+			}
+			switch fields[0] {
+			case 0:
+				r.addEntry(first+j, xrefEntry{free: true})
+			case 1:
+				r.addEntry(first+j, xrefEntry{offset: fields[1]})
+			case 2:
+				r.addEntry(first+j, xrefEntry{stream: fields[1], index: fields[2]})
+			}
+		}
+	}
+	return stream.Dict, nil
+}
+
+// addEntry records where an object is, unless a newer section already did
+func (r *Reader) addEntry(num int, entry xrefEntry) {
+	if _, exists := r.entries[num]; !exists {
+		r.entries[num] = entry
+	}
+}
+
+// Trailer returns the trailer dictionary of the newest revision
+func (r *Reader) Trailer() Dict {
+	return r.trailer
+}
+
+// StartXref returns the offset of the newest cross reference section
+func (r *Reader) StartXref() int {
+	return r.startxref
+}
+
+// Size returns the number of object numbers in use, from the newest trailer
+func (r *Reader) Size() int {
+	size, _ := r.trailer["Size"].(int)
+	return size
+}
+
+// Object reads an indirect object
+func (r *Reader) Object(ref Ref) (any, error) {
+	entry, ok := r.entries[ref.Num]
+	if !ok || entry.free {
+		return nil, nil
+	}
+	if r.reading[ref.Num] || len(r.reading) >= maxObjectDepth {
+		return nil, fmt.Errorf("%w: object %d refers to itself or is nested too deeply", ErrMalformed, ref.Num)
+	}
+	r.reading[ref.Num] = true
+	defer delete(r.reading, ref.Num)
+	if entry.stream != 0 {
+		return r.compressedObject(ref, entry)
+	}
+	offset := entry.offset
+	if offset < 0 || offset >= len(r.data) {
+		return nil, fmt.Errorf("%w: object %d offset out of range", ErrMalformed, ref.Num)
+	}
+	p := &parser{data: r.data, pos: offset, reader: r}
+	num, ok1 := p.next().(int)
+	_, ok2 := p.next().(int)
+	kw, ok3 := p.next().(keyword)
+	if !ok1 || !ok2 || !ok3 || num != ref.Num || kw != "obj" {
+		return nil, fmt.Errorf("%w: object %d not found at offset %d", ErrMalformed, ref.Num, offset)
+	}
+	object := p.object()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return object, nil
+}
+
+// compressedObject reads an object stored in an object stream
+func (r *Reader) compressedObject(ref Ref, entry xrefEntry) (any, error) {
+	objStream, ok := r.objStreams[entry.stream]
+	if !ok {
+		object, err := r.Object(Ref{Num: entry.stream})
+		if err != nil {
+			return nil, err
+		}
+		stream, ok := object.(Stream)
+		if !ok || stream.Dict["Type"] != Name("ObjStm") {
+			return nil, fmt.Errorf("%w: object %d is not an object stream", ErrMalformed, entry.stream)
+		}
+		data, err := DecodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := stream.Dict["N"].(int)
+		first, _ := stream.Dict["First"].(int)
+		if first < 0 || first > len(data) {
+			return nil, fmt.Errorf("%w: invalid object stream %d", ErrMalformed, entry.stream)
+		}
+		objStream = &objectStream{data: data[first:]}
+		header := &parser{data: data[:first]}
+		for i := 0; i < n; i++ {
+			_, ok1 := header.next().(int)
+			offset, ok2 := header.next().(int)
+			if !ok1 || !ok2 || offset < 0 {
+				return nil, fmt.Errorf("%w: invalid object stream %d header", ErrMalformed, entry.stream)
+			}
+			objStream.offsets = append(objStream.offsets, offset)
+		}
+		r.objStreams[entry.stream] = objStream
+	}
+	if entry.index < 0 || entry.index >= len(objStream.offsets) || objStream.offsets[entry.index] > len(objStream.data) {
+		return nil, fmt.Errorf("%w: object %d not found in object stream %d", ErrMalformed, ref.Num, entry.stream)
+	}
+	p := &parser{data: objStream.data, pos: objStream.offsets[entry.index], reader: r}
+	object := p.object()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return object, nil
+}
+
+// Resolve follows a reference, returning other objects unchanged
+func (r *Reader) Resolve(object any) (any, error) {
+	for i := 0; i < 32; i++ {
+		ref, ok := object.(Ref)
+		if !ok {
+			return object, nil
+		}
+		var err error
+		if object, err = r.Object(ref); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: reference loop", ErrMalformed)
+}
+
+// Dict resolves an object that must be a dictionary
+func (r *Reader) Dict(object any) (Dict, error) {
+	resolved, err := r.Resolve(object)
+	if err != nil {
+		return nil, err
+	}
+	switch v := resolved.(type) {
+	case Dict:
+		return v, nil
+	case Stream:
+		return v.Dict, nil
+	}
+	return nil, fmt.Errorf("%w: expected a dictionary, found %T", ErrMalformed, resolved)
+}
+
+// PageAttribute returns an attribute of a page, inherited from the page tree if the page does
+// not set it, such as /Resources, /MediaBox or /Rotate
+func (r *Reader) PageAttribute(page Ref, key Name) (any, error) {
+	node := any(page)
+	for depth := 0; depth < 32 && node != nil; depth++ {
+		dict, err := r.Dict(node)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := dict[key]; ok {
+			return r.Resolve(value)
+		}
+		node = dict["Parent"]
+	}
+	return nil, nil
+}
+
+// MediaBox returns the page boundaries as [llx lly urx ury] in points
+func (r *Reader) MediaBox(page Ref) ([4]float64, error) {
+	value, err := r.PageAttribute(page, "MediaBox")
+	if err != nil {
+		return [4]float64{}, err
+	}
+	array, _ := value.(Array)
+	if len(array) != 4 {
+		return [4]float64{}, fmt.Errorf("%w: page has no valid /MediaBox", ErrMalformed)
+	}
+	var box [4]float64
+	for i, v := range array {
+		switch v := v.(type) {
+		case int:
+			box[i] = float64(v)
+		case float64:
+			box[i] = v
+		default:
+			return [4]float64{}, fmt.Errorf("%w: page has no valid /MediaBox", ErrMalformed)
+		}
+	}
+	return box, nil
+}
+
+// Pages returns the references of the pages in order
+func (r *Reader) Pages() ([]Ref, error) {
+	catalog, err := r.Dict(r.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	root, ok := catalog["Pages"].(Ref)
+	if !ok {
+		return nil, fmt.Errorf("%w: catalog has no /Pages", ErrMalformed)
+	}
+	var pages []Ref
+	seen := map[Ref]bool{}
+	var walk func(ref Ref) error
+	walk = func(ref Ref) error {
+		if seen[ref] {
+			return fmt.Errorf("%w: page tree loop", ErrMalformed)
+		}
+		seen[ref] = true
+		node, err := r.Dict(ref)
+		if err != nil {
+			return err
+		}
+		if node["Type"] == Name("Page") {
+			pages = append(pages, ref)
+			return nil
+		}
+		kids, err := r.Resolve(node["Kids"])
+		if err != nil {
+			return err
+		}
+		kidArray, _ := kids.(Array)
+		for _, kid := range kidArray {
+			kidRef, ok := kid.(Ref)
+			if !ok {
+				return fmt.Errorf("%w: page tree kid is not a reference", ErrMalformed)
+			}
+			if err := walk(kidRef); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// PageBoxes opens a PDF and returns the media box of every page, as [llx lly urx ury] in points
+func PageBoxes(pdf []byte) ([][4]float64, error) {
+	r, err := Open(pdf)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := r.Pages()
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w: document has no pages", ErrMalformed)
+	}
+	boxes := make([][4]float64, len(pages))
+	for i, page := range pages {
+		box, err := r.MediaBox(page)
+		if err != nil {
+			return nil, err
+		}
+		// Corners may be given in any order
+		boxes[i] = [4]float64{min(box[0], box[2]), min(box[1], box[3]), max(box[0], box[2]), max(box[1], box[3])}
+	}
+	return boxes, nil
+}
+
+// keyword is a bare PDF token such as obj, R, stream or true
+type keyword string
+
+// delimiter is one of the PDF delimiters that start or end a composite object
+type delimiter string
+
+// parser reads PDF objects from data starting at pos. A reader is needed to resolve indirect
+// stream lengths.
+type parser struct {
+	data   []byte
+	pos    int
+	reader *Reader
+	err    error
+	// depth is the number of arrays and dictionaries being read
+	depth int
+}
+
+func (p *parser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s at offset %d", ErrMalformed, fmt.Sprintf(format, args...), p.pos)
+	}
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isWhitespace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// next reads one token: a number, name, string, delimiter or keyword
+func (p *parser) next() any {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		p.fail("unexpected end of data")
+		return nil
+	}
+	c := p.data[p.pos]
+	switch {
+	case c == '/':
+		p.pos++
+		var name []byte
+		for p.pos < len(p.data) && !isWhitespace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+			if p.data[p.pos] == '#' && p.pos+2 < len(p.data) {
+				if v, err := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8); err == nil {
+					name = append(name, byte(v))
+					p.pos += 3
+					continue
+				}
+			}
+			name = append(name, p.data[p.pos])
+			p.pos++
+		}
+		return Name(name)
+	case c == '(':
+		return p.literalString()
+	case c == '<':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '<' {
+			p.pos += 2
+			return delimiter("<<")
+		}
+		return p.hexString()
+	case c == '>':
+		if p.pos+1 < len(p.data) && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return delimiter(">>")
+		}
+		p.fail("unexpected >")
+		p.pos++
+		return nil
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		p.pos++
+		return delimiter(c)
+	}
+
+	start := p.pos
+	for p.pos < len(p.data) && !isWhitespace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	token := string(p.data[start:p.pos])
+	if token == "" {
+		p.fail("unexpected %q", c)
+		p.pos++
+		return nil
+	}
+	if n, err := strconv.Atoi(token); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(token, 64); err == nil && (token[0] == '.' || token[0] == '-' || token[0] == '+' || (token[0] >= '0' && token[0] <= '9')) {
+		return f
+	}
+	return keyword(token)
+}
+
+func (p *parser) literalString() String {
+	p.pos++
+	var s []byte
+	for depth := 1; p.pos < len(p.data); {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return String(s)
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				break
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	p.fail("unterminated string")
+	return String(s)
+}
+
+func (p *parser) hexString() HexString {
+	p.pos++
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if c := p.data[p.pos]; !isWhitespace(c) {
+			digits = append(digits, c)
+		}
+		p.pos++
+	}
+	p.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			p.fail("invalid hex string")
+			return nil
+		}
+		out[i] = byte(v)
+	}
+	return HexString(out)
+}
+
+// object reads a complete object, including references and streams
+func (p *parser) object() any {
+	return p.value(p.next())
+}
+
+func (p *parser) value(token any) any {
+	switch t := token.(type) {
+	case int:
+		// An integer may start a reference "num gen R"
+		save := p.pos
+		if gen, ok := p.next().(int); ok {
+			if kw, ok := p.next().(keyword); ok && kw == "R" {
+				return Ref{Num: t, Gen: gen}
+			}
+		}
+		p.pos = save
+		return t
+	case delimiter:
+		if t == "[" || t == "<<" {
+			if p.depth >= maxNesting {
+				p.fail("objects nested too deeply")
+				return nil
+			}
+			p.depth++
+			defer func() { p.depth-- }()
+		}
+		switch t {
+		case "[":
+			array := Array{}
+			for p.err == nil {
+				next := p.next()
+				if d, ok := next.(delimiter); ok && d == "]" {
+					return array
+				}
+				array = append(array, p.value(next))
+			}
+			return array
+		case "<<":
+			dict := Dict{}
+			for p.err == nil {
+				next := p.next()
+				if d, ok := next.(delimiter); ok && d == ">>" {
+					break
+				}
+				key, ok := next.(Name)
+				if !ok {
+					p.fail("dictionary key is not a name")
+					return dict
+				}
+				dict[key] = p.object()
+			}
+			return p.stream(dict)
+		}
+		p.fail("unexpected %s", t)
+		return nil
+	case keyword:
+		switch t {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		p.fail("unexpected keyword %s", t)
+		return nil
+	}
+	return token
+}
+
+// stream reads the data of a stream following its dictionary, or returns the dictionary
+func (p *parser) stream(dict Dict) any {
+	if p.err != nil {
+		return dict
+	}
+	save := p.pos
+	if kw, ok := p.next().(keyword); !ok || kw != "stream" {
+		// A dictionary at the end of the data is not an error
+		p.pos = save
+		p.err = nil
+		return dict
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	length, ok := dict["Length"].(int)
+	if ref, isRef := dict["Length"].(Ref); isRef && p.reader != nil {
+		resolved, _ := p.reader.Object(ref)
+		length, ok = resolved.(int)
+	}
+	if !ok || length < 0 || length > len(p.data)-p.pos {
+		p.fail("invalid stream length")
+		return Stream{Dict: dict}
+	}
+	data := p.data[p.pos : p.pos+length]
+	p.pos += length
+	return Stream{Dict: dict, Data: data}
+}
+
+// DecodeStream returns the decoded data of a stream. Flate with PNG predictors is supported,
+// which covers the cross reference, object and content streams written by common tools.
+func DecodeStream(s Stream) ([]byte, error) {
+	var filters Array
+	switch f := s.Dict["Filter"].(type) {
+	case nil:
+		return s.Data, nil
+	case Name:
+		filters = Array{f}
+	case Array:
+		filters = f
+	}
+	var params Array
+	switch p := s.Dict["DecodeParms"].(type) {
+	case Dict:
+		params = Array{p}
+	case Array:
+		params = p
+	}
+
+	data := s.Data
+	for i, filter := range filters {
+		if filter != Name("FlateDecode") {
+			return nil, fmt.Errorf("%w: unsupported stream filter %v", ErrMalformed, filter)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		// Truncated streams are common; keep what could be decoded
+		decoded, err := io.ReadAll(io.LimitReader(zr, maxDecodedStreamBytes+1))
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if len(decoded) > maxDecodedStreamBytes {
+			return nil, fmt.Errorf("%w: stream decodes to more than %d MB", ErrMalformed, maxDecodedStreamBytes>>20)
+		}
+		data = decoded
+		if i < len(params) {
+			if param, ok := params[i].(Dict); ok {
+				if data, err = unpredict(data, param); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return data, nil
+}
+
+// unpredict reverses a PNG predictor applied before Flate compression
+func unpredict(data []byte, params Dict) ([]byte, error) {
+	predictor, _ := params["Predictor"].(int)
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("%w: unsupported predictor %d", ErrMalformed, predictor)
+		}
+		return data, nil
+	}
+	columns, ok := params["Columns"].(int)
+	if !ok {
+		columns = 1
+	}
+	colors, ok := params["Colors"].(int)
+	if !ok {
+		colors = 1
+	}
+	bits, ok := params["BitsPerComponent"].(int)
+	if !ok {
+		bits = 8
+	}
+	bpp := max(1, colors*bits/8)
+	rowSize := (columns*colors*bits + 7) / 8
+	if rowSize <= 0 {
+		return nil, fmt.Errorf("%w: invalid predictor columns", ErrMalformed)
+	}
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowSize)
+	for len(data) >= rowSize+1 {
+		kind, row := data[0], append([]byte{}, data[1:rowSize+1]...)
+		data = data[rowSize+1:]
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up = prev[i]
+			switch kind {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("%w: invalid PNG predictor %d", ErrMalformed, kind)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
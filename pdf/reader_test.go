@@ -0,0 +1,209 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// xrefStreamPDF builds a PDF 1.5 file the way common tools write them: the catalog and page tree
+// in a compressed object stream and a cross reference stream with a PNG predictor
+func xrefStreamPDF(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n")
+	offsets := map[int]int{}
+
+	object := func(num int, body string) {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", num, body)
+	}
+	compress := func(data []byte) []byte {
+		var out bytes.Buffer
+		zw := zlib.NewWriter(&out)
+		zw.Write(data)
+		zw.Close()
+		return out.Bytes()
+	}
+
+	object(3, "<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>")
+	content := compress([]byte("BT /F1 12 Tf 72 720 Td (Contract) Tj ET"))
+	object(4, fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(content), content))
+
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	pages := "<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] /Resources << /Font << >> >> >>"
+	header := fmt.Sprintf("1 0 2 %d ", len(catalog)+1)
+	objStm := compress([]byte(header + catalog + " " + pages))
+	object(5, fmt.Sprintf("<< /Type /ObjStm /N 2 /First %d /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(header), len(objStm), objStm))
+
+	// Rows of type (1 byte), offset or object stream (2 bytes) and generation or index (1 byte)
+	rows := [][]int{{0, 0, 255}, {2, 5, 0}, {2, 5, 1}, {1, offsets[3], 0}, {1, offsets[4], 0}, {1, offsets[5], 0}, {1, buf.Len(), 0}}
+	var raw []byte
+	prev := make([]byte, 4)
+	for _, row := range rows {
+		line := []byte{byte(row[0]), byte(row[1] >> 8), byte(row[1]), byte(row[2])}
+		// PNG Up predictor
+		raw = append(raw, 2)
+		for i := range line {
+			raw = append(raw, line[i]-prev[i])
+		}
+		prev = line
+	}
+	xref := compress(raw)
+	start := buf.Len()
+	fmt.Fprintf(&buf, "6 0 obj\n<< /Type /XRef /Size 7 /W [1 2 1] /Root 1 0 R /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 4 >> /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(xref), xref)
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", start)
+	return buf.Bytes()
+}
+
+func TestOpen_XrefStream(t *testing.T) {
+	data := xrefStreamPDF(t)
+	r, err := Open(data)
+	assert.NoError(t, err)
+	assert.True(t, r.xrefStream)
+
+	pages, err := r.Pages()
+	assert.NoError(t, err)
+	assert.Equal(t, []Ref{{Num: 3}}, pages)
+
+	// The media box is inherited from the page tree
+	box, err := r.MediaBox(pages[0])
+	assert.NoError(t, err)
+	assert.Equal(t, [4]float64{0, 0, 612, 792}, box)
+
+	page, err := r.Dict(pages[0])
+	assert.NoError(t, err)
+	contents, err := r.Resolve(page["Contents"])
+	assert.NoError(t, err)
+	decoded, err := DecodeStream(contents.(Stream))
+	assert.NoError(t, err)
+	assert.Equal(t, "BT /F1 12 Tf 72 720 Td (Contract) Tj ET", string(decoded))
+
+	boxes, err := PageBoxes(data)
+	assert.NoError(t, err)
+	assert.Equal(t, [][4]float64{{0, 0, 612, 792}}, boxes)
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		expectedError error
+	}{
+		{name: "Not a PDF", data: []byte("hello"), expectedError: ErrMalformed},
+		{name: "Bad startxref", data: []byte("%PDF-1.7\nstartxref\n999\n%%EOF\n"), expectedError: ErrMalformed},
+		{name: "Encrypted", data: []byte("%PDF-1.7\nxref\n0 1\n0000000000 65535 f\r\ntrailer\n<< /Root 1 0 R /Encrypt 2 0 R /Size 3 >>\nstartxref\n9\n%%EOF\n"), expectedError: ErrEncrypted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(tt.data)
+			assert.ErrorIs(t, err, tt.expectedError)
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	p := &parser{data: []byte(`<< /Type /Example /Name#20Space 1 /Ref 12 0 R /Array [1 -2.5 (a\(b\)\n) <48 69>] /Nested << /Bool true /Null null >> >>`)}
+	assert.Equal(t, Dict{
+		"Type":       Name("Example"),
+		"Name Space": 1,
+		"Ref":        Ref{Num: 12},
+		"Array":      Array{1, -2.5, String("a(b)\n"), HexString("Hi")},
+		"Nested":     Dict{"Bool": true, "Null": nil},
+	}, p.object())
+	assert.NoError(t, p.err)
+
+	// Objects written by writeObject read back the same
+	var buf bytes.Buffer
+	original := Dict{"Title": TextString("Zażółć"), "Rect": Array{0, 0, 10.5, 20}, "Name": Name("A B")}
+	writeObject(&buf, original)
+	p = &parser{data: buf.Bytes()}
+	assert.Equal(t, original, p.object())
+}
+
+// classicPDF builds a PDF with a cross reference table of the given objects, numbered from 1,
+// and the first as its catalog
+func classicPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	start := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f\r\n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n\r\n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Root 1 0 R /Size %d >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, start)
+	return buf.Bytes()
+}
+
+func TestPageBoxes_Malformed(t *testing.T) {
+	pages := "<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 612 792] >>"
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "Stream length refers to its own object",
+			data: classicPDF("<< /Type /Catalog /Pages 2 0 R >>", pages, "<< /Type /Page /Parent 2 0 R /Length 3 0 R >>\nstream\nabc\nendstream"),
+		},
+		{
+			name: "Stream length overflows",
+			data: classicPDF("<< /Type /Catalog /Pages 2 0 R >>", pages, "<< /Type /Page /Parent 2 0 R /Length 9223372036854775807 >>\nstream\nabc\nendstream"),
+		},
+		{
+			name: "Deeply nested arrays",
+			data: classicPDF("<< /Type /Catalog /Pages 2 0 R >>", pages, "<< /Type /Page /Parent 2 0 R /Annots "+strings.Repeat("[", 1000)+strings.Repeat("]", 1000)+" >>"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := PageBoxes(tt.data)
+			assert.ErrorIs(t, err, ErrMalformed)
+		})
+	}
+}
+
+func TestReader_CompressedObject_Malformed(t *testing.T) {
+	objStream := func(header, objects string) string {
+		return fmt.Sprintf("<< /Type /ObjStm /N 1 /First %d /Length %d >>\nstream\n%s%s\nendstream", len(header), len(header)+len(objects), header, objects)
+	}
+	tests := []struct {
+		name    string
+		objects []string
+		entry   xrefEntry
+	}{
+		{name: "Negative index", objects: []string{"null", objStream("1 0 ", "<< >>")}, entry: xrefEntry{stream: 2, index: -1}},
+		{name: "Negative offset", objects: []string{"null", objStream("1 -5 ", "<< >>")}, entry: xrefEntry{stream: 2}},
+		{name: "Object stream holding itself", objects: []string{"null"}, entry: xrefEntry{stream: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Open(classicPDF(tt.objects...))
+			assert.NoError(t, err)
+			r.entries[1] = tt.entry
+			_, err = r.Object(Ref{Num: 1})
+			assert.ErrorIs(t, err, ErrMalformed)
+		})
+	}
+}
+
+func TestDecodeStream_Limit(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(make([]byte, maxDecodedStreamBytes+1))
+	zw.Close()
+
+	_, err := DecodeStream(Stream{Dict: Dict{"Filter": Name("FlateDecode")}, Data: compressed.Bytes()})
+	assert.ErrorIs(t, err, ErrMalformed)
+}
@@ -0,0 +1,160 @@
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// arabicForms maps Arabic letters to their presentation forms: isolated, final, initial and
+// medial. Letters that only join the letter before them have no initial and medial forms, and
+// the hamza, which joins neither, has only an isolated form.
+var arabicForms = map[rune][4]rune{}
+
+// lamAlef maps the alef letters to the isolated form of their ligature with a preceding lam.
+// The final form follows it.
+var lamAlef = map[rune]rune{
+	0x0622: 0xFEF5,
+	0x0623: 0xFEF7,
+	0x0625: 0xFEF9,
+	0x0627: 0xFEFB,
+}
+
+func init() {
+	// The Arabic Presentation Forms-B block lists the forms of the basic letters in their order
+	next := rune(0xFE80)
+	for _, letter := range []struct {
+		r     rune
+		forms int
+	}{
+		{0x0621, 1}, {0x0622, 2}, {0x0623, 2}, {0x0624, 2}, {0x0625, 2}, {0x0626, 4}, {0x0627, 2},
+		{0x0628, 4}, {0x0629, 2}, {0x062A, 4}, {0x062B, 4}, {0x062C, 4}, {0x062D, 4}, {0x062E, 4},
+		{0x062F, 2}, {0x0630, 2}, {0x0631, 2}, {0x0632, 2}, {0x0633, 4}, {0x0634, 4}, {0x0635, 4},
+		{0x0636, 4}, {0x0637, 4}, {0x0638, 4}, {0x0639, 4}, {0x063A, 4}, {0x0641, 4}, {0x0642, 4},
+		{0x0643, 4}, {0x0644, 4}, {0x0645, 4}, {0x0646, 4}, {0x0647, 4}, {0x0648, 2}, {0x0649, 2},
+		{0x064A, 4},
+	} {
+		var forms [4]rune
+		for i := 0; i < letter.forms; i++ {
+			forms[i] = next + rune(i)
+		}
+		arabicForms[letter.r] = forms
+		next += rune(letter.forms)
+	}
+
+	// The letters Persian and Urdu add are in the Arabic Presentation Forms-A block
+	for _, letter := range []struct {
+		r, isolated rune
+		forms       int
+	}{
+		{0x067E, 0xFB56, 4}, {0x0686, 0xFB7A, 4}, {0x0698, 0xFB8A, 2},
+		{0x06A9, 0xFB8E, 4}, {0x06AF, 0xFB92, 4}, {0x06CC, 0xFBFC, 4},
+	} {
+		var forms [4]rune
+		for i := 0; i < letter.forms; i++ {
+			forms[i] = letter.isolated + rune(i)
+		}
+		arabicForms[letter.r] = forms
+	}
+}
+
+// How a character joins its neighbours in cursive scripts
+type joiningType int
+
+const (
+	nonJoining joiningType = iota
+	// rightJoining letters join the letter before them only
+	rightJoining
+	dualJoining
+	// joinCausing characters, the tatweel and the zero width joiner, join on both sides
+	joinCausing
+	// transparent characters, the vowel marks, are skipped when joining
+	transparent
+)
+
+func joining(r rune) joiningType {
+	if forms, ok := arabicForms[r]; ok {
+		switch {
+		case forms[2] != 0:
+			return dualJoining
+		case forms[1] != 0:
+			return rightJoining
+		}
+		return nonJoining
+	}
+	if r == 0x0640 || r == 0x200D {
+		return joinCausing
+	}
+	if unicode.Is(unicode.Mn, r) {
+		return transparent
+	}
+	return nonJoining
+}
+
+// shapeArabic replaces the Arabic letters of text with the presentation forms for their
+// position in a word, and lam followed by alef with their ligature, since fonts are embedded
+// without the tables that would let a PDF reader shape the text itself
+func shapeArabic(text string) string {
+	if !strings.ContainsFunc(text, func(r rune) bool { return unicode.Is(unicode.Arabic, r) }) {
+		return text
+	}
+	runes := []rune(text)
+	shaped := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		forms, ok := arabicForms[runes[i]]
+		if !ok {
+			shaped = append(shaped, runes[i])
+			continue
+		}
+		before := neighbour(runes, i, -1)
+		after := neighbour(runes, i, 1)
+		joinsBefore := before >= 0 && (joining(runes[before]) == dualJoining || joining(runes[before]) == joinCausing)
+
+		if ligature, ok := lamAlef[runeAt(runes, i+1)]; ok && runes[i] == 0x0644 {
+			if joinsBefore {
+				ligature++
+			}
+			shaped = append(shaped, ligature)
+			i++
+			continue
+		}
+
+		joinsAfter := false
+		if after >= 0 && joining(runes[i]) == dualJoining {
+			switch joining(runes[after]) {
+			case dualJoining, rightJoining, joinCausing:
+				joinsAfter = true
+			}
+		}
+		joinsBefore = joinsBefore && joining(runes[i]) != nonJoining
+
+		form := 0
+		switch {
+		case joinsBefore && joinsAfter:
+			form = 3
+		case joinsBefore:
+			form = 1
+		case joinsAfter:
+			form = 2
+		}
+		shaped = append(shaped, forms[form])
+	}
+	return string(shaped)
+}
+
+// neighbour returns the index of the nearest character before (step -1) or after (step 1)
+// index i that is not transparent, or -1 when there is none
+func neighbour(runes []rune, i, step int) int {
+	for j := i + step; j >= 0 && j < len(runes); j += step {
+		if joining(runes[j]) != transparent {
+			return j
+		}
+	}
+	return -1
+}
+
+func runeAt(runes []rune, i int) rune {
+	if i < len(runes) {
+		return runes[i]
+	}
+	return 0
+}
@@ -0,0 +1,30 @@
+package pdf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShapeArabic(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "Latin text", text: "Zażółć gęślą jaźń", expected: "Zażółć gęślą jaźń"},
+		{name: "Initial, medial and final forms", text: "بيت", expected: "ﺑﻴﺖ"},
+		{name: "Lam-alef ligature", text: "سلام", expected: "ﺳﻼﻡ"},
+		{name: "Isolated lam-alef", text: "لا", expected: "ﻻ"},
+		{name: "Right-joining letter breaks the word", text: "دب", expected: "ﺩﺏ"},
+		{name: "Vowel marks are skipped", text: "بَت", expected: "ﺑَﺖ"},
+		{name: "Words are shaped separately", text: "بت بت", expected: "ﺑﺖ ﺑﺖ"},
+		{name: "Persian letter", text: "پپ", expected: "ﭘﭗ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, shapeArabic(tt.text))
+		})
+	}
+}
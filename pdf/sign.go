@@ -0,0 +1,339 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/cms"
+	"github.com/jakubsacha/signature-collector/tsa"
+)
+
+// signatureSize is the space reserved for the CMS signature in bytes. It holds a certificate
+// chain of a few certificates and a timestamp token.
+const signatureSize = 16384
+
+// byteRangePlaceholder reserves room for the byte range, which is only known once the update
+// is written
+const byteRangePlaceholder = "[0 0000000000 0000000000 0000000000]"
+
+// ErrSignatureTooLarge is returned when the CMS signature does not fit into the reserved space
+var ErrSignatureTooLarge = errors.New("signature does not fit into the reserved space")
+
+// Signer adds PAdES baseline signatures to PDFs with a configured certificate
+type Signer struct {
+	certificate *x509.Certificate
+	chain       []*x509.Certificate
+	key         crypto.Signer
+	timestamper tsa.Timestamper
+}
+
+// NewSigner creates a signer for key and its certificate. The chain holds intermediate
+// certificates embedded so readers can build the path to a trusted root.
+func NewSigner(certificate *x509.Certificate, key crypto.Signer, chain ...*x509.Certificate) *Signer {
+	return &Signer{certificate: certificate, key: key, chain: chain}
+}
+
+// WithTimestamper timestamps signatures, making them PAdES B-T
+func (s *Signer) WithTimestamper(timestamper tsa.Timestamper) *Signer {
+	s.timestamper = timestamper
+	return s
+}
+
+// Certificate returns the signing certificate
+func (s *Signer) Certificate() *x509.Certificate {
+	return s.certificate
+}
+
+// LoadSigner reads the signing certificate and key from PEM files. It returns nil when no
+// certificate is configured.
+//
+// The first certificate is the signing certificate; further certificates are its chain. The
+// key may be a PKCS#8, PKCS#1 RSA or SEC 1 EC private key.
+func LoadSigner(certFile, keyFile string) (*Signer, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("PDF signing needs both a certificate and a key file")
+	}
+	content, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF signing certificate: %v", err)
+	}
+	var certificates []*x509.Certificate
+	for block, rest := pem.Decode(content); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing PDF signing certificate: %v", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("PDF signing certificate file %s contains no certificate", certFile)
+	}
+
+	content, err = os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF signing key: %v", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("PDF signing key file %s contains no PEM block", keyFile)
+	}
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing PDF signing key: %v", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported PDF signing key type %T", key)
+	}
+	if !publicKeysEqual(signer.Public(), certificates[0].PublicKey) {
+		return nil, fmt.Errorf("PDF signing key does not match the certificate")
+	}
+	return NewSigner(certificates[0], signer, certificates[1:]...), nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
+
+// SignOptions describes a signature added by Sign
+type SignOptions struct {
+	// Name, Reason and Location are recorded in the signature dictionary
+	Name     string
+	Reason   string
+	Location string
+	// SigningTime is the claimed signing time shown by readers. PAdES keeps it out of the
+	// signed attributes; a timestamp is what proves it.
+	SigningTime time.Time
+	// Placement makes the signature visible; without it the signature is invisible
+	Placement *Placement
+	// Image is drawn as the visible signature's appearance, such as the handwritten signature
+	Image image.Image
+}
+
+// Sign adds a PAdES baseline signature to pdf as an incremental update, so the original bytes
+// and any earlier signatures are left intact
+func (s *Signer) Sign(ctx context.Context, pdf []byte, opts SignOptions) ([]byte, error) {
+	r, err := Open(pdf)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := r.Pages()
+	if err != nil {
+		return nil, err
+	}
+	placement := Placement{Page: 1}
+	if opts.Placement != nil {
+		placement = *opts.Placement
+	}
+	if placement.Page < 1 || placement.Page > len(pages) {
+		return nil, fmt.Errorf("signature placement page %d is out of range 1-%d", placement.Page, len(pages))
+	}
+	pageRef := pages[placement.Page-1]
+
+	u := newUpdate(r)
+	if opts.SigningTime.IsZero() {
+		opts.SigningTime = time.Now()
+	}
+	sigDict := Dict{
+		"Type":      Name("Sig"),
+		"Filter":    Name("Adobe.PPKLite"),
+		"SubFilter": Name("ETSI.CAdES.detached"),
+		"M":         String(Date(opts.SigningTime)),
+		"ByteRange": Raw(byteRangePlaceholder),
+		"Contents":  HexString(make([]byte, signatureSize)),
+	}
+	if opts.Name != "" {
+		sigDict["Name"] = TextString(opts.Name)
+	}
+	if opts.Reason != "" {
+		sigDict["Reason"] = TextString(opts.Reason)
+	}
+	if opts.Location != "" {
+		sigDict["Location"] = TextString(opts.Location)
+	}
+	sigRef := u.Add(sigDict)
+
+	catalog, err := r.Dict(r.Trailer()["Root"])
+	if err != nil {
+		return nil, err
+	}
+	catalog = copyDict(catalog)
+	acroForm := Dict{}
+	if catalog["AcroForm"] != nil {
+		existing, err := r.Dict(catalog["AcroForm"])
+		if err != nil {
+			return nil, err
+		}
+		acroForm = copyDict(existing)
+	}
+	fields, err := r.Resolve(acroForm["Fields"])
+	if err != nil {
+		return nil, err
+	}
+	fieldArray, _ := fields.(Array)
+
+	// The field and its widget annotation are merged into one dictionary
+	widget := Dict{
+		"Type":    Name("Annot"),
+		"Subtype": Name("Widget"),
+		"FT":      Name("Sig"),
+		"T":       String(fmt.Sprintf("Signature%d", len(fieldArray)+1)),
+		"V":       sigRef,
+		"P":       pageRef,
+		// Print and Locked
+		"F":    132,
+		"Rect": Array{0, 0, 0, 0},
+	}
+	if opts.Placement != nil {
+		widget["Rect"] = placement.rect()
+		appearance, err := s.appearance(u, placement, opts.Image)
+		if err != nil {
+			return nil, err
+		}
+		widget["AP"] = Dict{"N": appearance}
+	}
+	widgetRef := u.Add(widget)
+
+	acroForm["Fields"] = append(append(Array{}, fieldArray...), widgetRef)
+	// SignaturesExist and AppendOnly
+	acroForm["SigFlags"] = 3
+	catalog["AcroForm"] = acroForm
+	u.Set(r.Trailer()["Root"].(Ref), catalog)
+
+	page, err := r.Dict(pageRef)
+	if err != nil {
+		return nil, err
+	}
+	page = copyDict(page)
+	annots, err := r.Resolve(page["Annots"])
+	if err != nil {
+		return nil, err
+	}
+	annotArray, _ := annots.(Array)
+	page["Annots"] = append(append(Array{}, annotArray...), widgetRef)
+	u.Set(pageRef, page)
+
+	out, offsets := u.bytes(pdf)
+	sigOffset := offsets[sigRef.Num]
+
+	// The byte range covers the whole file except the hexadecimal signature value
+	contentsStart := bytes.Index(out[sigOffset:], []byte("/Contents <"))
+	if contentsStart < 0 {
+		return nil, fmt.Errorf("signature contents placeholder not found")
+	}
+	contentsStart += sigOffset + len("/Contents ")
+	contentsEnd := contentsStart + 2*signatureSize + 2
+	byteRange := fmt.Sprintf("[0 %d %d %d]", contentsStart, contentsEnd, len(out)-contentsEnd)
+	if len(byteRange) > len(byteRangePlaceholder) {
+		return nil, fmt.Errorf("file too large to sign")
+	}
+	rangeStart := bytes.Index(out[sigOffset:], []byte(byteRangePlaceholder))
+	if rangeStart < 0 {
+		return nil, fmt.Errorf("signature byte range placeholder not found")
+	}
+	rangeStart += sigOffset
+	copy(out[rangeStart:], fmt.Sprintf("%-*s", len(byteRangePlaceholder), byteRange))
+
+	signed := make([]byte, 0, len(out)-(contentsEnd-contentsStart))
+	signed = append(append(signed, out[:contentsStart]...), out[contentsEnd:]...)
+	signature, err := s.signature(ctx, signed)
+	if err != nil {
+		return nil, err
+	}
+	if len(signature) > signatureSize {
+		return nil, fmt.Errorf("%w: %d bytes, %d reserved", ErrSignatureTooLarge, len(signature), signatureSize)
+	}
+	copy(out[contentsStart+1:], fmt.Sprintf("%X", signature))
+	return out, nil
+}
+
+// signature creates the detached CAdES signature over the signed byte ranges, timestamping the
+// signature value when a timestamper is configured
+func (s *Signer) signature(ctx context.Context, content []byte) ([]byte, error) {
+	attribute, err := cms.NewSigningCertificateAttribute(s.certificate)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := cms.Sign(content, s.certificate, s.key, cms.SignOptions{
+		Detached:         true,
+		Chain:            s.chain,
+		SignedAttributes: []cms.Attribute{attribute},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.timestamper == nil {
+		return signature, nil
+	}
+
+	sd, err := cms.Parse(signature)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(sd.Signature())
+	token, err := s.timestamper.Timestamp(ctx, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("error timestamping PDF signature: %w", err)
+	}
+	tokenAttribute, err := cms.NewAttribute(cms.OIDAttributeTimeStampToken, asn1.RawValue{FullBytes: token})
+	if err != nil {
+		return nil, err
+	}
+	return sd.AddUnsignedAttribute(tokenAttribute)
+}
+
+// appearance adds the form XObject drawn for a visible signature
+func (s *Signer) appearance(u *update, placement Placement, img image.Image) (Ref, error) {
+	var content string
+	resources := Dict{}
+	if img != nil {
+		ref, err := imageXObject(u, img)
+		if err != nil {
+			return Ref{}, err
+		}
+		resources["XObject"] = Dict{"Im1": ref}
+		content = "q " + fitImage(img.Bounds(), Placement{Width: placement.Width, Height: placement.Height}, "Im1") + " Q"
+	}
+	return u.Add(Stream{
+		Dict: Dict{
+			"Type":      Name("XObject"),
+			"Subtype":   Name("Form"),
+			"BBox":      Array{0, 0, placement.Width, placement.Height},
+			"Resources": resources,
+		},
+		Data: []byte(content),
+	}), nil
+}
+
+func copyDict(dict Dict) Dict {
+	out := make(Dict, len(dict))
+	for key, value := range dict {
+		out[key] = value
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/tsa"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Signature Collector test", Organization: []string{"Example"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return NewSigner(certificate, key)
+}
+
+func TestSigner_Sign(t *testing.T) {
+	doc := testDocument()
+	img := doc.Signature
+	doc.Signature = nil
+	unsigned, placement, err := Render(doc)
+	assert.NoError(t, err)
+
+	authority, err := tsa.NewLocalTSA()
+	assert.NoError(t, err)
+	signingTime := time.Date(2024, 1, 2, 14, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		signer    *Signer
+		opts      SignOptions
+		timestamp bool
+	}{
+		{
+			name:   "Visible signature",
+			signer: newTestSigner(t),
+			opts:   SignOptions{Name: "Jan Kowalski", Reason: "Zgoda", SigningTime: signingTime, Placement: &placement, Image: img},
+		},
+		{
+			name:   "Invisible signature",
+			signer: newTestSigner(t),
+			opts:   SignOptions{SigningTime: signingTime},
+		},
+		{
+			name:      "Timestamped signature",
+			signer:    newTestSigner(t).WithTimestamper(authority),
+			opts:      SignOptions{SigningTime: signingTime, Placement: &placement, Image: img},
+			timestamp: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.signer.Sign(context.Background(), unsigned, tt.opts)
+			assert.NoError(t, err)
+
+			// The original revision is kept byte for byte
+			assert.True(t, bytes.HasPrefix(signed, unsigned))
+			checkXref(t, signed)
+
+			signatures, err := Verify(signed)
+			assert.NoError(t, err)
+			if !assert.Len(t, signatures, 1) {
+				return
+			}
+			signature := signatures[0]
+			assert.Equal(t, "Signature1", signature.Field)
+			assert.Equal(t, "ETSI.CAdES.detached", signature.SubFilter)
+			assert.Equal(t, tt.opts.Name, signature.Name)
+			assert.Equal(t, tt.opts.Reason, signature.Reason)
+			assert.Equal(t, signingTime, signature.SigningTime)
+			assert.Equal(t, tt.signer.Certificate(), signature.Signer)
+			assert.True(t, signature.CoversWholeFile)
+			if tt.timestamp {
+				assert.NotNil(t, signature.Timestamp)
+			} else {
+				assert.Nil(t, signature.Timestamp)
+			}
+
+			r, err := Open(signed)
+			assert.NoError(t, err)
+			pages, err := r.Pages()
+			assert.NoError(t, err)
+			page, err := r.Dict(pages[0])
+			assert.NoError(t, err)
+			annots, ok := page["Annots"].(Array)
+			assert.True(t, ok)
+			widget, err := r.Dict(annots[0])
+			assert.NoError(t, err)
+			if tt.opts.Placement != nil {
+				rect, _ := widget["Rect"].(Array)
+				if assert.Len(t, rect, 4) {
+					for i, v := range placement.rect() {
+						assert.InDelta(t, v, rect[i], 0.0001)
+					}
+				}
+				assert.Contains(t, widget, Name("AP"))
+			} else {
+				assert.Equal(t, Array{0, 0, 0, 0}, widget["Rect"])
+			}
+		})
+	}
+}
+
+func TestSigner_Sign_Twice(t *testing.T) {
+	unsigned, _, err := Render(testDocument())
+	assert.NoError(t, err)
+	first, err := newTestSigner(t).Sign(context.Background(), unsigned, SignOptions{})
+	assert.NoError(t, err)
+	second, err := newTestSigner(t).Sign(context.Background(), first, SignOptions{})
+	assert.NoError(t, err)
+	checkXref(t, second)
+
+	signatures, err := Verify(second)
+	assert.NoError(t, err)
+	assert.Len(t, signatures, 2)
+	assert.False(t, signatures[0].CoversWholeFile)
+	assert.True(t, signatures[1].CoversWholeFile)
+	assert.Equal(t, "Signature2", signatures[1].Field)
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	unsigned, _, err := Render(testDocument())
+	assert.NoError(t, err)
+	signed, err := newTestSigner(t).Sign(context.Background(), unsigned, SignOptions{})
+	assert.NoError(t, err)
+
+	tampered := bytes.Replace(signed, []byte("/Title"), []byte("/Titlf"), 1)
+	_, err = Verify(tampered)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	unsignedDoc, err := Verify(unsigned)
+	assert.NoError(t, err)
+	assert.Empty(t, unsignedDoc)
+}
+
+func TestLoadSigner(t *testing.T) {
+	dir := t.TempDir()
+	signer := newTestSigner(t)
+	certFile := filepath.Join(dir, "cert.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.Certificate().Raw}), 0600))
+	keyDER, err := x509.MarshalPKCS8PrivateKey(signer.key)
+	assert.NoError(t, err)
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	otherKeyFile := filepath.Join(dir, "other.pem")
+	assert.NoError(t, os.WriteFile(otherKeyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(otherKey)}), 0600))
+
+	loaded, err := LoadSigner("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	loaded, err = LoadSigner(certFile, keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, signer.Certificate(), loaded.Certificate())
+
+	_, err = LoadSigner(certFile, "")
+	assert.ErrorContains(t, err, "both a certificate and a key file")
+
+	_, err = LoadSigner(certFile, otherKeyFile)
+	assert.ErrorContains(t, err, "does not match the certificate")
+}
@@ -0,0 +1,98 @@
+package pdf
+
+import (
+	"fmt"
+	"image"
+)
+
+// Stamp draws an image into the pages of an existing PDF at each placement, as an incremental
+// update. The image becomes part of the page content, so it shows in every reader and cannot
+// be removed like an annotation.
+func Stamp(pdf []byte, img image.Image, placements []Placement) ([]byte, error) {
+	r, err := Open(pdf)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := r.Pages()
+	if err != nil {
+		return nil, err
+	}
+	for _, placement := range placements {
+		if placement.Page < 1 || placement.Page > len(pages) {
+			return nil, fmt.Errorf("placement page %d is out of range 1-%d", placement.Page, len(pages))
+		}
+	}
+
+	u := newUpdate(r)
+	imageRef, err := imageXObject(u, img)
+	if err != nil {
+		return nil, err
+	}
+	// The existing content is wrapped in q and Q, so a graphics state it leaves changed does
+	// not move the stamp
+	save := u.Add(Stream{Data: []byte("q\n")})
+
+	byPage := map[int][]Placement{}
+	var order []int
+	for _, placement := range placements {
+		if _, ok := byPage[placement.Page]; !ok {
+			order = append(order, placement.Page)
+		}
+		byPage[placement.Page] = append(byPage[placement.Page], placement)
+	}
+
+	for _, number := range order {
+		pageRef := pages[number-1]
+		page, err := r.Dict(pageRef)
+		if err != nil {
+			return nil, err
+		}
+		page = copyDict(page)
+
+		resources, err := r.PageAttribute(pageRef, "Resources")
+		if err != nil {
+			return nil, err
+		}
+		resourceDict, _ := resources.(Dict)
+		resourceDict = copyDict(resourceDict)
+		xobjects, err := r.Resolve(resourceDict["XObject"])
+		if err != nil {
+			return nil, err
+		}
+		xobjectDict, _ := xobjects.(Dict)
+		xobjectDict = copyDict(xobjectDict)
+		name := Name("SigStamp")
+		for i := 1; xobjectDict[name] != nil; i++ {
+			name = Name(fmt.Sprintf("SigStamp%d", i))
+		}
+		xobjectDict[name] = imageRef
+		resourceDict["XObject"] = xobjectDict
+		page["Resources"] = resourceDict
+
+		content := "Q\n"
+		for _, placement := range byPage[number] {
+			content += "q " + fitImage(img.Bounds(), placement, string(name)) + " Q\n"
+		}
+		stamp, err := flateStream(Dict{}, []byte(content))
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := r.Resolve(page["Contents"])
+		if err != nil {
+			return nil, err
+		}
+		streams := Array{save}
+		switch c := contents.(type) {
+		case Array:
+			streams = append(streams, c...)
+		case Stream:
+			streams = append(streams, page["Contents"])
+		}
+		page["Contents"] = append(streams, u.Add(stamp))
+		u.Set(pageRef, page)
+	}
+
+	out, _ := u.bytes(pdf)
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStamp(t *testing.T) {
+	original := xrefStreamPDF(t)
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 50))
+	img.Set(10, 10, color.Black)
+	placements := []Placement{
+		{Page: 1, X: 72, Y: 100, Width: 200, Height: 60},
+		{Page: 1, X: 340, Y: 100, Width: 200, Height: 60},
+	}
+
+	stamped, err := Stamp(original, img, placements)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(stamped, original))
+	checkXref(t, stamped)
+
+	r, err := Open(stamped)
+	assert.NoError(t, err)
+	// An update to a file with a cross reference stream is written as a stream too
+	assert.True(t, r.xrefStream)
+	pages, err := r.Pages()
+	assert.NoError(t, err)
+	page, err := r.Dict(pages[0])
+	assert.NoError(t, err)
+
+	resources, err := r.Dict(page["Resources"])
+	assert.NoError(t, err)
+	assert.Contains(t, resources, Name("Font"))
+	xobjects, err := r.Dict(resources["XObject"])
+	assert.NoError(t, err)
+	assert.Contains(t, xobjects, Name("SigStamp"))
+
+	contents, ok := page["Contents"].(Array)
+	assert.True(t, ok)
+	assert.Len(t, contents, 3)
+	var content []string
+	for _, ref := range contents {
+		stream, err := r.Resolve(ref)
+		assert.NoError(t, err)
+		data, err := DecodeStream(stream.(Stream))
+		assert.NoError(t, err)
+		content = append(content, string(data))
+	}
+	assert.Equal(t, "q\n", content[0])
+	assert.Equal(t, "BT /F1 12 Tf 72 720 Td (Contract) Tj ET", content[1])
+	assert.True(t, strings.HasPrefix(content[2], "Q\n"))
+	assert.Equal(t, 2, strings.Count(content[2], "/SigStamp Do"))
+
+	// The stamped file can be signed in a further update
+	signed, err := newTestSigner(t).Sign(context.Background(), stamped, SignOptions{Placement: &placements[0]})
+	assert.NoError(t, err)
+	checkXref(t, signed)
+	signatures, err := Verify(signed)
+	assert.NoError(t, err)
+	assert.Len(t, signatures, 1)
+
+	_, err = Stamp(original, img, []Placement{{Page: 2, Width: 10, Height: 10}})
+	assert.ErrorContains(t, err, "out of range")
+}
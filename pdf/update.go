@@ -0,0 +1,109 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// update collects the objects of an incremental update to an existing PDF
+type update struct {
+	reader  *Reader
+	next    int
+	objects map[int]any
+}
+
+func newUpdate(r *Reader) *update {
+	return &update{reader: r, next: r.Size(), objects: map[int]any{}}
+}
+
+// Add adds a new object to the update
+func (u *update) Add(object any) Ref {
+	ref := Ref{Num: u.next}
+	u.next++
+	u.objects[ref.Num] = object
+	return ref
+}
+
+// Set replaces an existing object
+func (u *update) Set(ref Ref, object any) {
+	u.objects[ref.Num] = object
+}
+
+// bytes appends the update to the original PDF and returns the result together with the
+// offsets of the objects written. The cross reference section is a stream when the original
+// ends with one, as a classic table may not follow a cross reference stream.
+func (u *update) bytes(original []byte) ([]byte, map[int]int) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(original)+2*signatureSize+4096))
+	buf.Write(original)
+	if len(original) > 0 && original[len(original)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(u.objects))
+	for num := range u.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	offsets := map[int]int{}
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		writeIndirect(buf, Ref{Num: num}, u.objects[num])
+	}
+
+	trailer := Dict{"Root": u.reader.Trailer()["Root"], "Prev": u.reader.StartXref()}
+	for _, key := range []Name{"Info", "ID"} {
+		if value, ok := u.reader.Trailer()[key]; ok {
+			trailer[key] = value
+		}
+	}
+	if !u.reader.xrefStream {
+		trailer["Size"] = u.next
+		writeXref(buf, offsets, trailer, false)
+		return buf.Bytes(), offsets
+	}
+
+	// The cross reference stream lists itself
+	xref := Ref{Num: u.next}
+	written := make(map[int]int, len(offsets)+1)
+	for num, offset := range offsets {
+		written[num] = offset
+	}
+	written[xref.Num] = buf.Len()
+	writeXrefStream(buf, xref, written, trailer)
+	return buf.Bytes(), offsets
+}
+
+// writeXrefStream writes a cross reference stream object for the objects at the given offsets,
+// which include the stream itself, and the startxref pointer
+func writeXrefStream(buf *bytes.Buffer, ref Ref, offsets map[int]int, trailer Dict) {
+	nums := make([]int, 0, len(offsets))
+	for num := range offsets {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	var index Array
+	var data []byte
+	for i := 0; i < len(nums); {
+		j := i + 1
+		for j < len(nums) && nums[j] == nums[j-1]+1 {
+			j++
+		}
+		index = append(index, nums[i], j-i)
+		for _, num := range nums[i:j] {
+			offset := offsets[num]
+			data = append(data, 1, byte(offset>>24), byte(offset>>16), byte(offset>>8), byte(offset), 0, 0)
+		}
+		i = j
+	}
+
+	dict := copyDict(trailer)
+	dict["Type"] = Name("XRef")
+	dict["Size"] = ref.Num + 1
+	dict["W"] = Array{1, 4, 2}
+	dict["Index"] = index
+	start := offsets[ref.Num]
+	writeIndirect(buf, ref, Stream{Dict: dict, Data: data})
+	fmt.Fprintf(buf, "startxref\n%d\n%%%%EOF\n", start)
+}
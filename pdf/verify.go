@@ -0,0 +1,215 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/cms"
+	"github.com/jakubsacha/signature-collector/tsa"
+)
+
+// ErrInvalidSignature is returned when a signature in a PDF does not verify
+var ErrInvalidSignature = errors.New("invalid PDF signature")
+
+// SignatureInfo describes a verified signature of a PDF
+type SignatureInfo struct {
+	Field     string
+	SubFilter string
+	Name      string
+	Reason    string
+	Location  string
+	// SigningTime is the time claimed in the signature dictionary
+	SigningTime time.Time
+	Signer      *x509.Certificate
+	// CoversWholeFile is false for signatures followed by later incremental updates
+	CoversWholeFile bool
+	// Timestamp is the verified signature timestamp, for B-T signatures
+	Timestamp *tsa.Token
+}
+
+// Verify checks every signature field of a PDF: the byte range must cover the file except the
+// signature value, and the CMS signature must verify over it. It does not decide whether the
+// signer's certificate is trusted; callers check Signer against their roots.
+func Verify(pdf []byte) ([]SignatureInfo, error) {
+	r, err := Open(pdf)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := r.Dict(r.Trailer()["Root"])
+	if err != nil {
+		return nil, err
+	}
+	if catalog["AcroForm"] == nil {
+		return nil, nil
+	}
+	acroForm, err := r.Dict(catalog["AcroForm"])
+	if err != nil {
+		return nil, err
+	}
+	fields, err := r.Resolve(acroForm["Fields"])
+	if err != nil {
+		return nil, err
+	}
+	fieldArray, _ := fields.(Array)
+
+	var signatures []SignatureInfo
+	for _, field := range fieldArray {
+		fieldDict, err := r.Dict(field)
+		if err != nil {
+			return nil, err
+		}
+		if fieldDict["FT"] != Name("Sig") || fieldDict["V"] == nil {
+			continue
+		}
+		sigDict, err := r.Dict(fieldDict["V"])
+		if err != nil {
+			return nil, err
+		}
+		info, err := verifySignature(pdf, sigDict)
+		if err != nil {
+			return nil, fmt.Errorf("signature %s: %w", textValue(fieldDict["T"]), err)
+		}
+		info.Field = textValue(fieldDict["T"])
+		signatures = append(signatures, info)
+	}
+	return signatures, nil
+}
+
+func verifySignature(pdf []byte, sigDict Dict) (SignatureInfo, error) {
+	info := SignatureInfo{
+		Name:     textValue(sigDict["Name"]),
+		Reason:   textValue(sigDict["Reason"]),
+		Location: textValue(sigDict["Location"]),
+	}
+	subFilter, _ := sigDict["SubFilter"].(Name)
+	info.SubFilter = string(subFilter)
+	if m, ok := sigDict["M"].(String); ok {
+		info.SigningTime, _ = ParseDate(string(m))
+	}
+
+	byteRange, _ := sigDict["ByteRange"].(Array)
+	ranges := make([]int, len(byteRange))
+	for i, v := range byteRange {
+		ranges[i], _ = v.(int)
+	}
+	// Exactly two ranges, from the start of the file around the hexadecimal signature value
+	if len(ranges) != 4 || ranges[0] != 0 || ranges[1] <= 0 || ranges[2] <= ranges[1] || ranges[3] < 0 ||
+		ranges[2]+ranges[3] > len(pdf) || pdf[ranges[1]] != '<' || pdf[ranges[2]-1] != '>' {
+		return info, fmt.Errorf("%w: invalid byte range", ErrInvalidSignature)
+	}
+	info.CoversWholeFile = ranges[2]+ranges[3] == len(pdf)
+
+	p := &parser{data: pdf[:ranges[2]], pos: ranges[1]}
+	contents, ok := p.next().(HexString)
+	if !ok || p.err != nil {
+		return info, fmt.Errorf("%w: byte range does not exclude the signature value", ErrInvalidSignature)
+	}
+	if stored, _ := sigDict["Contents"].(HexString); !bytes.Equal(stored, contents) {
+		return info, fmt.Errorf("%w: byte range does not exclude the signature value", ErrInvalidSignature)
+	}
+
+	sd, err := cms.Parse(contents)
+	if err != nil {
+		return info, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	signed := make([]byte, 0, ranges[1]+ranges[3])
+	signed = append(append(signed, pdf[:ranges[1]]...), pdf[ranges[2]:ranges[2]+ranges[3]]...)
+	if info.Signer, err = sd.Verify(signed); err != nil {
+		return info, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if subFilter == "ETSI.CAdES.detached" {
+		if err := sd.CheckSigningCertificate(info.Signer); err != nil {
+			return info, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+	}
+
+	var token asn1.RawValue
+	if ok, err := sd.UnsignedAttribute(cms.OIDAttributeTimeStampToken, &token); err != nil {
+		return info, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	} else if ok {
+		info.Timestamp, err = tsa.ParseToken(token.FullBytes)
+		if err != nil {
+			return info, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		h := info.Timestamp.Hash.New()
+		h.Write(sd.Signature())
+		if err := info.Timestamp.Verify(info.Timestamp.Hash, h.Sum(nil), nil); err != nil {
+			return info, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+	}
+	return info, nil
+}
+
+// textValue decodes a text string written by TextString
+func textValue(v any) string {
+	var raw []byte
+	switch v := v.(type) {
+	case String:
+		raw = []byte(v)
+	case HexString:
+		raw = v
+	default:
+		return ""
+	}
+	if len(raw) < 2 || raw[0] != 0xfe || raw[1] != 0xff {
+		return string(raw)
+	}
+	units := make([]rune, 0, len(raw)/2)
+	for i := 2; i+1 < len(raw); i += 2 {
+		units = append(units, rune(raw[i])<<8|rune(raw[i+1]))
+	}
+	var s []rune
+	for i := 0; i < len(units); i++ {
+		if units[i] >= 0xd800 && units[i] < 0xdc00 && i+1 < len(units) {
+			s = append(s, 0x10000+(units[i]-0xd800)<<10+(units[i+1]-0xdc00))
+			i++
+			continue
+		}
+		s = append(s, units[i])
+	}
+	return string(s)
+}
+
+// ParseDate parses a PDF date string such as D:20240102150405Z or D:20240102150405+01'00'
+func ParseDate(s string) (time.Time, error) {
+	if len(s) < 6 || s[:2] != "D:" {
+		return time.Time{}, fmt.Errorf("invalid PDF date %q", s)
+	}
+	s = s[2:]
+	fields := []int{0, 1, 1, 0, 0, 0}
+	widths := []int{4, 2, 2, 2, 2, 2}
+	for i, width := range widths {
+		if len(s) < width || s[0] < '0' || s[0] > '9' {
+			break
+		}
+		v, err := strconv.Atoi(s[:width])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid PDF date %q", s)
+		}
+		fields[i] = v
+		s = s[width:]
+	}
+	location := time.UTC
+	if len(s) >= 3 && (s[0] == '+' || s[0] == '-') {
+		hours, err1 := strconv.Atoi(s[1:3])
+		minutes := 0
+		var err2 error
+		if len(s) >= 6 && s[3] == '\'' {
+			minutes, err2 = strconv.Atoi(s[4:6])
+		}
+		if err1 != nil || err2 != nil {
+			return time.Time{}, fmt.Errorf("invalid PDF date offset %q", s)
+		}
+		offset := hours*3600 + minutes*60
+		if s[0] == '-' {
+			offset = -offset
+		}
+		location = time.FixedZone("", offset)
+	}
+	return time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, location), nil
+}
@@ -0,0 +1,97 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// header starts every PDF this package writes. The second line marks the file as binary.
+const header = "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"
+
+// Writer assembles a new PDF from indirect objects
+type Writer struct {
+	objects []any
+}
+
+// NewWriter creates an empty PDF writer
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Add adds an indirect object and returns its reference
+func (w *Writer) Add(object any) Ref {
+	w.objects = append(w.objects, object)
+	return Ref{Num: len(w.objects)}
+}
+
+// Reserve allocates a reference for an object that is set later, for objects that refer to
+// each other
+func (w *Writer) Reserve() Ref {
+	return w.Add(nil)
+}
+
+// Set sets the object of a reserved reference
+func (w *Writer) Set(ref Ref, object any) {
+	w.objects[ref.Num-1] = object
+}
+
+// Bytes writes the PDF with the given catalog and document information dictionary
+func (w *Writer) Bytes(root, info Ref) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	offsets := map[int]int{}
+	for i, object := range w.objects {
+		ref := Ref{Num: i + 1}
+		offsets[ref.Num] = buf.Len()
+		writeIndirect(&buf, ref, object)
+	}
+	trailer := Dict{"Size": len(w.objects) + 1, "Root": root}
+	if info.Num != 0 {
+		trailer["Info"] = info
+	}
+	writeXref(&buf, offsets, trailer, true)
+	return buf.Bytes()
+}
+
+func writeIndirect(buf *bytes.Buffer, ref Ref, object any) {
+	fmt.Fprintf(buf, "%d %d obj\n", ref.Num, ref.Gen)
+	writeObject(buf, object)
+	buf.WriteString("\nendobj\n")
+}
+
+// writeXref writes a cross reference table for the objects at the given offsets, the trailer
+// and the startxref pointer. A full table also lists the free object 0.
+func writeXref(buf *bytes.Buffer, offsets map[int]int, trailer Dict, full bool) {
+	start := buf.Len()
+	nums := make([]int, 0, len(offsets))
+	for num := range offsets {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	buf.WriteString("xref\n")
+	if full {
+		offsets[0] = -1
+		nums = append([]int{0}, nums...)
+	}
+	// Consecutive object numbers are written as one subsection
+	for i := 0; i < len(nums); {
+		j := i + 1
+		for j < len(nums) && nums[j] == nums[j-1]+1 {
+			j++
+		}
+		fmt.Fprintf(buf, "%d %d\n", nums[i], j-i)
+		for _, num := range nums[i:j] {
+			if offsets[num] < 0 {
+				buf.WriteString("0000000000 65535 f\r\n")
+			} else {
+				fmt.Fprintf(buf, "%010d 00000 n\r\n", offsets[num])
+			}
+		}
+		i = j
+	}
+	buf.WriteString("trailer\n")
+	writeObject(buf, trailer)
+	fmt.Fprintf(buf, "\nstartxref\n%d\n%%%%EOF\n", start)
+}
@@ -0,0 +1,484 @@
+// Package qr encodes text as a QR code (ISO/IEC 18004) in byte mode, for links shown on the
+// tablet that signers scan with their phones. Versions 1 to 10 are supported, which hold up to
+// 271 bytes at the lowest error correction level.
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is the error correction level of a code: the share of it that can be damaged and still
+// be read, from about 7% for L to 30% for H
+type Level int
+
+const (
+	L Level = iota
+	M
+	Q
+	H
+)
+
+// formatBits are the bits identifying each level in the format information
+var formatBits = [...]int{L: 1, M: 0, Q: 3, H: 2}
+
+// ErrTooLong is returned when the text does not fit in a version 10 code at the requested level
+var ErrTooLong = errors.New("text is too long for a QR code")
+
+// maxVersion is the largest version supported
+const maxVersion = 10
+
+// blocks describes how a version's data is split into blocks at a level: the number of error
+// correction codewords per block, and the number and data codewords of the blocks of each of the
+// two groups. Blocks of the second group hold one data codeword more.
+type blocks struct {
+	ecc, count1, data1, count2, data2 int
+}
+
+// blockTable lists the blocks of versions 1 to 10 at levels L, M, Q and H
+var blockTable = [maxVersion][4]blocks{
+	{{7, 1, 19, 0, 0}, {10, 1, 16, 0, 0}, {13, 1, 13, 0, 0}, {17, 1, 9, 0, 0}},
+	{{10, 1, 34, 0, 0}, {16, 1, 28, 0, 0}, {22, 1, 22, 0, 0}, {28, 1, 16, 0, 0}},
+	{{15, 1, 55, 0, 0}, {26, 1, 44, 0, 0}, {18, 2, 17, 0, 0}, {22, 2, 13, 0, 0}},
+	{{20, 1, 80, 0, 0}, {18, 2, 32, 0, 0}, {26, 2, 24, 0, 0}, {16, 4, 9, 0, 0}},
+	{{26, 1, 108, 0, 0}, {24, 2, 43, 0, 0}, {18, 2, 15, 2, 16}, {22, 2, 11, 2, 12}},
+	{{18, 2, 68, 0, 0}, {16, 4, 27, 0, 0}, {24, 4, 19, 0, 0}, {28, 4, 15, 0, 0}},
+	{{20, 2, 78, 0, 0}, {18, 4, 31, 0, 0}, {18, 2, 14, 4, 15}, {26, 4, 13, 1, 14}},
+	{{24, 2, 97, 0, 0}, {22, 2, 38, 2, 39}, {22, 4, 18, 2, 19}, {26, 4, 14, 2, 15}},
+	{{30, 2, 116, 0, 0}, {22, 3, 36, 2, 37}, {20, 4, 16, 4, 17}, {24, 4, 12, 4, 13}},
+	{{18, 2, 68, 2, 69}, {26, 4, 43, 1, 44}, {24, 6, 19, 2, 20}, {28, 6, 15, 2, 16}},
+}
+
+// alignmentPositions lists the row and column centres of the alignment patterns of versions 1
+// to 10
+var alignmentPositions = [maxVersion][]int{
+	nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+func (b blocks) dataCodewords() int {
+	return b.count1*b.data1 + b.count2*b.data2
+}
+
+// Code is an encoded QR code: a square of dark and light modules
+type Code struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode encodes text in the smallest version that holds it at the given level, with the mask
+// that makes it easiest to read
+func Encode(text string, level Level) (*Code, error) {
+	for version := 1; version <= maxVersion; version++ {
+		if len(text) <= capacity(version, level) {
+			return encode([]byte(text), version, level, -1), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d bytes", ErrTooLong, len(text))
+}
+
+// capacity returns how many bytes a version holds at a level
+func capacity(version int, level Level) int {
+	bits := blockTable[version-1][level].dataCodewords()*8 - 4 - countBits(version)
+	return bits / 8
+}
+
+// countBits returns the length of the character count of a byte mode segment
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// encode builds the code of data in a version at a level. A negative mask selects the one with
+// the lowest penalty.
+func encode(data []byte, version int, level Level, mask int) *Code {
+	c := &Code{size: 4*version + 17}
+	c.modules = make([][]bool, c.size)
+	c.function = make([][]bool, c.size)
+	for y := range c.modules {
+		c.modules[y] = make([]bool, c.size)
+		c.function[y] = make([]bool, c.size)
+	}
+
+	c.drawFunctionPatterns(version)
+	c.drawCodewords(codewords(data, version, level))
+
+	if mask < 0 {
+		lowest := -1
+		for candidate := 0; candidate < 8; candidate++ {
+			c.applyMask(candidate)
+			c.drawFormatBits(level, candidate)
+			if penalty := c.penalty(); lowest < 0 || penalty < lowest {
+				lowest, mask = penalty, candidate
+			}
+			c.applyMask(candidate)
+		}
+	}
+	c.applyMask(mask)
+	c.drawFormatBits(level, mask)
+	c.function = nil
+	return c
+}
+
+// codewords returns the data codewords of a byte mode segment, padded to the version's capacity,
+// interleaved with their error correction codewords
+func codewords(data []byte, version int, level Level) []byte {
+	b := blockTable[version-1][level]
+	capacityBits := b.dataCodewords() * 8
+
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, d := range data {
+		bits.append(int(d), 8)
+	}
+	bits.append(0, min(4, capacityBits-bits.len()))
+	bits.append(0, (8-bits.len()%8)%8)
+	for pad := 0xEC; bits.len() < capacityBits; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := bits.bytes()
+
+	// Split the data into blocks and compute the error correction of each
+	var dataBlocks, eccBlocks [][]byte
+	for i, offset := 0, 0; i < b.count1+b.count2; i++ {
+		length := b.data1
+		if i >= b.count1 {
+			length = b.data2
+		}
+		block := codewords[offset : offset+length]
+		dataBlocks = append(dataBlocks, block)
+		eccBlocks = append(eccBlocks, reedSolomon(block, b.ecc))
+		offset += length
+	}
+
+	// Interleave the codewords of the blocks, the data first
+	var result []byte
+	for i := 0; i < max(b.data1, b.data2); i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < b.ecc; i++ {
+		for _, block := range eccBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and the version
+// information, and reserves the modules of the format information
+func (c *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < c.size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.size-4, 3)
+	c.drawFinder(3, c.size-4)
+
+	positions := alignmentPositions[version-1]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Alignment patterns do not overlap the finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information, drawn once the mask is chosen
+	c.drawFormatBits(0, 0)
+
+	if version >= 7 {
+		remainder := version
+		for i := 0; i < 12; i++ {
+			remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+		}
+		bits := version<<12 | remainder
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := c.size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFinder draws a finder pattern and its separator around the centre x, y
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			if x+dx < 0 || x+dx >= c.size || y+dy < 0 || y+dy >= c.size {
+				continue
+			}
+			distance := max(abs(dx), abs(dy))
+			c.set(x+dx, y+dy, distance != 2 && distance != 4)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the format information, the level and mask protected by
+// a BCH code, and the dark module next to the lower one
+func (c *Code) drawFormatBits(level Level, mask int) {
+	data := formatBits[level]<<3 | mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.size-15+i, bit(i))
+	}
+	c.set(8, c.size-8, true)
+}
+
+// drawCodewords fills the modules not taken by function patterns with the codewords, in
+// two-module wide columns zigzagging up and down from the bottom right corner
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		// The vertical timing pattern is skipped
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vertical := 0; vertical < c.size; vertical++ {
+			y := vertical
+			if upward {
+				y = c.size - 1 - vertical
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] {
+					continue
+				}
+				// Remainder bits after the last codeword are light
+				if i < len(data)*8 {
+					c.modules[y][x] = data[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by a mask. Applying it twice removes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !c.function[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard a code is to read: long runs and blocks of one colour, patterns that
+// look like finders and an unbalanced share of dark modules
+func (c *Code) penalty() int {
+	penalty := 0
+	finderLike := []string{"10111010000", "00001011101"}
+	dark := 0
+	for i := 0; i < c.size; i++ {
+		var row, column strings.Builder
+		for j := 0; j < c.size; j++ {
+			row.WriteByte(c.bit(j, i))
+			column.WriteByte(c.bit(i, j))
+			if c.modules[i][j] {
+				dark++
+			}
+		}
+		for _, line := range []string{row.String(), column.String()} {
+			run := 1
+			for j := 1; j <= len(line); j++ {
+				if j < len(line) && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+			for _, pattern := range finderLike {
+				for j := 0; j+len(pattern) <= len(line); j++ {
+					if line[j:j+len(pattern)] == pattern {
+						penalty += 40
+					}
+				}
+			}
+		}
+	}
+
+	for y := 0; y < c.size-1; y++ {
+		for x := 0; x < c.size-1; x++ {
+			m := c.modules[y][x]
+			if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+				penalty += 3
+			}
+		}
+	}
+
+	total := c.size * c.size
+	penalty += max(0, (abs(dark*20-total*10)+total-1)/total-1) * 10
+	return penalty
+}
+
+func (c *Code) bit(x, y int) byte {
+	if c.modules[y][x] {
+		return '1'
+	}
+	return '0'
+}
+
+// Size returns the number of modules on a side of the code, without the quiet zone
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark reports whether the module at column x and row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// SVG draws the code as an SVG image with the quiet zone of four modules around it. The image
+// scales to the size it is shown at.
+func (c *Code) SVG() string {
+	const quiet = 4
+	var path strings.Builder
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+quiet, y+quiet)
+			}
+		}
+	}
+	side := c.size + 2*quiet
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/></svg>`, side, side, side, side, path.String())
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// bitBuffer collects bits most significant first
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		b.bits = append(b.bits, value>>i&1 == 1)
+	}
+}
+
+func (b *bitBuffer) len() int {
+	return len(b.bits)
+}
+
+func (b *bitBuffer) bytes() []byte {
+	result := make([]byte, (len(b.bits)+7)/8)
+	for i, bit := range b.bits {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+// reedSolomon returns the error correction codewords of a block: the remainder of its division
+// by the generator polynomial of degree n over GF(256)
+func reedSolomon(data []byte, n int) []byte {
+	generator := []byte{1}
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		// Multiply by (x - root), where subtraction is addition in GF(256)
+		next := make([]byte, len(generator)+1)
+		for j, coefficient := range generator {
+			next[j] ^= coefficient
+			next[j+1] ^= multiply(coefficient, root)
+		}
+		generator = next
+		root = multiply(root, 2)
+	}
+
+	remainder := make([]byte, n)
+	for _, d := range data {
+		factor := d ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[n-1] = 0
+		for j := 0; j < n; j++ {
+			remainder[j] ^= multiply(generator[j+1], factor)
+		}
+	}
+	return remainder
+}
+
+// multiply multiplies in GF(256) with the QR code's reducing polynomial x^8+x^4+x^3+x^2+1
+func multiply(x, y byte) byte {
+	var product byte
+	for i := 7; i >= 0; i-- {
+		carry := product >> 7
+		product = product<<1 ^ carry*0x1D
+		product ^= (y >> i & 1) * x
+	}
+	return product
+}
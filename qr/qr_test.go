@@ -0,0 +1,86 @@
+package qr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// HELLO WORLD in a version 1 code at level Q, in alphanumeric mode
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236}
+	expected := []byte{168, 72, 22, 82, 217, 54, 156, 0, 46, 15, 180, 122, 16}
+	assert.Equal(t, expected, reedSolomon(data, 13))
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name            string
+		text            string
+		level           Level
+		expectedVersion int
+	}{
+		{name: "Short", text: "hello", level: M, expectedVersion: 1},
+		{name: "Full version 1", text: strings.Repeat("x", 17), level: L, expectedVersion: 1},
+		{name: "Version 2", text: strings.Repeat("x", 18), level: L, expectedVersion: 2},
+		{name: "Link", text: "https://sign.example.com/c/Ab3xK9pQ2mZt", level: M, expectedVersion: 3},
+		{name: "Version information", text: strings.Repeat("x", 150), level: L, expectedVersion: 7},
+		{name: "Largest", text: strings.Repeat("x", 271), level: L, expectedVersion: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode(tt.text, tt.level)
+			assert.NoError(t, err)
+			assert.Equal(t, 4*tt.expectedVersion+17, code.Size())
+
+			// The finder pattern in the top left corner and its separator
+			for i := 0; i < 7; i++ {
+				assert.True(t, code.Dark(i, 0))
+				assert.True(t, code.Dark(0, i))
+				assert.False(t, code.Dark(i, 7))
+			}
+			assert.True(t, code.Dark(3, 3))
+			assert.False(t, code.Dark(1, 1))
+
+			// Both copies of the format information name the level
+			var upper, lower int
+			for i, p := range [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}} {
+				if code.Dark(p[0], p[1]) {
+					upper |= 1 << i
+				}
+			}
+			for i := 0; i < 15; i++ {
+				x, y := code.Size()-1-i, 8
+				if i >= 8 {
+					x, y = 8, code.Size()-15+i
+				}
+				if code.Dark(x, y) {
+					lower |= 1 << i
+				}
+			}
+			assert.Equal(t, upper, lower)
+			assert.Equal(t, formatBits[tt.level], (upper^0x5412)>>13)
+		})
+	}
+}
+
+func TestEncode_TooLong(t *testing.T) {
+	_, err := Encode(strings.Repeat("x", 272), L)
+	assert.True(t, errors.Is(err, ErrTooLong))
+
+	_, err = Encode(strings.Repeat("x", 120), H)
+	assert.True(t, errors.Is(err, ErrTooLong))
+}
+
+func TestSVG(t *testing.T) {
+	code, err := Encode("hello", M)
+	assert.NoError(t, err)
+
+	svg := code.SVG()
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"`))
+	assert.Contains(t, svg, `<path d="M4 4h1v1h-1z`)
+	assert.True(t, strings.HasSuffix(svg, "</svg>"))
+}
@@ -0,0 +1,217 @@
+// Package render normalises captured signature images and renders them in the formats and
+// sizes requested by API clients.
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Output formats
+const (
+	FormatPNG  = "png"
+	FormatSVG  = "svg"
+	FormatWebP = "webp"
+)
+
+// Limits applied to normalised and rendered images
+const (
+	// MaxWidth and MaxHeight bound the normalised image that is stored
+	MaxWidth  = 1200
+	MaxHeight = 600
+	// MaxRenderSize bounds the width and height a client may request
+	MaxRenderSize = 4096
+)
+
+// inkThreshold is the alpha below which a pixel is treated as background when trimming
+const inkThreshold = 8
+
+var (
+	// ErrUnsupportedImage is returned for data that is not a PNG or JPEG data URL
+	ErrUnsupportedImage = errors.New("unsupported signature image")
+	// ErrBlankImage is returned when the image contains no ink at all
+	ErrBlankImage = errors.New("signature image is blank")
+)
+
+// ContentType returns the MIME type of an output format
+func ContentType(format string) string {
+	switch format {
+	case FormatSVG:
+		return "image/svg+xml"
+	case FormatWebP:
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+// DecodeDataURL decodes a base64 "data:image/png" or "data:image/jpeg" URL
+func DecodeDataURL(dataURL string) (image.Image, error) {
+	header, payload, found := strings.Cut(dataURL, ",")
+	if !found || !strings.HasSuffix(header, ";base64") {
+		return nil, ErrUnsupportedImage
+	}
+	switch strings.TrimSuffix(header, ";base64") {
+	case "data:image/png", "data:image/jpeg":
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedImage, err)
+	}
+	return img, nil
+}
+
+// Normalize turns a captured signature into the form that is stored: the white or transparent
+// background becomes fully transparent, the blank margin is trimmed and the result is scaled
+// down to fit within MaxWidth x MaxHeight.
+func Normalize(img image.Image) (*image.NRGBA, error) {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	minX, minY, maxX, maxY := bounds.Dx(), bounds.Dy(), -1, -1
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := whiteToAlpha(color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA))
+			out.SetNRGBA(x, y, c)
+			if c.A >= inkThreshold {
+				minX, minY, maxX, maxY = min(minX, x), min(minY, y), max(maxX, x), max(maxY, y)
+			}
+		}
+	}
+	if maxX < 0 {
+		return nil, ErrBlankImage
+	}
+	ink := image.Rect(minX, minY, maxX+1, maxY+1)
+
+	trimmed := out.SubImage(ink).(*image.NRGBA)
+	width, height := Fit(ink.Dx(), ink.Dy(), MaxWidth, MaxHeight)
+	if width < ink.Dx() {
+		return Scale(trimmed, width, height), nil
+	}
+
+	// Copy so the result starts at the origin and does not retain the untrimmed pixels
+	result := image.NewNRGBA(image.Rect(0, 0, ink.Dx(), ink.Dy()))
+	draw.Draw(result, result.Bounds(), trimmed, ink.Min, draw.Src)
+	return result, nil
+}
+
+// NormalizeDataURL normalises a signature data URL and returns it as a PNG data URL
+func NormalizeDataURL(dataURL string) (string, error) {
+	img, err := DecodeDataURL(dataURL)
+	if err != nil {
+		return "", err
+	}
+	normalized, err := Normalize(img)
+	if err != nil {
+		return "", err
+	}
+	return PNGDataURL(normalized)
+}
+
+// PNGDataURL encodes an image as a base64 PNG data URL
+func PNGDataURL(img image.Image) (string, error) {
+	data, err := EncodePNG(img)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// whiteToAlpha removes white from a pixel, so anti-aliased ink drawn on a white background
+// keeps its edges when composited onto any other background
+func whiteToAlpha(c color.NRGBA) color.NRGBA {
+	if c.A == 0 {
+		return color.NRGBA{}
+	}
+	// Composite over white first so JPEG and opaque PNG captures are treated alike
+	r := 255 - (255-int(c.R))*int(c.A)/255
+	g := 255 - (255-int(c.G))*int(c.A)/255
+	b := 255 - (255-int(c.B))*int(c.A)/255
+
+	alpha := max(255-r, 255-g, 255-b)
+	if alpha == 0 {
+		return color.NRGBA{}
+	}
+	unmix := func(v int) uint8 {
+		return uint8(255 - (255-v)*255/alpha)
+	}
+	return color.NRGBA{R: unmix(r), G: unmix(g), B: unmix(b), A: uint8(alpha)}
+}
+
+// Fit returns the largest size with the aspect ratio of width x height that fits within
+// maxWidth x maxHeight. A zero bound is unconstrained; with both bounds zero the size is kept.
+func Fit(width, height, maxWidth, maxHeight int) (int, int) {
+	if maxWidth <= 0 && maxHeight <= 0 {
+		return width, height
+	}
+	scale := 0.0
+	if maxWidth > 0 {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 {
+		if s := float64(maxHeight) / float64(height); scale == 0 || s < scale {
+			scale = s
+		}
+	}
+	return max(1, int(float64(width)*scale+0.5)), max(1, int(float64(height)*scale+0.5))
+}
+
+// Scale resamples an image to the given size
+func Scale(img image.Image, width, height int) *image.NRGBA {
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(out, out.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return out
+}
+
+// Render encodes a normalised signature in the given format, scaled to fit within width x height.
+// Zero dimensions keep the stored size.
+func Render(img image.Image, format string, width, height int) ([]byte, error) {
+	bounds := img.Bounds()
+	w, h := Fit(bounds.Dx(), bounds.Dy(), width, height)
+	if w != bounds.Dx() || h != bounds.Dy() {
+		img = Scale(img, w, h)
+	}
+
+	switch format {
+	case FormatPNG:
+		return EncodePNG(img)
+	case FormatWebP:
+		return EncodeWebP(img)
+	case FormatSVG:
+		data, err := EncodePNG(img)
+		if err != nil {
+			return nil, err
+		}
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+
+			`<image width="%d" height="%d" href="data:image/png;base64,%s"/></svg>`,
+			w, h, w, h, w, h, base64.StdEncoding.EncodeToString(data))
+		return []byte(svg), nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// EncodePNG encodes an image as a compressed PNG
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,157 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/webp"
+)
+
+func pngDataURL(t *testing.T, img image.Image) string {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// signatureOnWhite draws a dark blue bar with an anti-aliased edge on an opaque white canvas
+func signatureOnWhite() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+	for y := 40; y < 50; y++ {
+		for x := 30; x < 150; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 0, G: 0, B: 100, A: 255})
+		}
+		// Half-covered edge pixel blended with the white background
+		img.SetNRGBA(150, y, color.NRGBA{R: 128, G: 128, B: 178, A: 255})
+	}
+	return img
+}
+
+func TestNormalize(t *testing.T) {
+	normalized, err := Normalize(signatureOnWhite())
+	assert.NoError(t, err)
+
+	// The white margin is trimmed
+	assert.Equal(t, image.Rect(0, 0, 121, 10), normalized.Bounds())
+
+	// Ink keeps its colour, the anti-aliased edge becomes semi-transparent ink
+	assert.Equal(t, color.NRGBA{R: 0, G: 0, B: 100, A: 255}, normalized.NRGBAAt(0, 0))
+	edge := normalized.NRGBAAt(120, 0)
+	assert.InDelta(t, 127, int(edge.A), 2)
+	assert.InDelta(t, 0, int(edge.R), 2)
+	assert.InDelta(t, 100, int(edge.B), 2)
+}
+
+func TestNormalize_TransparentBackground(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	img.SetNRGBA(10, 20, color.NRGBA{A: 255})
+	img.SetNRGBA(12, 21, color.NRGBA{A: 255})
+
+	normalized, err := Normalize(img)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 3, 2), normalized.Bounds())
+	assert.Equal(t, color.NRGBA{}, normalized.NRGBAAt(1, 0))
+}
+
+func TestNormalize_ScalesDownLargeImages(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3000, 500))
+	for x := 0; x < 3000; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{A: 255})
+		img.SetNRGBA(x, 499, color.NRGBA{A: 255})
+	}
+
+	normalized, err := Normalize(img)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, MaxWidth, 200), normalized.Bounds())
+}
+
+func TestNormalize_Blank(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 50, 50))
+	_, err := Normalize(img)
+	assert.ErrorIs(t, err, ErrBlankImage)
+}
+
+func TestDecodeDataURL(t *testing.T) {
+	valid := pngDataURL(t, signatureOnWhite())
+
+	tests := []struct {
+		name      string
+		dataURL   string
+		wantError bool
+	}{
+		{name: "PNG", dataURL: valid},
+		{name: "Empty", dataURL: "", wantError: true},
+		{name: "SVG", dataURL: "data:image/svg+xml;base64,PHN2Zz48L3N2Zz4=", wantError: true},
+		{name: "Not base64", dataURL: "data:image/png;base64,***", wantError: true},
+		{name: "Not an image", dataURL: "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("hello")), wantError: true},
+		{name: "Plain text", dataURL: "base64_encoded_signature_data", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeDataURL(tt.dataURL)
+			if tt.wantError {
+				assert.True(t, errors.Is(err, ErrUnsupportedImage))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name                       string
+		maxWidth, maxHeight        int
+		expectedWidth, expectedHgt int
+	}{
+		{name: "Unconstrained", expectedWidth: 400, expectedHgt: 100},
+		{name: "Width only", maxWidth: 200, expectedWidth: 200, expectedHgt: 50},
+		{name: "Height only", maxHeight: 200, expectedWidth: 800, expectedHgt: 200},
+		{name: "Width limits", maxWidth: 100, maxHeight: 100, expectedWidth: 100, expectedHgt: 25},
+		{name: "Height limits", maxWidth: 1000, maxHeight: 50, expectedWidth: 200, expectedHgt: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := Fit(400, 100, tt.maxWidth, tt.maxHeight)
+			assert.Equal(t, tt.expectedWidth, width)
+			assert.Equal(t, tt.expectedHgt, height)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	normalized, err := Normalize(signatureOnWhite())
+	assert.NoError(t, err)
+
+	data, err := Render(normalized, FormatPNG, 60, 0)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 60, 5), img.Bounds())
+
+	data, err = Render(normalized, FormatWebP, 0, 0)
+	assert.NoError(t, err)
+	img, err = webp.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, normalized.Bounds(), img.Bounds())
+
+	data, err = Render(normalized, FormatSVG, 0, 20)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), `<svg xmlns="http://www.w3.org/2000/svg" width="242" height="20"`))
+
+	_, err = Render(normalized, "gif", 0, 0)
+	assert.Error(t, err)
+}
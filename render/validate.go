@@ -0,0 +1,118 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Limits a submitted signature image must meet
+const (
+	// MaxSignatureBytes is the largest decoded image accepted
+	MaxSignatureBytes = 2 << 20
+	// MaxSignatureDimension is the largest width or height accepted
+	MaxSignatureDimension = 4096
+	// MinInkPixels is the least number of inked pixels a signature must have
+	MinInkPixels = 150
+	// MinInkExtent is the least width or height, in pixels, the inked area must span
+	MinInkExtent = 40
+)
+
+// Reasons a signature is rejected
+const (
+	ReasonMissing         = "missing"
+	ReasonMalformed       = "malformed"
+	ReasonUnsupportedType = "unsupported_type"
+	ReasonTooLarge        = "too_large"
+	ReasonBlank           = "blank"
+	ReasonInsufficientInk = "insufficient_ink"
+	// ReasonInsufficientStrokes is used for stroke data too sparse to be a signature
+	ReasonInsufficientStrokes = "insufficient_strokes"
+)
+
+// SignatureError describes why a submitted signature image was rejected
+type SignatureError struct {
+	Reason  string
+	Message string
+}
+
+func (e *SignatureError) Error() string {
+	return e.Message
+}
+
+// ValidateSignature checks that a signature data URL is a PNG or JPEG within the size limits
+// that contains enough ink to be a real signature, and returns the decoded image
+func ValidateSignature(dataURL string) (image.Image, error) {
+	if strings.TrimSpace(dataURL) == "" {
+		return nil, &SignatureError{Reason: ReasonMissing, Message: "signature_data is required"}
+	}
+
+	header, payload, found := strings.Cut(dataURL, ",")
+	if !found || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, &SignatureError{Reason: ReasonMalformed, Message: "signature_data must be a base64 data URL"}
+	}
+	mediaType := strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	if mediaType != "image/png" && mediaType != "image/jpeg" {
+		return nil, &SignatureError{Reason: ReasonUnsupportedType, Message: fmt.Sprintf("signature image type %q is not allowed, use image/png or image/jpeg", mediaType)}
+	}
+	if base64.StdEncoding.DecodedLen(len(payload)) > MaxSignatureBytes+2 {
+		return nil, &SignatureError{Reason: ReasonTooLarge, Message: fmt.Sprintf("signature image must not exceed %d bytes", MaxSignatureBytes)}
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, &SignatureError{Reason: ReasonMalformed, Message: "signature_data is not valid base64"}
+	}
+
+	// Check the dimensions before decoding the pixels
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, &SignatureError{Reason: ReasonMalformed, Message: "signature_data is not a valid image"}
+	}
+	if "image/"+format != mediaType {
+		return nil, &SignatureError{Reason: ReasonMalformed, Message: fmt.Sprintf("signature image is %s but declared as %s", format, mediaType)}
+	}
+	if config.Width > MaxSignatureDimension || config.Height > MaxSignatureDimension {
+		return nil, &SignatureError{Reason: ReasonTooLarge, Message: fmt.Sprintf("signature image must not exceed %dx%d pixels", MaxSignatureDimension, MaxSignatureDimension)}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, &SignatureError{Reason: ReasonMalformed, Message: "signature_data is not a valid image"}
+	}
+
+	pixels, extent := measureInk(img)
+	if pixels == 0 {
+		return nil, &SignatureError{Reason: ReasonBlank, Message: "signature image is blank"}
+	}
+	if pixels < MinInkPixels || (extent.Dx() < MinInkExtent && extent.Dy() < MinInkExtent) {
+		return nil, &SignatureError{Reason: ReasonInsufficientInk, Message: "signature does not contain enough ink"}
+	}
+
+	return img, nil
+}
+
+// measureInk returns the number of inked pixels and the rectangle they span
+func measureInk(img image.Image) (int, image.Rectangle) {
+	bounds := img.Bounds()
+	pixels := 0
+	extent := image.Rectangle{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if whiteToAlpha(color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)).A < inkThreshold {
+				continue
+			}
+			if pixels == 0 {
+				extent = image.Rect(x, y, x+1, y+1)
+			} else {
+				extent.Min.X, extent.Min.Y = min(extent.Min.X, x), min(extent.Min.Y, y)
+				extent.Max.X, extent.Max.Y = max(extent.Max.X, x+1), max(extent.Max.Y, y+1)
+			}
+			pixels++
+		}
+	}
+	return pixels, extent
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSignature(t *testing.T) {
+	blank := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+
+	dot := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+	for y := 50; y < 53; y++ {
+		for x := 50; x < 53; x++ {
+			dot.SetNRGBA(x, y, color.NRGBA{A: 255})
+		}
+	}
+
+	var jpegBuf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&jpegBuf, signatureOnWhite(), nil))
+	jpegData := base64.StdEncoding.EncodeToString(jpegBuf.Bytes())
+
+	tests := []struct {
+		name       string
+		dataURL    string
+		wantReason string
+	}{
+		{name: "PNG signature", dataURL: pngDataURL(t, signatureOnWhite())},
+		{name: "JPEG signature", dataURL: "data:image/jpeg;base64," + jpegData},
+		{name: "Empty", dataURL: "", wantReason: ReasonMissing},
+		{name: "Not a data URL", dataURL: "base64_encoded_signature_data", wantReason: ReasonMalformed},
+		{name: "Not base64", dataURL: "data:image/png;base64,!!!", wantReason: ReasonMalformed},
+		{name: "Not an image", dataURL: "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("hello")), wantReason: ReasonMalformed},
+		{name: "Mislabelled JPEG", dataURL: "data:image/png;base64," + jpegData, wantReason: ReasonMalformed},
+		{name: "Disallowed type", dataURL: "data:image/svg+xml;base64,PHN2Zy8+", wantReason: ReasonUnsupportedType},
+		{name: "Too many bytes", dataURL: "data:image/png;base64," + strings.Repeat("A", MaxSignatureBytes*2), wantReason: ReasonTooLarge},
+		{name: "Too many pixels", dataURL: pngDataURL(t, image.NewNRGBA(image.Rect(0, 0, MaxSignatureDimension+1, 1))), wantReason: ReasonTooLarge},
+		{name: "Blank", dataURL: pngDataURL(t, blank), wantReason: ReasonBlank},
+		{name: "Single dot", dataURL: pngDataURL(t, dot), wantReason: ReasonInsufficientInk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ValidateSignature(tt.dataURL)
+			if tt.wantReason == "" {
+				assert.NoError(t, err)
+				assert.NotNil(t, img)
+				return
+			}
+			var signatureErr *SignatureError
+			if assert.True(t, errors.As(err, &signatureErr)) {
+				assert.Equal(t, tt.wantReason, signatureErr.Reason)
+			}
+			assert.Nil(t, img)
+		})
+	}
+}
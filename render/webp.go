@@ -0,0 +1,326 @@
+package render
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// EncodeWebP encodes an image as a lossless WebP (VP8L) file.
+//
+// The encoder is deliberately simple: every pixel is written as four literals using one
+// Huffman code per channel built from the image's histograms, without transforms, colour
+// cache or backward references. Signatures are mostly transparent with a handful of ink
+// colours, which these codes already compress well.
+func EncodeWebP(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	pixels := make([]color.NRGBA, 0, width*height)
+	hasAlpha := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				c = color.NRGBA{}
+			}
+			if c.A != 0xff {
+				hasAlpha = true
+			}
+			pixels = append(pixels, c)
+		}
+	}
+
+	// Symbol histograms in VP8L order: green, red, blue, alpha
+	var histograms [4][]int
+	histograms[0] = make([]int, 256+24) // green plus the unused length prefix codes
+	for i := 1; i < 4; i++ {
+		histograms[i] = make([]int, 256)
+	}
+	for _, c := range pixels {
+		histograms[0][c.G]++
+		histograms[1][c.R]++
+		histograms[2][c.B]++
+		histograms[3][c.A]++
+	}
+
+	w := &bitWriter{}
+	w.writeBits(0x2f, 8) // VP8L signature
+	w.writeBits(uint32(width-1), 14)
+	w.writeBits(uint32(height-1), 14)
+	if hasAlpha {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+	w.writeBits(0, 3) // version
+	w.writeBits(0, 1) // no transforms
+	w.writeBits(0, 1) // no colour cache
+	w.writeBits(0, 1) // no meta prefix codes
+
+	var codes [4]prefixCode
+	for i, histogram := range histograms {
+		codes[i] = writePrefixCode(w, histogram)
+	}
+	// The distance code is never used
+	writePrefixCode(w, []int{1})
+
+	for _, c := range pixels {
+		codes[0].write(w, int(c.G))
+		codes[1].write(w, int(c.R))
+		codes[2].write(w, int(c.B))
+		codes[3].write(w, int(c.A))
+	}
+
+	data := w.bytes()
+	var buf bytes.Buffer
+	chunkSize := len(data)
+	padding := chunkSize % 2
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+chunkSize+padding))
+	buf.WriteString("WEBPVP8L")
+	binary.Write(&buf, binary.LittleEndian, uint32(chunkSize))
+	buf.Write(data)
+	if padding == 1 {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes(), nil
+}
+
+// bitWriter packs values least significant bit first, as VP8L expects
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+func (w *bitWriter) writeBits(value uint32, n uint) {
+	w.acc |= uint64(value) << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nBits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nBits = 0, 0
+	}
+	return w.buf
+}
+
+// prefixCode maps symbols to canonical Huffman codes. Codes with a single symbol use no bits.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+}
+
+func (c prefixCode) write(w *bitWriter, symbol int) {
+	length := c.lengths[symbol]
+	if length == 0 {
+		return
+	}
+	// Huffman codes are read one bit at a time starting with the most significant bit
+	code := c.codes[symbol]
+	reversed := uint32(0)
+	for i := 0; i < length; i++ {
+		reversed = reversed<<1 | (code>>i)&1
+	}
+	w.writeBits(reversed, uint(length))
+}
+
+// codeLengthCodeOrder is the order in which code length code lengths are stored
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writePrefixCode builds a prefix code for the histogram, writes its description and returns it
+func writePrefixCode(w *bitWriter, histogram []int) prefixCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+
+	// Simple codes describe up to two symbols below 256 directly
+	if len(used) <= 2 && used[len(used)-1] < 256 {
+		w.writeBits(1, 1)
+		w.writeBits(uint32(len(used)-1), 1)
+		if used[0] < 2 {
+			w.writeBits(0, 1)
+			w.writeBits(uint32(used[0]), 1)
+		} else {
+			w.writeBits(1, 1)
+			w.writeBits(uint32(used[0]), 8)
+		}
+		if len(used) == 2 {
+			w.writeBits(uint32(used[1]), 8)
+		}
+
+		lengths := make([]int, len(histogram))
+		codes := make([]uint32, len(histogram))
+		if len(used) == 2 {
+			lengths[used[0]], lengths[used[1]] = 1, 1
+			codes[used[1]] = 1
+		}
+		return prefixCode{lengths: lengths, codes: codes}
+	}
+
+	lengths := huffmanLengths(histogram, 15)
+
+	// Code lengths are themselves Huffman coded, using only the literal lengths 0-15
+	lengthHistogram := make([]int, 19)
+	for _, length := range lengths {
+		lengthHistogram[length]++
+	}
+	lengthLengths := huffmanLengths(lengthHistogram, 7)
+	lengthCode := prefixCode{lengths: lengthLengths, codes: canonicalCodes(lengthLengths)}
+	if countUsed(lengthLengths) == 1 {
+		lengthCode.lengths = make([]int, len(lengthLengths))
+	}
+
+	numCodes := 4
+	for i, symbol := range codeLengthCodeOrder {
+		if lengthLengths[symbol] > 0 {
+			numCodes = max(numCodes, i+1)
+		}
+	}
+	w.writeBits(0, 1) // normal code
+	w.writeBits(uint32(numCodes-4), 4)
+	for _, symbol := range codeLengthCodeOrder[:numCodes] {
+		w.writeBits(uint32(lengthLengths[symbol]), 3)
+	}
+	w.writeBits(0, 1) // code lengths for the whole alphabet follow
+	for _, length := range lengths {
+		lengthCode.write(w, length)
+	}
+
+	code := prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+	if countUsed(lengths) == 1 {
+		code.lengths = make([]int, len(lengths))
+	}
+	return code
+}
+
+func countUsed(lengths []int) int {
+	used := 0
+	for _, length := range lengths {
+		if length > 0 {
+			used++
+		}
+	}
+	return used
+}
+
+// canonicalCodes assigns canonical Huffman codes to code lengths
+func canonicalCodes(lengths []int) []uint32 {
+	var lengthCounts [16]uint32
+	for _, length := range lengths {
+		if length > 0 {
+			lengthCounts[length]++
+		}
+	}
+	var nextCode [16]uint32
+	code := uint32(0)
+	for length := 1; length < 16; length++ {
+		code = (code + lengthCounts[length-1]) << 1
+		nextCode[length] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for symbol, length := range lengths {
+		if length > 0 {
+			codes[symbol] = nextCode[length]
+			nextCode[length]++
+		}
+	}
+	return codes
+}
+
+// huffmanLengths returns Huffman code lengths for a histogram, no longer than maxLength.
+// When the optimal code is too deep the counts are flattened until it fits.
+func huffmanLengths(histogram []int, maxLength int) []int {
+	counts := append([]int(nil), histogram...)
+	for {
+		lengths := buildHuffmanLengths(counts)
+		deepest := 0
+		for _, length := range lengths {
+			deepest = max(deepest, length)
+		}
+		if deepest <= maxLength {
+			return lengths
+		}
+		for i, count := range counts {
+			if count > 0 {
+				counts[i] = (count + 1) / 2
+			}
+		}
+	}
+}
+
+type huffmanNode struct {
+	count  int
+	symbol int
+	left   *huffmanNode
+	right  *huffmanNode
+}
+
+type huffmanQueue []*huffmanNode
+
+func (q huffmanQueue) Len() int { return len(q) }
+func (q huffmanQueue) Less(i, j int) bool {
+	if q[i].count != q[j].count {
+		return q[i].count < q[j].count
+	}
+	return q[i].symbol < q[j].symbol
+}
+func (q huffmanQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *huffmanQueue) Push(x any)   { *q = append(*q, x.(*huffmanNode)) }
+func (q *huffmanQueue) Pop() any {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+func buildHuffmanLengths(counts []int) []int {
+	lengths := make([]int, len(counts))
+	queue := huffmanQueue{}
+	for symbol, count := range counts {
+		if count > 0 {
+			queue = append(queue, &huffmanNode{count: count, symbol: symbol})
+		}
+	}
+	switch len(queue) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[queue[0].symbol] = 1
+		return lengths
+	}
+
+	heap.Init(&queue)
+	for queue.Len() > 1 {
+		a := heap.Pop(&queue).(*huffmanNode)
+		b := heap.Pop(&queue).(*huffmanNode)
+		heap.Push(&queue, &huffmanNode{count: a.count + b.count, symbol: min(a.symbol, b.symbol), left: a, right: b})
+	}
+
+	var walk func(node *huffmanNode, depth int)
+	walk = func(node *huffmanNode, depth int) {
+		if node.left == nil {
+			lengths[node.symbol] = depth
+			return
+		}
+		walk(node.left, depth+1)
+		walk(node.right, depth+1)
+	}
+	walk(queue[0], 0)
+	return lengths
+}
@@ -0,0 +1,102 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name  string
+		width int
+		fill  func(x, y int) color.NRGBA
+	}{
+		{
+			name:  "Single colour",
+			width: 7,
+			fill:  func(x, y int) color.NRGBA { return color.NRGBA{R: 10, G: 20, B: 30, A: 255} },
+		},
+		{
+			name:  "Two colours",
+			width: 16,
+			fill: func(x, y int) color.NRGBA {
+				if (x+y)%3 == 0 {
+					return color.NRGBA{A: 255}
+				}
+				return color.NRGBA{}
+			},
+		},
+		{
+			name:  "Anti-aliased ink",
+			width: 33,
+			fill: func(x, y int) color.NRGBA {
+				return color.NRGBA{R: 0, G: 0, B: 128, A: uint8((x * y * 7) % 256)}
+			},
+		},
+		{
+			name:  "Every value in every channel",
+			width: 64,
+			fill: func(x, y int) color.NRGBA {
+				return color.NRGBA{R: uint8(random.Intn(256)), G: uint8(random.Intn(256)), B: uint8(random.Intn(256)), A: uint8(random.Intn(255) + 1)}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, tt.width, 9))
+			for y := 0; y < 9; y++ {
+				for x := 0; x < tt.width; x++ {
+					img.SetNRGBA(x, y, tt.fill(x, y))
+				}
+			}
+
+			data, err := EncodeWebP(img)
+			assert.NoError(t, err)
+
+			decoded, err := webp.Decode(bytes.NewReader(data))
+			assert.NoError(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, img.Bounds(), decoded.Bounds())
+			for y := 0; y < 9; y++ {
+				for x := 0; x < tt.width; x++ {
+					expected := img.NRGBAAt(x, y)
+					if expected.A == 0 {
+						// Fully transparent pixels are written as transparent black
+						expected = color.NRGBA{}
+					}
+					assert.Equal(t, expected, color.NRGBAModel.Convert(decoded.At(x, y)), "pixel %d,%d", x, y)
+				}
+			}
+		})
+	}
+}
+
+func TestHuffmanLengths_LimitsDepth(t *testing.T) {
+	// Fibonacci counts produce the deepest possible optimal tree
+	histogram := make([]int, 30)
+	a, b := 1, 1
+	for i := range histogram {
+		histogram[i] = a
+		a, b = b, a+b
+	}
+
+	lengths := huffmanLengths(histogram, 15)
+	kraft := 0.0
+	for _, length := range lengths {
+		assert.LessOrEqual(t, length, 15)
+		assert.Greater(t, length, 0)
+		kraft += 1 / float64(int(1)<<length)
+	}
+	assert.Equal(t, 1.0, kraft)
+}
@@ -0,0 +1,156 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/models"
+)
+
+// maxErrorBytes bounds the size of an error response that is read
+const maxErrorBytes = 64 << 10
+
+// Client stores blobs as objects in a bucket of an S3-compatible object store, such as AWS S3
+// or MinIO. It implements models.BlobStore.
+//
+// Objects are addressed path-style, as endpoint/bucket/key, which every S3-compatible store
+// supports.
+type Client struct {
+	endpoint    *url.URL
+	bucket      string
+	credentials credentials
+	client      *http.Client
+	timeNow     func() time.Time
+}
+
+// NewClient creates a client for bucket at endpoint, such as https://s3.eu-central-1.amazonaws.com
+// or http://localhost:9000, signing requests with the given access key
+func NewClient(endpoint, bucket, region, accessKey, secretKey string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &Client{
+		endpoint:    u,
+		bucket:      bucket,
+		credentials: credentials{accessKey: accessKey, secretKey: secretKey, region: region},
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		timeNow: time.Now,
+	}, nil
+}
+
+// LoadClient creates a client from its configuration. It returns nil when no bucket is
+// configured.
+func LoadClient(endpoint, bucket, region, accessKey, secretKey string) (*Client, error) {
+	if bucket == "" {
+		return nil, nil
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3 storage needs both an access key and a secret key")
+	}
+	return NewClient(endpoint, bucket, region, accessKey, secretKey)
+}
+
+// WithClient sets a custom HTTP client
+func (c *Client) WithClient(client *http.Client) *Client {
+	c.client = client
+	return c
+}
+
+// PutBlob uploads data as the object key
+func (c *Client) PutBlob(key string, data []byte) error {
+	resp, err := c.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// GetBlob downloads the object key, returning models.ErrBlobNotFound when it does not exist
+func (c *Client) GetBlob(key string) ([]byte, error) {
+	resp, err := c.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, models.ErrBlobNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading S3 object %s: %v", key, err)
+	}
+	return data, nil
+}
+
+// DeleteBlob deletes the object key. S3 reports success for objects that do not exist.
+func (c *Client) DeleteBlob(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (c *Client) do(method, key string, body []byte) (*http.Response, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return nil, fmt.Errorf("invalid blob key %q", key)
+	}
+	u := *c.endpoint
+	u.Path = path.Join("/", c.endpoint.Path, c.bucket) + "/" + key
+	u.RawPath = ""
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating S3 request: %v", err)
+	}
+	c.credentials.signRequest(req, body, c.timeNow())
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting S3 object %s: %v", key, err)
+	}
+	return resp, nil
+}
+
+// errorResponse is the XML body of an S3 error
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func responseError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	var e errorResponse
+	if xml.Unmarshal(data, &e) == nil && e.Code != "" {
+		return fmt.Errorf("S3 returned status %d: %s: %s", resp.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Errorf("S3 returned status %d", resp.StatusCode)
+}
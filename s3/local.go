@@ -0,0 +1,149 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxObjectBytes bounds the size of an object stored by the local server
+const maxObjectBytes = 100 << 20
+
+// maxClockSkew is how far the time of a signed request may be from the server's clock
+const maxClockSkew = 15 * time.Minute
+
+// LocalServer is an in-process stand-in for an S3-compatible object store, for development and
+// tests. It keeps objects in memory and serves the object API path-style, like MinIO, checking
+// the Signature Version 4 signature of every request.
+type LocalServer struct {
+	credentials credentials
+	timeNow     func() time.Time
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewLocalServer creates a local server accepting requests signed with the given access key
+func NewLocalServer(accessKey, secretKey, region string) *LocalServer {
+	return &LocalServer{
+		credentials: credentials{accessKey: accessKey, secretKey: secretKey, region: region},
+		timeNow:     time.Now,
+		objects:     make(map[string][]byte),
+	}
+}
+
+// Objects returns the names of the stored objects as bucket/key, sorted
+func (l *LocalServer) Objects() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := make([]string, 0, len(l.objects))
+	for name := range l.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP answers PUT, GET, HEAD and DELETE requests for /bucket/key
+func (l *LocalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxObjectBytes+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", "Error reading the request body")
+		return
+	}
+	if len(body) > maxObjectBytes {
+		writeError(w, http.StatusBadRequest, "EntityTooLarge", "The object exceeds the maximum size")
+		return
+	}
+	if code, message := l.authenticate(r, body); code != "" {
+		status := http.StatusForbidden
+		if code == "XAmzContentSHA256Mismatch" {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, code, message)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if bucket, key, ok := strings.Cut(name, "/"); !ok || bucket == "" || key == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Only object requests are supported")
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		l.objects[name] = body
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		data, ok := l.objects[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(l.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
+}
+
+// authenticate checks the request's signature, returning an S3 error code and message when
+// it is not valid
+func (l *LocalServer) authenticate(r *http.Request, body []byte) (string, string) {
+	authorization, ok := strings.CutPrefix(r.Header.Get("Authorization"), algorithm+" ")
+	if !ok {
+		return "AccessDenied", "Requests must be signed with " + algorithm
+	}
+	params := map[string]string{}
+	for _, param := range strings.Split(authorization, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		params[name] = value
+	}
+	accessKey, _, _ := strings.Cut(params["Credential"], "/")
+	if accessKey != l.credentials.accessKey {
+		return "InvalidAccessKeyId", "The access key does not exist"
+	}
+
+	signedAt, err := time.Parse(amzDateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return "AccessDenied", "X-Amz-Date is missing or malformed"
+	}
+	if skew := l.timeNow().Sub(signedAt); skew > maxClockSkew || skew < -maxClockSkew {
+		return "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large"
+	}
+	digest := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(digest[:]) {
+		return "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed"
+	}
+
+	signedHeaders := strings.Split(params["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return "AccessDenied", "SignedHeaders must be sorted"
+	}
+	scope, signature := l.credentials.signature(r, signedHeaders, signedAt)
+	if params["Credential"] != accessKey+"/"+scope || !hmac.Equal([]byte(params["Signature"]), []byte(signature)) {
+		return "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided"
+	}
+	return "", ""
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	body, _ := xml.Marshal(errorResponse{Code: code, Message: message})
+	w.Write(append([]byte(xml.Header), body...))
+}
@@ -0,0 +1,126 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// algorithm is the only signature algorithm supported, AWS Signature Version 4 with SHA-256
+const algorithm = "AWS4-HMAC-SHA256"
+
+// amzDateFormat is the format of the x-amz-date header
+const amzDateFormat = "20060102T150405Z"
+
+// service is the service name in the credential scope
+const service = "s3"
+
+// credentials identify and authenticate a client
+type credentials struct {
+	accessKey string
+	secretKey string
+	region    string
+}
+
+// signRequest adds the x-amz-date, x-amz-content-sha256 and Authorization headers of a
+// Signature Version 4 signature to req. The payload is the request body.
+func (c credentials) signRequest(req *http.Request, payload []byte, now time.Time) {
+	digest := sha256.Sum256(payload)
+	req.Header.Set("X-Amz-Date", now.UTC().Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(digest[:]))
+
+	signedHeaders := []string{"host"}
+	for name := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" || name == "content-md5" || name == "range" {
+			signedHeaders = append(signedHeaders, name)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	scope, signature := c.signature(req, signedHeaders, now)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, c.accessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// signature returns the credential scope and the hex signature of req over signedHeaders,
+// which must be lowercase and sorted
+func (c credentials) signature(req *http.Request, signedHeaders []string, now time.Time) (string, string) {
+	date := now.UTC().Format("20060102")
+	scope := date + "/" + c.region + "/" + service + "/aws4_request"
+
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	requestDigest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		algorithm,
+		now.UTC().Format(amzDateFormat),
+		scope,
+		hex.EncodeToString(requestDigest[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name, then value
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and slashes unless
+// encodeSlash is set
+func uriEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/s3"
+	"github.com/joho/godotenv"
+)
+
+// reencrypt encrypts existing plaintext documents and moves documents encrypted with an older
+// key onto the current primary key. Run it after enabling encryption or rotating keys.
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
+	}
+
+	keyring, err := models.LoadKeyring(os.Getenv("ENCRYPTION_KEY_FILE"), os.Getenv("ENCRYPTION_KEY"), os.Getenv("ENCRYPTION_KEY_ID"))
+	if err != nil {
+		log.Fatalf("Error loading encryption keys: %v", err)
+	}
+	if keyring == nil {
+		log.Fatalf("ENCRYPTION_KEY_FILE or ENCRYPTION_KEY must be set")
+	}
+
+	var config models.DBConfig
+	if dbHost := os.Getenv("DB_HOST"); dbHost != "" {
+		log.Println("Using MySQL database configuration")
+		config = models.DBConfig{
+			Driver:   "mysql",
+			Host:     dbHost,
+			User:     os.Getenv("DB_USER"),
+			Password: os.Getenv("DB_PASSWORD"),
+			Name:     os.Getenv("DB_NAME"),
+		}
+	} else {
+		log.Println("Using SQLite database configuration")
+		config = models.DBConfig{
+			Driver: "sqlite3",
+			Name:   "local.db",
+		}
+	}
+
+	db, err := models.InitDB(config)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+	defer db.Close()
+
+	blobs, err := loadBlobStore()
+	if err != nil {
+		log.Fatalf("Error setting up blob store: %v", err)
+	}
+
+	count, err := models.ReencryptDocuments(db, keyring, blobs)
+	if err != nil {
+		log.Fatalf("Error re-encrypting documents: %v", err)
+	}
+
+	fmt.Printf("✓ Re-encrypted %d documents with key %s\n", count, keyring.PrimaryID())
+}
+
+// loadBlobStore returns the S3 bucket configured with S3_BUCKET, or the BLOB_DIR directory
+func loadBlobStore() (models.BlobStore, error) {
+	bucket, err := s3.LoadClient(os.Getenv("S3_ENDPOINT"), os.Getenv("S3_BUCKET"), os.Getenv("S3_REGION"),
+		os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	if bucket != nil {
+		return bucket, nil
+	}
+	blobDir := os.Getenv("BLOB_DIR")
+	if blobDir == "" {
+		blobDir = "blobs"
+	}
+	return models.NewFileBlobStore(blobDir)
+}
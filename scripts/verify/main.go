@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/tsa"
+)
+
+// verify checks a certificate of completion offline: the record in the certificate must match
+// its integrity hash, the seal must verify with the service's published public keys and the
+// trusted timestamp, if any, must be for the integrity hash.
+//
+//	go run scripts/verify/main.go -keys keys.json -tsa-roots tsa-root.pem certificate.json
+//
+// The keys file is the response of GET /api/seal/keys, or PEM public keys or certificates.
+// Without -keys the seal is not checked, and without -tsa-roots the timestamp authority is not
+// checked to be trusted. The exit status is 1 when verification fails.
+func main() {
+	log.SetFlags(0)
+
+	keysFile := flag.String("keys", "", "file with the seal public keys (JSON from /api/seal/keys or PEM)")
+	rootsFile := flag.String("tsa-roots", "", "PEM file with the trusted timestamp authority root certificates")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-keys file] [-tsa-roots file] <certificate.json | ->\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts models.VerifyOptions
+	if *keysFile != "" {
+		content, err := os.ReadFile(*keysFile)
+		if err != nil {
+			log.Fatalf("Error reading keys: %v", err)
+		}
+		if opts.SealKeys, err = models.ParseSealKeys(content); err != nil {
+			log.Fatalf("Error reading keys: %v", err)
+		}
+	}
+	var err error
+	if opts.TimestampRoots, err = tsa.LoadRoots(*rootsFile); err != nil {
+		log.Fatalf("Error loading timestamp roots: %v", err)
+	}
+
+	var input io.Reader = os.Stdin
+	if path := flag.Arg(0); path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Error reading certificate: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+	var certificate models.Certificate
+	if err := json.NewDecoder(input).Decode(&certificate); err != nil {
+		log.Fatalf("Error reading certificate: %v", err)
+	}
+
+	verification, err := models.VerifyCertificate(certificate, opts)
+	if err != nil {
+		log.Fatalf("Error verifying certificate: %v", err)
+	}
+
+	fmt.Printf("Request:        %s\n", verification.RequestID)
+	fmt.Printf("Integrity hash: %s\n", verification.IntegrityHash)
+	for _, check := range verification.Checks {
+		if check.Passed {
+			fmt.Printf("✓ %s\n", check.Name)
+		} else {
+			fmt.Printf("✗ %s: %s\n", check.Name, check.Message)
+		}
+	}
+	if opts.SealKeys == nil {
+		fmt.Println("- seal not checked, no -keys given")
+	}
+	if certificate.Timestamp != nil {
+		fmt.Printf("Timestamped:    %s by %s\n", certificate.Timestamp.GenTime.Format(time.RFC3339), certificate.Timestamp.Authority)
+		if opts.TimestampRoots == nil {
+			fmt.Println("- timestamp authority not checked, no -tsa-roots given")
+		}
+	}
+
+	if !verification.Valid {
+		fmt.Println("Certificate is NOT valid")
+		os.Exit(1)
+	}
+	fmt.Println("Certificate is valid")
+}
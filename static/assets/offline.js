@@ -0,0 +1,62 @@
+// Registers the service worker that keeps the tablet working offline, hands it the pending
+// documents listed on the page to cache and shows whether signatures are waiting to be sent
+(function () {
+    if (!('serviceWorker' in navigator)) {
+        return;
+    }
+    const script = document.currentScript;
+    let queued = 0;
+
+    function showStatus() {
+        const banner = document.getElementById('offlineStatus');
+        if (!banner) {
+            return;
+        }
+        const lines = [];
+        if (!navigator.onLine) {
+            lines.push(banner.dataset.offline);
+        }
+        if (queued > 0) {
+            lines.push(banner.dataset.queued.replace('{count}', queued));
+        }
+        banner.textContent = lines.join(' ');
+        banner.hidden = lines.length === 0;
+    }
+
+    function send(message) {
+        navigator.serviceWorker.ready.then(registration => registration.active.postMessage(message));
+    }
+
+    // The list of documents names every document pending on the tablet, so signing pages of
+    // documents it no longer lists are dropped from the cache
+    function precache() {
+        const urls = Array.from(document.querySelectorAll('[data-offline-urls]'))
+            .flatMap(element => element.dataset.offlineUrls.split(' ').filter(Boolean));
+        const prune = document.querySelector('[data-offline-documents]') !== null;
+        if (urls.length > 0 || prune) {
+            send({ type: 'precache', urls, prune });
+        }
+    }
+
+    navigator.serviceWorker.register(script.dataset.serviceWorker, { scope: '/' }).catch(error => {
+        console.error('Service worker registration failed', error);
+    });
+    navigator.serviceWorker.addEventListener('message', event => {
+        if (event.data && event.data.type === 'queue') {
+            queued = event.data.queued;
+            showStatus();
+        }
+    });
+
+    window.addEventListener('online', () => {
+        showStatus();
+        send({ type: 'flush' });
+    });
+    window.addEventListener('offline', showStatus);
+    document.addEventListener('htmx:afterSwap', precache);
+    document.addEventListener('DOMContentLoaded', () => {
+        showStatus();
+        precache();
+        send({ type: 'flush' });
+    });
+})();
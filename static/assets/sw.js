@@ -0,0 +1,247 @@
+// Service worker that keeps a tablet usable while its connection drops. Pages are served from
+// the network when it is reachable and from a cache otherwise; the pending documents listed on a
+// tablet are cached ahead of time. A signature submitted while offline is queued on the tablet,
+// with the time it was captured, and replayed when the connection is back. The service accepts
+// each submission once by its submission_id, so replaying one whose response was lost is safe.
+
+const CACHE = 'signature-collector';
+const QUEUE_DATABASE = 'signature-collector';
+const QUEUE_STORE = 'submissions';
+const SYNC_TAG = 'signatures';
+const SIGN_PAGE = /^\/documents\/sign\/[^/]+$/;
+
+// A signer's copy of a document, the QR code of its link and the pages of their remote signing
+// link are theirs alone, so they are not kept on the device
+const SIGNER_COPY = /^\/(c\/|r\/|documents\/sign\/[^/]+\/copy\.svg$)/;
+
+// Gateway errors mean the service could not be reached, so the submission is queued
+const UNREACHABLE = [502, 503, 504];
+
+// Responses to a replayed submission after which it is tried again later rather than dropped
+const RETRY = [401, 408, 429];
+
+self.addEventListener('install', () => self.skipWaiting());
+
+self.addEventListener('activate', event => {
+    event.waitUntil(self.clients.claim().then(flush));
+});
+
+self.addEventListener('fetch', event => {
+    const request = event.request;
+    const url = new URL(request.url);
+    const sameOrigin = url.origin === self.location.origin;
+
+    if (request.method === 'POST' && sameOrigin && SIGN_PAGE.test(url.pathname)) {
+        event.respondWith(submit(request));
+        return;
+    }
+    if (request.method !== 'GET' || (sameOrigin && (url.pathname.startsWith('/api/') || SIGNER_COPY.test(url.pathname)))) {
+        return;
+    }
+    // Assets carry their version in the URL or are pinned to a release, so a cached copy is
+    // always current
+    if (!sameOrigin || url.pathname.startsWith('/static/')) {
+        event.respondWith(cacheFirst(request));
+    } else {
+        event.respondWith(networkFirst(request));
+    }
+});
+
+self.addEventListener('message', event => {
+    const message = event.data || {};
+    if (message.type === 'flush') {
+        event.waitUntil(flush());
+    } else if (message.type === 'precache') {
+        event.waitUntil(precache(message.urls || [], message.prune));
+    }
+});
+
+// Background sync replays the queue even when no page is open; the browser retries the sync
+// while submissions remain
+self.addEventListener('sync', event => {
+    if (event.tag === SYNC_TAG) {
+        event.waitUntil(flush().then(remaining => {
+            if (remaining > 0) {
+                throw new Error(`${remaining} signatures still queued`);
+            }
+        }));
+    }
+});
+
+async function networkFirst(request) {
+    const cache = await caches.open(CACHE);
+    try {
+        const response = await fetch(request);
+        if (response.ok) {
+            await cache.put(request, response.clone());
+        }
+        return response;
+    } catch (error) {
+        const cached = await cache.match(request);
+        if (cached) {
+            return cached;
+        }
+        throw error;
+    }
+}
+
+async function cacheFirst(request) {
+    const cache = await caches.open(CACHE);
+    const cached = await cache.match(request);
+    if (cached) {
+        return cached;
+    }
+    const response = await fetch(request);
+    if (response.ok || response.type === 'opaque') {
+        await forgetOtherVersions(cache, request.url);
+        await cache.put(request, response.clone());
+    }
+    return response;
+}
+
+// forgetOtherVersions removes earlier versions of an asset from the cache
+async function forgetOtherVersions(cache, url) {
+    const { origin, pathname } = new URL(url);
+    for (const cached of await cache.keys()) {
+        const other = new URL(cached.url);
+        if (other.origin === origin && other.pathname === pathname && other.href !== url) {
+            await cache.delete(cached);
+        }
+    }
+}
+
+// precache caches the signing pages of the pending documents and the files they load. With
+// prune, the signing pages of documents no longer listed, which were signed or removed, are
+// dropped.
+async function precache(urls, prune) {
+    const cache = await caches.open(CACHE);
+    const wanted = new Set(urls.map(url => new URL(url, self.location.origin).href));
+    if (prune) {
+        for (const cached of await cache.keys()) {
+            if (new URL(cached.url).pathname.startsWith('/documents/sign/') && !wanted.has(cached.url)) {
+                await cache.delete(cached);
+            }
+        }
+    }
+    await Promise.all(Array.from(wanted, async url => {
+        const { origin, pathname } = new URL(url);
+        const asset = origin !== self.location.origin || pathname.startsWith('/static/');
+        if (asset && await cache.match(url)) {
+            return;
+        }
+        try {
+            const response = await fetch(url, { credentials: 'same-origin' });
+            if (response.ok) {
+                if (asset) {
+                    await forgetOtherVersions(cache, url);
+                }
+                await cache.put(url, response);
+            }
+        } catch (error) {
+            // Offline; the page is cached the next time the list is shown
+        }
+    }));
+}
+
+// submit sends a signature to the service, queueing it when the service cannot be reached.
+// The page is answered with 202 Accepted for a queued submission.
+async function submit(request) {
+    const body = await request.clone().text();
+    try {
+        const response = await fetch(request);
+        if (!UNREACHABLE.includes(response.status)) {
+            return response;
+        }
+    } catch (error) {
+        // Offline; the submission is queued below
+    }
+
+    let id = request.url;
+    try {
+        id = JSON.parse(body).submission_id || id;
+    } catch (error) {
+        // The service rejects the body when it is replayed
+    }
+    await queue('readwrite', store => store.put({ id, url: request.url, body, queued_at: new Date().toISOString() }));
+    if (self.registration.sync) {
+        await self.registration.sync.register(SYNC_TAG).catch(() => {});
+    }
+    await notify();
+    return new Response(JSON.stringify({ status: 'queued' }), {
+        status: 202,
+        headers: { 'Content-Type': 'application/json' },
+    });
+}
+
+let flushing = null;
+
+// flush replays the queued submissions in the order they were queued, one replay at a time,
+// and resolves to the number still queued
+function flush() {
+    if (!flushing) {
+        flushing = replay().finally(() => {
+            flushing = null;
+        });
+    }
+    return flushing;
+}
+
+async function replay() {
+    const submissions = await queue('readonly', store => store.getAll());
+    submissions.sort((a, b) => a.queued_at.localeCompare(b.queued_at));
+    let remaining = submissions.length;
+    for (const submission of submissions) {
+        let response;
+        try {
+            response = await fetch(submission.url, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: submission.body,
+                credentials: 'same-origin',
+            });
+        } catch (error) {
+            break;
+        }
+        if (response.status >= 500 || RETRY.includes(response.status)) {
+            break;
+        }
+        // Accepted, or refused for good, such as for a document removed in the meantime
+        if (!response.ok) {
+            console.error(`Queued signature for ${submission.url} was refused with status ${response.status}`);
+        }
+        await queue('readwrite', store => store.delete(submission.id));
+        remaining--;
+    }
+    await notify();
+    return remaining;
+}
+
+// notify tells the open pages how many submissions are queued
+async function notify() {
+    const queued = await queue('readonly', store => store.count());
+    for (const client of await self.clients.matchAll()) {
+        client.postMessage({ type: 'queue', queued });
+    }
+}
+
+// queue runs an operation on the store of queued submissions, resolving to its result
+function queue(mode, operation) {
+    return new Promise((resolve, reject) => {
+        const open = indexedDB.open(QUEUE_DATABASE, 1);
+        open.onupgradeneeded = () => open.result.createObjectStore(QUEUE_STORE, { keyPath: 'id' });
+        open.onerror = () => reject(open.error);
+        open.onsuccess = () => {
+            const database = open.result;
+            const transaction = database.transaction(QUEUE_STORE, mode);
+            const request = operation(transaction.objectStore(QUEUE_STORE));
+            transaction.oncomplete = () => {
+                database.close();
+                resolve(request.result);
+            };
+            transaction.onerror = () => {
+                database.close();
+                reject(transaction.error);
+            };
+        };
+    });
+}
@@ -0,0 +1,131 @@
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// assetFS holds the stylesheet and scripts of the tablet pages, so they load on networks
+// without internet access
+//
+//go:embed assets
+var assetFS embed.FS
+
+// Prefix is the path assets are served under
+const Prefix = "/static/"
+
+// ServiceWorker is the script of the service worker that keeps the tablet pages working offline.
+// It is served under Prefix but controls every page.
+const ServiceWorker = "sw.js"
+
+// Vendored are the third-party scripts the pages load, pinned to a release. Run
+// `go run ./scripts/vendor` to download them into assets, from where they are embedded, and
+// commit them: the service does not start while any is missing.
+var Vendored = []Vendor{
+	{Name: "htmx.min.js", URL: "https://unpkg.com/htmx.org@1.9.9/dist/htmx.min.js"},
+	{Name: "signature_pad.umd.min.js", URL: "https://cdn.jsdelivr.net/npm/signature_pad@4.1.7/dist/signature_pad.umd.min.js"},
+	{Name: "pdf.min.js", URL: "https://cdnjs.cloudflare.com/ajax/libs/pdf.js/3.11.174/pdf.min.js"},
+	{Name: "pdf.worker.min.js", URL: "https://cdnjs.cloudflare.com/ajax/libs/pdf.js/3.11.174/pdf.worker.min.js"},
+}
+
+// Vendor is a third-party script and the URL of its pinned release
+type Vendor struct {
+	Name string
+	URL  string
+}
+
+// asset is an embedded file with the version its URL carries and its Subresource Integrity
+// hash
+type asset struct {
+	data      []byte
+	version   string
+	integrity string
+}
+
+var assets = loadAssets()
+
+func loadAssets() map[string]asset {
+	loaded := map[string]asset{}
+	entries, err := fs.ReadDir(assetFS, "assets")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := assetFS.ReadFile(path.Join("assets", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		digest := sha256.Sum256(data)
+		integrity := sha512.Sum384(data)
+		loaded[entry.Name()] = asset{
+			data:      data,
+			version:   hex.EncodeToString(digest[:6]),
+			integrity: "sha384-" + base64.StdEncoding.EncodeToString(integrity[:]),
+		}
+	}
+	return loaded
+}
+
+// URL returns the URL of an asset. The URL changes with the asset's content, so browsers can
+// cache it for good.
+func URL(name string) string {
+	if a, ok := assets[name]; ok {
+		return Prefix + name + "?v=" + a.version
+	}
+	return Prefix + name
+}
+
+// Integrity returns the Subresource Integrity hash of an asset, for the integrity attribute of
+// the element loading it
+func Integrity(name string) string {
+	return assets[name].integrity
+}
+
+// Missing returns the names of the vendored scripts that have not been downloaded
+func Missing() []string {
+	var missing []string
+	for _, v := range Vendored {
+		if _, ok := assets[v.Name]; !ok {
+			missing = append(missing, v.Name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// Handler serves the assets under Prefix. Requests for the current version of an asset may be
+// cached for a year; others are revalidated with the asset's ETag.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, Prefix)
+		a, ok := assets[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("v") == a.version {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		if name == ServiceWorker {
+			w.Header().Set("Service-Worker-Allowed", "/")
+		}
+		w.Header().Set("ETag", `"`+a.version+`"`)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(a.data))
+	})
+}
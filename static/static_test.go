@@ -0,0 +1,90 @@
+package static
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURL(t *testing.T) {
+	css, err := assetFS.ReadFile("assets/app.css")
+	assert.NoError(t, err)
+	digest := sha512.Sum384(css)
+	assert.Equal(t, "sha384-"+base64.StdEncoding.EncodeToString(digest[:]), Integrity("app.css"))
+	assert.Regexp(t, `^/static/app\.css\?v=[0-9a-f]{12}$`, URL("app.css"))
+
+	// Vendored scripts are never loaded from their release
+	for _, v := range Vendored {
+		assert.True(t, strings.HasPrefix(URL(v.Name), Prefix), v.Name)
+	}
+}
+
+func TestMissing(t *testing.T) {
+	assert.Empty(t, Missing(), "run `make vendor` and commit the downloaded scripts")
+	for _, v := range Vendored {
+		assert.NotEmpty(t, Integrity(v.Name), v.Name)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	version := assets["app.css"].version
+
+	tests := []struct {
+		name                 string
+		method               string
+		path                 string
+		ifNoneMatch          string
+		expectedStatus       int
+		expectedCacheControl string
+	}{
+		{name: "Current version", method: http.MethodGet, path: URL("app.css"), expectedStatus: http.StatusOK, expectedCacheControl: "public, max-age=31536000, immutable"},
+		{name: "Other version", method: http.MethodGet, path: "/static/app.css?v=0", expectedStatus: http.StatusOK, expectedCacheControl: "no-cache"},
+		{name: "Unchanged", method: http.MethodGet, path: "/static/app.css", ifNoneMatch: `"` + version + `"`, expectedStatus: http.StatusNotModified, expectedCacheControl: "no-cache"},
+		{name: "HEAD", method: http.MethodHead, path: URL("app.css"), expectedStatus: http.StatusOK, expectedCacheControl: "public, max-age=31536000, immutable"},
+		{name: "Unknown asset", method: http.MethodGet, path: "/static/missing.js", expectedStatus: http.StatusNotFound},
+		{name: "Embedded directory", method: http.MethodGet, path: "/static/../static.go", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			Handler().ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCacheControl == "" {
+				return
+			}
+			assert.Equal(t, tt.expectedCacheControl, rr.Header().Get("Cache-Control"))
+			assert.Equal(t, `"`+version+`"`, rr.Header().Get("ETag"))
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "text/css; charset=utf-8", rr.Header().Get("Content-Type"))
+				assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+			}
+			if tt.method == http.MethodGet && tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, string(assets["app.css"].data), rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_ServiceWorker(t *testing.T) {
+	// The service worker controls every page, not only those under Prefix
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, URL(ServiceWorker), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "/", rr.Header().Get("Service-Worker-Allowed"))
+	assert.Equal(t, "text/javascript; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	rr = httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, URL("app.css"), nil))
+	assert.Empty(t, rr.Header().Get("Service-Worker-Allowed"))
+}
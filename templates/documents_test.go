@@ -0,0 +1,59 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/i18n"
+	"github.com/jakubsacha/signature-collector/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignerShownOnceVerified(t *testing.T) {
+	assert.NoError(t, i18n.Init("en"))
+
+	tests := []struct {
+		name          string
+		doc           models.Document
+		expectedShown bool
+	}{
+		{name: "No verification", doc: models.Document{}, expectedShown: true},
+		{name: "Verification pending", doc: models.Document{VerificationMethod: models.VerificationEmailCode}},
+		{name: "Verification failed", doc: models.Document{VerificationMethod: models.VerificationName, VerificationOutcome: models.VerificationFailed}},
+		{name: "Verified", doc: models.Document{VerificationMethod: models.VerificationName, VerificationOutcome: models.VerificationVerified}, expectedShown: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := tt.doc
+			doc.ID = "req1"
+			doc.DocumentTitle = "Agreement"
+			doc.SignerName = "John Smith"
+			doc.SignerEmail = "john@example.com"
+			doc.Status = models.StatusPending
+
+			pages := map[string]func(*bytes.Buffer) error{
+				"documents": func(buf *bytes.Buffer) error {
+					return DocumentsContent("device1", []models.Document{doc}, "").Render(context.Background(), buf)
+				},
+				"signature": func(buf *bytes.Buffer) error {
+					return SignaturePage(doc, doc.ID, time.Now()).Render(context.Background(), buf)
+				},
+			}
+			for page, render := range pages {
+				var buf bytes.Buffer
+				assert.NoError(t, render(&buf), page)
+				if tt.expectedShown {
+					assert.Contains(t, buf.String(), "John Smith", page)
+					assert.Contains(t, buf.String(), "john@example.com", page)
+				} else {
+					assert.NotContains(t, buf.String(), "John Smith", page)
+					assert.NotContains(t, buf.String(), "john@example.com", page)
+					assert.Contains(t, buf.String(), "j•••@example.com", page)
+				}
+			}
+		})
+	}
+}
@@ -11,14 +11,11 @@ import templruntime "github.com/a-h/templ/runtime"
 import (
 	"github.com/jakubsacha/signature-collector/i18n"
 	"github.com/jakubsacha/signature-collector/models"
+	"github.com/jakubsacha/signature-collector/static"
 	"time"
 )
 
-var location, _ = time.LoadLocation("Europe/Warsaw")
-var now = time.Now().In(location)
-var selectAllRendered = false
-
-func SignaturePage(doc models.Document, requestID string) templ.Component {
+func SignaturePage(doc models.Document, requestID string, now time.Time) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -47,13 +44,83 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 		var templ_7745c5c3_Var2 string
 		templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(doc.DocumentTitle)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 17, Col: 67}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 15, Col: 67}
 		}
 		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</h1><!-- Document Content --><div class=\"mb-8\">")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</h1>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if doc.IsPDF() {
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!-- PDF pages, drawn by pdf.js with the signature fields marked --> <div id=\"documentPages\" class=\"mb-8 space-y-4\" data-pdf-url=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var3 string
+			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs("/documents/sign/" + requestID + "/pdf")
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 21, Col: 74}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\" data-worker-url=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var4 string
+			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(static.URL("pdf.worker.min.js"))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 22, Col: 69}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\"></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templ.JSONScript("signatureFields", doc.SignatureFields).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(" <script src=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var5 string
+			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(static.URL("pdf.min.js"))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 25, Col: 54}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\" integrity=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var6 string
+			templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(static.Integrity("pdf.min.js"))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 25, Col: 99}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\"></script>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!-- Document Content --><div class=\"mb-8\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -63,12 +130,12 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var3 string
-				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T("SelectAll", nil))
+				var templ_7745c5c3_Var7 string
+				templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "SelectAll", nil))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 32, Col: 62}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 41, Col: 67}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
@@ -87,12 +154,12 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var4 string
-				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(section.Content)
+				var templ_7745c5c3_Var8 string
+				templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(section.Content)
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 40, Col: 48}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 49, Col: 48}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
@@ -110,12 +177,12 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					var templ_7745c5c3_Var5 string
-					templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs("consent_" + *section.ConsentType)
+					var templ_7745c5c3_Var9 string
+					templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs("consent_" + *section.ConsentType)
 					if templ_7745c5c3_Err != nil {
-						return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 49, Col: 84}
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 58, Col: 84}
 					}
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
@@ -128,12 +195,12 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var6 string
-				templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs("consent_" + *section.ConsentType)
+				var templ_7745c5c3_Var10 string
+				templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs("consent_" + *section.ConsentType)
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 55, Col: 80}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 64, Col: 80}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
@@ -158,7 +225,7 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 					return templ_7745c5c3_Err
 				}
 				if section.ConsentMandatory != nil && *section.ConsentMandatory {
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<span class=\"absolute top-1 left-0 w-4 h-4 bg-blue-500 text-white flex items-center justify-center font-bold text-center cursor-not-allowed rounded-sm\"><span class=\"text-sm text-center ml-[1px]\">&check;</span></span>")
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<span class=\"absolute top-1 start-0 w-4 h-4 bg-blue-500 text-white flex items-center justify-center font-bold text-center cursor-not-allowed rounded-sm\"><span class=\"text-sm text-center ms-[1px]\">&check;</span></span>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
@@ -173,12 +240,12 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 						return templ_7745c5c3_Err
 					}
 				}
-				var templ_7745c5c3_Var7 string
-				templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(section.Content)
+				var templ_7745c5c3_Var11 string
+				templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(section.Content)
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 75, Col: 49}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 84, Col: 49}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
@@ -188,68 +255,183 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 				}
 			}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</div><!-- Signature Canvas --><div class=\"mb-8\"><h2 class=\"text-xl font-semibold mb-4\">")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var8 string
-		templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T("Signature", nil))
-		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 85, Col: 81}
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
+		if len(doc.Attachments) > 0 {
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!-- Attachments --> <div class=\"mb-8\"><h2 class=\"text-xl font-semibold mb-4\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var12 string
+			templ_7745c5c3_Var12, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "Attachments", nil))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 95, Col: 92}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</h2><ul class=\"space-y-4\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for _, attachment := range doc.Attachments {
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<li>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				if attachment.IsImage() {
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<div class=\"mb-1\"><img src=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var13 string
+					templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs("/documents/sign/" + requestID + "/attachments/" + attachment.ID)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 102, Col: 114}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\" alt=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var14 string
+					templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(attachment.Filename)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 103, Col: 69}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var14))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\" class=\"max-h-64 rounded border border-gray-300\"></div>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<a href=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var15 templ.SafeURL = templ.SafeURL("/documents/sign/" + requestID + "/attachments/" + attachment.ID)
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(string(templ_7745c5c3_Var15)))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\" target=\"_blank\" class=\"text-blue-600 hover:underline\">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var16 string
+				templ_7745c5c3_Var16, templ_7745c5c3_Err = templ.JoinStringErrs(attachment.Filename)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 112, Col: 54}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var16))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</a></li>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</ul></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</h2><div class=\"flex gap-2 items-center mb-2\"><h3 class=\"text-lg \">")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!-- Signature Canvas --><div class=\"mb-8\"><h2 class=\"text-xl font-semibold mb-4\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var9 string
-		templ_7745c5c3_Var9, templ_7745c5c3_Err = templ.JoinStringErrs(doc.SignerName)
+		var templ_7745c5c3_Var17 string
+		templ_7745c5c3_Var17, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "Signature", nil))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 88, Col: 39}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 121, Col: 86}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var9))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var17))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(" <span class=\"text-gray-500\">(")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</h2><div class=\"flex gap-2 items-center mb-2\"><h3 class=\"text-lg \">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var10 string
-		templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(doc.SignerEmail)
-		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 88, Col: 86}
-		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
-		if templ_7745c5c3_Err != nil {
-			return templ_7745c5c3_Err
+		if doc.VerificationRequired() {
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<span class=\"text-gray-500\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var18 string
+			templ_7745c5c3_Var18, templ_7745c5c3_Err = templ.JoinStringErrs(models.MaskEmail(doc.SignerEmail))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 125, Col: 90}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var18))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</span>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		} else {
+			var templ_7745c5c3_Var19 string
+			templ_7745c5c3_Var19, templ_7745c5c3_Err = templ.JoinStringErrs(doc.SignerName)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 127, Col: 43}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var19))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(" <span class=\"text-gray-500\">(")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var20 string
+			templ_7745c5c3_Var20, templ_7745c5c3_Err = templ.JoinStringErrs(doc.SignerEmail)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 127, Col: 90}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var20))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(")</span>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(")</span></h3><span class=\"text-gray-500\">")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</h3><span class=\"text-gray-500\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var11 string
-		templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(now.Format("02.01.2006"))
+		var templ_7745c5c3_Var21 string
+		templ_7745c5c3_Var21, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.FormatDate(ctx, now))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 91, Col: 46}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 131, Col: 47}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var21))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</span></div><div class=\"border-2 border-gray-300 rounded-lg\"><canvas id=\"signatureCanvas\" class=\"w-full h-64 rounded cursor-crosshair\"></canvas></div><div class=\"mt-4 flex justify-end space-x-4\"><button id=\"clearButton\" class=\"bg-[#F6F0E4] text-black px-4 py-2 rounded-full hover:bg-[#F6F0E4] transition-colors\">")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</span></div><div class=\"border-2 border-gray-300 rounded-lg\"><canvas id=\"signatureCanvas\" class=\"w-full h-64 rounded cursor-crosshair\"></canvas></div><div class=\"mt-4 flex justify-end gap-4\"><button id=\"clearButton\" class=\"bg-[#F6F0E4] text-black px-4 py-2 rounded-full hover:bg-[#F6F0E4] transition-colors\">")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var12 string
-		templ_7745c5c3_Var12, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T("Clear", nil))
+		var templ_7745c5c3_Var22 string
+		templ_7745c5c3_Var22, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "Clear", nil))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 102, Col: 46}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 142, Col: 51}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var22))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -257,12 +439,12 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var13 string
-		templ_7745c5c3_Var13, templ_7745c5c3_Err = templ.JoinStringErrs(requestID)
+		var templ_7745c5c3_Var23 string
+		templ_7745c5c3_Var23, templ_7745c5c3_Err = templ.JoinStringErrs(requestID)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 107, Col: 51}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 147, Col: 51}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var13))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var23))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -270,12 +452,12 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var14 string
-		templ_7745c5c3_Var14, templ_7745c5c3_Err = templ.JoinStringErrs(doc.DeviceID)
+		var templ_7745c5c3_Var24 string
+		templ_7745c5c3_Var24, templ_7745c5c3_Err = templ.JoinStringErrs(doc.DeviceID)
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 108, Col: 53}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 148, Col: 53}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var14))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var24))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -283,30 +465,113 @@ func SignaturePage(doc models.Document, requestID string) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		var templ_7745c5c3_Var15 string
-		templ_7745c5c3_Var15, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T("Submit", nil))
+		var templ_7745c5c3_Var25 string
+		templ_7745c5c3_Var25, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "Submit", nil))
 		if templ_7745c5c3_Err != nil {
-			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 110, Col: 47}
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 150, Col: 52}
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var15))
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var25))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</button></div></div></div></div>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</button></div></div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		if doc.Review {
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<!-- Summary of the consents and signature, confirmed before they are submitted --> <div id=\"reviewPanel\" class=\"mb-8\" hidden><h2 class=\"text-xl font-semibold mb-4\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var26 string
+			templ_7745c5c3_Var26, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "ReviewTitle", nil))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 158, Col: 92}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var26))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</h2><p class=\"text-gray-700 mb-4\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var27 string
+			templ_7745c5c3_Var27, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "ReviewIntro", nil))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 159, Col: 83}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var27))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</p><ul id=\"reviewConsents\" class=\"space-y-4 mb-4\"></ul><img id=\"reviewSignature\" alt=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var28 string
+			templ_7745c5c3_Var28, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "Signature", nil))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 161, Col: 81}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var28))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("\" class=\"max-h-64 mx-auto border border-gray-300 rounded mb-4\"><div class=\"flex justify-end gap-4\"><button id=\"reviewBackButton\" class=\"bg-[#F6F0E4] text-black px-4 py-2 rounded-full hover:bg-[#F6F0E4] transition-colors\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var29 string
+			templ_7745c5c3_Var29, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "Back", nil))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 167, Col: 54}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var29))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</button> <button id=\"reviewConfirmButton\" class=\"bg-[#FF7355] text-white px-4 py-2 rounded-full hover:bg-[#FE8460] transition-colors\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var30 string
+			templ_7745c5c3_Var30, templ_7745c5c3_Err = templ.JoinStringErrs(i18n.T(ctx, "ConfirmAndSubmit", nil))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `templates/signature.templ`, Line: 173, Col: 66}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var30))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</button></div></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("</div></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
 		templ_7745c5c3_Err = templ.JSONScript("translations", map[string]string{
-			"pleaseSignBeforeSubmitting": i18n.T("PleaseSignBeforeSubmitting", nil),
-			"failedToSubmitSignature":    i18n.T("FailedToSubmitSignature", nil),
-			"error":                      i18n.T("Error", nil),
-			"signatureSubmitted":         i18n.T("SignatureSubmitted", nil),
-			"complete":                   i18n.T("Complete", nil),
+			"pleaseSignBeforeSubmitting": i18n.T(ctx, "PleaseSignBeforeSubmitting", nil),
+			"failedToSubmitSignature":    i18n.T(ctx, "FailedToSubmitSignature", nil),
+			"signatureRejected":          i18n.T(ctx, "SignatureRejected", nil),
+			"signHere":                   i18n.T(ctx, "SignHere", nil),
+			"failedToLoadDocument":       i18n.T(ctx, "FailedToLoadDocument", nil),
+			"error":                      i18n.T(ctx, "Error", nil),
+			"signatureSubmitted":         i18n.T(ctx, "SignatureSubmitted", nil),
+			"signatureQueued":            i18n.T(ctx, "SignatureQueued", nil),
+			"complete":                   i18n.T(ctx, "Complete", nil),
+			"consentGranted":             i18n.T(ctx, "ConsentGranted", nil),
+			"consentDenied":              i18n.T(ctx, "ConsentDenied", nil),
+			"scanForCopy":                i18n.T(ctx, "ScanForCopy", nil),
+			"openLinkForCopy":            i18n.T(ctx, "OpenLinkForCopy", nil),
 		}).Render(ctx, templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<script>\n        const translations = JSON.parse(document.getElementById('translations').textContent);\n\n        document.addEventListener('DOMContentLoaded', function() {\n            const canvas = document.getElementById('signatureCanvas');\n\n            // Select all consents\n            document.getElementById('selectAllConsents').addEventListener('change', function() {\n                document.querySelectorAll('input[type=\"checkbox\"][name^=\"consent_\"]').forEach(input => {\n                    input.checked = this.checked;\n                });\n            });\n\n            // Set canvas size\n            function resizeCanvas() {\n                const rect = canvas.getBoundingClientRect();\n                canvas.width = rect.width;\n                canvas.height = rect.height;\n            }\n            resizeCanvas();\n            window.addEventListener('resize', resizeCanvas);\n\n            // Initialize SignaturePad\n            const signaturePad = new SignaturePad(canvas);\n\n            // Clear button\n            document.getElementById('clearButton').addEventListener('click', () => {\n                signaturePad.clear();\n            });\n\n            // Submit button\n            document.getElementById('submitButton').addEventListener('click', async () => {\n                if (signaturePad.isEmpty()) {\n                    alert(translations.pleaseSignBeforeSubmitting);\n                    return;\n                }\n\n                const requestID = document.getElementById('submitButton').dataset.requestId;\n                const deviceID = document.getElementById('submitButton').dataset.deviceId;\n                const signatureData = signaturePad.toDataURL();\n\n                // Get all consent checkboxes\n                const consentInputs = document.querySelectorAll('input[type=\"checkbox\"][name^=\"consent_\"]');\n                const consents = Array.from(consentInputs).map(input => ({\n                    consent_type: input.name.replace('consent_', ''),\n                    granted: input.checked,\n                    timestamp: new Date().toISOString()\n                }));\n\n                try {\n                    const response = await fetch(`/documents/sign/${requestID}`, {\n                        method: 'POST',\n                        headers: {\n                            'Content-Type': 'application/json',\n                        },\n                        body: JSON.stringify({\n                            signature_data: signatureData,\n                            consents: consents\n                        }),\n                    });\n\n                    if (response.ok) {\n                        // Show confirmation message and return button\n                        const confirmationMessage = document.createElement('div');\n                        confirmationMessage.className = 'text-center mt-8';\n                        confirmationMessage.innerHTML = `\n                            <p class=\"text-lg font-semibold mb-4\">${translations.signatureSubmitted}</p>\n                            <button \n                                id=\"returnButton\"\n                                class=\"bg-[#FF7355] text-white px-4 py-2 rounded-full hover:bg-[#FE8460] transition-colors\"\n                            >\n                                ${translations.complete}\n                            </button>\n                        `;\n                        document.querySelector('.container div').replaceChildren(confirmationMessage);\n\n                        // Add event listener to the return button\n                        document.getElementById('returnButton').addEventListener('click', () => {\n                            window.location.href = '/documents/' + deviceID;\n                        });\n                    } else {\n                        console.error(translations.failedToSubmitSignature);\n                    }\n                } catch (error) {\n                    console.error(translations.error, error);\n                }\n            });\n        });\n    </script>")
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString("<script>\n        const translations = JSON.parse(document.getElementById('translations').textContent);\n\n        // Draw every page of a PDF document and mark the fields it will be signed in\n        async function renderDocumentPages(container, fields) {\n            pdfjsLib.GlobalWorkerOptions.workerSrc = container.dataset.workerUrl;\n            const pdf = await pdfjsLib.getDocument(container.dataset.pdfUrl).promise;\n            for (let number = 1; number <= pdf.numPages; number++) {\n                const page = await pdf.getPage(number);\n                const viewport = page.getViewport({ scale: 1 });\n                const scaled = page.getViewport({ scale: container.clientWidth * window.devicePixelRatio / viewport.width });\n\n                const wrapper = document.createElement('div');\n                wrapper.className = 'relative border border-gray-300';\n                const pageCanvas = document.createElement('canvas');\n                pageCanvas.className = 'block w-full';\n                pageCanvas.width = scaled.width;\n                pageCanvas.height = scaled.height;\n                wrapper.appendChild(pageCanvas);\n\n                // Fields are in points from the bottom left corner of the page; the viewport\n                // converts them to the rendered page, taking its rotation into account\n                fields.filter(field => field.page === number).forEach(field => {\n                    const [x1, y1, x2, y2] = viewport.convertToViewportRectangle([field.x, field.y, field.x + field.width, field.y + field.height]);\n                    const box = document.createElement('div');\n                    box.className = 'absolute flex items-end p-1 border-2 border-dashed border-[#FF7355] bg-[#FF7355]/10 text-xs text-[#FF7355]';\n                    box.style.left = (100 * Math.min(x1, x2) / viewport.width) + '%';\n                    box.style.top = (100 * Math.min(y1, y2) / viewport.height) + '%';\n                    box.style.width = (100 * Math.abs(x2 - x1) / viewport.width) + '%';\n                    box.style.height = (100 * Math.abs(y2 - y1) / viewport.height) + '%';\n                    box.textContent = translations.signHere;\n                    wrapper.appendChild(box);\n                });\n\n                container.appendChild(wrapper);\n                await page.render({ canvasContext: pageCanvas.getContext('2d'), viewport: scaled }).promise;\n            }\n        }\n\n        document.addEventListener('DOMContentLoaded', function() {\n            const canvas = document.getElementById('signatureCanvas');\n\n            const documentPages = document.getElementById('documentPages');\n            if (documentPages) {\n                const fields = JSON.parse(document.getElementById('signatureFields').textContent) || [];\n                renderDocumentPages(documentPages, fields).catch(error => {\n                    console.error(translations.failedToLoadDocument, error);\n                });\n            }\n\n            // Select all consents\n            const selectAll = document.getElementById('selectAllConsents');\n            if (selectAll) {\n                selectAll.addEventListener('change', function() {\n                    document.querySelectorAll('input[type=\"checkbox\"][name^=\"consent_\"]').forEach(input => {\n                        input.checked = this.checked;\n                    });\n                });\n            }\n\n            // Set canvas size\n            function resizeCanvas() {\n                const rect = canvas.getBoundingClientRect();\n                canvas.width = rect.width;\n                canvas.height = rect.height;\n            }\n            resizeCanvas();\n            window.addEventListener('resize', resizeCanvas);\n\n            // Initialize SignaturePad\n            const signaturePad = new SignaturePad(canvas);\n\n            // Clear button\n            document.getElementById('clearButton').addEventListener('click', () => {\n                signaturePad.clear();\n            });\n\n            // Every submission from this page has the same ID, so that one repeated, or replayed\n            // after the connection dropped, completes the document only once\n            const submissionID = crypto.randomUUID ? crypto.randomUUID() : Date.now().toString(36) + Math.random().toString(36).slice(2);\n\n            // Show confirmation message and return button, with the link to the signer's copy of\n            // the document when the service offers one\n            function showConfirmation(message, result) {\n                const deviceID = document.getElementById('submitButton').dataset.deviceId;\n                const confirmationMessage = document.createElement('div');\n                confirmationMessage.className = 'text-center mt-8';\n                confirmationMessage.innerHTML = `\n                    <p class=\"text-lg font-semibold mb-4\">${message}</p>\n                    <button \n                        id=\"returnButton\"\n                        class=\"bg-[#FF7355] text-white px-4 py-2 rounded-full hover:bg-[#FE8460] transition-colors\"\n                    >\n                        ${translations.complete}\n                    </button>\n                `;\n                if (result && result.copy_url) {\n                    const copy = document.createElement('div');\n                    copy.className = 'mb-4';\n                    const hint = document.createElement('p');\n                    hint.className = 'text-gray-700 mb-2';\n                    hint.textContent = result.copy_qr_url ? translations.scanForCopy : translations.openLinkForCopy;\n                    copy.appendChild(hint);\n                    if (result.copy_qr_url) {\n                        const code = document.createElement('img');\n                        code.src = result.copy_qr_url;\n                        code.alt = result.copy_url;\n                        code.className = 'block mx-auto h-64 mb-2';\n                        copy.appendChild(code);\n                    }\n                    const link = document.createElement('p');\n                    link.className = 'font-mono break-all';\n                    link.textContent = result.copy_url;\n                    copy.appendChild(link);\n                    confirmationMessage.insertBefore(copy, confirmationMessage.querySelector('#returnButton'));\n                }\n                document.querySelector('.container div').replaceChildren(confirmationMessage);\n\n                // A signer signing remotely in their own browser has no tablet list to return to\n                const returnButton = document.getElementById('returnButton');\n                if (deviceID) {\n                    returnButton.addEventListener('click', () => {\n                        window.location.href = '/documents/' + deviceID;\n                    });\n                } else {\n                    returnButton.remove();\n                }\n            }\n\n            // Show either the document with the signature pad or, for a document reviewed before\n            // it is submitted, only the summary of what is about to be submitted\n            const reviewPanel = document.getElementById('reviewPanel');\n            let reviewed = null;\n            function showReview(visible) {\n                Array.from(reviewPanel.parentElement.children).forEach(child => {\n                    child.hidden = visible !== (child === reviewPanel);\n                });\n                window.scrollTo(0, 0);\n            }\n\n            function review(submission, consentInputs) {\n                const items = Array.from(consentInputs).map(input => {\n                    const item = document.createElement('li');\n                    const status = document.createElement('span');\n                    status.className = 'font-semibold';\n                    status.textContent = (input.checked ? translations.consentGranted : translations.consentDenied) + ': ';\n                    const text = input.closest('label').querySelector('.flex-1').textContent;\n                    item.append(status, text.replace(/^\\s*\\*/, '').trim());\n                    return item;\n                });\n                document.getElementById('reviewConsents').replaceChildren(...items);\n                document.getElementById('reviewSignature').src = submission.signature_data;\n                reviewed = submission;\n                showReview(true);\n            }\n\n            // Send a signature; it was captured when the signer submitted or confirmed it\n            async function submit(submission) {\n                const requestID = document.getElementById('submitButton').dataset.requestId;\n                try {\n                    const response = await fetch(`/documents/sign/${requestID}`, {\n                        method: 'POST',\n                        headers: {\n                            'Content-Type': 'application/json',\n                        },\n                        body: JSON.stringify({ ...submission, captured_at: new Date().toISOString() }),\n                    });\n\n                    if (response.status === 202) {\n                        // The tablet is offline: the service worker queued the signature and sends\n                        // it when the connection is back\n                        showConfirmation(translations.signatureQueued);\n                    } else if (response.ok) {\n                        showConfirmation(translations.signatureSubmitted, await response.json());\n                    } else if (response.status === 422 && (await response.json()).code === 'invalid_signature') {\n                        // The server found no usable signature in what was drawn\n                        alert(translations.signatureRejected);\n                        signaturePad.clear();\n                        if (reviewPanel) {\n                            showReview(false);\n                        }\n                    } else {\n                        console.error(translations.failedToSubmitSignature, response.status);\n                        alert(translations.failedToSubmitSignature);\n                    }\n                } catch (error) {\n                    // Without a service worker nothing was queued; the signature stays on the pad\n                    // to be submitted again\n                    console.error(translations.error, error);\n                    alert(translations.failedToSubmitSignature);\n                }\n            }\n\n            // Submit button\n            document.getElementById('submitButton').addEventListener('click', async () => {\n                if (signaturePad.isEmpty()) {\n                    alert(translations.pleaseSignBeforeSubmitting);\n                    return;\n                }\n\n                // Keep the raw stroke points so the signature can be re-rendered and verified\n                const signatureStrokes = {\n                    width: canvas.width,\n                    height: canvas.height,\n                    strokes: signaturePad.toData().map(group => ({\n                        pen_color: group.penColor,\n                        min_width: group.minWidth,\n                        max_width: group.maxWidth,\n                        points: group.points.map(point => ({\n                            x: point.x,\n                            y: point.y,\n                            time: point.time,\n                            pressure: point.pressure\n                        }))\n                    }))\n                };\n\n                // Get all consent checkboxes\n                const consentInputs = document.querySelectorAll('input[type=\"checkbox\"][name^=\"consent_\"]');\n                const consents = Array.from(consentInputs).map(input => ({\n                    consent_type: input.name.replace('consent_', ''),\n                    granted: input.checked,\n                    timestamp: new Date().toISOString()\n                }));\n\n                const submission = {\n                    signature_data: signaturePad.toDataURL(),\n                    signature_strokes: signatureStrokes,\n                    consents: consents,\n                    submission_id: submissionID\n                };\n                if (reviewPanel) {\n                    review(submission, consentInputs);\n                } else {\n                    await submit(submission);\n                }\n            });\n\n            if (reviewPanel) {\n                document.getElementById('reviewBackButton').addEventListener('click', () => {\n                    reviewed = null;\n                    showReview(false);\n                });\n                document.getElementById('reviewConfirmButton').addEventListener('click', async () => {\n                    if (reviewed) {\n                        await submit(reviewed);\n                    }\n                });\n            }\n        });\n    </script>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
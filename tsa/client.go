@@ -0,0 +1,127 @@
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxResponseBytes bounds the size of a timestamp response
+const maxResponseBytes = 1 << 20
+
+// Client requests timestamps from an RFC 3161 timestamp authority over HTTP
+type Client struct {
+	url      string
+	client   *http.Client
+	username string
+	password string
+	policy   asn1.ObjectIdentifier
+}
+
+// NewClient creates a client for the timestamp authority at url
+func NewClient(url string) *Client {
+	return &Client{
+		url: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// WithClient sets a custom HTTP client
+func (c *Client) WithClient(client *http.Client) *Client {
+	c.client = client
+	return c
+}
+
+// WithCredentials sets HTTP basic auth credentials, as required by some commercial authorities
+func (c *Client) WithCredentials(username, password string) *Client {
+	c.username = username
+	c.password = password
+	return c
+}
+
+// WithPolicy requests timestamps under a specific policy of the authority
+func (c *Client) WithPolicy(policy asn1.ObjectIdentifier) *Client {
+	c.policy = policy
+	return c
+}
+
+// Timestamp requests a timestamp token for a SHA-256 digest. The token is checked to be for the
+// digest and validly signed before it is returned.
+func (c *Client) Timestamp(ctx context.Context, digest []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("error generating nonce: %v", err)
+	}
+	body, err := newRequest(digest, nonce, c.policy)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding timestamp request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating timestamp request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	req.Header.Set("Accept", "application/timestamp-reply")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting timestamp: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp authority returned status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error reading timestamp response: %v", err)
+	}
+
+	der, err := parseResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	token, err := ParseToken(der)
+	if err != nil {
+		return nil, err
+	}
+	if token.Nonce == nil || token.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("%w: the nonce does not match the request", ErrInvalidToken)
+	}
+	if err := token.Verify(crypto.SHA256, digest, nil); err != nil {
+		return nil, err
+	}
+	return der, nil
+}
+
+// parseResponse returns the token of a granted timestamp response
+func parseResponse(data []byte) ([]byte, error) {
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("error parsing timestamp response: %v", err)
+	}
+	if resp.Status.Status != statusGranted && resp.Status.Status != statusGrantedWithMods {
+		message := fmt.Sprintf("timestamp request rejected with status %d", resp.Status.Status)
+		if len(resp.Status.StatusString) > 0 {
+			message += ": " + strings.Join(resp.Status.StatusString, "; ")
+		}
+		return nil, fmt.Errorf("%s", message)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp response has no token")
+	}
+	return resp.TimeStampToken.FullBytes, nil
+}
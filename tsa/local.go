@@ -0,0 +1,189 @@
+package tsa
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/cms"
+)
+
+// LocalPolicy is the policy local timestamps are issued under. It is the example policy of the
+// OpenSSL TSA configuration and carries no trust.
+var LocalPolicy = asn1.ObjectIdentifier{1, 2, 3, 4, 1}
+
+// oidExtKeyUsage is the extended key usage certificate extension
+var oidExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+
+// oidKeyPurposeTimeStamping is the timestamping extended key usage
+var oidKeyPurposeTimeStamping = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+
+// LocalTSA is an in-process stand-in for a timestamp authority, for development and tests. It
+// issues real RFC 3161 tokens with a throwaway root and signing certificate generated at start,
+// so its timestamps only prove what the service's own clock said.
+type LocalTSA struct {
+	root        *x509.Certificate
+	certificate *x509.Certificate
+	key         crypto.Signer
+	timeNow     func() time.Time
+
+	mu     sync.Mutex
+	serial *big.Int
+}
+
+// NewLocalTSA creates a local timestamp authority with freshly generated keys
+func NewLocalTSA() (*LocalTSA, error) {
+	now := time.Now()
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating local TSA root key: %v", err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Signature Collector local TSA root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating local TSA root certificate: %v", err)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("error generating local TSA key: %v", err)
+	}
+	// RFC 3161 requires the timestamping extended key usage to be the only one and critical,
+	// which x509.CreateCertificate cannot express, so the extension is encoded here
+	extKeyUsage, err := asn1.Marshal([]asn1.ObjectIdentifier{oidKeyPurposeTimeStamping})
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		Subject:         pkix.Name{CommonName: "Signature Collector local TSA"},
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.AddDate(10, 0, 0),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: extKeyUsage}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, root, key.Public(), rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("error creating local TSA certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalTSA{
+		root:        root,
+		certificate: certificate,
+		key:         key,
+		timeNow:     time.Now,
+		serial:      big.NewInt(now.UnixNano()),
+	}, nil
+}
+
+// Root returns the root certificate local timestamps chain to
+func (l *LocalTSA) Root() *x509.Certificate {
+	return l.root
+}
+
+// Timestamp issues a timestamp token for a SHA-256 digest
+func (l *LocalTSA) Timestamp(ctx context.Context, digest []byte) ([]byte, error) {
+	return l.issue(messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: cms.OIDDigestSHA256, Parameters: asn1.NullRawValue},
+		HashedMessage: digest,
+	}, nil)
+}
+
+// ServeHTTP answers RFC 3161 requests over HTTP, so clients can be pointed at a local authority
+func (l *LocalTSA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxResponseBytes))
+	if err != nil {
+		http.Error(w, "Error reading request", http.StatusBadRequest)
+		return
+	}
+
+	var resp timeStampResp
+	var req timeStampReq
+	if _, err := asn1.Unmarshal(data, &req); err != nil {
+		resp.Status = rejection(failBadDataFormat, "malformed timestamp request")
+	} else if _, err := cms.HashFunc(req.MessageImprint.HashAlgorithm.Algorithm); err != nil {
+		resp.Status = rejection(failBadAlg, "unsupported hash algorithm")
+	} else if req.ReqPolicy != nil && !req.ReqPolicy.Equal(LocalPolicy) {
+		resp.Status = rejection(failBadRequest, "unsupported policy")
+	} else if token, err := l.issue(req.MessageImprint, req.Nonce); err != nil {
+		log.Printf("Error issuing local timestamp: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	} else {
+		resp.TimeStampToken = asn1.RawValue{FullBytes: token}
+	}
+
+	body, err := asn1.Marshal(resp)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.Write(body)
+}
+
+func (l *LocalTSA) issue(imprint messageImprint, nonce *big.Int) ([]byte, error) {
+	l.mu.Lock()
+	serial := new(big.Int).Add(l.serial, big.NewInt(1))
+	l.serial = serial
+	l.mu.Unlock()
+
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         LocalPolicy,
+		MessageImprint: imprint,
+		SerialNumber:   serial,
+		GenTime:        l.timeNow().UTC().Truncate(time.Second),
+		Accuracy:       accuracy{Seconds: 1},
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding timestamp info: %v", err)
+	}
+	attribute, err := cms.NewSigningCertificateAttribute(l.certificate)
+	if err != nil {
+		return nil, err
+	}
+	return cms.Sign(info, l.certificate, l.key, cms.SignOptions{
+		ContentType:      OIDTSTInfo,
+		SignedAttributes: []cms.Attribute{attribute},
+	})
+}
+
+func rejection(failure int, message string) pkiStatusInfo {
+	failInfo := asn1.BitString{Bytes: make([]byte, 1+failure/8), BitLength: failure + 1}
+	failInfo.Bytes[failure/8] |= 0x80 >> (failure % 8)
+	return pkiStatusInfo{Status: statusRejection, StatusString: []string{message}, FailInfo: failInfo}
+}
@@ -0,0 +1,220 @@
+// Package tsa implements RFC 3161 trusted timestamps: a client for external timestamp
+// authorities, a local stand-in authority for development and tests, and token verification.
+package tsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/jakubsacha/signature-collector/cms"
+)
+
+// OIDTSTInfo is the content type of a timestamp token's signed content
+var OIDTSTInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+
+// ErrInvalidToken is returned when a timestamp token does not verify
+var ErrInvalidToken = errors.New("invalid timestamp token")
+
+// Timestamper obtains RFC 3161 timestamp tokens
+type Timestamper interface {
+	// Timestamp returns the DER encoded timestamp token for a SHA-256 digest
+	Timestamp(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+// PKI statuses of a timestamp response
+const (
+	statusGranted         = 0
+	statusGrantedWithMods = 1
+	statusRejection       = 2
+)
+
+// Failure info bits of a rejected request
+const (
+	failBadAlg        = 0
+	failBadRequest    = 2
+	failBadDataFormat = 5
+)
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional,default:false"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,explicit,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// Token is a parsed RFC 3161 timestamp token
+type Token struct {
+	// Raw is the DER encoded token
+	Raw []byte
+	// GenTime is the time the authority asserts the digest existed at
+	GenTime time.Time
+	// Accuracy is the authority's stated accuracy of GenTime, zero when not given
+	Accuracy     time.Duration
+	SerialNumber *big.Int
+	Policy       asn1.ObjectIdentifier
+	// Hash and HashedMessage are the timestamped digest
+	Hash          crypto.Hash
+	HashedMessage []byte
+	Nonce         *big.Int
+	// Certificate is the authority's signing certificate, when included in the token
+	Certificate *x509.Certificate
+
+	signedData *cms.SignedData
+}
+
+// ParseToken parses a DER encoded timestamp token
+func ParseToken(der []byte) (*Token, error) {
+	signedData, err := cms.Parse(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timestamp token: %v", err)
+	}
+	if !signedData.ContentType.Equal(OIDTSTInfo) {
+		return nil, fmt.Errorf("timestamp token content type %v is not TSTInfo", signedData.ContentType)
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(signedData.Content, &info); err != nil {
+		return nil, fmt.Errorf("error parsing timestamp token info: %v", err)
+	}
+	hash, err := cms.HashFunc(info.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Raw:           der,
+		GenTime:       info.GenTime.UTC(),
+		SerialNumber:  info.SerialNumber,
+		Policy:        info.Policy,
+		Hash:          hash,
+		HashedMessage: info.MessageImprint.HashedMessage,
+		Nonce:         info.Nonce,
+		Accuracy: time.Duration(info.Accuracy.Seconds)*time.Second +
+			time.Duration(info.Accuracy.Millis)*time.Millisecond +
+			time.Duration(info.Accuracy.Micros)*time.Microsecond,
+		signedData: signedData,
+	}
+	if certificate, err := signedData.SignerCertificate(); err == nil {
+		token.Certificate = certificate
+	}
+	return token, nil
+}
+
+// Verify checks that the token timestamps digest and is validly signed by a timestamping
+// certificate. With roots the certificate must also chain to one of them at GenTime.
+func (t *Token) Verify(hash crypto.Hash, digest []byte, roots *x509.CertPool) error {
+	if t.Hash != hash || !bytes.Equal(t.HashedMessage, digest) {
+		return fmt.Errorf("%w: the token is for another digest", ErrInvalidToken)
+	}
+	certificate, err := t.signedData.Verify(nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := t.signedData.CheckSigningCertificate(certificate); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	timestamping := false
+	for _, usage := range certificate.ExtKeyUsage {
+		timestamping = timestamping || usage == x509.ExtKeyUsageTimeStamping
+	}
+	if !timestamping {
+		return fmt.Errorf("%w: the signing certificate is not for timestamping", ErrInvalidToken)
+	}
+	if t.GenTime.Before(certificate.NotBefore) || t.GenTime.After(certificate.NotAfter) {
+		return fmt.Errorf("%w: the signing certificate was not valid at %s", ErrInvalidToken, t.GenTime.Format(time.RFC3339))
+	}
+
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, c := range t.signedData.Certificates {
+			intermediates.AddCert(c)
+		}
+		_, err := certificate.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   t.GenTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+	}
+	return nil
+}
+
+// newRequest encodes a timestamp request for a SHA-256 digest
+func newRequest(digest []byte, nonce *big.Int, policy asn1.ObjectIdentifier) ([]byte, error) {
+	return asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: cms.OIDDigestSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest,
+		},
+		ReqPolicy: policy,
+		Nonce:     nonce,
+		CertReq:   true,
+	})
+}
+
+// LoadRoots reads the trusted root certificates of timestamp authorities from a PEM file. It
+// returns nil when no file is configured.
+func LoadRoots(rootsFile string) (*x509.CertPool, error) {
+	if rootsFile == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(rootsFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading timestamp roots: %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no certificates found in %s", rootsFile)
+	}
+	return roots, nil
+}
@@ -0,0 +1,127 @@
+package tsa
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Timestamp(t *testing.T) {
+	authority, err := NewLocalTSA()
+	assert.NoError(t, err)
+	server := httptest.NewServer(authority)
+	defer server.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	digest := sha256.Sum256([]byte("completion record"))
+
+	tests := []struct {
+		name          string
+		client        *Client
+		expectedError string
+	}{
+		{name: "Granted", client: NewClient(server.URL)},
+		{name: "Granted with policy", client: NewClient(server.URL).WithPolicy(LocalPolicy)},
+		{name: "Unsupported policy", client: NewClient(server.URL).WithPolicy(asn1.ObjectIdentifier{1, 2, 3}), expectedError: "rejected with status 2: unsupported policy"},
+		{name: "Authority unavailable", client: NewClient(failing.URL), expectedError: "status 503"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := tt.client.Timestamp(context.Background(), digest[:])
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			token, err := ParseToken(der)
+			assert.NoError(t, err)
+			assert.Equal(t, crypto.SHA256, token.Hash)
+			assert.Equal(t, digest[:], token.HashedMessage)
+			assert.Equal(t, LocalPolicy, token.Policy)
+			assert.Equal(t, time.Second, token.Accuracy)
+			assert.NotNil(t, token.Nonce)
+			assert.WithinDuration(t, time.Now(), token.GenTime, 2*time.Second)
+		})
+	}
+}
+
+func TestLocalTSA_ServeHTTP(t *testing.T) {
+	authority, err := NewLocalTSA()
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not a request"))
+	rr := httptest.NewRecorder()
+	authority.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/timestamp-reply", rr.Header().Get("Content-Type"))
+	_, err = parseResponse(rr.Body.Bytes())
+	assert.ErrorContains(t, err, "malformed timestamp request")
+}
+
+func TestToken_Verify(t *testing.T) {
+	authority, err := NewLocalTSA()
+	assert.NoError(t, err)
+	otherAuthority, err := NewLocalTSA()
+	assert.NoError(t, err)
+
+	digest := sha256.Sum256([]byte("completion record"))
+	der, err := authority.Timestamp(context.Background(), digest[:])
+	assert.NoError(t, err)
+	token, err := ParseToken(der)
+	assert.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Root())
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherAuthority.Root())
+	otherDigest := sha256.Sum256([]byte("other record"))
+
+	// Flipping a byte of the signature invalidates the token
+	tampered := append([]byte(nil), der...)
+	signature := token.signedData.Signature()
+	offset := strings.Index(string(tampered), string(signature))
+	tampered[offset+len(signature)/2] ^= 0xff
+	tamperedToken, err := ParseToken(tampered)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		token     *Token
+		digest    []byte
+		roots     *x509.CertPool
+		wantError bool
+	}{
+		{name: "Valid", token: token, digest: digest[:]},
+		{name: "Trusted authority", token: token, digest: digest[:], roots: roots},
+		{name: "Untrusted authority", token: token, digest: digest[:], roots: otherRoots, wantError: true},
+		{name: "Other digest", token: token, digest: otherDigest[:], wantError: true},
+		{name: "Tampered signature", token: tamperedToken, digest: digest[:], wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.token.Verify(crypto.SHA256, tt.digest, tt.roots)
+			if tt.wantError {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}